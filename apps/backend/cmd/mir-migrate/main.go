@@ -0,0 +1,48 @@
+// Command mir-migrate upgrades every stored project MIR document to the
+// current contract version and prints a JSON report of the steps that ran.
+//
+//	go run ./cmd/mir-migrate -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	backendconfig "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/config"
+	backendproject "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/modules/project"
+	backenddatabase "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the migrations without writing them")
+	flag.Parse()
+
+	cfg := backendconfig.LoadConfig()
+	db, err := backenddatabase.OpenDatabase(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("close database: %v", closeErr)
+		}
+	}()
+
+	report, err := backendproject.NewProjectStore(db).MigrateStoredMIR(context.Background(), *dryRun)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(report); encodeErr != nil {
+			log.Printf("encode report: %v", encodeErr)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"database/sql"
+	"log"
 	"time"
 
 	backendconfig "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/config"
@@ -43,6 +44,11 @@ func NewRuntimeModules(db *sql.DB, tokenTTL time.Duration, cfg backendconfig.Con
 	modules.GitHub.Store = backendgithub.NewStore(db)
 	modules.Workspace.Store = backendworkspace.NewWorkspaceStore(db)
 	modules.Workspace.Module = backendworkspace.NewModule(modules.Workspace.Store, modules.Project.Store)
+	if flags, err := backendworkspace.ParseCapabilityFlags(cfg.WorkspaceCapabilityFlags); err != nil {
+		log.Printf("[workspace] ignoring WORKSPACE_CAPABILITY_FLAGS: %v", err)
+	} else {
+		modules.Workspace.Module.SetCapabilityFlags(flags)
+	}
 	modules.Workspace.Handler = backendworkspace.NewHandler(modules.Workspace.Store, modules.Workspace.Module)
 	modules.Project.Handler = backendproject.NewHandler(modules.Project.Store, modules.Workspace.Module)
 	modules.GitHub.Handler = backendgithub.NewHandler(modules.GitHub.Store, modules.Project.Store, cfg.GitHub, cfg.Environment)
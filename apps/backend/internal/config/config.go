@@ -17,6 +17,9 @@ type Config struct {
 	DBMaxIdleConns int
 	DBMaxLifetime  time.Duration
 	GitHub         GitHubAppConfig
+	// WorkspaceCapabilityFlags switches workspace intents on or off, e.g.
+	// "core.nodegraph=on,core.history.restore=off".
+	WorkspaceCapabilityFlags string
 }
 
 type GitHubAppConfig struct {
@@ -54,6 +57,7 @@ func LoadConfig() Config {
 			WebhookSecret: getEnv("GITHUB_APP_WEBHOOK_SECRET", ""),
 			SetupURL:      getEnv("GITHUB_APP_SETUP_URL", ""),
 		},
+		WorkspaceCapabilityFlags: getEnv("WORKSPACE_CAPABILITY_FLAGS", ""),
 	}
 }
 
@@ -113,11 +113,16 @@ func (handler *Handler) HandleGetProject(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "API-5001", "Could not load project.")
 		return
 	}
-	if _, err := normalizeMIR(project.MIR); err != nil {
+	migration, err := UpgradeMIR(project)
+	if err != nil {
 		respondError(c, http.StatusUnprocessableEntity, "MIR-4001", mircontract.LegacyDocumentOpenMessage)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"project": project})
+	response := gin.H{"project": project}
+	if migration.Migrated() {
+		response["mirMigration"] = migration
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (handler *Handler) HandleUpdateProject(c *gin.Context) {
@@ -166,11 +171,16 @@ func (handler *Handler) HandleGetProjectMIR(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "API-5001", "Could not load project.")
 		return
 	}
-	if _, err := normalizeMIR(project.MIR); err != nil {
+	migration, err := UpgradeMIR(project)
+	if err != nil {
 		respondError(c, http.StatusUnprocessableEntity, "MIR-4001", mircontract.LegacyDocumentOpenMessage)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"id": project.ID, "mir": project.MIR, "updatedAt": project.UpdatedAt})
+	response := gin.H{"id": project.ID, "mir": project.MIR, "updatedAt": project.UpdatedAt}
+	if migration.Migrated() {
+		response["mirMigration"] = migration
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (handler *Handler) HandlePublishProject(c *gin.Context) {
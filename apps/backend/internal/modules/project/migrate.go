@@ -0,0 +1,100 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/mircontract"
+)
+
+// MIRMigrationOutcome records what happened to one stored project during a
+// batch MIR migration. Error is set when the document could not be upgraded;
+// such projects are left untouched. Skipped is set when the project was saved
+// between the scan and the write, so the upgrade was not written over it.
+type MIRMigrationOutcome struct {
+	ProjectID string                      `json:"projectId"`
+	Result    mircontract.MigrationResult `json:"result"`
+	Error     string                      `json:"error,omitempty"`
+	Skipped   bool                        `json:"skipped,omitempty"`
+}
+
+type MIRMigrationReport struct {
+	DryRun   bool                  `json:"dryRun"`
+	Scanned  int                   `json:"scanned"`
+	Migrated int                   `json:"migrated"`
+	Failed   int                   `json:"failed"`
+	Skipped  int                   `json:"skipped"`
+	Outcomes []MIRMigrationOutcome `json:"outcomes"`
+}
+
+// MigrateStoredMIR upgrades every stored project MIR that is not on the
+// current contract version. With dryRun set it only reports what would run.
+// updated_at is left alone: the upgrade is a format change, not an edit.
+// Each write only applies while mir_json still holds the scanned document;
+// a project saved in the meantime is counted as skipped.
+func (store *ProjectStore) MigrateStoredMIR(ctx context.Context, dryRun bool) (*MIRMigrationReport, error) {
+	const selectQuery = `SELECT id, mir_json
+FROM projects
+WHERE mir_json->>'version' IS DISTINCT FROM $1
+ORDER BY id`
+
+	rows, err := store.db.QueryContext(ctx, selectQuery, mircontract.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	type storedMIR struct {
+		projectID string
+		mir       json.RawMessage
+	}
+	pending := make([]storedMIR, 0)
+	for rows.Next() {
+		var item storedMIR
+		var mirBytes []byte
+		if err := rows.Scan(&item.projectID, &mirBytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.mir = json.RawMessage(mirBytes)
+		pending = append(pending, item)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	const updateQuery = `UPDATE projects
+SET mir_json = $2::jsonb
+WHERE id = $1 AND mir_json = $3::jsonb`
+
+	report := &MIRMigrationReport{DryRun: dryRun, Scanned: len(pending), Outcomes: make([]MIRMigrationOutcome, 0, len(pending))}
+	for _, item := range pending {
+		migrated, result, err := normalizeMIR(item.mir)
+		outcome := MIRMigrationOutcome{ProjectID: item.projectID, Result: result}
+		if err != nil {
+			outcome.Error = err.Error()
+			report.Failed++
+			report.Outcomes = append(report.Outcomes, outcome)
+			continue
+		}
+		if !dryRun {
+			updated, err := store.db.ExecContext(ctx, updateQuery, item.projectID, string(migrated), string(item.mir))
+			if err != nil {
+				return report, err
+			}
+			affected, err := updated.RowsAffected()
+			if err != nil {
+				return report, err
+			}
+			if affected == 0 {
+				outcome.Skipped = true
+				report.Skipped++
+				report.Outcomes = append(report.Outcomes, outcome)
+				continue
+			}
+		}
+		report.Migrated++
+		report.Outcomes = append(report.Outcomes, outcome)
+	}
+	return report, nil
+}
@@ -37,7 +37,7 @@ func (store *ProjectStore) Create(ownerID, name, description string, resourceTyp
 		return nil, ErrInvalidResourceType
 	}
 
-	normalizedMir, err := normalizeMIR(mir)
+	normalizedMir, _, err := normalizeMIR(mir)
 	if err != nil {
 		return nil, err
 	}
@@ -137,7 +137,7 @@ WHERE owner_id = $1 AND id = $2`
 }
 
 func (store *ProjectStore) SaveMIR(ownerID, projectID string, mir json.RawMessage) (*Project, error) {
-	normalizedMir, err := normalizeMIR(mir)
+	normalizedMir, _, err := normalizeMIR(mir)
 	if err != nil {
 		return nil, err
 	}
@@ -392,36 +392,51 @@ func ParsePositiveInt(value string, fallback int) int {
 	return parsed
 }
 
-func normalizeMIR(mir json.RawMessage) (json.RawMessage, error) {
+// normalizeMIR upgrades a legacy MIR document to the current contract version
+// and checks the resulting v1.3 shape. The returned result lists the
+// migration steps that ran, if any.
+func normalizeMIR(mir json.RawMessage) (json.RawMessage, mircontract.MigrationResult, error) {
 	if len(mir) == 0 || strings.TrimSpace(string(mir)) == "" {
-		return defaultMIRDocument, nil
+		return defaultMIRDocument, mircontract.MigrationResult{FromVersion: mircontract.CurrentVersion, ToVersion: mircontract.CurrentVersion, Steps: []string{}}, nil
 	}
-	var payload map[string]any
-	if err := json.Unmarshal(mir, &payload); err != nil {
-		return nil, err
+	migrated, result, err := mircontract.Migrate(mir)
+	if err != nil {
+		return nil, result, err
 	}
-	if payload["version"] != mircontract.CurrentVersion {
-		return nil, errors.New("MIR document version must be " + mircontract.CurrentVersion)
+	var payload map[string]any
+	if err := json.Unmarshal(migrated, &payload); err != nil {
+		return nil, result, err
 	}
 	ui, ok := payload["ui"].(map[string]any)
 	if !ok {
-		return nil, errors.New("MIR document ui.graph is required")
+		return nil, result, errors.New("MIR document ui.graph is required")
 	}
 	if _, hasRoot := ui["root"]; hasRoot {
-		return nil, errors.New("MIR " + mircontract.CurrentLabel + " must not contain ui.root")
+		return nil, result, errors.New("MIR " + mircontract.CurrentLabel + " must not contain ui.root")
 	}
 	graph, ok := ui["graph"].(map[string]any)
 	if !ok {
-		return nil, errors.New("MIR document ui.graph is required")
+		return nil, result, errors.New("MIR document ui.graph is required")
 	}
 	if _, ok := graph["nodesById"].(map[string]any); !ok {
-		return nil, errors.New("MIR document ui.graph.nodesById is required")
+		return nil, result, errors.New("MIR document ui.graph.nodesById is required")
 	}
 	normalized, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return nil, result, err
+	}
+	return normalized, result, nil
+}
+
+// UpgradeMIR replaces project.MIR with its current-version form. It does not
+// persist the upgrade; MigrateStoredMIR does that in bulk.
+func UpgradeMIR(project *Project) (mircontract.MigrationResult, error) {
+	normalized, result, err := normalizeMIR(project.MIR)
+	if err != nil {
+		return result, err
 	}
-	return normalized, nil
+	project.MIR = normalized
+	return result, nil
 }
 
 func normalizeResourceType(resourceType ResourceType) ResourceType {
@@ -0,0 +1,106 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var ErrWorkspaceAccessDenied = errors.New("workspace access denied")
+
+type WorkspaceRole string
+
+const (
+	WorkspaceRoleNone  WorkspaceRole = ""
+	WorkspaceRoleOwner WorkspaceRole = "owner"
+)
+
+type WorkspaceAction string
+
+const (
+	WorkspaceActionRead  WorkspaceAction = "read"
+	WorkspaceActionWrite WorkspaceAction = "write"
+)
+
+func (role WorkspaceRole) Allows(action WorkspaceAction) bool {
+	switch role {
+	case WorkspaceRoleOwner:
+		return action == WorkspaceActionRead || action == WorkspaceActionWrite
+	default:
+		return false
+	}
+}
+
+// WorkspaceAccessPolicy resolves the role a user holds on a workspace. It
+// must return ErrWorkspaceNotFound when the workspace does not exist so the
+// module can fall back to bootstrapping legacy projects.
+type WorkspaceAccessPolicy interface {
+	ResolveRole(ctx context.Context, userID string, workspaceID string) (WorkspaceRole, error)
+}
+
+type ownerWorkspaceAccessPolicy struct {
+	store *WorkspaceStore
+}
+
+func NewOwnerWorkspaceAccessPolicy(store *WorkspaceStore) WorkspaceAccessPolicy {
+	return ownerWorkspaceAccessPolicy{store: store}
+}
+
+func (policy ownerWorkspaceAccessPolicy) ResolveRole(ctx context.Context, userID string, workspaceID string) (WorkspaceRole, error) {
+	ownerID, err := policy.store.GetWorkspaceOwner(ctx, workspaceID)
+	if err != nil {
+		return WorkspaceRoleNone, err
+	}
+	if strings.TrimSpace(userID) != "" && ownerID == strings.TrimSpace(userID) {
+		return WorkspaceRoleOwner, nil
+	}
+	return WorkspaceRoleNone, nil
+}
+
+func (store *WorkspaceStore) GetWorkspaceOwner(ctx context.Context, workspaceID string) (string, error) {
+	if store == nil || store.db == nil {
+		return "", errors.New("workspace store is not initialized")
+	}
+	workspaceID = strings.TrimSpace(workspaceID)
+	if workspaceID == "" {
+		return "", ErrWorkspaceNotFound
+	}
+
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT owner_id FROM workspaces WHERE id = $1`
+	var ownerID string
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID).Scan(&ownerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrWorkspaceNotFound
+		}
+		return "", err
+	}
+	return ownerID, nil
+}
+
+// AuthorizeWorkspace checks that userID may perform action on an existing
+// workspace. It does not bootstrap missing workspaces; reads go through
+// GetSnapshotForUser for that.
+func (module *Module) AuthorizeWorkspace(ctx context.Context, userID string, workspaceID string, action WorkspaceAction) error {
+	if module == nil || module.store == nil {
+		return errors.New("workspace module is not initialized")
+	}
+	role, err := module.accessPolicy().ResolveRole(ctx, strings.TrimSpace(userID), strings.TrimSpace(workspaceID))
+	if err != nil {
+		return err
+	}
+	if !role.Allows(action) {
+		return ErrWorkspaceAccessDenied
+	}
+	return nil
+}
+
+func (module *Module) accessPolicy() WorkspaceAccessPolicy {
+	if module.access != nil {
+		return module.access
+	}
+	return NewOwnerWorkspaceAccessPolicy(module.store)
+}
@@ -0,0 +1,665 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	animationNamespace = "core.animation"
+	animationDomain    = "animation"
+)
+
+const (
+	DiagnosticAnimationDurationInvalid   = "ANI-1001"
+	DiagnosticAnimationTimelineDuplicate = "ANI-1002"
+	DiagnosticAnimationShapeInvalid      = "ANI-1003"
+	DiagnosticAnimationTimelineNotFound  = "ANI-1004"
+	DiagnosticAnimationIterationsInvalid = "ANI-1010"
+	DiagnosticAnimationTargetNotFound    = "ANI-2001"
+	DiagnosticAnimationBindingDuplicate  = "ANI-2002"
+	DiagnosticAnimationBindingNotFound   = "ANI-2003"
+	DiagnosticAnimationPrimitiveNotFound = "ANI-3002"
+	DiagnosticAnimationTrackInvalid      = "ANI-3003"
+	DiagnosticAnimationTrackDuplicate    = "ANI-3004"
+	DiagnosticAnimationTrackNotFound     = "ANI-3005"
+	DiagnosticAnimationFilterUnit        = "ANI-3010"
+	DiagnosticAnimationKeyframeOrder     = "ANI-4001"
+	DiagnosticAnimationKeyframeRange     = "ANI-4002"
+	DiagnosticAnimationEasingInvalid     = "ANI-4003"
+	DiagnosticAnimationKeyframeNotFound  = "ANI-4004"
+	DiagnosticAnimationKeyframeExists    = "ANI-4005"
+	DiagnosticAnimationValueType         = "ANI-4010"
+)
+
+// The enums mirror AnimationDefinition in the web engine types; the easing
+// list is what the preview sampler can evaluate.
+var (
+	animationStyleProperties = map[string]bool{
+		"opacity": true, "transform.translateX": true, "transform.translateY": true, "transform.scale": true, "color": true,
+	}
+	animationFilterFunctions = map[string]bool{
+		"blur": true, "brightness": true, "contrast": true, "grayscale": true,
+		"hue-rotate": true, "invert": true, "saturate": true, "sepia": true,
+	}
+	animationFilterUnits = map[string]bool{"px": true, "%": true, "deg": true}
+	animationDirections  = map[string]bool{"normal": true, "reverse": true, "alternate": true, "alternate-reverse": true}
+	animationFillModes   = map[string]bool{"none": true, "forwards": true, "backwards": true, "both": true}
+	animationEasings     = map[string]bool{"linear": true, "ease": true, "ease-in": true, "ease-out": true, "ease-in-out": true}
+	cubicBezierPattern   = regexp.MustCompile(`^cubic-bezier\(\s*(-?\d*\.?\d+)\s*,\s*(-?\d*\.?\d+)\s*,\s*(-?\d*\.?\d+)\s*,\s*(-?\d*\.?\d+)\s*\)$`)
+)
+
+func isAnimationEasing(easing string) bool {
+	easing = strings.TrimSpace(easing)
+	return animationEasings[easing] || cubicBezierPattern.MatchString(easing)
+}
+
+func isFiniteNumber(raw any) (float64, bool) {
+	value, ok := raw.(float64)
+	return value, ok && !math.IsInf(value, 0) && !math.IsNaN(value)
+}
+
+func indexSegment(index int) string {
+	return strconv.Itoa(index)
+}
+
+// validateAnimationDocument checks a mir-animation document: timeline,
+// binding and track ids are unique, tracks use supported properties and
+// filters, and keyframes are strictly ascending within the timeline
+// duration. Binding targets live in other documents and are checked by
+// validateAnimationTargets.
+func validateAnimationDocument(payload json.RawMessage) error {
+	var document map[string]any
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return err
+	}
+	if document == nil {
+		return documentValidationError(animationDomain, DiagnosticAnimationShapeInvalid, "", "animation document must be an object")
+	}
+	diagnostics := diagnosticReport{domain: animationDomain}
+	report := diagnostics.add
+	if version, ok := document["version"].(float64); !ok || version != 1 {
+		report(DiagnosticAnimationShapeInvalid, "/version", "animation document version must be 1")
+	}
+
+	primitives := make(map[string]map[string]bool)
+	if raw, exists := document["svgFilters"]; exists {
+		filters, ok := raw.([]any)
+		if !ok {
+			report(DiagnosticAnimationShapeInvalid, "/svgFilters", "svgFilters must be an array")
+		}
+		for _, rawFilter := range filters {
+			filter, _ := rawFilter.(map[string]any)
+			filterID, _ := filter["id"].(string)
+			ids := make(map[string]bool)
+			entries, _ := filter["primitives"].([]any)
+			for _, rawPrimitive := range entries {
+				primitive, _ := rawPrimitive.(map[string]any)
+				if primitiveID, _ := primitive["id"].(string); primitiveID != "" {
+					ids[primitiveID] = true
+				}
+			}
+			primitives[filterID] = ids
+		}
+	}
+
+	timelines, ok := document["timelines"].([]any)
+	if !ok {
+		report(DiagnosticAnimationShapeInvalid, "/timelines", "timelines must be an array")
+	}
+	timelineIDs := make(map[string]bool)
+	for timelineIndex, rawTimeline := range timelines {
+		path := jsonPointerPath("timelines", indexSegment(timelineIndex))
+		timeline, ok := rawTimeline.(map[string]any)
+		if !ok {
+			report(DiagnosticAnimationShapeInvalid, path, "timeline must be an object")
+			continue
+		}
+		timelineID, _ := timeline["id"].(string)
+		if strings.TrimSpace(timelineID) == "" {
+			report(DiagnosticAnimationShapeInvalid, path+"/id", "timeline id is required")
+		} else if timelineIDs[timelineID] {
+			report(DiagnosticAnimationTimelineDuplicate, path+"/id", fmt.Sprintf("timeline id %q is already used", timelineID))
+		}
+		timelineIDs[timelineID] = true
+		duration, durationOK := isFiniteNumber(timeline["durationMs"])
+		if !durationOK || duration <= 0 {
+			report(DiagnosticAnimationDurationInvalid, path+"/durationMs", "timeline durationMs must be greater than 0")
+			durationOK = false
+		}
+		if raw, exists := timeline["delayMs"]; exists {
+			if delay, ok := isFiniteNumber(raw); !ok || delay < 0 {
+				report(DiagnosticAnimationShapeInvalid, path+"/delayMs", "timeline delayMs must not be negative")
+			}
+		}
+		if raw, exists := timeline["iterations"]; exists {
+			count, isNumber := isFiniteNumber(raw)
+			if raw != "infinite" && (!isNumber || count <= 0) {
+				report(DiagnosticAnimationIterationsInvalid, path+"/iterations", `timeline iterations must be a positive number or "infinite"`)
+			}
+		}
+		if raw, exists := timeline["direction"]; exists {
+			if direction, _ := raw.(string); !animationDirections[direction] {
+				report(DiagnosticAnimationShapeInvalid, path+"/direction", fmt.Sprintf("timeline direction %v is not supported", raw))
+			}
+		}
+		if raw, exists := timeline["fillMode"]; exists {
+			if fillMode, _ := raw.(string); !animationFillModes[fillMode] {
+				report(DiagnosticAnimationShapeInvalid, path+"/fillMode", fmt.Sprintf("timeline fillMode %v is not supported", raw))
+			}
+		}
+		validateAnimationEasing(report, timeline, path)
+
+		bindings, ok := timeline["bindings"].([]any)
+		if !ok {
+			report(DiagnosticAnimationShapeInvalid, path+"/bindings", "timeline bindings must be an array")
+		}
+		bindingIDs := make(map[string]bool)
+		trackIDs := make(map[string]bool)
+		for bindingIndex, rawBinding := range bindings {
+			bindingPath := path + jsonPointerPath("bindings", indexSegment(bindingIndex))
+			binding, ok := rawBinding.(map[string]any)
+			if !ok {
+				report(DiagnosticAnimationShapeInvalid, bindingPath, "binding must be an object")
+				continue
+			}
+			bindingID, _ := binding["id"].(string)
+			if strings.TrimSpace(bindingID) == "" {
+				report(DiagnosticAnimationShapeInvalid, bindingPath+"/id", "binding id is required")
+			} else if bindingIDs[bindingID] {
+				report(DiagnosticAnimationBindingDuplicate, bindingPath+"/id", fmt.Sprintf("binding id %q is already used in this timeline", bindingID))
+			}
+			bindingIDs[bindingID] = true
+			if targetNodeID, _ := binding["targetNodeId"].(string); strings.TrimSpace(targetNodeID) == "" {
+				report(DiagnosticAnimationShapeInvalid, bindingPath+"/targetNodeId", "binding targetNodeId is required")
+			}
+			tracks, ok := binding["tracks"].([]any)
+			if !ok {
+				report(DiagnosticAnimationShapeInvalid, bindingPath+"/tracks", "binding tracks must be an array")
+			}
+			for trackIndex, rawTrack := range tracks {
+				trackPath := bindingPath + jsonPointerPath("tracks", indexSegment(trackIndex))
+				track, ok := rawTrack.(map[string]any)
+				if !ok {
+					report(DiagnosticAnimationShapeInvalid, trackPath, "track must be an object")
+					continue
+				}
+				trackID, _ := track["id"].(string)
+				if strings.TrimSpace(trackID) == "" {
+					report(DiagnosticAnimationShapeInvalid, trackPath+"/id", "track id is required")
+				} else if trackIDs[trackID] {
+					report(DiagnosticAnimationTrackDuplicate, trackPath+"/id", fmt.Sprintf("track id %q is already used in this timeline", trackID))
+				}
+				trackIDs[trackID] = true
+				validateAnimationTrack(report, track, trackPath, primitives)
+				validateAnimationKeyframes(report, track, trackPath, duration, durationOK)
+			}
+		}
+	}
+	return diagnostics.err()
+}
+
+func validateAnimationEasing(report func(code, path, message string), object map[string]any, path string) {
+	raw, exists := object["easing"]
+	if !exists {
+		return
+	}
+	if easing, _ := raw.(string); !isAnimationEasing(easing) {
+		report(DiagnosticAnimationEasingInvalid, path+"/easing", fmt.Sprintf("easing %v is not supported", raw))
+	}
+}
+
+func validateAnimationTrack(report func(code, path, message string), track map[string]any, path string, primitives map[string]map[string]bool) {
+	switch kind, _ := track["kind"].(string); kind {
+	case "style":
+		if property, _ := track["property"].(string); !animationStyleProperties[property] {
+			report(DiagnosticAnimationTrackInvalid, path+"/property", fmt.Sprintf("style property %v is not supported", track["property"]))
+		}
+	case "css-filter":
+		if fn, _ := track["fn"].(string); !animationFilterFunctions[fn] {
+			report(DiagnosticAnimationTrackInvalid, path+"/fn", fmt.Sprintf("css filter %v is not supported", track["fn"]))
+		}
+		if raw, exists := track["unit"]; exists {
+			if unit, _ := raw.(string); !animationFilterUnits[unit] {
+				report(DiagnosticAnimationFilterUnit, path+"/unit", fmt.Sprintf("css filter unit %v is not supported", raw))
+			}
+		}
+	case "svg-filter-attr":
+		filterID, _ := track["filterId"].(string)
+		primitiveID, _ := track["primitiveId"].(string)
+		attr, _ := track["attr"].(string)
+		if filterID == "" || primitiveID == "" || strings.TrimSpace(attr) == "" {
+			report(DiagnosticAnimationTrackInvalid, path, "svg-filter-attr track requires filterId, primitiveId and attr")
+			return
+		}
+		if !primitives[filterID][primitiveID] {
+			report(DiagnosticAnimationPrimitiveNotFound, path+"/primitiveId", fmt.Sprintf("svg filter %q has no primitive %q", filterID, primitiveID))
+		}
+	default:
+		report(DiagnosticAnimationTrackInvalid, path+"/kind", fmt.Sprintf("track kind %v is not supported", track["kind"]))
+	}
+}
+
+func validateAnimationKeyframes(report func(code, path, message string), track map[string]any, path string, duration float64, durationOK bool) {
+	keyframes, ok := track["keyframes"].([]any)
+	if !ok {
+		report(DiagnosticAnimationShapeInvalid, path+"/keyframes", "track keyframes must be an array")
+		return
+	}
+	previous := math.Inf(-1)
+	for index, rawKeyframe := range keyframes {
+		keyframePath := path + jsonPointerPath("keyframes", indexSegment(index))
+		keyframe, ok := rawKeyframe.(map[string]any)
+		if !ok {
+			report(DiagnosticAnimationShapeInvalid, keyframePath, "keyframe must be an object")
+			continue
+		}
+		if atMs, ok := isFiniteNumber(keyframe["atMs"]); !ok {
+			report(DiagnosticAnimationShapeInvalid, keyframePath+"/atMs", "keyframe atMs must be a number")
+		} else {
+			if atMs < 0 || (durationOK && atMs > duration) {
+				report(DiagnosticAnimationKeyframeRange, keyframePath+"/atMs", "keyframe atMs must be within the timeline duration")
+			}
+			if atMs <= previous {
+				report(DiagnosticAnimationKeyframeOrder, keyframePath+"/atMs", "keyframes must be strictly ascending by atMs")
+			}
+			previous = atMs
+		}
+		switch keyframe["value"].(type) {
+		case float64, string:
+		default:
+			report(DiagnosticAnimationValueType, keyframePath+"/value", "keyframe value must be a number or a string")
+		}
+		validateAnimationEasing(report, keyframe, keyframePath)
+		if raw, exists := keyframe["hold"]; exists {
+			if _, ok := raw.(bool); !ok {
+				report(DiagnosticAnimationShapeInvalid, keyframePath+"/hold", "keyframe hold must be a boolean")
+			}
+		}
+	}
+}
+
+type animationTarget struct {
+	path     string
+	targetID string
+}
+
+func animationTargets(payload json.RawMessage) []animationTarget {
+	var document struct {
+		Timelines []struct {
+			Bindings []struct {
+				TargetNodeID string `json:"targetNodeId"`
+			} `json:"bindings"`
+		} `json:"timelines"`
+	}
+	if json.Unmarshal(payload, &document) != nil {
+		return nil
+	}
+	targets := make([]animationTarget, 0)
+	for timelineIndex, timeline := range document.Timelines {
+		for bindingIndex, binding := range timeline.Bindings {
+			targets = append(targets, animationTarget{
+				path:     jsonPointerPath("timelines", indexSegment(timelineIndex), "bindings", indexSegment(bindingIndex), "targetNodeId"),
+				targetID: binding.TargetNodeID,
+			})
+		}
+	}
+	return targets
+}
+
+// validateAnimationTargets resolves binding targets introduced by a content
+// change against the MIR nodes of the workspace's page, layout and component
+// documents. Targets that were already bound are not re-checked, so removing
+// a node elsewhere does not block unrelated animation edits.
+func validateAnimationTargets(ctx context.Context, conn workspaceQuerier, workspaceID string, previous, next json.RawMessage) error {
+	bound := make(map[string]bool)
+	for _, target := range animationTargets(previous) {
+		bound[target.targetID] = true
+	}
+	introduced := make([]animationTarget, 0)
+	for _, target := range animationTargets(next) {
+		if target.targetID != "" && !bound[target.targetID] {
+			introduced = append(introduced, target)
+		}
+	}
+	if len(introduced) == 0 {
+		return nil
+	}
+
+	const query = `SELECT content_json
+FROM workspace_documents
+WHERE workspace_id = $1 AND doc_type IN ('mir-page', 'mir-layout', 'mir-component')`
+	rows, err := conn.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	nodeIDs := make(map[string]bool)
+	for rows.Next() {
+		var content []byte
+		if err := rows.Scan(&content); err != nil {
+			return err
+		}
+		var document struct {
+			UI struct {
+				Graph struct {
+					NodesByID map[string]json.RawMessage `json:"nodesById"`
+				} `json:"graph"`
+			} `json:"ui"`
+		}
+		if json.Unmarshal(content, &document) != nil {
+			continue
+		}
+		for nodeID := range document.UI.Graph.NodesByID {
+			nodeIDs[nodeID] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	diagnostics := diagnosticReport{domain: animationDomain}
+	for _, target := range introduced {
+		if !nodeIDs[target.targetID] {
+			diagnostics.add(DiagnosticAnimationTargetNotFound, target.path, fmt.Sprintf("MIR node %q does not exist in this workspace", target.targetID))
+		}
+	}
+	return diagnostics.err()
+}
+
+// animationDocument is a view over the timelines of a decoded mir-animation
+// document. Timelines, bindings and tracks are maps shared with the
+// document, so edits through the view write through.
+type animationDocument struct {
+	timelines []any
+}
+
+func openAnimationDocument(documentType WorkspaceDocumentType, document map[string]any) (animationDocument, error) {
+	if documentType != WorkspaceDocumentTypeMIRAnimation {
+		return animationDocument{}, ErrInvalidWorkspaceDocumentType
+	}
+	raw, exists := document["timelines"]
+	if !exists {
+		raw = make([]any, 0)
+		document["timelines"] = raw
+	}
+	timelines, ok := raw.([]any)
+	if !ok {
+		return animationDocument{}, documentValidationError(animationDomain, DiagnosticAnimationShapeInvalid, "/timelines", "timelines must be an array")
+	}
+	return animationDocument{timelines: timelines}, nil
+}
+
+func (animation animationDocument) timeline(timelineID string) (map[string]any, error) {
+	for _, raw := range animation.timelines {
+		if timeline, ok := raw.(map[string]any); ok && timeline["id"] == timelineID {
+			return timeline, nil
+		}
+	}
+	return nil, documentValidationError(animationDomain, DiagnosticAnimationTimelineNotFound, "/timelines", fmt.Sprintf("timeline %q does not exist", timelineID))
+}
+
+func (animation animationDocument) track(timelineID, trackID string) (map[string]any, error) {
+	timeline, err := animation.timeline(timelineID)
+	if err != nil {
+		return nil, err
+	}
+	bindings, _ := timeline["bindings"].([]any)
+	for _, rawBinding := range bindings {
+		binding, _ := rawBinding.(map[string]any)
+		tracks, _ := binding["tracks"].([]any)
+		for _, rawTrack := range tracks {
+			if track, ok := rawTrack.(map[string]any); ok && track["id"] == trackID {
+				return track, nil
+			}
+		}
+	}
+	return nil, documentValidationError(animationDomain, DiagnosticAnimationTrackNotFound, "/timelines", fmt.Sprintf("track %q does not exist in timeline %q", trackID, timelineID))
+}
+
+// keyframeIndex returns the position of the keyframe at atMs, or -1.
+func keyframeIndex(keyframes []any, atMs float64) int {
+	for index, raw := range keyframes {
+		if keyframe, ok := raw.(map[string]any); ok && keyframe["atMs"] == atMs {
+			return index
+		}
+	}
+	return -1
+}
+
+func sortKeyframes(keyframes []any) {
+	sort.SliceStable(keyframes, func(i, j int) bool {
+		left, _ := keyframes[i].(map[string]any)["atMs"].(float64)
+		right, _ := keyframes[j].(map[string]any)["atMs"].(float64)
+		return left < right
+	})
+}
+
+type AnimationKeyframe struct {
+	AtMs   float64 `json:"atMs"`
+	Value  any     `json:"value"`
+	Easing string  `json:"easing,omitempty"`
+	Hold   bool    `json:"hold,omitempty"`
+}
+
+func (keyframe AnimationKeyframe) object() map[string]any {
+	object := map[string]any{"atMs": keyframe.AtMs, "value": keyframe.Value}
+	if keyframe.Easing != "" {
+		object["easing"] = keyframe.Easing
+	}
+	if keyframe.Hold {
+		object["hold"] = true
+	}
+	return object
+}
+
+type AnimationIntentParams struct {
+	WorkspaceID        string
+	DocumentID         string
+	ExpectedContentRev int64
+	Command            WorkspaceCommandEnvelope
+}
+
+func (store *WorkspaceStore) changeAnimation(ctx context.Context, params AnimationIntentParams, change func(animation animationDocument) (string, error)) (*WorkspaceMutationResult, error) {
+	return store.changeDocumentContent(ctx, ChangeDocumentContentParams(params), func(documentType WorkspaceDocumentType, document map[string]any) (string, error) {
+		animation, err := openAnimationDocument(documentType, document)
+		if err != nil {
+			return "", err
+		}
+		return change(animation)
+	})
+}
+
+// AddAnimationKeyframe inserts keyframe into the track, keeping the
+// keyframes ordered by atMs. A keyframe already at atMs must be updated
+// instead.
+func (store *WorkspaceStore) AddAnimationKeyframe(ctx context.Context, params AnimationIntentParams, timelineID, trackID string, keyframe AnimationKeyframe) (*WorkspaceMutationResult, error) {
+	return store.changeAnimation(ctx, params, func(animation animationDocument) (string, error) {
+		track, err := animation.track(timelineID, trackID)
+		if err != nil {
+			return "", err
+		}
+		keyframes, _ := track["keyframes"].([]any)
+		if keyframeIndex(keyframes, keyframe.AtMs) >= 0 {
+			return "", documentValidationError(animationDomain, DiagnosticAnimationKeyframeExists, "/timelines", fmt.Sprintf("track %q already has a keyframe at %gms", trackID, keyframe.AtMs))
+		}
+		keyframes = append(keyframes, keyframe.object())
+		sortKeyframes(keyframes)
+		track["keyframes"] = keyframes
+		return fmt.Sprintf("Add keyframe at %gms", keyframe.AtMs), nil
+	})
+}
+
+// UpdateAnimationKeyframe replaces the keyframe at atMs; a different
+// keyframe.AtMs moves it along the track.
+func (store *WorkspaceStore) UpdateAnimationKeyframe(ctx context.Context, params AnimationIntentParams, timelineID, trackID string, atMs float64, keyframe AnimationKeyframe) (*WorkspaceMutationResult, error) {
+	return store.changeAnimation(ctx, params, func(animation animationDocument) (string, error) {
+		track, err := animation.track(timelineID, trackID)
+		if err != nil {
+			return "", err
+		}
+		keyframes, _ := track["keyframes"].([]any)
+		index := keyframeIndex(keyframes, atMs)
+		if index < 0 {
+			return "", documentValidationError(animationDomain, DiagnosticAnimationKeyframeNotFound, "/timelines", fmt.Sprintf("track %q has no keyframe at %gms", trackID, atMs))
+		}
+		if keyframe.AtMs != atMs && keyframeIndex(keyframes, keyframe.AtMs) >= 0 {
+			return "", documentValidationError(animationDomain, DiagnosticAnimationKeyframeExists, "/timelines", fmt.Sprintf("track %q already has a keyframe at %gms", trackID, keyframe.AtMs))
+		}
+		keyframes[index] = keyframe.object()
+		sortKeyframes(keyframes)
+		return fmt.Sprintf("Update keyframe at %gms", atMs), nil
+	})
+}
+
+func (store *WorkspaceStore) RemoveAnimationKeyframe(ctx context.Context, params AnimationIntentParams, timelineID, trackID string, atMs float64) (*WorkspaceMutationResult, error) {
+	return store.changeAnimation(ctx, params, func(animation animationDocument) (string, error) {
+		track, err := animation.track(timelineID, trackID)
+		if err != nil {
+			return "", err
+		}
+		keyframes, _ := track["keyframes"].([]any)
+		index := keyframeIndex(keyframes, atMs)
+		if index < 0 {
+			return "", documentValidationError(animationDomain, DiagnosticAnimationKeyframeNotFound, "/timelines", fmt.Sprintf("track %q has no keyframe at %gms", trackID, atMs))
+		}
+		track["keyframes"] = append(keyframes[:index:index], keyframes[index+1:]...)
+		return fmt.Sprintf("Remove keyframe at %gms", atMs), nil
+	})
+}
+
+// BindAnimationClip appends binding to the timeline. The target node is
+// resolved against the workspace's MIR documents when the change is
+// committed.
+func (store *WorkspaceStore) BindAnimationClip(ctx context.Context, params AnimationIntentParams, timelineID string, binding json.RawMessage) (*WorkspaceMutationResult, error) {
+	var object map[string]any
+	if err := json.Unmarshal(binding, &object); err != nil || object == nil {
+		return nil, documentValidationError(animationDomain, DiagnosticAnimationShapeInvalid, "", "binding must be an object")
+	}
+	bindingID, _ := object["id"].(string)
+	if strings.TrimSpace(bindingID) == "" {
+		return nil, documentValidationError(animationDomain, DiagnosticAnimationShapeInvalid, "", "binding id is required")
+	}
+	if _, exists := object["tracks"]; !exists {
+		object["tracks"] = make([]any, 0)
+	}
+	return store.changeAnimation(ctx, params, func(animation animationDocument) (string, error) {
+		timeline, err := animation.timeline(timelineID)
+		if err != nil {
+			return "", err
+		}
+		bindings, _ := timeline["bindings"].([]any)
+		for _, raw := range bindings {
+			if existing, ok := raw.(map[string]any); ok && existing["id"] == bindingID {
+				return "", documentValidationError(animationDomain, DiagnosticAnimationBindingDuplicate, "/timelines", fmt.Sprintf("binding %q already exists in timeline %q", bindingID, timelineID))
+			}
+		}
+		timeline["bindings"] = append(bindings, object)
+		return fmt.Sprintf("Bind %v to timeline %q", object["targetNodeId"], timelineID), nil
+	})
+}
+
+func (store *WorkspaceStore) UnbindAnimationClip(ctx context.Context, params AnimationIntentParams, timelineID, bindingID string) (*WorkspaceMutationResult, error) {
+	return store.changeAnimation(ctx, params, func(animation animationDocument) (string, error) {
+		timeline, err := animation.timeline(timelineID)
+		if err != nil {
+			return "", err
+		}
+		bindings, _ := timeline["bindings"].([]any)
+		for index, raw := range bindings {
+			if existing, ok := raw.(map[string]any); ok && existing["id"] == bindingID {
+				timeline["bindings"] = append(bindings[:index:index], bindings[index+1:]...)
+				return fmt.Sprintf("Unbind %q from timeline %q", bindingID, timelineID), nil
+			}
+		}
+		return "", documentValidationError(animationDomain, DiagnosticAnimationBindingNotFound, "/timelines", fmt.Sprintf("binding %q does not exist in timeline %q", bindingID, timelineID))
+	})
+}
+
+type animationIntentHandler struct{}
+
+func (animationIntentHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{
+		intentV1(animationNamespace, "timeline.keyframe.add", RevPartitionContent),
+		intentV1(animationNamespace, "timeline.keyframe.update", RevPartitionContent),
+		intentV1(animationNamespace, "timeline.keyframe.remove", RevPartitionContent),
+		intentV1(animationNamespace, "clip.bind", RevPartitionContent),
+		intentV1(animationNamespace, "clip.unbind", RevPartitionContent),
+	}
+}
+
+func (animationIntentHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	intent IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID         string             `json:"documentId"`
+		ExpectedContentRev int64              `json:"expectedContentRev"`
+		TimelineID         string             `json:"timelineId"`
+		TrackID            string             `json:"trackId"`
+		AtMs               *float64           `json:"atMs"`
+		Keyframe           *AnimationKeyframe `json:"keyframe"`
+		BindingID          string             `json:"bindingId"`
+		Binding            json.RawMessage    `json:"binding"`
+	}
+	invalid := func(message string) *RequestFailure {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	if len(request.Intent.Payload) == 0 || json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.DocumentID) == "" || payload.ExpectedContentRev <= 0 {
+		return nil, invalid("intent payload.documentId and payload.expectedContentRev are required.")
+	}
+	if strings.TrimSpace(payload.TimelineID) == "" {
+		return nil, invalid("intent payload.timelineId is required.")
+	}
+	params := AnimationIntentParams{
+		WorkspaceID:        workspaceID,
+		DocumentID:         strings.TrimSpace(payload.DocumentID),
+		ExpectedContentRev: payload.ExpectedContentRev,
+		Command:            command,
+	}
+	var result *WorkspaceMutationResult
+	var err error
+	switch intent.Type {
+	case "timeline.keyframe.add":
+		if payload.TrackID == "" || payload.Keyframe == nil {
+			return nil, invalid("intent payload.trackId and payload.keyframe are required.")
+		}
+		result, err = store.AddAnimationKeyframe(ctx, params, payload.TimelineID, payload.TrackID, *payload.Keyframe)
+	case "timeline.keyframe.update":
+		if payload.TrackID == "" || payload.AtMs == nil || payload.Keyframe == nil {
+			return nil, invalid("intent payload.trackId, payload.atMs and payload.keyframe are required.")
+		}
+		result, err = store.UpdateAnimationKeyframe(ctx, params, payload.TimelineID, payload.TrackID, *payload.AtMs, *payload.Keyframe)
+	case "timeline.keyframe.remove":
+		if payload.TrackID == "" || payload.AtMs == nil {
+			return nil, invalid("intent payload.trackId and payload.atMs are required.")
+		}
+		result, err = store.RemoveAnimationKeyframe(ctx, params, payload.TimelineID, payload.TrackID, *payload.AtMs)
+	case "clip.bind":
+		if len(payload.Binding) == 0 {
+			return nil, invalid("intent payload.binding is required.")
+		}
+		result, err = store.BindAnimationClip(ctx, params, payload.TimelineID, payload.Binding)
+	default:
+		if strings.TrimSpace(payload.BindingID) == "" {
+			return nil, invalid("intent payload.bindingId is required.")
+		}
+		result, err = store.UnbindAnimationClip(ctx, params, payload.TimelineID, payload.BindingID)
+	}
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
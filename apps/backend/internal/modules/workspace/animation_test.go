@@ -0,0 +1,188 @@
+package workspace
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	backendresponse "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/http/response"
+)
+
+const animationTestContent = `{"version":1,"timelines":[{"id":"intro","name":"Intro","durationMs":1000,"bindings":[` +
+	`{"id":"b1","targetNodeId":"hero","tracks":[{"id":"fade","kind":"style","property":"opacity","keyframes":[{"atMs":0,"value":0},{"atMs":1000,"value":1}]}]}` +
+	`]}]}`
+
+var animationTestDocument = testDocument{
+	id:         "anim_1",
+	docType:    WorkspaceDocumentTypeMIRAnimation,
+	name:       "Intro",
+	path:       "/intro.animation.json",
+	contentRev: 3,
+	metaRev:    1,
+	content:    animationTestContent,
+}
+
+// animationKeyframesMatcher checks the atMs order of the first track in the
+// stored content.
+type animationKeyframesMatcher struct {
+	t    *testing.T
+	want []float64
+}
+
+func (matcher animationKeyframesMatcher) Match(value driver.Value) bool {
+	raw, _ := value.(string)
+	var document struct {
+		Timelines []struct {
+			Bindings []struct {
+				Tracks []struct {
+					Keyframes []AnimationKeyframe `json:"keyframes"`
+				} `json:"tracks"`
+			} `json:"bindings"`
+		} `json:"timelines"`
+	}
+	if err := json.Unmarshal([]byte(raw), &document); err != nil {
+		matcher.t.Errorf("decode stored content: %v", err)
+		return false
+	}
+	keyframes := document.Timelines[0].Bindings[0].Tracks[0].Keyframes
+	if len(keyframes) != len(matcher.want) {
+		matcher.t.Errorf("unexpected keyframes: %+v", keyframes)
+		return false
+	}
+	for index, atMs := range matcher.want {
+		if keyframes[index].AtMs != atMs {
+			matcher.t.Errorf("unexpected keyframes: %+v", keyframes)
+			return false
+		}
+	}
+	return true
+}
+
+func TestWorkspaceStoreAddAnimationKeyframeKeepsOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	expectDocumentLock(mock, animationTestDocument)
+	expectDocumentContentLock(mock, animationTestDocument, testHead{workspaceRev: 9, routeRev: 4, opSeq: 33})
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", "anim_1", animationKeyframesMatcher{t: t, want: []float64{0, 500, 1000}}).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(4, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(34), "core.animation.timeline.keyframe.add@1.0", "anim_1", sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	params := AnimationIntentParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "anim_1",
+		ExpectedContentRev: 3,
+		Command:            testCommand(animationNamespace, "timeline.keyframe.add", issuedAt),
+	}
+	result, err := NewWorkspaceStore(db).AddAnimationKeyframe(context.Background(), params, "intro", "fade",
+		AnimationKeyframe{AtMs: 500, Value: 0.5, Easing: "ease-out"})
+	if err != nil {
+		t.Fatalf("add keyframe: %v", err)
+	}
+	if result.UpdatedDocuments[0].ContentRev != 4 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreBindAnimationClipRejectsMissingTarget(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	expectDocumentLock(mock, animationTestDocument)
+	expectDocumentContentLock(mock, animationTestDocument, testHead{workspaceRev: 9, routeRev: 4, opSeq: 33})
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT content_json
+FROM workspace_documents
+WHERE workspace_id = $1 AND doc_type IN ('mir-page', 'mir-layout', 'mir-component')`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"content_json"}).
+			AddRow([]byte(`{"version":"1.3","ui":{"graph":{"rootId":"root","nodesById":{"root":{"id":"root","type":"container"},"hero":{"id":"hero","type":"text"}}}}}`)))
+	mock.ExpectRollback()
+
+	params := AnimationIntentParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "anim_1",
+		ExpectedContentRev: 3,
+		Command:            testCommand(animationNamespace, "clip.bind", time.Now()),
+	}
+	_, err = NewWorkspaceStore(db).BindAnimationClip(context.Background(), params, "intro",
+		json.RawMessage(`{"id":"b2","targetNodeId":"ghost"}`))
+	failure := MapStoreError(err)
+	if failure == nil || failure.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %v", err)
+	}
+	payload, _ := failure.Payload["error"].(backendresponse.ErrorPayload)
+	if payload.Code != DiagnosticAnimationTargetNotFound || payload.Domain != "animation" ||
+		len(payload.Diagnostics) != 1 || payload.Diagnostics[0].Path != "/timelines/0/bindings/1/targetNodeId" {
+		t.Fatalf("unexpected failure payload: %+v", payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestValidateAnimationDocumentReportsEveryProblem(t *testing.T) {
+	if err := validateAnimationDocument(defaultMIRAnimationDocument); err != nil {
+		t.Fatalf("default animation should be valid: %v", err)
+	}
+	if err := validateAnimationDocument(json.RawMessage(animationTestContent)); err != nil {
+		t.Fatalf("test animation should be valid: %v", err)
+	}
+	err := validateAnimationDocument(json.RawMessage(`{"version":1,"timelines":[{"id":"t","name":"T","durationMs":100,"easing":"bounce","bindings":[` +
+		`{"id":"b","targetNodeId":"n","tracks":[` +
+		`{"id":"x","kind":"style","property":"width","keyframes":[{"atMs":50,"value":1},{"atMs":20,"value":true},{"atMs":200,"value":2}]},` +
+		`{"id":"x","kind":"css-filter","fn":"blur","unit":"em","keyframes":[]}` +
+		`]}]}]}`))
+	validationErr, ok := err.(*DocumentValidationError)
+	if !ok {
+		t.Fatalf("expected animation validation error, got %v", err)
+	}
+	paths := make([]string, 0, len(validationErr.Diagnostics))
+	for _, diagnostic := range validationErr.Diagnostics {
+		paths = append(paths, diagnostic.Code+" "+diagnostic.Path)
+	}
+	expected := []string{
+		"ANI-4003 /timelines/0/easing",
+		"ANI-3003 /timelines/0/bindings/0/tracks/0/property",
+		"ANI-4001 /timelines/0/bindings/0/tracks/0/keyframes/1/atMs",
+		"ANI-4010 /timelines/0/bindings/0/tracks/0/keyframes/1/value",
+		"ANI-4002 /timelines/0/bindings/0/tracks/0/keyframes/2/atMs",
+		"ANI-3004 /timelines/0/bindings/0/tracks/1/id",
+		"ANI-3010 /timelines/0/bindings/0/tracks/1/unit",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("unexpected diagnostics: %v", paths)
+	}
+	for index := range expected {
+		if paths[index] != expected[index] {
+			t.Fatalf("unexpected diagnostics: %v", paths)
+		}
+	}
+}
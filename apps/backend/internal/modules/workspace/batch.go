@@ -0,0 +1,104 @@
+package workspace
+
+import (
+	backendresponse "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/http/response"
+)
+
+type WorkspaceBatchOperationResult struct {
+	Index            int                         `json:"index"`
+	Op               string                      `json:"op"`
+	WorkspaceRev     int64                       `json:"workspaceRev"`
+	RouteRev         int64                       `json:"routeRev"`
+	SettingsRev      int64                       `json:"settingsRev,omitempty"`
+	OpSeq            int64                       `json:"opSeq"`
+	UpdatedDocuments []WorkspaceDocumentRevision `json:"updatedDocuments,omitempty"`
+	RemovedDocuments []string                    `json:"removedDocuments,omitempty"`
+}
+
+type WorkspaceBatchResult struct {
+	Latest     *WorkspaceMutationResult        `json:"latest"`
+	FromOpSeq  int64                           `json:"fromOpSeq"`
+	ToOpSeq    int64                           `json:"toOpSeq"`
+	Operations []WorkspaceBatchOperationResult `json:"operations"`
+}
+
+func (batch *WorkspaceBatchResult) append(index int, op string, result *WorkspaceMutationResult) {
+	if batch.Latest == nil {
+		batch.FromOpSeq = result.OpSeq
+	}
+	batch.Latest = result
+	batch.ToOpSeq = result.OpSeq
+	batch.Operations = append(batch.Operations, WorkspaceBatchOperationResult{
+		Index:            index,
+		Op:               op,
+		WorkspaceRev:     result.WorkspaceRev,
+		RouteRev:         result.RouteRev,
+		SettingsRev:      result.SettingsRev,
+		OpSeq:            result.OpSeq,
+		UpdatedDocuments: result.UpdatedDocuments,
+		RemovedDocuments: result.RemovedDocuments,
+	})
+}
+
+// committedResult summarizes the batch as one mutation result: the latest
+// revisions plus the final revision of every document the batch touched and
+// still exists, and the documents it removed.
+func (batch *WorkspaceBatchResult) committedResult() *WorkspaceMutationResult {
+	if batch == nil || batch.Latest == nil {
+		return nil
+	}
+	result := *batch.Latest
+	result.UpdatedDocuments = nil
+	result.RemovedDocuments = nil
+	order := make([]string, 0)
+	latest := make(map[string]*WorkspaceDocumentRevision)
+	for _, operation := range batch.Operations {
+		for _, document := range operation.UpdatedDocuments {
+			if _, ok := latest[document.ID]; !ok {
+				order = append(order, document.ID)
+			}
+			revision := document
+			latest[document.ID] = &revision
+		}
+		for _, documentID := range operation.RemovedDocuments {
+			if _, ok := latest[documentID]; !ok {
+				order = append(order, documentID)
+			}
+			latest[documentID] = nil
+		}
+	}
+	for _, documentID := range order {
+		if revision := latest[documentID]; revision != nil {
+			result.UpdatedDocuments = append(result.UpdatedDocuments, *revision)
+		} else {
+			result.RemovedDocuments = append(result.RemovedDocuments, documentID)
+		}
+	}
+	return &result
+}
+
+// withBatchOperationIndex tags a failure with the index of the batch operation
+// that produced it so clients can locate the rejected entry.
+func withBatchOperationIndex(failure *RequestFailure, index int) *RequestFailure {
+	if failure == nil {
+		return nil
+	}
+	errorPayload, ok := failure.Payload["error"].(backendresponse.ErrorPayload)
+	if !ok {
+		return failure
+	}
+	details := map[string]any{}
+	if existing, ok := errorPayload.Details.(map[string]any); ok {
+		for key, value := range existing {
+			details[key] = value
+		}
+	}
+	details["index"] = index
+	errorPayload.Details = details
+	payload := make(map[string]any, len(failure.Payload))
+	for key, value := range failure.Payload {
+		payload[key] = value
+	}
+	payload["error"] = errorPayload
+	return &RequestFailure{Status: failure.Status, Payload: payload}
+}
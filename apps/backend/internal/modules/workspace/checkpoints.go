@@ -0,0 +1,390 @@
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxWorkspaceCheckpointNameLength = 120
+
+var ErrWorkspaceCheckpointNotFound = errors.New("workspace checkpoint not found")
+var ErrWorkspaceCheckpointNameTaken = errors.New("workspace checkpoint name already exists")
+
+// WorkspaceCheckpoint is a named snapshot of the whole workspace: tree,
+// route manifest, settings and every document with its revisions, taken at
+// OpSeq.
+type WorkspaceCheckpoint struct {
+	ID            string    `json:"id"`
+	WorkspaceID   string    `json:"workspaceId"`
+	Name          string    `json:"name"`
+	OpSeq         int64     `json:"opSeq"`
+	WorkspaceRev  int64     `json:"workspaceRev"`
+	RouteRev      int64     `json:"routeRev"`
+	DocumentCount int       `json:"documentCount"`
+	CreatedBy     string    `json:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type WorkspaceCheckpointDocument struct {
+	ID         string                `json:"id"`
+	Type       WorkspaceDocumentType `json:"type"`
+	Name       string                `json:"name"`
+	Path       string                `json:"path"`
+	ContentRev int64                 `json:"contentRev"`
+	MetaRev    int64                 `json:"metaRev"`
+	Content    json.RawMessage       `json:"content"`
+}
+
+type workspaceCheckpointRecord struct {
+	WorkspaceCheckpoint
+	Tree          json.RawMessage
+	RouteManifest json.RawMessage
+	Settings      json.RawMessage
+	Documents     []WorkspaceCheckpointDocument
+}
+
+func (record *workspaceCheckpointRecord) state() workspaceState {
+	state := workspaceState{
+		Tree:          record.Tree,
+		RouteManifest: record.RouteManifest,
+		Settings:      record.Settings,
+		Documents:     make(map[string]workspaceStateDocument, len(record.Documents)),
+	}
+	for _, document := range record.Documents {
+		state.Documents[document.ID] = workspaceStateDocument{Type: document.Type, Name: document.Name, Path: document.Path, Content: document.Content}
+	}
+	return state
+}
+
+// WorkspaceCheckpointDiff compares a checkpoint with the live workspace.
+// Document changes read from the checkpoint to the live state: "added"
+// documents exist only live, "removed" ones only in the checkpoint.
+type WorkspaceCheckpointDiff struct {
+	Checkpoint           WorkspaceCheckpoint                 `json:"checkpoint"`
+	LiveOpSeq            int64                               `json:"liveOpSeq"`
+	LiveWorkspaceRev     int64                               `json:"liveWorkspaceRev"`
+	TreeChanged          bool                                `json:"treeChanged"`
+	RouteManifestChanged bool                                `json:"routeManifestChanged"`
+	SettingsChanged      bool                                `json:"settingsChanged"`
+	Documents            []WorkspaceCheckpointDocumentChange `json:"documents"`
+}
+
+type WorkspaceCheckpointDocumentChange struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	// ContentPaths lists the JSON pointers whose content differs.
+	ContentPaths []string `json:"contentPaths,omitempty"`
+	MetaChanged  bool     `json:"metaChanged,omitempty"`
+}
+
+type CreateWorkspaceCheckpointParams struct {
+	WorkspaceID string
+	Name        string
+	CreatedBy   string
+}
+
+type RestoreWorkspaceCheckpointParams struct {
+	WorkspaceID          string
+	CheckpointID         string
+	ExpectedWorkspaceRev int64
+	Command              WorkspaceCommandEnvelope
+}
+
+func newWorkspaceCheckpointID() string {
+	var bytes [8]byte
+	if _, err := rand.Read(bytes[:]); err != nil {
+		return fmt.Sprintf("ckpt_%d", time.Now().UnixNano())
+	}
+	return "ckpt_" + hex.EncodeToString(bytes[:])
+}
+
+func (store *WorkspaceStore) CreateCheckpoint(ctx context.Context, params CreateWorkspaceCheckpointParams) (*WorkspaceCheckpoint, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > maxWorkspaceCheckpointNameLength {
+		return nil, fmt.Errorf("checkpoint name must be 1-%d characters", maxWorkspaceCheckpointNameLength)
+	}
+
+	var checkpoint *WorkspaceCheckpoint
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		locked, err := txStore.lockWorkspaceState(ctx, params.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		documentIDs := make([]string, 0, len(locked.documents))
+		for documentID := range locked.documents {
+			documentIDs = append(documentIDs, documentID)
+		}
+		if err := txStore.loadWorkspaceStateContent(ctx, locked, documentIDs); err != nil {
+			return err
+		}
+		documents := make([]WorkspaceCheckpointDocument, 0, len(locked.documents))
+		for _, document := range locked.documents {
+			documents = append(documents, WorkspaceCheckpointDocument{
+				ID:         document.ID,
+				Type:       document.Type,
+				Name:       document.Name,
+				Path:       document.Path,
+				ContentRev: document.ContentRev,
+				MetaRev:    document.MetaRev,
+				Content:    document.Content,
+			})
+		}
+		sort.Slice(documents, func(left, right int) bool { return documents[left].ID < documents[right].ID })
+		documentsJSON, err := json.Marshal(documents)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := withStoreTimeout(ctx)
+		defer cancel()
+
+		checkpoint = &WorkspaceCheckpoint{
+			ID:            newWorkspaceCheckpointID(),
+			WorkspaceID:   params.WorkspaceID,
+			Name:          name,
+			OpSeq:         locked.opSeq,
+			WorkspaceRev:  locked.workspaceRev,
+			RouteRev:      locked.routeRev,
+			DocumentCount: len(documents),
+			CreatedBy:     strings.TrimSpace(params.CreatedBy),
+		}
+		const insertCheckpoint = `INSERT INTO workspace_checkpoints (
+	id, workspace_id, name, op_seq, workspace_rev, route_rev, tree_json, route_manifest_json, settings_json, documents_json, created_by, created_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9::jsonb, $10::jsonb, $11, NOW())
+RETURNING created_at`
+		err = txStore.conn().QueryRowContext(
+			ctx,
+			insertCheckpoint,
+			checkpoint.ID,
+			checkpoint.WorkspaceID,
+			checkpoint.Name,
+			checkpoint.OpSeq,
+			checkpoint.WorkspaceRev,
+			checkpoint.RouteRev,
+			string(locked.state.Tree),
+			string(locked.state.RouteManifest),
+			string(locked.state.Settings),
+			string(documentsJSON),
+			checkpoint.CreatedBy,
+		).Scan(&checkpoint.CreatedAt)
+		if isUniqueViolation(err) {
+			return ErrWorkspaceCheckpointNameTaken
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (store *WorkspaceStore) ListCheckpoints(ctx context.Context, workspaceID string) ([]WorkspaceCheckpoint, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT id, workspace_id, name, op_seq, workspace_rev, route_rev, jsonb_array_length(documents_json), created_by, created_at
+FROM workspace_checkpoints
+WHERE workspace_id = $1
+ORDER BY op_seq DESC, created_at DESC`
+	rows, err := store.conn().QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := make([]WorkspaceCheckpoint, 0)
+	for rows.Next() {
+		var checkpoint WorkspaceCheckpoint
+		if err := rows.Scan(
+			&checkpoint.ID,
+			&checkpoint.WorkspaceID,
+			&checkpoint.Name,
+			&checkpoint.OpSeq,
+			&checkpoint.WorkspaceRev,
+			&checkpoint.RouteRev,
+			&checkpoint.DocumentCount,
+			&checkpoint.CreatedBy,
+			&checkpoint.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+func (store *WorkspaceStore) getCheckpoint(ctx context.Context, workspaceID string, checkpointID string) (*workspaceCheckpointRecord, error) {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT id, workspace_id, name, op_seq, workspace_rev, route_rev, tree_json, route_manifest_json, settings_json, documents_json, created_by, created_at
+FROM workspace_checkpoints
+WHERE workspace_id = $1 AND id = $2`
+	record := &workspaceCheckpointRecord{}
+	var treeBytes []byte
+	var routeBytes []byte
+	var settingsBytes []byte
+	var documentsBytes []byte
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID, checkpointID).Scan(
+		&record.ID,
+		&record.WorkspaceID,
+		&record.Name,
+		&record.OpSeq,
+		&record.WorkspaceRev,
+		&record.RouteRev,
+		&treeBytes,
+		&routeBytes,
+		&settingsBytes,
+		&documentsBytes,
+		&record.CreatedBy,
+		&record.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceCheckpointNotFound
+		}
+		return nil, err
+	}
+	record.Tree = json.RawMessage(treeBytes)
+	record.RouteManifest = json.RawMessage(routeBytes)
+	record.Settings = json.RawMessage(settingsBytes)
+	if err := json.Unmarshal(documentsBytes, &record.Documents); err != nil {
+		return nil, err
+	}
+	record.DocumentCount = len(record.Documents)
+	return record, nil
+}
+
+// DiffCheckpoint compares a checkpoint with the current workspace.
+func (store *WorkspaceStore) DiffCheckpoint(ctx context.Context, workspaceID string, checkpointID string) (*WorkspaceCheckpointDiff, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	record, err := store.getCheckpoint(ctx, workspaceID, checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := store.GetSnapshot(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &WorkspaceCheckpointDiff{
+		Checkpoint:           record.WorkspaceCheckpoint,
+		LiveOpSeq:            snapshot.Workspace.OpSeq,
+		LiveWorkspaceRev:     snapshot.Workspace.WorkspaceRev,
+		TreeChanged:          !jsonBytesEqual(record.Tree, snapshot.Workspace.Tree),
+		RouteManifestChanged: !jsonBytesEqual(record.RouteManifest, snapshot.RouteManifest),
+		SettingsChanged:      !jsonBytesEqual(record.Settings, snapshot.Settings),
+		Documents:            make([]WorkspaceCheckpointDocumentChange, 0),
+	}
+	saved := make(map[string]WorkspaceCheckpointDocument, len(record.Documents))
+	for _, document := range record.Documents {
+		saved[document.ID] = document
+	}
+	live := make(map[string]bool, len(snapshot.Documents))
+	for _, document := range snapshot.Documents {
+		live[document.ID] = true
+		before, ok := saved[document.ID]
+		if !ok {
+			diff.Documents = append(diff.Documents, WorkspaceCheckpointDocumentChange{ID: document.ID, Path: document.Path, Change: "added"})
+			continue
+		}
+		ops, err := diffJSONDocuments(before.Content, document.Content)
+		if err != nil {
+			return nil, err
+		}
+		metaChanged := before.Type != document.Type || before.Name != document.Name || before.Path != document.Path
+		if len(ops) == 0 && !metaChanged {
+			continue
+		}
+		change := WorkspaceCheckpointDocumentChange{ID: document.ID, Path: document.Path, Change: "modified", MetaChanged: metaChanged}
+		for _, op := range ops {
+			change.ContentPaths = append(change.ContentPaths, op.Path)
+		}
+		diff.Documents = append(diff.Documents, change)
+	}
+	for _, document := range record.Documents {
+		if !live[document.ID] {
+			diff.Documents = append(diff.Documents, WorkspaceCheckpointDocumentChange{ID: document.ID, Path: document.Path, Change: "removed"})
+		}
+	}
+	return diff, nil
+}
+
+// RestoreCheckpoint brings the workspace back to a checkpoint as one
+// core.workspace.checkpoint.restore command. Its ops patch the workspace
+// state, so the restore can be undone through core.history.undo with
+// namespace core.workspace.
+func (store *WorkspaceStore) RestoreCheckpoint(ctx context.Context, params RestoreWorkspaceCheckpointParams) (*WorkspaceMutationResult, error) {
+	result, err := store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(txStore *WorkspaceStore, _ *lockedWorkspaceState, next *workspaceState) (string, error) {
+		record, err := txStore.getCheckpoint(ctx, params.WorkspaceID, params.CheckpointID)
+		if err != nil {
+			return "", err
+		}
+		*next = record.state()
+		return fmt.Sprintf("Restore checkpoint %q", record.Name), nil
+	})
+	if errors.Is(err, ErrWorkspaceStateUnchanged) {
+		return nil, ErrWorkspaceRestoreUnchanged
+	}
+	return result, err
+}
+
+type workspaceCheckpointRestoreHandler struct{}
+
+func (workspaceCheckpointRestoreHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "checkpoint.restore", RevPartitionWorkspace)}
+}
+
+func (workspaceCheckpointRestoreHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		CheckpointID string `json:"checkpointId"`
+	}
+	if len(request.Intent.Payload) == 0 ||
+		json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.CheckpointID) == "" {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.checkpointId is required.",
+			nil,
+		)
+	}
+	result, err := store.RestoreCheckpoint(ctx, RestoreWorkspaceCheckpointParams{
+		WorkspaceID:          workspaceID,
+		CheckpointID:         strings.TrimSpace(payload.CheckpointID),
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWorkspaceStoreRestoreCheckpointAppliesOneWorkspaceCommand(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC)
+	tree := []byte(`{"rootId":"root","nodes":[]}`)
+
+	mock.ExpectBegin()
+	documents := []testDocument{
+		{id: "code_a", docType: WorkspaceDocumentTypeCode, name: "a.ts", path: "/src/a.ts", contentRev: 5, metaRev: 1, content: `{"language":"ts","source":"live"}`},
+		{id: "code_b", docType: WorkspaceDocumentTypeCode, name: "b.ts", path: "/src/b.ts", contentRev: 1, metaRev: 1, content: `{"language":"ts","source":""}`},
+	}
+	expectWorkspaceStateLock(mock, testHead{workspaceRev: 7, routeRev: 3, opSeq: 40}, string(tree), `{"version":"1","root":{"id":"root"}}`, documents...)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, workspace_id, name, op_seq, workspace_rev, route_rev, tree_json, route_manifest_json, settings_json, documents_json, created_by, created_at
+FROM workspace_checkpoints
+WHERE workspace_id = $1 AND id = $2`)).
+		WithArgs("ws_1", "ckpt_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "op_seq", "workspace_rev", "route_rev", "tree_json", "route_manifest_json", "settings_json", "documents_json", "created_by", "created_at"}).
+			AddRow("ckpt_1", "ws_1", "v1 demo", 20, 5, 3, tree, []byte(`{"version":"1","root":{"id":"root"}}`), []byte(`{}`),
+				[]byte(`[{"id":"code_a","type":"code","name":"a.ts","path":"/src/a.ts","contentRev":3,"metaRev":1,"content":{"language":"ts","source":"demo"}}]`),
+				"user_1", updatedAt))
+	expectDocumentLock(mock, documents[0])
+	expectDocumentLock(mock, documents[1])
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, route_rev = route_rev + $3, op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1", `{"nodes":[],"rootId":"root"}`, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(8, 3, 41))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE workspace_documents
+SET doc_type = $3, name = $4, path = $5, content_json = $6::jsonb, content_rev = $7, meta_rev = $8, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2`)).
+		WithArgs("ws_1", "code_a", "code", "a.ts", "/src/a.ts", `{"language":"ts","source":"demo"}`, int64(6), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCheckpoint := func(opSeq int64, contentRev int64, content string) {
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_document_checkpoints`)).
+			WithArgs("ws_1", "code_a", opSeq, contentRev, content).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectCheckpoint(40, 5, `{"language":"ts","source":"live"}`)
+	expectCheckpoint(41, 6, `{"language":"ts","source":"demo"}`)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM workspace_documents WHERE workspace_id = $1 AND id = $2`)).
+		WithArgs("ws_1", "code_b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(41), "core.workspace.checkpoint.restore@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := store.RestoreCheckpoint(context.Background(), RestoreWorkspaceCheckpointParams{
+		WorkspaceID:          "ws_1",
+		CheckpointID:         "ckpt_1",
+		ExpectedWorkspaceRev: 7,
+		Command:              testCommand("core.workspace", "checkpoint.restore", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("restore checkpoint: %v", err)
+	}
+	if result.WorkspaceRev != 8 || result.OpSeq != 41 {
+		t.Fatalf("unexpected revisions: %+v", result)
+	}
+	if len(result.UpdatedDocuments) != 1 || result.UpdatedDocuments[0].ContentRev != 6 {
+		t.Fatalf("unexpected updated documents: %+v", result.UpdatedDocuments)
+	}
+	if len(result.RemovedDocuments) != 1 || result.RemovedDocuments[0] != "code_b" {
+		t.Fatalf("unexpected removed documents: %+v", result.RemovedDocuments)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStateOpsMapsOperationsOntoWorkspacePaths(t *testing.T) {
+	content := historyTestCommand("core.code", "/source", `"next"`, `"prev"`)
+	ops := workspaceStateOps(content, "code/a")
+	if len(ops) != 1 || ops[0].Path != "/documents/code~1a/content/source" {
+		t.Fatalf("unexpected content ops: %+v", ops)
+	}
+	route := WorkspaceCommandEnvelope{Namespace: "core.route", ForwardOps: []WorkspacePatchOp{}}
+	if ops := workspaceStateOps(route, ""); len(ops) != 1 || ops[0].Path != "/routeManifest" {
+		t.Fatalf("unexpected route ops: %+v", ops)
+	}
+	restore := WorkspaceCommandEnvelope{Namespace: "core.workspace", ForwardOps: []WorkspacePatchOp{{Op: "remove", Path: "/documents/code_b"}}}
+	if ops := workspaceStateOps(restore, ""); len(ops) != 1 || ops[0].Path != "/documents/code_b" {
+		t.Fatalf("unexpected workspace ops: %+v", ops)
+	}
+
+	entries := []historyEntry{
+		{opSeq: 41, domain: "core.workspace.checkpoint.restore@1.0", command: WorkspaceCommandEnvelope{
+			Namespace:  "core.workspace",
+			ForwardOps: []WorkspacePatchOp{{Op: "replace", Path: "/documents/code~1a/content/source", Value: json.RawMessage(`"demo"`)}},
+			ReverseOps: []WorkspacePatchOp{{Op: "replace", Path: "/documents/code~1a/content/source", Value: json.RawMessage(`"live"`)}},
+		}},
+		{opSeq: 42, domain: "core.code.update@1.0", command: WorkspaceCommandEnvelope{Namespace: "core.code", ForwardOps: workspaceStateOps(content, "code/a")}},
+	}
+	scope := buildHistoryScope("core.workspace", entries)
+	target, _, _, err := scope.next(historyActionUndo)
+	if err != nil || target != 41 {
+		t.Fatalf("unexpected undo target %d: %v", target, err)
+	}
+	if conflicts := scope.conflicts(historyActionUndo, target); len(conflicts) != 1 || conflicts[0].OpSeq != 42 {
+		t.Fatalf("expected later content edit to conflict, got %+v", conflicts)
+	}
+}
+
+func TestWorkspaceStateOpTargetsLoadsOnlyTouchedContent(t *testing.T) {
+	documentIDs, routes := workspaceStateOpTargets([]WorkspacePatchOp{
+		{Op: "replace", Path: "/documents/code_a/name", Value: json.RawMessage(`"b.ts"`)},
+		{Op: "replace", Path: "/documents/code_b/content/source", Value: json.RawMessage(`"x"`)},
+		{Op: "replace", Path: "/tree", Value: json.RawMessage(`{}`)},
+	})
+	if len(documentIDs) != 1 || documentIDs[0] != "code_b" || routes {
+		t.Fatalf("unexpected targets %v (routes %v)", documentIDs, routes)
+	}
+	documentIDs, routes = workspaceStateOpTargets([]WorkspacePatchOp{{Op: "remove", Path: "/documents/layout_main"}})
+	if len(documentIDs) != 1 || documentIDs[0] != "layout_main" || !routes {
+		t.Fatalf("removing a document must load it and re-check routes, got %v (routes %v)", documentIDs, routes)
+	}
+}
@@ -0,0 +1,344 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// workspaceTextEdit is the value of a "text" patch op: it replaces Length
+// characters of a string at Offset with Text. Offsets and lengths count
+// UTF-16 code units, the unit editors and LSP use.
+type workspaceTextEdit struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Text   string `json:"text"`
+}
+
+func decodeWorkspaceTextEdit(raw json.RawMessage) (workspaceTextEdit, error) {
+	var edit workspaceTextEdit
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if len(raw) == 0 || decoder.Decode(&edit) != nil {
+		return workspaceTextEdit{}, fmt.Errorf("%w: text value must be {offset, length, text}", ErrWorkspacePatchInvalid)
+	}
+	if edit.Offset < 0 || edit.Length < 0 {
+		return workspaceTextEdit{}, fmt.Errorf("%w: text offset and length must not be negative", ErrWorkspacePatchInvalid)
+	}
+	return edit, nil
+}
+
+func spliceWorkspaceText(source string, edit workspaceTextEdit) (string, error) {
+	start, err := utf16ByteOffset(source, edit.Offset)
+	if err != nil {
+		return "", err
+	}
+	end, err := utf16ByteOffset(source, edit.Offset+edit.Length)
+	if err != nil {
+		return "", err
+	}
+	return source[:start] + edit.Text + source[end:], nil
+}
+
+// utf16ByteOffset turns a UTF-16 offset into a byte offset of source. An
+// offset inside a surrogate pair has no byte position and is rejected.
+func utf16ByteOffset(source string, offset int) (int, error) {
+	units := 0
+	for index, r := range source {
+		if units == offset {
+			return index, nil
+		}
+		units += utf16.RuneLen(r)
+		if units > offset {
+			return 0, fmt.Errorf("%w: text offset %d splits a surrogate pair", ErrWorkspacePatchInvalid, offset)
+		}
+	}
+	if units == offset {
+		return len(source), nil
+	}
+	return 0, fmt.Errorf("%w: text offset %d is beyond the end of the string (%d)", ErrWorkspacePatchInvalid, offset, units)
+}
+
+func utf16Length(text string) int {
+	units := 0
+	for _, r := range text {
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// positionByteOffset resolves an LSP position. As in LSP, a character past
+// the end of the line stands for the end of the line; the line break itself
+// (\n or \r\n) cannot be addressed by character.
+func positionByteOffset(source string, position CodeTextPosition) (int, error) {
+	if position.Line < 0 || position.Character < 0 {
+		return 0, fmt.Errorf("%w: line and character must not be negative", ErrWorkspacePatchInvalid)
+	}
+	lineStart := 0
+	for line := 0; line < position.Line; line++ {
+		next := strings.IndexByte(source[lineStart:], '\n')
+		if next < 0 {
+			return 0, fmt.Errorf("%w: line %d is beyond the end of the source", ErrWorkspacePatchInvalid, position.Line)
+		}
+		lineStart += next + 1
+	}
+	lineText := source[lineStart:]
+	if next := strings.IndexByte(lineText, '\n'); next >= 0 {
+		lineText = lineText[:next]
+	}
+	lineText = strings.TrimSuffix(lineText, "\r")
+
+	units := 0
+	for index, r := range lineText {
+		if units == position.Character {
+			return lineStart + index, nil
+		}
+		units += utf16.RuneLen(r)
+		if units > position.Character {
+			return 0, fmt.Errorf("%w: character %d of line %d splits a surrogate pair", ErrWorkspacePatchInvalid, position.Character, position.Line)
+		}
+	}
+	return lineStart + len(lineText), nil
+}
+
+type CodeTextPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type CodeTextRange struct {
+	Start CodeTextPosition `json:"start"`
+	End   CodeTextPosition `json:"end"`
+}
+
+// CodeTextEdit is one edit of core.code.source.edit, addressed either by
+// offset/length or by an LSP range. Like an LSP TextEdit[], every edit of an
+// intent refers to the source as it was before the intent.
+type CodeTextEdit struct {
+	Offset *int           `json:"offset,omitempty"`
+	Length int            `json:"length,omitempty"`
+	Range  *CodeTextRange `json:"range,omitempty"`
+	Text   string         `json:"text"`
+}
+
+type codeTextSpan struct {
+	start     int
+	end       int
+	startByte int
+	endByte   int
+	text      string
+}
+
+func resolveCodeTextEdit(source string, index int, edit CodeTextEdit) (codeTextSpan, error) {
+	span := codeTextSpan{text: edit.Text}
+	switch {
+	case edit.Offset != nil && edit.Range != nil:
+		return span, fmt.Errorf("%w: edit %d sets both offset and range", ErrWorkspacePatchInvalid, index)
+	case edit.Range != nil:
+		var err error
+		if span.startByte, err = positionByteOffset(source, edit.Range.Start); err != nil {
+			return span, err
+		}
+		if span.endByte, err = positionByteOffset(source, edit.Range.End); err != nil {
+			return span, err
+		}
+		if span.endByte < span.startByte {
+			return span, fmt.Errorf("%w: edit %d range ends before it starts", ErrWorkspacePatchInvalid, index)
+		}
+		span.start = utf16Length(source[:span.startByte])
+		span.end = span.start + utf16Length(source[span.startByte:span.endByte])
+	case edit.Offset != nil:
+		if *edit.Offset < 0 || edit.Length < 0 {
+			return span, fmt.Errorf("%w: edit %d offset and length must not be negative", ErrWorkspacePatchInvalid, index)
+		}
+		var err error
+		span.start, span.end = *edit.Offset, *edit.Offset+edit.Length
+		if span.startByte, err = utf16ByteOffset(source, span.start); err != nil {
+			return span, err
+		}
+		if span.endByte, err = utf16ByteOffset(source, span.end); err != nil {
+			return span, err
+		}
+	default:
+		return span, fmt.Errorf("%w: edit %d needs an offset or a range", ErrWorkspacePatchInvalid, index)
+	}
+	return span, nil
+}
+
+// codeSourceTextOps turns edits into "text" ops on /source. Forward ops run
+// from the end of the source backwards so earlier offsets stay valid; each
+// reverse op restores the replaced text at the position the edit ends up at.
+func codeSourceTextOps(source string, edits []CodeTextEdit) (forwardOps []WorkspacePatchOp, reverseOps []WorkspacePatchOp, err error) {
+	spans := make([]codeTextSpan, 0, len(edits))
+	for index, edit := range edits {
+		span, err := resolveCodeTextEdit(source, index, edit)
+		if err != nil {
+			return nil, nil, err
+		}
+		if source[span.startByte:span.endByte] == span.text {
+			continue
+		}
+		spans = append(spans, span)
+	}
+	sort.SliceStable(spans, func(left, right int) bool { return spans[left].start < spans[right].start })
+	for index := 1; index < len(spans); index++ {
+		if spans[index].start < spans[index-1].end {
+			return nil, nil, fmt.Errorf("%w: edits overlap at offset %d", ErrWorkspacePatchInvalid, spans[index].start)
+		}
+	}
+
+	textOp := func(offset, length int, text string) (WorkspacePatchOp, error) {
+		value, err := json.Marshal(workspaceTextEdit{Offset: offset, Length: length, Text: text})
+		return WorkspacePatchOp{Op: "text", Path: "/source", Value: value}, err
+	}
+	forwardOps = make([]WorkspacePatchOp, len(spans))
+	reverseOps = make([]WorkspacePatchOp, len(spans))
+	shift := 0
+	for index, span := range spans {
+		inserted := utf16Length(span.text)
+		position := len(spans) - 1 - index
+		if forwardOps[position], err = textOp(span.start, span.end-span.start, span.text); err != nil {
+			return nil, nil, err
+		}
+		if reverseOps[position], err = textOp(span.start+shift, inserted, source[span.startByte:span.endByte]); err != nil {
+			return nil, nil, err
+		}
+		shift += inserted - (span.end - span.start)
+	}
+	return forwardOps, reverseOps, nil
+}
+
+type EditCodeSourceParams struct {
+	WorkspaceID        string
+	DocumentID         string
+	ExpectedContentRev int64
+	Edits              []CodeTextEdit
+	Command            WorkspaceCommandEnvelope
+}
+
+// EditCodeSource applies text edits to the source of a code document. Only
+// the edits and the text they replace are logged, so large files sync and
+// undo without resending the whole source.
+func (store *WorkspaceStore) EditCodeSource(ctx context.Context, params EditCodeSourceParams) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	if params.ExpectedContentRev <= 0 {
+		return nil, errors.New("expectedContentRev must be positive")
+	}
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		// Workspace row before document row, the order every writer uses.
+		lockCtx, cancel := withStoreTimeout(ctx)
+		defer cancel()
+		if err := txStore.lockWorkspaceRow(lockCtx, params.WorkspaceID); err != nil {
+			return err
+		}
+		current, err := txStore.getDocument(lockCtx, params.WorkspaceID, params.DocumentID, "\nFOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if current.ContentRev != params.ExpectedContentRev {
+			// The edits address the expected revision; resolving them against
+			// newer content would only produce misleading range errors.
+			head, err := txStore.GetWorkspaceHead(ctx, params.WorkspaceID)
+			if err != nil {
+				return err
+			}
+			return &WorkspaceRevisionConflictError{
+				ConflictType:       WorkspaceConflictDocument,
+				WorkspaceID:        params.WorkspaceID,
+				DocumentID:         params.DocumentID,
+				ServerWorkspaceRev: head.WorkspaceRev,
+				ServerRouteRev:     head.RouteRev,
+				ServerContentRev:   current.ContentRev,
+				ServerMetaRev:      current.MetaRev,
+				ServerOpSeq:        head.OpSeq,
+			}
+		}
+		if current.Type != WorkspaceDocumentTypeCode {
+			return ErrInvalidWorkspaceDocumentType
+		}
+		var document struct {
+			Source string `json:"source"`
+		}
+		if err := json.Unmarshal(current.Content, &document); err != nil {
+			return err
+		}
+		forwardOps, reverseOps, err := codeSourceTextOps(document.Source, params.Edits)
+		if err != nil {
+			return err
+		}
+		if len(forwardOps) == 0 {
+			return ErrWorkspaceStateUnchanged
+		}
+		command := params.Command
+		command.ForwardOps = forwardOps
+		command.ReverseOps = reverseOps
+		command.Target.DocumentID = params.DocumentID
+		if command.Label == "" {
+			command.Label = "Edit source"
+		}
+		result, err = txStore.PatchDocumentContent(ctx, PatchDocumentContentParams{
+			WorkspaceID:        params.WorkspaceID,
+			DocumentID:         params.DocumentID,
+			ExpectedContentRev: params.ExpectedContentRev,
+			Command:            command,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type codeSourceEditHandler struct{}
+
+func (codeSourceEditHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.code", "source.edit", RevPartitionContent)}
+}
+
+func (codeSourceEditHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID         string         `json:"documentId"`
+		ExpectedContentRev int64          `json:"expectedContentRev"`
+		Edits              []CodeTextEdit `json:"edits"`
+	}
+	if len(request.Intent.Payload) == 0 ||
+		json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.DocumentID) == "" ||
+		payload.ExpectedContentRev <= 0 ||
+		len(payload.Edits) == 0 {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.documentId, payload.expectedContentRev and payload.edits are required.",
+			nil,
+		)
+	}
+	result, err := store.EditCodeSource(ctx, EditCodeSourceParams{
+		WorkspaceID:        workspaceID,
+		DocumentID:         strings.TrimSpace(payload.DocumentID),
+		ExpectedContentRev: payload.ExpectedContentRev,
+		Edits:              payload.Edits,
+		Command:            command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func codeTextOffset(offset int) *int {
+	return &offset
+}
+
+func applyCodeTextOps(t *testing.T, source string, forwardOps, reverseOps []WorkspacePatchOp) string {
+	t.Helper()
+	content, err := json.Marshal(map[string]any{"language": "ts", "source": source})
+	if err != nil {
+		t.Fatalf("marshal content: %v", err)
+	}
+	patch, err := patchWorkspaceDocument(WorkspaceDocumentTypeCode, content, forwardOps)
+	if err != nil {
+		t.Fatalf("apply forward ops: %v", err)
+	}
+	restored, err := patch.restoredBy(reverseOps)
+	if err != nil || !restored {
+		t.Fatalf("reverse ops do not restore the source: %v", err)
+	}
+	var document struct {
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal(patch.Content, &document); err != nil {
+		t.Fatalf("decode patched content: %v", err)
+	}
+	return document.Source
+}
+
+func TestCodeSourceTextOpsApplyEditsAgainstOriginalSource(t *testing.T) {
+	source := "const a = 1;\r\nconst 😀 = 2;\nexport {};"
+	edits := []CodeTextEdit{
+		{Range: &CodeTextRange{Start: CodeTextPosition{Line: 1, Character: 8}, End: CodeTextPosition{Line: 1, Character: 9}}, Text: "\"b\""},
+		{Offset: codeTextOffset(6), Length: 1, Text: "answer"},
+		{Range: &CodeTextRange{Start: CodeTextPosition{Line: 1, Character: 6}, End: CodeTextPosition{Line: 1, Character: 8}}, Text: "smile"},
+		{Range: &CodeTextRange{Start: CodeTextPosition{Line: 2, Character: 99}, End: CodeTextPosition{Line: 2, Character: 99}}, Text: "\n"},
+	}
+
+	forwardOps, reverseOps, err := codeSourceTextOps(source, edits)
+	if err != nil {
+		t.Fatalf("build ops: %v", err)
+	}
+	if len(forwardOps) != 4 || forwardOps[0].Op != "text" || forwardOps[0].Path != "/source" {
+		t.Fatalf("unexpected forward ops: %+v", forwardOps)
+	}
+	got := applyCodeTextOps(t, source, forwardOps, reverseOps)
+	want := "const answer = 1;\r\nconst smile\"b\"= 2;\nexport {};\n"
+	if got != want {
+		t.Fatalf("unexpected source:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestCodeSourceTextOpsRejectsInvalidEdits(t *testing.T) {
+	source := "a😀b\nc"
+	cases := map[string][]CodeTextEdit{
+		"overlap":          {{Offset: codeTextOffset(0), Length: 2, Text: "x"}, {Offset: codeTextOffset(1), Length: 1, Text: "y"}},
+		"surrogate split":  {{Offset: codeTextOffset(2), Length: 0, Text: "x"}},
+		"past end":         {{Offset: codeTextOffset(6), Length: 2, Text: "x"}},
+		"line past end":    {{Range: &CodeTextRange{Start: CodeTextPosition{Line: 2}, End: CodeTextPosition{Line: 2}}, Text: "x"}},
+		"offset and range": {{Offset: codeTextOffset(0), Range: &CodeTextRange{}, Text: "x"}},
+		"no position":      {{Text: "x"}},
+	}
+	for name, edits := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := codeSourceTextOps(source, edits); !errors.Is(err, ErrWorkspacePatchInvalid) {
+				t.Fatalf("expected ErrWorkspacePatchInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPatchWorkspaceDocumentRejectsTextOpsOutsideCodeDocuments(t *testing.T) {
+	ops := []WorkspacePatchOp{{Op: "text", Path: "/metadata/title", Value: json.RawMessage(`{"offset":0,"length":0,"text":"x"}`)}}
+	_, err := patchWorkspaceDocument(WorkspaceDocumentTypeMIRPage, json.RawMessage(`{"version":"1.3","metadata":{"title":""}}`), ops)
+	if !errors.Is(err, ErrWorkspacePatchInvalid) {
+		t.Fatalf("expected ErrWorkspacePatchInvalid, got %v", err)
+	}
+}
+
+func TestWorkspaceStoreEditCodeSourceLogsTextOps(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	content := `{"language":"ts","source":"export const value = 1;\n"}`
+
+	document := testDocument{id: "code_1", docType: WorkspaceDocumentTypeCode, name: "value.ts", path: "/src/value.ts", contentRev: 5, metaRev: 1, content: content}
+
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	expectDocumentLock(mock, document)
+	expectDocumentContentLock(mock, document, testHead{workspaceRev: 9, routeRev: 4, opSeq: 33})
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", "code_1", `{"language":"ts","source":"export const value = 42;\n"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(6, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(34), "core.code.source.edit@1.0", "code_1",
+			graphReplaceOpsMatcher{t: t, paths: []string{"/source"}}, issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := NewWorkspaceStore(db).EditCodeSource(context.Background(), EditCodeSourceParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "code_1",
+		ExpectedContentRev: 5,
+		Edits:              []CodeTextEdit{{Range: &CodeTextRange{Start: CodeTextPosition{Character: 21}, End: CodeTextPosition{Character: 22}}, Text: "42"}},
+		Command:            testCommand("core.code", "source.edit", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("edit code source: %v", err)
+	}
+	if result.UpdatedDocuments[0].ContentRev != 6 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreEditCodeSourceReturnsDocumentConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at`)).
+		WithArgs("ws_1", "code_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "id", "doc_type", "name", "path", "content_rev", "meta_rev", "content_json", "updated_at"}).
+			AddRow("ws_1", "code_1", "code", "value.ts", "/src/value.ts", 6, 1, []byte(`{"language":"ts","source":""}`), issuedAt))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectRollback()
+
+	_, err = NewWorkspaceStore(db).EditCodeSource(context.Background(), EditCodeSourceParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "code_1",
+		ExpectedContentRev: 5,
+		Edits:              []CodeTextEdit{{Offset: codeTextOffset(40), Text: "x"}},
+		Command:            testCommand("core.code", "source.edit", issuedAt),
+	})
+	var conflictErr *WorkspaceRevisionConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.ConflictType != WorkspaceConflictDocument || conflictErr.ServerContentRev != 6 {
+		t.Fatalf("expected document conflict, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package workspace
+
+import (
+	"errors"
+	"strings"
+
+	backendresponse "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/http/response"
+)
+
+// DocumentValidationError carries every problem a content validator found.
+// Each diagnostic points at the offending value with a JSON pointer; the
+// first one decides the error code of the response.
+type DocumentValidationError struct {
+	Domain      string
+	Diagnostics []backendresponse.Diagnostic
+}
+
+func (err *DocumentValidationError) Error() string {
+	if len(err.Diagnostics) == 0 {
+		return err.Domain + " document is invalid"
+	}
+	return err.Diagnostics[0].Message
+}
+
+func documentValidationError(domain, code, path, message string) *DocumentValidationError {
+	report := diagnosticReport{domain: domain}
+	report.add(code, path, message)
+	return &DocumentValidationError{Domain: domain, Diagnostics: report.diagnostics}
+}
+
+// diagnosticReport collects diagnostics so validators can report every
+// problem instead of stopping at the first one.
+type diagnosticReport struct {
+	domain      string
+	diagnostics []backendresponse.Diagnostic
+}
+
+func (report *diagnosticReport) add(code, path, message string) {
+	report.addTarget(code, path, message, nil)
+}
+
+func (report *diagnosticReport) addTarget(code, path, message string, targetRef map[string]any) {
+	report.diagnostics = append(report.diagnostics, backendresponse.Diagnostic{
+		Code:      code,
+		Message:   message,
+		Severity:  "error",
+		Domain:    report.domain,
+		Path:      path,
+		TargetRef: targetRef,
+	})
+}
+
+func (report *diagnosticReport) err() error {
+	if len(report.diagnostics) == 0 {
+		return nil
+	}
+	return &DocumentValidationError{Domain: report.domain, Diagnostics: report.diagnostics}
+}
+
+// withDocumentTarget completes the targetRefs of a validation error with the
+// document they point into; content validators only see the payload.
+func withDocumentTarget(err error, documentID string) error {
+	var validationErr *DocumentValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	for _, diagnostic := range validationErr.Diagnostics {
+		if diagnostic.TargetRef != nil {
+			diagnostic.TargetRef["documentId"] = documentID
+		}
+	}
+	return err
+}
+
+func jsonPointerPath(segments ...string) string {
+	var builder strings.Builder
+	for _, segment := range segments {
+		builder.WriteString("/")
+		builder.WriteString(escapeJSONPointerSegment(segment))
+	}
+	return builder.String()
+}
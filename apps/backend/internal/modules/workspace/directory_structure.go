@@ -0,0 +1,240 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type CreateWorkspaceDirectoryParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	Path                 string
+	Command              WorkspaceCommandEnvelope
+}
+
+type RenameWorkspaceDirectoryParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	Path                 string
+	Name                 string
+	Command              WorkspaceCommandEnvelope
+}
+
+type DeleteWorkspaceDirectoryParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	Path                 string
+	Command              WorkspaceCommandEnvelope
+}
+
+// lookupDirectoryNode resolves a non-root directory by path.
+func lookupDirectoryNode(tree workspaceVFSTree, directoryPath string) (string, error) {
+	nodeID, ok := tree.lookupPath(directoryPath)
+	if !ok || tree.TreeByID[nodeID].Kind != "dir" {
+		return "", fmt.Errorf("%w: directory does not exist", ErrWorkspaceVFSInvalid)
+	}
+	if nodeID == tree.TreeRootID {
+		return "", fmt.Errorf("%w: the workspace root cannot be changed", ErrWorkspaceVFSInvalid)
+	}
+	return nodeID, nil
+}
+
+// CreateDirectory adds an empty directory, creating missing parents.
+func (store *WorkspaceStore) CreateDirectory(ctx context.Context, params CreateWorkspaceDirectoryParams) (*WorkspaceMutationResult, error) {
+	segments := directoryPathSegments(params.Path)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%w: directory path must not be the workspace root", ErrWorkspaceVFSInvalid)
+	}
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		if _, exists := tree.lookupPath(params.Path); exists {
+			return "", fmt.Errorf("%w: workspace path already exists", ErrWorkspaceVFSInvalid)
+		}
+		nodeID, err := tree.ensureDirectories(segments)
+		if err != nil {
+			return "", err
+		}
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Create folder %q", tree.nodePath(nodeID)), nil
+	})
+}
+
+// RenameDirectory renames a directory in place. Every document below it gets
+// its path rewritten in the same command, bumping each document's metaRev.
+func (store *WorkspaceStore) RenameDirectory(ctx context.Context, params RenameWorkspaceDirectoryParams) (*WorkspaceMutationResult, error) {
+	name := strings.TrimSpace(params.Name)
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		nodeID, err := lookupDirectoryNode(tree, params.Path)
+		if err != nil {
+			return "", err
+		}
+		node := tree.TreeByID[nodeID]
+		if err := tree.placeNode(nodeID, *node.ParentID, name); err != nil {
+			return "", err
+		}
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Rename folder %q to %q", node.Name, name), nil
+	})
+}
+
+// DeleteDirectory removes a directory with everything below it as a single
+// command; its reverse ops restore the subtree and every deleted document.
+func (store *WorkspaceStore) DeleteDirectory(ctx context.Context, params DeleteWorkspaceDirectoryParams) (*WorkspaceMutationResult, error) {
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		nodeID, err := lookupDirectoryNode(tree, params.Path)
+		if err != nil {
+			return "", err
+		}
+		directoryPath := tree.nodePath(nodeID)
+		removed := tree.removeNode(nodeID)
+		for _, documentID := range removed {
+			if documentID == canonicalWorkspaceDocumentID {
+				return "", ErrWorkspaceCanonicalDocument
+			}
+			delete(next.Documents, documentID)
+		}
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Delete folder %q", directoryPath), nil
+	})
+}
+
+type workspaceDirectoryCreateHandler struct{}
+
+func (workspaceDirectoryCreateHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "directory.create", RevPartitionWorkspace)}
+}
+
+func (workspaceDirectoryCreateHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		Path string `json:"path"`
+	}
+	const message = "intent payload.path is required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.Path) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.CreateDirectory(ctx, CreateWorkspaceDirectoryParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		Path:                 payload.Path,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
+
+type workspaceDirectoryRenameHandler struct{}
+
+func (workspaceDirectoryRenameHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "directory.rename", RevPartitionWorkspace)}
+}
+
+func (workspaceDirectoryRenameHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		Path string `json:"path"`
+		Name string `json:"name"`
+	}
+	const message = "intent payload.path and payload.name are required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.Path) == "" || strings.TrimSpace(payload.Name) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.RenameDirectory(ctx, RenameWorkspaceDirectoryParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		Path:                 payload.Path,
+		Name:                 payload.Name,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
+
+type workspaceDirectoryDeleteHandler struct{}
+
+func (workspaceDirectoryDeleteHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "directory.delete", RevPartitionWorkspace)}
+}
+
+func (workspaceDirectoryDeleteHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		Path string `json:"path"`
+	}
+	const message = "intent payload.path is required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.Path) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.DeleteDirectory(ctx, DeleteWorkspaceDirectoryParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		Path:                 payload.Path,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
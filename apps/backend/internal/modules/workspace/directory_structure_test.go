@@ -0,0 +1,137 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const directoryTestTree = `{"treeRootId":"root","treeById":{` +
+	`"root":{"id":"root","kind":"dir","name":"/","parentId":null,"children":["doc_root_node","dir_src"]},` +
+	`"doc_root_node":{"id":"doc_root_node","kind":"doc","name":"mir.json","parentId":"root","docId":"doc_root"},` +
+	`"dir_src":{"id":"dir_src","kind":"dir","name":"src","parentId":"root","children":["node_a","node_b"]},` +
+	`"node_a":{"id":"node_a","kind":"doc","name":"a.ts","parentId":"dir_src","docId":"code_a"},` +
+	`"node_b":{"id":"node_b","kind":"doc","name":"b.ts","parentId":"dir_src","docId":"code_b"}}}`
+
+var directoryTestDocuments = []testDocument{
+	{id: "code_a", docType: WorkspaceDocumentTypeCode, name: "a.ts", path: "/src/a.ts", contentRev: 2, metaRev: 1, content: `{"language":"ts","source":"a"}`},
+	{id: "code_b", docType: WorkspaceDocumentTypeCode, name: "b.ts", path: "/src/b.ts", contentRev: 4, metaRev: 3, content: `{"language":"ts","source":"b"}`},
+	{id: "doc_root", docType: WorkspaceDocumentTypeMIRPage, name: "Root", path: "/mir.json", contentRev: 9, metaRev: 1,
+		content: `{"version":"1.3","ui":{"graph":{"version":1,"rootId":"root","nodesById":{"root":{"id":"root","type":"container"}},"childIdsById":{"root":[]}}}}`},
+}
+
+func TestWorkspaceStoreRenameDirectoryRewritesDescendantPaths(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectWorkspaceStateLock(mock, testHead{workspaceRev: 7, routeRev: 3, opSeq: 40}, directoryTestTree, `{}`, directoryTestDocuments...)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, route_rev = route_rev + $3, op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", sqlmock.AnyArg(), 0).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(8, 3, 41))
+	updateDocument := regexp.QuoteMeta(`UPDATE workspace_documents
+SET doc_type = $3, name = $4, path = $5, meta_rev = $6, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2`)
+	mock.ExpectExec(updateDocument).
+		WithArgs("ws_1", "code_a", "code", "a.ts", "/lib/a.ts", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateDocument).
+		WithArgs("ws_1", "code_b", "code", "b.ts", "/lib/b.ts", int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(41), "core.workspace.directory.rename@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := NewWorkspaceStore(db).RenameDirectory(context.Background(), RenameWorkspaceDirectoryParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 7,
+		Path:                 "/src",
+		Name:                 "lib",
+		Command:              testCommand("core.workspace", "directory.rename", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("rename directory: %v", err)
+	}
+	if len(result.UpdatedDocuments) != 2 {
+		t.Fatalf("unexpected updated documents: %+v", result.UpdatedDocuments)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreDeleteDirectoryRemovesSubtreeInOneCommand(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectWorkspaceStateLock(mock, testHead{workspaceRev: 7, routeRev: 3, opSeq: 40}, directoryTestTree, `{}`, directoryTestDocuments...)
+	expectDocumentLock(mock, directoryTestDocuments[0])
+	expectDocumentLock(mock, directoryTestDocuments[1])
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, route_rev = route_rev + $3, op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", `{"treeById":{"doc_root_node":{"docId":"doc_root","id":"doc_root_node","kind":"doc","name":"mir.json","parentId":"root"},"root":{"children":["doc_root_node"],"id":"root","kind":"dir","name":"/","parentId":null}},"treeRootId":"root"}`, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(8, 3, 41))
+	deleteDocument := regexp.QuoteMeta(`DELETE FROM workspace_documents WHERE workspace_id = $1 AND id = $2`)
+	mock.ExpectExec(deleteDocument).WithArgs("ws_1", "code_a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(deleteDocument).WithArgs("ws_1", "code_b").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(41), "core.workspace.directory.delete@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := NewWorkspaceStore(db).DeleteDirectory(context.Background(), DeleteWorkspaceDirectoryParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 7,
+		Path:                 "/src/",
+		Command:              testCommand("core.workspace", "directory.delete", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("delete directory: %v", err)
+	}
+	if len(result.RemovedDocuments) != 2 || result.RemovedDocuments[0] != "code_a" || result.RemovedDocuments[1] != "code_b" {
+		t.Fatalf("unexpected removed documents: %+v", result.RemovedDocuments)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreCreateDirectoryRejectsExistingPath(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectWorkspaceStateLock(mock, testHead{workspaceRev: 7, routeRev: 3, opSeq: 40}, directoryTestTree, `{}`, directoryTestDocuments...)
+	mock.ExpectRollback()
+
+	_, err = NewWorkspaceStore(db).CreateDirectory(context.Background(), CreateWorkspaceDirectoryParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 7,
+		Path:                 "src",
+		Command:              testCommand("core.workspace", "directory.create", time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)),
+	})
+	if !errors.Is(err, ErrWorkspaceVFSInvalid) {
+		t.Fatalf("expected VFS error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+type ChangeDocumentContentParams struct {
+	WorkspaceID        string
+	DocumentID         string
+	ExpectedContentRev int64
+	Command            WorkspaceCommandEnvelope
+}
+
+// changeDocumentContent is the content counterpart of changeWorkspaceState:
+// it locks the document, lets change edit the decoded content and commits the
+// minimal difference through PatchDocumentContent, so intent handlers only
+// describe the edit and still get reversible, validated ops.
+func (store *WorkspaceStore) changeDocumentContent(
+	ctx context.Context,
+	params ChangeDocumentContentParams,
+	change func(documentType WorkspaceDocumentType, document map[string]any) (label string, err error),
+) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	if params.ExpectedContentRev <= 0 {
+		return nil, errors.New("expectedContentRev must be positive")
+	}
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		// Workspace row before document row, the order every writer uses.
+		lockCtx, cancel := withStoreTimeout(ctx)
+		defer cancel()
+		if err := txStore.lockWorkspaceRow(lockCtx, params.WorkspaceID); err != nil {
+			return err
+		}
+		current, err := txStore.getDocument(lockCtx, params.WorkspaceID, params.DocumentID, "\nFOR UPDATE")
+		if err != nil {
+			return err
+		}
+		var document map[string]any
+		if err := json.Unmarshal(current.Content, &document); err != nil {
+			return err
+		}
+		if document == nil {
+			document = make(map[string]any)
+		}
+		label, err := change(current.Type, document)
+		if err != nil {
+			return err
+		}
+		target, err := json.Marshal(document)
+		if err != nil {
+			return err
+		}
+		forwardOps, err := diffJSONDocuments(current.Content, target)
+		if err != nil {
+			return err
+		}
+		if len(forwardOps) == 0 {
+			return ErrWorkspaceStateUnchanged
+		}
+		reverseOps, err := diffJSONDocuments(target, current.Content)
+		if err != nil {
+			return err
+		}
+		command := params.Command
+		command.ForwardOps = forwardOps
+		command.ReverseOps = reverseOps
+		command.Target.DocumentID = params.DocumentID
+		if command.Label == "" {
+			command.Label = label
+		}
+		result, err = txStore.PatchDocumentContent(ctx, PatchDocumentContentParams{
+			WorkspaceID:        params.WorkspaceID,
+			DocumentID:         params.DocumentID,
+			ExpectedContentRev: params.ExpectedContentRev,
+			Command:            command,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
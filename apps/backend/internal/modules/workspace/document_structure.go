@@ -0,0 +1,410 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// canonicalWorkspaceDocumentID is the document bootstrapped with every
+// workspace; its content is mirrored back to the project MIR.
+const canonicalWorkspaceDocumentID = "doc_root"
+
+var ErrWorkspaceCanonicalDocument = errors.New("the canonical workspace document cannot be deleted")
+
+type CreateMIRDocumentParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	DocumentID           string
+	NodeID               string
+	Type                 WorkspaceDocumentType
+	Name                 string
+	Path                 string
+	Content              json.RawMessage
+	Command              WorkspaceCommandEnvelope
+}
+
+type RenameWorkspaceDocumentParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	DocumentID           string
+	Name                 string
+	Command              WorkspaceCommandEnvelope
+}
+
+type MoveWorkspaceDocumentParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	DocumentID           string
+	ParentPath           string
+	Command              WorkspaceCommandEnvelope
+}
+
+type DeleteWorkspaceDocumentParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	DocumentID           string
+	Command              WorkspaceCommandEnvelope
+}
+
+// stateTree parses the tree of next, falling back to the locked documents
+// for workspaces whose tree predates treeById.
+func stateTree(locked *lockedWorkspaceState, next *workspaceState) (workspaceVFSTree, error) {
+	documents := make([]WorkspaceDocumentRecord, 0, len(locked.documents))
+	for _, document := range locked.documents {
+		documents = append(documents, document)
+	}
+	return parseWorkspaceVFSTree(next.Tree, "root", documents)
+}
+
+// commitStateTree writes tree back into next and re-derives document paths.
+func commitStateTree(tree workspaceVFSTree, next *workspaceState) error {
+	tree.syncDocumentPaths(next.Documents)
+	treeJSON, err := tree.marshal()
+	if err != nil {
+		return err
+	}
+	next.Tree = treeJSON
+	return nil
+}
+
+func lookupDocumentNode(tree workspaceVFSTree, next *workspaceState, documentID string) (string, error) {
+	if _, ok := next.Documents[documentID]; !ok {
+		return "", ErrWorkspaceDocumentNotFound
+	}
+	nodeID, ok := tree.documentNodeID(documentID)
+	if !ok {
+		return "", fmt.Errorf("%w: document is not mounted in the workspace tree", ErrWorkspaceVFSInvalid)
+	}
+	return nodeID, nil
+}
+
+// resolveDirectoryPath returns the directory node at directoryPath, creating
+// missing directories along the way.
+func resolveDirectoryPath(tree workspaceVFSTree, directoryPath string) (string, error) {
+	segments := directoryPathSegments(directoryPath)
+	if len(segments) == 0 {
+		return tree.TreeRootID, nil
+	}
+	return tree.ensureDirectories(segments)
+}
+
+// CreateMIRDocument mounts a new MIR document in the tree. Empty content is
+// seeded with the default for its type; the result is validated like any
+// other write before the workspace-level command is logged.
+func (store *WorkspaceStore) CreateMIRDocument(ctx context.Context, params CreateMIRDocumentParams) (*WorkspaceMutationResult, error) {
+	documentID := strings.TrimSpace(params.DocumentID)
+	if documentID == "" {
+		return nil, errors.New("documentID is required")
+	}
+	if params.Type == WorkspaceDocumentTypeCode || !isValidWorkspaceDocumentType(params.Type) {
+		return nil, ErrInvalidWorkspaceDocumentType
+	}
+	documentPath, err := normalizeWorkspacePath(params.Path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := normalizeWorkspaceDocumentContent(params.Type, params.Content)
+	if err != nil {
+		return nil, withDocumentTarget(err, documentID)
+	}
+	fileName := workspacePathName(documentPath)
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		name = fileName
+	}
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		if _, exists := next.Documents[documentID]; exists {
+			return "", fmt.Errorf("%w: document id already exists", ErrWorkspaceVFSInvalid)
+		}
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		if err := tree.addDocument(codeDocumentMount{
+			DocumentID: documentID,
+			NodeID:     strings.TrimSpace(params.NodeID),
+			Path:       documentPath,
+			Name:       fileName,
+		}); err != nil {
+			return "", err
+		}
+		next.Documents[documentID] = workspaceStateDocument{
+			Type:    params.Type,
+			Name:    name,
+			Path:    documentPath,
+			Content: content,
+		}
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Create %s %q", params.Type, name), nil
+	})
+}
+
+// RenameDocument changes a document's file name in place. The tree node,
+// document name and path change together, bumping the document's metaRev.
+func (store *WorkspaceStore) RenameDocument(ctx context.Context, params RenameWorkspaceDocumentParams) (*WorkspaceMutationResult, error) {
+	documentID := strings.TrimSpace(params.DocumentID)
+	name := strings.TrimSpace(params.Name)
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		nodeID, err := lookupDocumentNode(tree, next, documentID)
+		if err != nil {
+			return "", err
+		}
+		node := tree.TreeByID[nodeID]
+		if err := tree.placeNode(nodeID, *node.ParentID, name); err != nil {
+			return "", err
+		}
+		document := next.Documents[documentID]
+		document.Name = name
+		next.Documents[documentID] = document
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Rename %q to %q", node.Name, name), nil
+	})
+}
+
+// MoveDocument mounts a document under another directory, creating the
+// directory when it does not exist yet. The file name is kept.
+func (store *WorkspaceStore) MoveDocument(ctx context.Context, params MoveWorkspaceDocumentParams) (*WorkspaceMutationResult, error) {
+	documentID := strings.TrimSpace(params.DocumentID)
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		nodeID, err := lookupDocumentNode(tree, next, documentID)
+		if err != nil {
+			return "", err
+		}
+		parentID, err := resolveDirectoryPath(tree, params.ParentPath)
+		if err != nil {
+			return "", err
+		}
+		node := tree.TreeByID[nodeID]
+		if err := tree.placeNode(nodeID, parentID, node.Name); err != nil {
+			return "", err
+		}
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Move %q to %q", node.Name, tree.nodePath(parentID)), nil
+	})
+}
+
+// DeleteDocument unmounts and deletes a document. The logged reverse ops
+// carry the full document, so undo brings it back at the same path.
+func (store *WorkspaceStore) DeleteDocument(ctx context.Context, params DeleteWorkspaceDocumentParams) (*WorkspaceMutationResult, error) {
+	documentID := strings.TrimSpace(params.DocumentID)
+	if documentID == canonicalWorkspaceDocumentID {
+		return nil, ErrWorkspaceCanonicalDocument
+	}
+	return store.changeWorkspaceState(ctx, ApplyWorkspaceStateParams{
+		WorkspaceID:          params.WorkspaceID,
+		ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+		Command:              params.Command,
+	}, func(_ *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error) {
+		tree, err := stateTree(locked, next)
+		if err != nil {
+			return "", err
+		}
+		nodeID, err := lookupDocumentNode(tree, next, documentID)
+		if err != nil {
+			return "", err
+		}
+		name := tree.TreeByID[nodeID].Name
+		for _, removedID := range tree.removeNode(nodeID) {
+			delete(next.Documents, removedID)
+		}
+		if err := commitStateTree(tree, next); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Delete %q", name), nil
+	})
+}
+
+func decodeDocumentStructurePayload(request ApplyIntentRequest, payload any, message string) *RequestFailure {
+	if len(request.Intent.Payload) == 0 || json.Unmarshal(request.Intent.Payload, payload) != nil {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	return nil
+}
+
+type workspaceMIRDocumentCreateHandler struct{}
+
+func (workspaceMIRDocumentCreateHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "mir-document.create", RevPartitionWorkspace)}
+}
+
+func (workspaceMIRDocumentCreateHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID string                `json:"documentId"`
+		NodeID     string                `json:"nodeId"`
+		Type       WorkspaceDocumentType `json:"type"`
+		Name       string                `json:"name"`
+		Path       string                `json:"path"`
+		Content    json.RawMessage       `json:"content"`
+	}
+	const message = "intent payload.documentId, payload.type and payload.path are required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.DocumentID) == "" || strings.TrimSpace(string(payload.Type)) == "" || strings.TrimSpace(payload.Path) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.CreateMIRDocument(ctx, CreateMIRDocumentParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		DocumentID:           payload.DocumentID,
+		NodeID:               payload.NodeID,
+		Type:                 payload.Type,
+		Name:                 payload.Name,
+		Path:                 payload.Path,
+		Content:              payload.Content,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
+
+type workspaceDocumentRenameHandler struct{}
+
+func (workspaceDocumentRenameHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "document.rename", RevPartitionWorkspace)}
+}
+
+func (workspaceDocumentRenameHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID string `json:"documentId"`
+		Name       string `json:"name"`
+	}
+	const message = "intent payload.documentId and payload.name are required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.DocumentID) == "" || strings.TrimSpace(payload.Name) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.RenameDocument(ctx, RenameWorkspaceDocumentParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		DocumentID:           payload.DocumentID,
+		Name:                 payload.Name,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
+
+type workspaceDocumentMoveHandler struct{}
+
+func (workspaceDocumentMoveHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "document.move", RevPartitionWorkspace)}
+}
+
+func (workspaceDocumentMoveHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID string `json:"documentId"`
+		ParentPath string `json:"parentPath"`
+	}
+	const message = "intent payload.documentId and payload.parentPath are required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.DocumentID) == "" || strings.TrimSpace(payload.ParentPath) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.MoveDocument(ctx, MoveWorkspaceDocumentParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		DocumentID:           payload.DocumentID,
+		ParentPath:           payload.ParentPath,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
+
+type workspaceDocumentDeleteHandler struct{}
+
+func (workspaceDocumentDeleteHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "document.delete", RevPartitionWorkspace)}
+}
+
+func (workspaceDocumentDeleteHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID string `json:"documentId"`
+	}
+	const message = "intent payload.documentId is required."
+	if failure := decodeDocumentStructurePayload(request, &payload, message); failure != nil {
+		return nil, failure
+	}
+	if strings.TrimSpace(payload.DocumentID) == "" {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	result, err := store.DeleteDocument(ctx, DeleteWorkspaceDocumentParams{
+		WorkspaceID:          workspaceID,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		DocumentID:           payload.DocumentID,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,189 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWorkspaceStoreRenameDocumentUpdatesTreeAndPath(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	tree := `{"treeRootId":"root","treeById":{"root":{"id":"root","kind":"dir","name":"/","parentId":null,"children":["node_a"]},"node_a":{"id":"node_a","kind":"doc","name":"a.ts","parentId":"root","docId":"code_a"}}}`
+
+	mock.ExpectBegin()
+	expectWorkspaceStateLock(mock, testHead{workspaceRev: 7, routeRev: 3, opSeq: 40}, tree, `{}`,
+		testDocument{id: "code_a", docType: WorkspaceDocumentTypeCode, name: "a.ts", path: "/a.ts", contentRev: 5, metaRev: 1, content: `{"language":"ts","source":"x"}`})
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, route_rev = route_rev + $3, op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1", `{"treeById":{"node_a":{"docId":"code_a","id":"node_a","kind":"doc","name":"b.ts","parentId":"root"},"root":{"children":["node_a"],"id":"root","kind":"dir","name":"/","parentId":null}},"treeRootId":"root"}`, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(8, 3, 41))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE workspace_documents
+SET doc_type = $3, name = $4, path = $5, meta_rev = $6, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2`)).
+		WithArgs("ws_1", "code_a", "code", "b.ts", "/b.ts", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(41), "core.workspace.document.rename@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := store.RenameDocument(context.Background(), RenameWorkspaceDocumentParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 7,
+		DocumentID:           "code_a",
+		Name:                 "b.ts",
+		Command:              testCommand("core.workspace", "document.rename", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("rename document: %v", err)
+	}
+	if len(result.UpdatedDocuments) != 1 || result.UpdatedDocuments[0].ContentRev != 5 || result.UpdatedDocuments[0].MetaRev != 2 {
+		t.Fatalf("unexpected updated documents: %+v", result.UpdatedDocuments)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreDeleteDocumentRejectsCanonicalDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	_, err = NewWorkspaceStore(db).DeleteDocument(context.Background(), DeleteWorkspaceDocumentParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 7,
+		DocumentID:           canonicalWorkspaceDocumentID,
+	})
+	if !errors.Is(err, ErrWorkspaceCanonicalDocument) {
+		t.Fatalf("expected canonical document error, got %v", err)
+	}
+	if failure := MapStoreError(err); failure.Status != 422 {
+		t.Fatalf("unexpected failure status %d", failure.Status)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestDefaultWorkspaceDocumentContentValidatesForEveryType(t *testing.T) {
+	for _, documentType := range []WorkspaceDocumentType{
+		WorkspaceDocumentTypeMIRPage,
+		WorkspaceDocumentTypeMIRLayout,
+		WorkspaceDocumentTypeMIRComponent,
+		WorkspaceDocumentTypeMIRGraph,
+		WorkspaceDocumentTypeMIRAnimation,
+		WorkspaceDocumentTypeCode,
+	} {
+		if err := validateWorkspaceDocumentContent(documentType, defaultWorkspaceDocumentContent(documentType)); err != nil {
+			t.Fatalf("default %s content is invalid: %v", documentType, err)
+		}
+	}
+	if !strings.Contains(string(defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRLayout)), `"MdrOutlet"`) {
+		t.Fatalf("default layout must contain an outlet")
+	}
+}
+
+func TestWorkspaceStoreCreateMIRDocumentMountsSeededDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectWorkspaceStateLock(mock, testHead{workspaceRev: 7, routeRev: 3, opSeq: 40}, directoryTestTree, `{}`, directoryTestDocuments...)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, route_rev = route_rev + $3, op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", sqlmock.AnyArg(), 0).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(8, 3, 41))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_documents (`)).
+		WithArgs("ws_1", "layout_main", "mir-layout", "Main layout", "/layouts/main.mir.json", sqlmock.AnyArg(), int64(41)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_document_checkpoints`)).
+		WithArgs("ws_1", "layout_main", int64(41), int64(1), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(41), "core.workspace.mir-document.create@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := NewWorkspaceStore(db).CreateMIRDocument(context.Background(), CreateMIRDocumentParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 7,
+		DocumentID:           "layout_main",
+		Type:                 WorkspaceDocumentTypeMIRLayout,
+		Name:                 "Main layout",
+		Path:                 "layouts/main.mir.json",
+		Command:              testCommand("core.workspace", "mir-document.create", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("create mir document: %v", err)
+	}
+	if result.WorkspaceRev != 8 || len(result.UpdatedDocuments) != 1 || result.UpdatedDocuments[0].ID != "layout_main" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+
+	if _, err := NewWorkspaceStore(db).CreateMIRDocument(context.Background(), CreateMIRDocumentParams{
+		WorkspaceID: "ws_1",
+		DocumentID:  "code_x",
+		Type:        WorkspaceDocumentTypeCode,
+		Path:        "/x.ts",
+	}); !errors.Is(err, ErrInvalidWorkspaceDocumentType) {
+		t.Fatalf("expected code documents to be rejected, got %v", err)
+	}
+}
+
+func TestWorkspaceStoreGetDocumentAtOpSeqHidesMIRDocumentBeforeCreation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// layout_main was created by core.workspace.mir-document.create at op 41,
+	// which is logged without a document id.
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1 AND id = $2
+FOR SHARE`)).
+		WithArgs("ws_1", "layout_main").
+		WillReturnRows(testDocumentRows(testDocument{id: "layout_main", docType: WorkspaceDocumentTypeMIRLayout, name: "Main layout", path: "/layouts/main.mir.json",
+			contentRev: 1, metaRev: 1, content: string(defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRLayout))}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(8, 3, 45))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT created_op_seq FROM workspace_documents WHERE workspace_id = $1 AND id = $2`)).
+		WithArgs("ws_1", "layout_main").
+		WillReturnRows(sqlmock.NewRows([]string{"created_op_seq"}).AddRow(41))
+	mock.ExpectRollback()
+
+	_, err = NewWorkspaceStore(db).GetDocumentAtOpSeq(context.Background(), "ws_1", "layout_main", 40)
+	if !errors.Is(err, ErrWorkspaceDocumentNotFound) {
+		t.Fatalf("expected document not found before creation, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every workspace write bumps opSeq, so workspaceRev and opSeq together
+// identify one state of the snapshot. The meta listing gets its own tag
+// because it is a different representation of that state.
+func workspaceSnapshotETag(workspaceRev int64, opSeq int64, metaOnly bool) string {
+	if metaOnly {
+		return fmt.Sprintf(`"ws-%d-%d-meta"`, workspaceRev, opSeq)
+	}
+	return fmt.Sprintf(`"ws-%d-%d"`, workspaceRev, opSeq)
+}
+
+// workspaceDocumentETag carries contentRev and metaRev plus a hash of what
+// they version. The revisions start over when a document is deleted and
+// created again under the same id, so they alone could tag two different
+// documents alike. A historical read also pins the opSeq it was rebuilt at.
+func workspaceDocumentETag(document *WorkspaceDocumentRecord, atOpSeq int64, historical bool) string {
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(document.Type), []byte(document.Name), []byte(document.Path), document.Content} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	digest := hex.EncodeToString(hash.Sum(nil)[:8])
+	if historical {
+		return fmt.Sprintf(`"doc-%d-%d-%s-at-%d"`, document.ContentRev, document.MetaRev, digest, atOpSeq)
+	}
+	return fmt.Sprintf(`"doc-%d-%d-%s"`, document.ContentRev, document.MetaRev, digest)
+}
+
+// etagMatches implements the weak comparison If-None-Match asks for.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeETag sets the validator headers and answers 304 when the client
+// already holds etag.
+func writeETag(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
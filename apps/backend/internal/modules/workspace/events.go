@@ -0,0 +1,268 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const workspaceEventSubscriptionBuffer = 16
+
+// workspaceEventHeartbeatInterval keeps idle streams alive through proxies.
+var workspaceEventHeartbeatInterval = 25 * time.Second
+
+// WorkspaceEvent is pushed to stream subscribers after a commit. Operations
+// are read back from the operation log, so an event always covers every
+// command committed after the subscriber's cursor. The revision fields
+// describe the workspace at the time the event was built and are never older
+// than OpSeq.
+type WorkspaceEvent struct {
+	WorkspaceID      string                      `json:"workspaceId"`
+	WorkspaceRev     int64                       `json:"workspaceRev"`
+	RouteRev         int64                       `json:"routeRev"`
+	SettingsRev      int64                       `json:"settingsRev,omitempty"`
+	OpSeq            int64                       `json:"opSeq"`
+	UpdatedDocuments []WorkspaceDocumentRevision `json:"updatedDocuments,omitempty"`
+	RemovedDocuments []string                    `json:"removedDocuments,omitempty"`
+	Operations       []WorkspaceOperation        `json:"operations"`
+}
+
+// WorkspaceEventBroker fans commit notifications out to subscribers of one
+// workspace. It is process-local: subscribers connected to another instance
+// only learn about the commit on their next notification or reconnect, which
+// is safe because every event is rebuilt from the operation log.
+type WorkspaceEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*WorkspaceEventSubscription]struct{}
+}
+
+type WorkspaceEventSubscription struct {
+	workspaceID string
+	notify      chan *WorkspaceMutationResult
+}
+
+func NewWorkspaceEventBroker() *WorkspaceEventBroker {
+	return &WorkspaceEventBroker{subscribers: make(map[string]map[*WorkspaceEventSubscription]struct{})}
+}
+
+// C delivers the mutation result of each commit published for the workspace.
+func (subscription *WorkspaceEventSubscription) C() <-chan *WorkspaceMutationResult {
+	return subscription.notify
+}
+
+func (broker *WorkspaceEventBroker) Subscribe(workspaceID string) *WorkspaceEventSubscription {
+	subscription := &WorkspaceEventSubscription{workspaceID: workspaceID, notify: make(chan *WorkspaceMutationResult, workspaceEventSubscriptionBuffer)}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.subscribers[workspaceID] == nil {
+		broker.subscribers[workspaceID] = make(map[*WorkspaceEventSubscription]struct{})
+	}
+	broker.subscribers[workspaceID][subscription] = struct{}{}
+	return subscription
+}
+
+func (broker *WorkspaceEventBroker) Unsubscribe(subscription *WorkspaceEventSubscription) {
+	if subscription == nil {
+		return
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	subscribers := broker.subscribers[subscription.workspaceID]
+	delete(subscribers, subscription)
+	if len(subscribers) == 0 {
+		delete(broker.subscribers, subscription.workspaceID)
+	}
+}
+
+// Publish never blocks the committing request. A subscriber whose buffer is
+// full already has a pending notification, and handling it reads the log up
+// to the latest commit, so dropping this one loses nothing.
+func (broker *WorkspaceEventBroker) Publish(workspaceID string, result *WorkspaceMutationResult) {
+	if broker == nil || result == nil {
+		return
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	for subscription := range broker.subscribers[workspaceID] {
+		select {
+		case subscription.notify <- result:
+		default:
+		}
+	}
+}
+
+func (broker *WorkspaceEventBroker) subscriberCount(workspaceID string) int {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	return len(broker.subscribers[workspaceID])
+}
+
+// PublishCommitted notifies stream subscribers about a committed mutation.
+// Replayed idempotent results must not be published again.
+func (module *Module) PublishCommitted(workspaceID string, result *WorkspaceMutationResult) {
+	if module == nil {
+		return
+	}
+	module.events.Publish(workspaceID, result)
+}
+
+func (module *Module) Events() *WorkspaceEventBroker {
+	if module == nil {
+		return nil
+	}
+	return module.events
+}
+
+// GetWorkspaceHead returns the current revision counters of a workspace.
+func (store *WorkspaceStore) GetWorkspaceHead(ctx context.Context, workspaceID string) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`
+	head := &WorkspaceMutationResult{WorkspaceID: workspaceID}
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID).Scan(&head.WorkspaceRev, &head.RouteRev, &head.OpSeq); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	return head, nil
+}
+
+func (store *WorkspaceStore) listDocumentRevisions(ctx context.Context, workspaceID string) (map[string]WorkspaceDocumentRevision, error) {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT id, content_rev, meta_rev FROM workspace_documents WHERE workspace_id = $1`
+	rows, err := store.conn().QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make(map[string]WorkspaceDocumentRevision)
+	for rows.Next() {
+		var revision WorkspaceDocumentRevision
+		if err := rows.Scan(&revision.ID, &revision.ContentRev, &revision.MetaRev); err != nil {
+			return nil, err
+		}
+		revisions[revision.ID] = revision
+	}
+	return revisions, rows.Err()
+}
+
+// BuildWorkspaceEvents reads every operation committed after afterOpSeq and
+// groups them into events of at most one log page each. latest is the
+// mutation result that triggered the call, if any; when it matches the last
+// operation read its revisions are used instead of querying them again.
+func (store *WorkspaceStore) BuildWorkspaceEvents(ctx context.Context, workspaceID string, afterOpSeq int64, latest *WorkspaceMutationResult) ([]WorkspaceEvent, error) {
+	events := make([]WorkspaceEvent, 0, 1)
+	cursor := afterOpSeq
+	for {
+		page, err := store.ListOperations(ctx, ListWorkspaceOperationsParams{WorkspaceID: workspaceID, AfterOpSeq: cursor, Limit: maxWorkspaceOperationPageSize})
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Operations) == 0 {
+			return events, nil
+		}
+		event := WorkspaceEvent{WorkspaceID: workspaceID, OpSeq: page.NextAfterOpSeq, Operations: page.Operations}
+		if !page.HasMore && latest != nil && latest.OpSeq == page.NextAfterOpSeq {
+			event.WorkspaceRev = latest.WorkspaceRev
+			event.RouteRev = latest.RouteRev
+			event.SettingsRev = latest.SettingsRev
+			event.UpdatedDocuments = latest.UpdatedDocuments
+			event.RemovedDocuments = latest.RemovedDocuments
+		} else if err := store.fillEventRevisions(ctx, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+		cursor = page.NextAfterOpSeq
+		if !page.HasMore {
+			return events, nil
+		}
+	}
+}
+
+// fillEventRevisions derives the revisions of an event from the results
+// logged with its operations, so commands that are not tied to one document
+// (structure, checkpoint and settings commands) advance the client as well.
+// Operations logged before results were recorded fall back to the current
+// workspace head and document revisions.
+func (store *WorkspaceStore) fillEventRevisions(ctx context.Context, event *WorkspaceEvent) error {
+	touched := make([]string, 0)
+	updated := make(map[string]WorkspaceDocumentRevision)
+	removed := make(map[string]bool)
+	touch := func(documentID string) {
+		if _, ok := updated[documentID]; !ok && !removed[documentID] {
+			touched = append(touched, documentID)
+		}
+	}
+	unrecorded := make([]string, 0)
+	for _, operation := range event.Operations {
+		if len(operation.Result) == 0 {
+			unrecorded = append(unrecorded, operation.DocumentID)
+			continue
+		}
+		var result WorkspaceMutationResult
+		if err := json.Unmarshal(operation.Result, &result); err != nil {
+			return err
+		}
+		event.WorkspaceRev = result.WorkspaceRev
+		event.RouteRev = result.RouteRev
+		if result.SettingsRev > 0 {
+			event.SettingsRev = result.SettingsRev
+		}
+		for _, revision := range result.UpdatedDocuments {
+			touch(revision.ID)
+			updated[revision.ID] = revision
+			delete(removed, revision.ID)
+		}
+		for _, documentID := range result.RemovedDocuments {
+			touch(documentID)
+			removed[documentID] = true
+			delete(updated, documentID)
+		}
+	}
+
+	if len(unrecorded) > 0 {
+		head, err := store.GetWorkspaceHead(ctx, event.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		event.WorkspaceRev = head.WorkspaceRev
+		event.RouteRev = head.RouteRev
+		revisions, err := store.listDocumentRevisions(ctx, event.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		for _, documentID := range unrecorded {
+			if documentID == "" {
+				continue
+			}
+			touch(documentID)
+			if revision, ok := revisions[documentID]; ok {
+				updated[documentID] = revision
+				delete(removed, documentID)
+			} else {
+				removed[documentID] = true
+				delete(updated, documentID)
+			}
+		}
+	}
+
+	for _, documentID := range touched {
+		if revision, ok := updated[documentID]; ok {
+			event.UpdatedDocuments = append(event.UpdatedDocuments, revision)
+		} else if removed[documentID] {
+			event.RemovedDocuments = append(event.RemovedDocuments, documentID)
+		}
+	}
+	return nil
+}
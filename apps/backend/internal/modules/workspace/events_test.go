@@ -0,0 +1,180 @@
+package workspace
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestWorkspaceEventBrokerPublishesToWorkspaceSubscribers(t *testing.T) {
+	broker := NewWorkspaceEventBroker()
+	first := broker.Subscribe("ws_1")
+	other := broker.Subscribe("ws_2")
+
+	broker.Publish("ws_1", &WorkspaceMutationResult{WorkspaceID: "ws_1", OpSeq: 3})
+
+	select {
+	case result := <-first.C():
+		if result.OpSeq != 3 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	default:
+		t.Fatalf("expected ws_1 subscriber to be notified")
+	}
+	select {
+	case result := <-other.C():
+		t.Fatalf("ws_2 subscriber should not be notified: %+v", result)
+	default:
+	}
+
+	broker.Unsubscribe(first)
+	broker.Unsubscribe(other)
+	if broker.subscriberCount("ws_1") != 0 || broker.subscriberCount("ws_2") != 0 {
+		t.Fatalf("expected subscriptions to be removed")
+	}
+}
+
+func TestWorkspaceEventBrokerDropsWhenSubscriberIsBehind(t *testing.T) {
+	broker := NewWorkspaceEventBroker()
+	subscription := broker.Subscribe("ws_1")
+	for index := 0; index < workspaceEventSubscriptionBuffer+5; index++ {
+		broker.Publish("ws_1", &WorkspaceMutationResult{WorkspaceID: "ws_1", OpSeq: int64(index + 1)})
+	}
+	if len(subscription.C()) != workspaceEventSubscriptionBuffer {
+		t.Fatalf("expected buffered notifications to be capped, got %d", len(subscription.C()))
+	}
+}
+
+func TestHandleStreamWorkspaceEventsResumesAndPushesCommits(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	createdAt := time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC)
+	operationsQuery := regexp.QuoteMeta(`SELECT op_seq, domain, document_id, payload_json, created_at, result_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND op_seq > $2
+ORDER BY op_seq ASC
+LIMIT $3`)
+	headQuery := regexp.QuoteMeta(`SELECT op_seq FROM workspaces WHERE id = $1`)
+	operationColumns := []string{"op_seq", "domain", "document_id", "payload_json", "created_at", "result_json"}
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	// Resume from Last-Event-ID: replay opSeq 34 and 35 from the log. Their
+	// revisions come from the logged results, including the settings patch
+	// that is not tied to a document.
+	mock.ExpectQuery(headQuery).WithArgs("ws_1").WillReturnRows(sqlmock.NewRows([]string{"op_seq"}).AddRow(35))
+	mock.ExpectQuery(operationsQuery).
+		WithArgs("ws_1", int64(33), maxWorkspaceOperationPageSize+1).
+		WillReturnRows(sqlmock.NewRows(operationColumns).
+			AddRow(34, "core.code.source.update@1.0", "code_open_dialog", []byte(`{"id":"cmd_34"}`), createdAt,
+				[]byte(`{"workspaceId":"ws_1","workspaceRev":9,"routeRev":4,"opSeq":34,"updatedDocuments":[{"id":"code_open_dialog","contentRev":4,"metaRev":1}]}`)).
+			AddRow(35, "core.settings.global.patch@1.0", nil, []byte(`{"id":"cmd_35"}`), createdAt,
+				[]byte(`{"workspaceId":"ws_1","workspaceRev":9,"routeRev":4,"opSeq":35,"settingsRev":6}`)))
+	// Live commit: revisions come from the published mutation result.
+	mock.ExpectQuery(headQuery).WithArgs("ws_1").WillReturnRows(sqlmock.NewRows([]string{"op_seq"}).AddRow(36))
+	mock.ExpectQuery(operationsQuery).
+		WithArgs("ws_1", int64(35), maxWorkspaceOperationPageSize+1).
+		WillReturnRows(sqlmock.NewRows(operationColumns).AddRow(36, "core.settings.global.update@1.0", nil, []byte(`{"id":"intent_36"}`), createdAt, nil))
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1/events",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+	context.Request.Header.Set("Last-Event-ID", "33")
+	cancel := cancelableRequestContext(context)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleStreamWorkspaceEvents(context)
+		close(done)
+	}()
+
+	waitFor(t, func() bool { return handler.module.Events().subscriberCount("ws_1") == 1 })
+	handler.module.PublishCommitted("ws_1", &WorkspaceMutationResult{WorkspaceID: "ws_1", WorkspaceRev: 10, RouteRev: 4, OpSeq: 36})
+	waitFor(t, func() bool { return mock.ExpectationsWereMet() == nil })
+	cancel()
+	<-done
+
+	body := response.Body.String()
+	if response.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", response.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, "id: 35\nevent: commit\n") || !strings.Contains(body, `"settingsRev":6`) ||
+		!strings.Contains(body, `"updatedDocuments":[{"id":"code_open_dialog","contentRev":4,"metaRev":1}]`) {
+		t.Fatalf("expected replayed event, got %q", body)
+	}
+	if !strings.Contains(body, "id: 36\nevent: commit\n") || !strings.Contains(body, `"workspaceRev":10`) {
+		t.Fatalf("expected live event, got %q", body)
+	}
+	if handler.module.Events().subscriberCount("ws_1") != 0 {
+		t.Fatalf("expected subscription to be released")
+	}
+}
+
+func cancelableRequestContext(c *gin.Context) context.CancelFunc {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	return cancel
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBuildWorkspaceEventsFallsBackForUnrecordedOperations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq"}).AddRow(6))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq, domain, document_id, payload_json, created_at, result_json`)).
+		WithArgs("ws_1", int64(4), maxWorkspaceOperationPageSize+1).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json", "created_at", "result_json"}).
+			AddRow(5, "core.workspace.document.delete@1.0", nil, []byte(`{"id":"cmd_5"}`), createdAt,
+				[]byte(`{"workspaceId":"ws_1","workspaceRev":7,"routeRev":2,"opSeq":5,"removedDocuments":["doc_old"]}`)).
+			AddRow(6, "core.mir.document_update@1.0", "doc_home", []byte(`{"id":"cmd_6"}`), createdAt, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(7, 2, 6))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, content_rev, meta_rev FROM workspace_documents WHERE workspace_id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "content_rev", "meta_rev"}).AddRow("doc_home", 3, 1))
+
+	events, err := NewWorkspaceStore(db).BuildWorkspaceEvents(context.Background(), "ws_1", 4, nil)
+	if err != nil {
+		t.Fatalf("build events: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %+v", events)
+	}
+	event := events[0]
+	if event.WorkspaceRev != 7 || event.OpSeq != 6 ||
+		len(event.RemovedDocuments) != 1 || event.RemovedDocuments[0] != "doc_old" ||
+		len(event.UpdatedDocuments) != 1 || event.UpdatedDocuments[0].ContentRev != 3 {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -20,6 +20,7 @@ func TestHandleGetWorkspaceReturnsSnapshot(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
 	expectWorkspaceSnapshotQueries(mock, "ws_1")
 
 	context, response := newWorkspaceHandlerContext(
@@ -62,12 +63,7 @@ func TestHandleGetWorkspaceNotFound(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
-	workspaceQuery := regexp.QuoteMeta(`SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json
-FROM workspaces w
-LEFT JOIN workspace_routes r ON r.workspace_id = w.id
-LEFT JOIN workspace_settings s ON s.workspace_id = w.id
-WHERE w.id = $1`)
-	mock.ExpectQuery(workspaceQuery).WithArgs("ws_missing").WillReturnError(sql.ErrNoRows)
+	expectWorkspaceOwnerMissing(mock, "ws_missing")
 	projectQuery := regexp.QuoteMeta(`SELECT id, owner_id, resource_type, name, description, mir_json, is_public, stars_count, created_at, updated_at
 FROM projects
 WHERE owner_id = $1 AND id = $2`)
@@ -97,13 +93,186 @@ WHERE owner_id = $1 AND id = $2`)
 	}
 }
 
+func TestHandleGetWorkspaceMetaOmitsDocumentContent(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	now := time.Date(2026, time.February, 8, 9, 0, 0, 0, time.UTC)
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "project_id", "owner_id", "name", "workspace_rev", "route_rev", "op_seq", "tree_root_id", "tree_json", "created_at", "updated_at", "manifest_json", "settings_json", "settings_rev",
+		}).AddRow("ws_1", "project_1", "user_1", "Workspace One", 3, 2, 11, "root", []byte(`{"rootId":"root","nodes":[]}`), now, now, nil, nil, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, NULL, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1
+ORDER BY path ASC`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"workspace_id", "id", "doc_type", "name", "path", "content_rev", "meta_rev", "content_json", "updated_at",
+		}).AddRow("ws_1", "doc_home", "mir-page", "Home", "/home", 4, 1, nil, now))
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1?include=meta",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleGetWorkspace(context)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	if etag := response.Header().Get("ETag"); etag != `"ws-3-11-meta"` {
+		t.Fatalf("unexpected ETag: %q", etag)
+	}
+	var payload struct {
+		Workspace struct {
+			Documents []map[string]any `json:"documents"`
+		} `json:"workspace"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Workspace.Documents) != 1 || payload.Workspace.Documents[0]["path"] != "/home" {
+		t.Fatalf("unexpected documents: %v", payload.Workspace.Documents)
+	}
+	if _, ok := payload.Workspace.Documents[0]["content"]; ok {
+		t.Fatalf("meta listing should not include content: %v", payload.Workspace.Documents[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleGetWorkspaceReturnsNotModifiedWithoutLoadingDocuments(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(3, 2, 11))
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+	context.Request.Header.Set("If-None-Match", `"ws-3-10", W/"ws-3-11"`)
+
+	handler.HandleGetWorkspace(context)
+	context.Writer.WriteHeaderNow()
+
+	if response.Code != http.StatusNotModified || response.Body.Len() != 0 {
+		t.Fatalf("expected empty 304, got %d: %s", response.Code, response.Body.String())
+	}
+	if etag := response.Header().Get("ETag"); etag != `"ws-3-11"` {
+		t.Fatalf("unexpected ETag: %q", etag)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleGetWorkspaceReturnsSnapshotForStaleETag(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(3, 2, 11))
+	expectWorkspaceSnapshotQueries(mock, "ws_1")
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+	context.Request.Header.Set("If-None-Match", `"ws-3-10"`)
+
+	handler.HandleGetWorkspace(context)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	if etag := response.Header().Get("ETag"); etag != `"ws-3-11"` {
+		t.Fatalf("unexpected ETag: %q", etag)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceDocumentETagTellsRecreatedDocumentsApart(t *testing.T) {
+	original := &WorkspaceDocumentRecord{ID: "code_a", Type: WorkspaceDocumentTypeCode, Name: "a.ts", Path: "/src/a.ts", ContentRev: 1, MetaRev: 1, Content: json.RawMessage(`{"language":"ts","source":"a"}`)}
+	recreated := *original
+	recreated.Content = json.RawMessage(`{"language":"ts","source":"b"}`)
+	if workspaceDocumentETag(original, 0, false) == workspaceDocumentETag(&recreated, 0, false) {
+		t.Fatalf("documents recreated at the same revisions must not share an ETag")
+	}
+	if workspaceDocumentETag(original, 0, false) != workspaceDocumentETag(original, 0, false) {
+		t.Fatalf("ETag must be stable for the same document")
+	}
+}
+
+func TestHandleGetWorkspaceDocumentHonorsIfNoneMatch(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	now := time.Date(2026, time.February, 8, 9, 0, 0, 0, time.UTC)
+	const etag = `"doc-4-1-306fd3b8fb002ff1"`
+	for _, ifNoneMatch := range []string{"", etag} {
+		expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1 AND id = $2`)).
+			WithArgs("ws_1", "doc_home").
+			WillReturnRows(sqlmock.NewRows([]string{
+				"workspace_id", "id", "doc_type", "name", "path", "content_rev", "meta_rev", "content_json", "updated_at",
+			}).AddRow("ws_1", "doc_home", "mir-page", "Home", "/home", 4, 1, []byte(`{"type":"page"}`), now))
+
+		context, response := newWorkspaceHandlerContext(
+			http.MethodGet,
+			"/api/workspaces/ws_1/documents/doc_home",
+			"",
+			gin.Params{{Key: "workspaceId", Value: "ws_1"}, {Key: "documentId", Value: "doc_home"}},
+		)
+		if ifNoneMatch != "" {
+			context.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		handler.HandleGetWorkspaceDocument(context)
+		context.Writer.WriteHeaderNow()
+
+		if got := response.Header().Get("ETag"); got != etag {
+			t.Fatalf("unexpected ETag: %q", got)
+		}
+		expected := http.StatusOK
+		if ifNoneMatch != "" {
+			expected = http.StatusNotModified
+		}
+		if response.Code != expected {
+			t.Fatalf("expected %d, got %d: %s", expected, response.Code, response.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestHandleGetWorkspaceBootstrapsFromProjectWhenMissing(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
 	now := time.Date(2026, time.February, 8, 9, 0, 0, 0, time.UTC)
 
-	workspaceQuery := regexp.QuoteMeta(`SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json
+	workspaceQuery := regexp.QuoteMeta(`SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json, COALESCE(s.settings_rev, 1)
 FROM workspaces w
 LEFT JOIN workspace_routes r ON r.workspace_id = w.id
 LEFT JOIN workspace_settings s ON s.workspace_id = w.id
@@ -125,7 +294,7 @@ FROM workspace_documents
 WHERE workspace_id = $1
 ORDER BY path ASC`)
 
-	mock.ExpectQuery(workspaceQuery).WithArgs("prj_bootstrap").WillReturnError(sql.ErrNoRows)
+	expectWorkspaceOwnerMissing(mock, "prj_bootstrap")
 	mock.ExpectQuery(projectQuery).
 		WithArgs("user_1", "prj_bootstrap").
 		WillReturnRows(sqlmock.NewRows([]string{
@@ -182,7 +351,7 @@ ORDER BY path ASC`)
 	mock.ExpectQuery(workspaceQuery).
 		WithArgs("prj_bootstrap").
 		WillReturnRows(sqlmock.NewRows([]string{
-			"id", "project_id", "owner_id", "name", "workspace_rev", "route_rev", "op_seq", "tree_root_id", "tree_json", "created_at", "updated_at", "manifest_json", "settings_json",
+			"id", "project_id", "owner_id", "name", "workspace_rev", "route_rev", "op_seq", "tree_root_id", "tree_json", "created_at", "updated_at", "manifest_json", "settings_json", "settings_rev",
 		}).AddRow(
 			"prj_bootstrap",
 			"prj_bootstrap",
@@ -197,6 +366,7 @@ ORDER BY path ASC`)
 			now,
 			[]byte(`{"version":"1","root":{"id":"root"}}`),
 			[]byte(`{"global":{"theme":"dark"},"projectGlobalById":{}}`),
+			1,
 		))
 	mock.ExpectQuery(documentQuery).
 		WithArgs("prj_bootstrap").
@@ -235,6 +405,7 @@ func TestHandleGetWorkspaceCapabilitiesReturnsCapabilityMap(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
 	expectWorkspaceSnapshotQueries(mock, "ws_1")
 
 	context, response := newWorkspaceHandlerContext(
@@ -265,8 +436,11 @@ func TestHandleGetWorkspaceCapabilitiesReturnsCapabilityMap(t *testing.T) {
 	if !payload.Capabilities["core.settings.global.update@1.0"] {
 		t.Fatalf("missing core settings capability: %+v", payload.Capabilities)
 	}
-	if payload.Capabilities["core.nodegraph.node.move@1.0"] {
-		t.Fatalf("reserved nodegraph capability should be false: %+v", payload.Capabilities)
+	if !payload.Capabilities["core.nodegraph.node.move@1.0"] {
+		t.Fatalf("missing nodegraph capability: %+v", payload.Capabilities)
+	}
+	if !payload.Capabilities["core.animation.clip.bind@1.0"] {
+		t.Fatalf("missing animation capability: %+v", payload.Capabilities)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
@@ -277,6 +451,8 @@ func TestHandleApplyWorkspaceIntentRejectsUnsupportedIntent(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+
 	context, response := newWorkspaceHandlerContext(
 		http.MethodPost,
 		"/api/workspaces/ws_1/intents",
@@ -316,6 +492,9 @@ func TestHandleApplyWorkspaceIntentReturnsRouteConflict(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	expectWorkspaceSettingsRead(mock, `{}`)
+
 	lockWorkspace := regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq
 FROM workspaces
 WHERE id = $1
@@ -364,37 +543,71 @@ FOR UPDATE`)
 	}
 }
 
+func TestHandleApplyWorkspaceIntentHonorsWorkspaceCapabilityFlags(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	expectWorkspaceSettingsRead(mock, `{"capabilities":{"core.route":false}}`)
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodPost,
+		"/api/workspaces/ws_1/intents",
+		`{
+			"expectedWorkspaceRev": 9,
+			"expectedRouteRev": 4,
+			"intent": {
+				"id": "intent_2",
+				"namespace": "core.route",
+				"type": "manifest.update",
+				"version": "1.0",
+				"payload": {
+					"routeManifest": {"version":"1","root":{"id":"root"}}
+				},
+				"issuedAt": "2026-02-08T10:02:00Z"
+			}
+		}`,
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleApplyWorkspaceIntent(context)
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", response.Code, response.Body.String())
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if errorCode(payload) != ErrorReservedDomain {
+		t.Fatalf("unexpected disabled intent payload: %v", payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestHandleApplyWorkspaceIntentSavesSettings(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
-	lockWorkspace := regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq
-FROM workspaces
-WHERE id = $1
-FOR UPDATE`)
-	upsertSettings := regexp.QuoteMeta(`INSERT INTO workspace_settings (workspace_id, settings_json, updated_at)
-VALUES ($1, $2::jsonb, NOW())
-ON CONFLICT (workspace_id) DO UPDATE
-SET settings_json = EXCLUDED.settings_json, updated_at = EXCLUDED.updated_at`)
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+
 	bumpWorkspaceOnly := regexp.QuoteMeta(`UPDATE workspaces
 SET workspace_rev = workspace_rev + 1, op_seq = op_seq + 1, updated_at = NOW()
 WHERE id = $1
 RETURNING workspace_rev, route_rev, op_seq`)
-	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
+	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(lockWorkspace).
-		WithArgs("ws_1").
-		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
-	mock.ExpectExec(upsertSettings).
-		WithArgs("ws_1", sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSettingsLock(mock, testHead{workspaceRev: 9, routeRev: 4, opSeq: 34}, "", 1)
+	expectSettingsUpsert(mock, "ws_1", sqlmock.AnyArg(), 2)
 	mock.ExpectQuery(bumpWorkspaceOnly).
 		WithArgs("ws_1").
 		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(10, 4, 35))
 	mock.ExpectExec(insertOperation).
-		WithArgs("ws_1", int64(35), "core.settings.global.update@1.0", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("ws_1", int64(35), "core.settings.global.update@1.0", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -403,6 +616,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 		"/api/workspaces/ws_1/intents",
 		`{
 			"expectedWorkspaceRev": 9,
+			"expectedSettingsRev": 1,
 			"intent": {
 				"id": "intent_settings_1",
 				"namespace": "core.settings",
@@ -441,6 +655,9 @@ func TestHandleApplyWorkspaceIntentCreatesCodeDocument(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	expectWorkspaceSettingsRead(mock, `{}`)
+
 	now := time.Date(2026, time.February, 8, 10, 10, 0, 0, time.UTC)
 	lockWorkspace := regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq, tree_root_id, tree_json
 FROM workspaces
@@ -451,14 +668,14 @@ FROM workspace_documents
 WHERE workspace_id = $1
 ORDER BY path ASC`)
 	insertDocument := regexp.QuoteMeta(`INSERT INTO workspace_documents (
-	workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
-) VALUES ($1, $2, $3, $4, $5, 1, 1, $6::jsonb, NOW())`)
+	workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, created_op_seq, updated_at
+) VALUES ($1, $2, $3, $4, $5, 1, 1, $6::jsonb, $7, NOW())`)
 	updateWorkspace := regexp.QuoteMeta(`UPDATE workspaces
 SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, op_seq = op_seq + 1, updated_at = NOW()
 WHERE id = $1
 RETURNING workspace_rev, route_rev, op_seq`)
-	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
+	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(lockWorkspace).
@@ -478,6 +695,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 			"button-1.css",
 			"/styles/mounted/button-1.css",
 			`{"language":"css","metadata":{"slotKind":"mounted-css"},"source":"/* Mounted CSS */\n"}`,
+			int64(35),
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectQuery(updateWorkspace).
@@ -491,6 +709,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 			"code_mounted_css_button_1",
 			sqlmock.AnyArg(),
 			now,
+			sqlmock.AnyArg(),
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
@@ -538,10 +757,21 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 	}
 }
 
+func TestTouchesCanonicalDocumentSelectsMirrorSyncs(t *testing.T) {
+	if touchesCanonicalDocument(&WorkspaceMutationResult{UpdatedDocuments: []WorkspaceDocumentRevision{{ID: "code_1"}}}) {
+		t.Fatalf("code document intents must not sync the project mirror")
+	}
+	if !touchesCanonicalDocument(&WorkspaceMutationResult{UpdatedDocuments: []WorkspaceDocumentRevision{{ID: "code_1"}, {ID: canonicalWorkspaceDocumentID}}}) {
+		t.Fatalf("intents that change doc_root must sync the project mirror")
+	}
+}
+
 func TestHandleApplyWorkspaceBatchRejectsUnsupportedOperation(t *testing.T) {
 	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
 	defer cleanup()
 
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+
 	context, response := newWorkspaceHandlerContext(
 		http.MethodPost,
 		"/api/workspaces/ws_1/batch",
@@ -571,6 +801,321 @@ func TestHandleApplyWorkspaceBatchRejectsUnsupportedOperation(t *testing.T) {
 	}
 }
 
+func TestHandleApplyWorkspaceBatchCommitsOperationsInOneTransaction(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectBegin()
+	expectIdempotencyRecordLookup(mock, "ws_1", "batch_1")
+	expectBatchCodePatch(mock)
+	expectSettingsLock(mock, testHead{workspaceRev: 9, routeRev: 4, opSeq: 34}, "", 1)
+	expectSettingsUpsert(mock, "ws_1", sqlmock.AnyArg(), 2)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET workspace_rev = workspace_rev + 1, op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(10, 4, 35))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)).
+		WithArgs("ws_1", int64(35), "core.settings.global.update@1.0", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectIdempotencyRecordSave(mock, "ws_1", "batch_1", sqlmock.AnyArg(), sqlmock.AnyArg())
+	mock.ExpectCommit()
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodPost,
+		"/api/workspaces/ws_1/batch",
+		workspaceBatchTestBody,
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleApplyWorkspaceBatch(context)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	var payload struct {
+		WorkspaceRev int64 `json:"workspaceRev"`
+		OpSeq        int64 `json:"opSeq"`
+		OpSeqRange   struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		} `json:"opSeqRange"`
+		Operations []WorkspaceBatchOperationResult `json:"operations"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.WorkspaceRev != 10 || payload.OpSeq != 35 || payload.OpSeqRange.From != 34 || payload.OpSeqRange.To != 35 {
+		t.Fatalf("unexpected batch payload: %s", response.Body.String())
+	}
+	if len(payload.Operations) != 2 || payload.Operations[0].Op != "patchDocument" || payload.Operations[1].Op != "intent" {
+		t.Fatalf("unexpected per-operation results: %+v", payload.Operations)
+	}
+	if len(payload.Operations[0].UpdatedDocuments) != 1 || payload.Operations[0].UpdatedDocuments[0].ContentRev != 4 {
+		t.Fatalf("unexpected patch result: %+v", payload.Operations[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleApplyWorkspaceBatchRollsBackWhenLaterOperationFails(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectBegin()
+	expectIdempotencyRecordLookup(mock, "ws_1", "batch_1")
+	expectBatchCodePatch(mock)
+	expectSettingsLock(mock, testHead{workspaceRev: 10, routeRev: 4, opSeq: 34}, "", 1)
+	mock.ExpectRollback()
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodPost,
+		"/api/workspaces/ws_1/batch",
+		workspaceBatchTestBody,
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleApplyWorkspaceBatch(context)
+
+	if response.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", response.Code, response.Body.String())
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	details, _ := payload["error"].(map[string]any)["details"].(map[string]any)
+	if errorCode(payload) != "WKS-4001" || details["index"] != float64(1) {
+		t.Fatalf("unexpected conflict payload: %v", payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+const workspaceBatchTestBody = `{
+	"expectedWorkspaceRev": 9,
+	"expectedSettingsRev": 1,
+	"clientBatchId": "batch_1",
+	"operations": [
+		{
+			"op": "patchDocument",
+			"documentId": "code_open_dialog",
+			"expectedContentRev": 3,
+			"command": {
+				"id": "cmd_code_update_1",
+				"namespace": "core.code",
+				"type": "source.update",
+				"version": "1.0",
+				"issuedAt": "2026-02-08T10:00:00Z",
+				"forwardOps": [{"op":"replace","path":"/source","value":"next"}],
+				"reverseOps": [{"op":"replace","path":"/source","value":"prev"}],
+				"target": {"workspaceId":"ws_1","documentId":"code_open_dialog"}
+			}
+		},
+		{
+			"op": "intent",
+			"intent": {
+				"id": "intent_settings_1",
+				"namespace": "core.settings",
+				"type": "global.update",
+				"version": "1.0",
+				"payload": {"settings": {"global": {}}},
+				"issuedAt": "2026-02-08T10:00:01Z"
+			}
+		}
+	]
+}`
+
+func expectBatchCodePatch(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.doc_type, d.content_json, d.content_rev, d.meta_rev, w.workspace_rev, w.route_rev, w.op_seq
+FROM workspace_documents d
+JOIN workspaces w ON w.id = d.workspace_id
+WHERE d.workspace_id = $1 AND d.id = $2
+FOR UPDATE OF d, w`)).
+		WithArgs("ws_1", "code_open_dialog").
+		WillReturnRows(sqlmock.NewRows([]string{"doc_type", "content_json", "content_rev", "meta_rev", "workspace_rev", "route_rev", "op_seq"}).
+			AddRow("code", []byte(`{"language":"ts","source":"prev"}`), 3, 1, 9, 4, 33))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2
+RETURNING content_rev, meta_rev`)).
+		WithArgs("ws_1", "code_open_dialog", `{"language":"ts","source":"next"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(4, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)).
+		WithArgs("ws_1", int64(34), "core.code.source.update@1.0", "code_open_dialog", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestHandleListWorkspaceOperationsReturnsPage(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq"}).AddRow(35))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq, domain, document_id, payload_json, created_at, result_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND op_seq > $2
+ORDER BY op_seq ASC
+LIMIT $3`)).
+		WithArgs("ws_1", int64(33), 51).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json", "created_at", "result_json"}).
+			AddRow(34, "core.code.source.update@1.0", "code_open_dialog", []byte(`{"id":"cmd_code_update_1"}`), time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC), nil).
+			AddRow(35, "core.settings.global.update@1.0", nil, []byte(`{"id":"intent_settings_1"}`), time.Date(2026, time.February, 8, 10, 0, 1, 0, time.UTC), []byte(`{"workspaceId":"ws_1","workspaceRev":10,"routeRev":4,"opSeq":35,"settingsRev":2}`)))
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1/operations?afterOpSeq=33&limit=50",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleListWorkspaceOperations(context)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	var payload WorkspaceOperationPage
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Operations) != 2 || payload.HeadOpSeq != 35 || payload.NextAfterOpSeq != 35 || payload.HasMore {
+		t.Fatalf("unexpected operations page: %s", response.Body.String())
+	}
+	if payload.Operations[1].DocumentID != "" || string(payload.Operations[1].Command) != `{"id":"intent_settings_1"}` {
+		t.Fatalf("unexpected workspace-level operation: %+v", payload.Operations[1])
+	}
+	if payload.Operations[0].Result != nil || !strings.Contains(string(payload.Operations[1].Result), `"settingsRev":2`) {
+		t.Fatalf("unexpected operation results: %s", response.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleListWorkspaceOperationsRejectsInvalidCursor(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1/operations?afterOpSeq=-1",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleListWorkspaceOperations(context)
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", response.Code, response.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleGetWorkspaceRejectsNonOwner(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_other")
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodGet,
+		"/api/workspaces/ws_1",
+		"",
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleGetWorkspace(context)
+
+	if response.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", response.Code, response.Body.String())
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if errorCode(payload) != ErrorWorkspaceAccessDenied {
+		t.Fatalf("unexpected error payload: %v", payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleWorkspaceWritesRejectNonOwner(t *testing.T) {
+	testCases := []struct {
+		name   string
+		path   string
+		body   string
+		params gin.Params
+		invoke func(handler *Handler, context *gin.Context)
+	}{
+		{
+			name:   "patch document",
+			path:   "/api/workspaces/ws_1/documents/doc_home",
+			body:   `{"expectedContentRev": 3, "command": {}}`,
+			params: gin.Params{{Key: "workspaceId", Value: "ws_1"}, {Key: "documentId", Value: "doc_home"}},
+			invoke: func(handler *Handler, context *gin.Context) { handler.HandlePatchWorkspaceDocument(context) },
+		},
+		{
+			name:   "intent",
+			path:   "/api/workspaces/ws_1/intents",
+			body:   `{"expectedWorkspaceRev": 9, "intent": {"id": "intent_1", "namespace": "core.settings", "type": "global.update", "version": "1.0", "payload": {"settings": {}}, "issuedAt": "2026-02-08T10:00:00Z"}}`,
+			params: gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+			invoke: func(handler *Handler, context *gin.Context) { handler.HandleApplyWorkspaceIntent(context) },
+		},
+		{
+			name:   "batch",
+			path:   "/api/workspaces/ws_1/batch",
+			body:   `{"expectedWorkspaceRev": 9, "operations": [{"op":"noop"}]}`,
+			params: gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+			invoke: func(handler *Handler, context *gin.Context) { handler.HandleApplyWorkspaceBatch(context) },
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+			defer cleanup()
+
+			expectWorkspaceOwnerQuery(mock, "ws_1", "user_other")
+
+			context, response := newWorkspaceHandlerContext(http.MethodPost, testCase.path, testCase.body, testCase.params)
+			testCase.invoke(handler, context)
+
+			if response.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d: %s", response.Code, response.Body.String())
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if errorCode(payload) != ErrorWorkspaceAccessDenied {
+				t.Fatalf("unexpected error payload: %v", payload)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("sql expectations: %v", err)
+			}
+		})
+	}
+}
+
 func errorCode(payload map[string]any) string {
 	errorPayload, ok := payload["error"].(map[string]any)
 	if !ok {
@@ -618,10 +1163,22 @@ func newWorkspaceHandlerContext(method, path, body string, params gin.Params) (*
 	return context, response
 }
 
+func expectWorkspaceOwnerQuery(mock sqlmock.Sqlmock, workspaceID string, ownerID string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT owner_id FROM workspaces WHERE id = $1`)).
+		WithArgs(workspaceID).
+		WillReturnRows(sqlmock.NewRows([]string{"owner_id"}).AddRow(ownerID))
+}
+
+func expectWorkspaceOwnerMissing(mock sqlmock.Sqlmock, workspaceID string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT owner_id FROM workspaces WHERE id = $1`)).
+		WithArgs(workspaceID).
+		WillReturnError(sql.ErrNoRows)
+}
+
 func expectWorkspaceSnapshotQueries(mock sqlmock.Sqlmock, workspaceID string) {
 	now := time.Date(2026, time.February, 8, 9, 0, 0, 0, time.UTC)
 
-	workspaceQuery := regexp.QuoteMeta(`SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json
+	workspaceQuery := regexp.QuoteMeta(`SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json, COALESCE(s.settings_rev, 1)
 FROM workspaces w
 LEFT JOIN workspace_routes r ON r.workspace_id = w.id
 LEFT JOIN workspace_settings s ON s.workspace_id = w.id
@@ -634,7 +1191,7 @@ ORDER BY path ASC`)
 	mock.ExpectQuery(workspaceQuery).
 		WithArgs(workspaceID).
 		WillReturnRows(sqlmock.NewRows([]string{
-			"id", "project_id", "owner_id", "name", "workspace_rev", "route_rev", "op_seq", "tree_root_id", "tree_json", "created_at", "updated_at", "manifest_json", "settings_json",
+			"id", "project_id", "owner_id", "name", "workspace_rev", "route_rev", "op_seq", "tree_root_id", "tree_json", "created_at", "updated_at", "manifest_json", "settings_json", "settings_rev",
 		}).AddRow(
 			workspaceID,
 			"project_1",
@@ -649,6 +1206,7 @@ ORDER BY path ASC`)
 			now,
 			[]byte(`{"version":"1","root":{"id":"root"}}`),
 			[]byte(`{"global":{"eventTriggerMode":"selected-only"},"projectGlobalById":{}}`),
+			3,
 		))
 	mock.ExpectQuery(documentQuery).
 		WithArgs(workspaceID).
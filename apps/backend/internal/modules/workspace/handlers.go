@@ -2,7 +2,10 @@ package workspace
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +31,13 @@ func (handler *Handler) Routes(requireAuth gin.HandlerFunc) RouteHandlers {
 		PatchWorkspaceDocument:   handler.HandlePatchWorkspaceDocument,
 		ApplyWorkspaceIntent:     handler.HandleApplyWorkspaceIntent,
 		ApplyWorkspaceBatch:      handler.HandleApplyWorkspaceBatch,
+		ListWorkspaceOperations:  handler.HandleListWorkspaceOperations,
+		StreamWorkspaceEvents:    handler.HandleStreamWorkspaceEvents,
+		GetWorkspaceDocument:     handler.HandleGetWorkspaceDocument,
+		ListCheckpoints:          handler.HandleListCheckpoints,
+		CreateCheckpoint:         handler.HandleCreateCheckpoint,
+		DiffCheckpoint:           handler.HandleDiffCheckpoint,
+		ResolveRoute:             handler.HandleResolveRoute,
 	}
 }
 
@@ -37,7 +47,7 @@ type documentResponse struct {
 	Path       string                `json:"path"`
 	ContentRev int64                 `json:"contentRev"`
 	MetaRev    int64                 `json:"metaRev"`
-	Content    json.RawMessage       `json:"content"`
+	Content    json.RawMessage       `json:"content,omitempty"`
 	UpdatedAt  time.Time             `json:"updatedAt"`
 }
 
@@ -45,6 +55,7 @@ type snapshotResponse struct {
 	ID            string             `json:"id"`
 	WorkspaceRev  int64              `json:"workspaceRev"`
 	RouteRev      int64              `json:"routeRev"`
+	SettingsRev   int64              `json:"settingsRev"`
 	OpSeq         int64              `json:"opSeq"`
 	Tree          json.RawMessage    `json:"tree"`
 	Documents     []documentResponse `json:"documents"`
@@ -55,6 +66,7 @@ type snapshotResponse struct {
 type PatchDocumentRequest struct {
 	ExpectedContentRev int64                    `json:"expectedContentRev"`
 	ClientMutationID   string                   `json:"clientMutationId"`
+	AutoRebase         bool                     `json:"autoRebase"`
 	Command            WorkspaceCommandEnvelope `json:"command"`
 }
 
@@ -77,6 +89,7 @@ type intentEnvelope struct {
 type ApplyIntentHTTPrequest struct {
 	ExpectedWorkspaceRev int64          `json:"expectedWorkspaceRev"`
 	ExpectedRouteRev     int64          `json:"expectedRouteRev"`
+	ExpectedSettingsRev  int64          `json:"expectedSettingsRev"`
 	Intent               intentEnvelope `json:"intent"`
 	ClientMutationID     string         `json:"clientMutationId"`
 }
@@ -84,6 +97,7 @@ type ApplyIntentHTTPrequest struct {
 type ApplyBatchRequest struct {
 	ExpectedWorkspaceRev int64             `json:"expectedWorkspaceRev"`
 	ExpectedRouteRev     int64             `json:"expectedRouteRev"`
+	ExpectedSettingsRev  int64             `json:"expectedSettingsRev"`
 	Operations           []json.RawMessage `json:"operations"`
 	ClientBatchID        string            `json:"clientBatchId"`
 }
@@ -96,6 +110,7 @@ type batchPatchDocumentOperation struct {
 	Op                 string                   `json:"op"`
 	DocumentID         string                   `json:"documentId"`
 	ExpectedContentRev int64                    `json:"expectedContentRev"`
+	AutoRebase         bool                     `json:"autoRebase"`
 	Command            WorkspaceCommandEnvelope `json:"command"`
 }
 
@@ -112,6 +127,8 @@ func toIntent(intent intentEnvelope) IntentEnvelope {
 	return IntentEnvelope{ID: intent.ID, Namespace: intent.Namespace, Type: intent.Type, Version: intent.Version, Payload: intent.Payload, IdempotencyKey: intent.IdempotencyKey, Actor: actor, IssuedAt: intent.IssuedAt}
 }
 
+// HandleGetWorkspace returns the workspace snapshot. ?include=meta leaves
+// out document content so clients can fetch documents one by one.
 func (handler *Handler) HandleGetWorkspace(c *gin.Context) {
 	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
 	user, ok := backendauth.GetAuthUser[backendauth.User](c)
@@ -119,17 +136,46 @@ func (handler *Handler) HandleGetWorkspace(c *gin.Context) {
 		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
 		return
 	}
-	snapshot, err := handler.module.GetSnapshotForUser(c.Request.Context(), user.ID, workspaceID)
+	include := strings.TrimSpace(c.Query("include"))
+	if include != "" && include != "meta" {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "include must be meta.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	metaOnly := include == "meta"
+
+	// A revalidation only needs the revision counters, so skip loading the
+	// documents when the client's copy is current. Anything unusual (a
+	// workspace that still has to be bootstrapped, a failed lookup) falls
+	// through to the full path, which reports it. Authorization runs once
+	// and is shared by both paths.
+	authErr := handler.module.AuthorizeWorkspace(c.Request.Context(), user.ID, workspaceID, WorkspaceActionRead)
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && authErr == nil {
+		head, err := handler.store.GetWorkspaceHead(c.Request.Context(), workspaceID)
+		if err == nil && etagMatches(ifNoneMatch, workspaceSnapshotETag(head.WorkspaceRev, head.OpSeq, metaOnly)) {
+			writeETag(c, workspaceSnapshotETag(head.WorkspaceRev, head.OpSeq, metaOnly))
+			return
+		}
+	}
+
+	load := handler.store.GetSnapshot
+	if metaOnly {
+		load = handler.store.GetSnapshotMeta
+	}
+	snapshot, err := handler.module.loadSnapshotForUser(c.Request.Context(), user.ID, workspaceID, authErr, load)
 	if err != nil {
 		failure := MapStoreError(err)
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
+	if writeETag(c, workspaceSnapshotETag(snapshot.Workspace.WorkspaceRev, snapshot.Workspace.OpSeq, metaOnly)) {
+		return
+	}
 	documents := make([]documentResponse, 0, len(snapshot.Documents))
 	for _, document := range snapshot.Documents {
 		documents = append(documents, documentResponse{ID: document.ID, Type: document.Type, Path: document.Path, ContentRev: document.ContentRev, MetaRev: document.MetaRev, Content: document.Content, UpdatedAt: document.UpdatedAt})
 	}
-	c.JSON(http.StatusOK, map[string]any{"workspace": snapshotResponse{ID: snapshot.Workspace.ID, WorkspaceRev: snapshot.Workspace.WorkspaceRev, RouteRev: snapshot.Workspace.RouteRev, OpSeq: snapshot.Workspace.OpSeq, Tree: snapshot.Workspace.Tree, Documents: documents, RouteManifest: snapshot.RouteManifest, Settings: snapshot.Settings}})
+	c.JSON(http.StatusOK, map[string]any{"workspace": snapshotResponse{ID: snapshot.Workspace.ID, WorkspaceRev: snapshot.Workspace.WorkspaceRev, RouteRev: snapshot.Workspace.RouteRev, SettingsRev: snapshot.SettingsRev, OpSeq: snapshot.Workspace.OpSeq, Tree: snapshot.Workspace.Tree, Documents: documents, RouteManifest: snapshot.RouteManifest, Settings: snapshot.Settings}})
 }
 
 func (handler *Handler) HandleGetWorkspaceCapabilities(c *gin.Context) {
@@ -139,12 +185,296 @@ func (handler *Handler) HandleGetWorkspaceCapabilities(c *gin.Context) {
 		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
 		return
 	}
-	if _, err := handler.module.GetSnapshotForUser(c.Request.Context(), user.ID, workspaceID); err != nil {
+	snapshot, err := handler.module.GetSnapshotForUser(c.Request.Context(), user.ID, workspaceID)
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	capabilities, intents := handler.module.Capabilities(snapshot.Settings)
+	c.JSON(http.StatusOK, map[string]any{"workspaceId": workspaceID, "capabilities": capabilities, "intents": intents})
+}
+
+func (handler *Handler) HandleListWorkspaceOperations(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	afterOpSeq, err := parseNonNegativeQueryInt(c.Query("afterOpSeq"))
+	if err != nil {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "afterOpSeq must be a non-negative integer.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	limit, err := parseNonNegativeQueryInt(c.Query("limit"))
+	if err != nil {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "limit must be a non-negative integer.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionRead) {
+		return
+	}
+	page, err := handler.store.ListOperations(c.Request.Context(), ListWorkspaceOperationsParams{
+		WorkspaceID: workspaceID,
+		AfterOpSeq:  afterOpSeq,
+		DocumentID:  c.Query("documentId"),
+		Domain:      c.Query("domain"),
+		Limit:       int(limit),
+	})
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// HandleGetWorkspaceDocument returns one document. With ?atOpSeq=N the
+// content and contentRev are rebuilt as of that opSeq. The ETag follows
+// contentRev and metaRev.
+func (handler *Handler) HandleGetWorkspaceDocument(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	documentID := strings.TrimSpace(c.Param("documentId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	rawAtOpSeq, hasAtOpSeq := c.GetQuery("atOpSeq")
+	atOpSeq, err := parseNonNegativeQueryInt(rawAtOpSeq)
+	if err != nil || (hasAtOpSeq && strings.TrimSpace(rawAtOpSeq) == "") {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "atOpSeq must be a non-negative integer.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionRead) {
+		return
+	}
+
+	var document *WorkspaceDocumentRecord
+	if hasAtOpSeq {
+		document, err = handler.store.GetDocumentAtOpSeq(c.Request.Context(), workspaceID, documentID, atOpSeq)
+	} else {
+		document, err = handler.store.GetDocument(c.Request.Context(), workspaceID, documentID)
+	}
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if writeETag(c, workspaceDocumentETag(document, atOpSeq, hasAtOpSeq)) {
+		return
+	}
+	response := map[string]any{
+		"document": documentResponse{ID: document.ID, Type: document.Type, Path: document.Path, ContentRev: document.ContentRev, MetaRev: document.MetaRev, Content: document.Content, UpdatedAt: document.UpdatedAt},
+	}
+	if hasAtOpSeq {
+		response["atOpSeq"] = atOpSeq
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type CreateCheckpointRequest struct {
+	Name string `json:"name"`
+}
+
+func (handler *Handler) HandleListCheckpoints(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionRead) {
+		return
+	}
+	checkpoints, err := handler.store.ListCheckpoints(c.Request.Context(), workspaceID)
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]any{"checkpoints": checkpoints})
+}
+
+func (handler *Handler) HandleCreateCheckpoint(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	var request CreateCheckpointRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		failure := NewRequestFailure(http.StatusBadRequest, ErrorInvalidPayload, "Invalid request payload.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxWorkspaceCheckpointNameLength {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "name must be 1-120 characters.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionWrite) {
+		return
+	}
+	checkpoint, err := handler.store.CreateCheckpoint(c.Request.Context(), CreateWorkspaceCheckpointParams{
+		WorkspaceID: workspaceID,
+		Name:        name,
+		CreatedBy:   user.ID,
+	})
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	c.JSON(http.StatusCreated, map[string]any{"checkpoint": checkpoint})
+}
+
+func (handler *Handler) HandleDiffCheckpoint(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionRead) {
+		return
+	}
+	diff, err := handler.store.DiffCheckpoint(c.Request.Context(), workspaceID, strings.TrimSpace(c.Param("checkpointId")))
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// HandleResolveRoute matches ?path= against the route manifest for the
+// preview runtime and debugger. An unmatched path is not an error: the
+// response has matched=false and the closest candidates.
+func (handler *Handler) HandleResolveRoute(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	path := strings.TrimSpace(c.Query("path"))
+	if _, err := splitRoutePath(path); err != nil {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "path must be an absolute URL path such as /users/42.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionRead) {
+		return
+	}
+	resolution, err := handler.store.ResolveRoute(c.Request.Context(), workspaceID, path)
+	if err != nil {
+		failure := MapStoreError(err)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	c.JSON(http.StatusOK, resolution)
+}
+
+// HandleStreamWorkspaceEvents pushes commit events as server-sent events.
+// Clients resume with the Last-Event-ID header or the afterOpSeq query; event
+// ids are opSeq values. Without a cursor the stream starts at the current head.
+func (handler *Handler) HandleStreamWorkspaceEvents(c *gin.Context) {
+	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
+	cursorValue := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if cursorValue == "" {
+		cursorValue = strings.TrimSpace(c.Query("afterOpSeq"))
+	}
+	cursor, err := parseNonNegativeQueryInt(cursorValue)
+	if err != nil {
+		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "afterOpSeq must be a non-negative integer.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionRead) {
+		return
+	}
+	broker := handler.module.Events()
+	if broker == nil {
+		failure := NewRequestFailure(http.StatusInternalServerError, ErrorWorkspaceOperationFailed, "Workspace event stream is unavailable.", nil)
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	ctx := c.Request.Context()
+	// Subscribe before reading the log so a commit landing in between is
+	// still delivered as a notification.
+	subscription := broker.Subscribe(workspaceID)
+	defer broker.Unsubscribe(subscription)
+
+	if cursorValue == "" {
+		head, err := handler.store.GetWorkspaceHead(ctx, workspaceID)
+		if err != nil {
+			failure := MapStoreError(err)
+			c.JSON(failure.Status, failure.Payload)
+			return
+		}
+		cursor = head.OpSeq
+	}
+	events, err := handler.store.BuildWorkspaceEvents(ctx, workspaceID, cursor, nil)
+	if err != nil {
 		failure := MapStoreError(err)
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
-	c.JSON(http.StatusOK, map[string]any{"workspaceId": workspaceID, "capabilities": DefaultCapabilities()})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	cursor = writeWorkspaceEvents(c, cursor, events)
+
+	heartbeat := time.NewTicker(workspaceEventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			_, _ = c.Writer.WriteString(": heartbeat\n\n")
+			c.Writer.Flush()
+		case latest := <-subscription.C():
+			if latest.OpSeq <= cursor {
+				continue
+			}
+			events, err := handler.store.BuildWorkspaceEvents(ctx, workspaceID, cursor, latest)
+			if err != nil {
+				// The client reconnects with Last-Event-ID and resumes from
+				// the last event it received.
+				return
+			}
+			cursor = writeWorkspaceEvents(c, cursor, events)
+		}
+	}
+}
+
+func writeWorkspaceEvents(c *gin.Context, cursor int64, events []WorkspaceEvent) int64 {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		_, _ = fmt.Fprintf(c.Writer, "id: %d\nevent: commit\ndata: %s\n\n", event.OpSeq, data)
+		cursor = event.OpSeq
+	}
+	c.Writer.Flush()
+	return cursor
 }
 
 func (handler *Handler) HandlePatchWorkspaceDocument(c *gin.Context) {
@@ -166,14 +496,36 @@ func (handler *Handler) HandlePatchWorkspaceDocument(c *gin.Context) {
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
-	result, err := handler.store.PatchDocumentContent(c.Request.Context(), PatchDocumentContentParams{WorkspaceID: workspaceID, DocumentID: documentID, ExpectedContentRev: request.ExpectedContentRev, Command: request.Command})
-	if err != nil {
-		failure := MapStoreError(err)
+	if failure := rejectHistoryCommand(request.Command); failure != nil {
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionWrite) {
+		return
+	}
+	idempotency := idempotentMutationRequest{
+		Key:         request.ClientMutationID,
+		Scope:       "patchDocument",
+		Fingerprint: map[string]any{"documentId": documentID, "expectedContentRev": request.ExpectedContentRev, "autoRebase": request.AutoRebase, "command": request.Command},
+	}
+	result, replayed, failure := runIdempotentMutation(c.Request.Context(), handler.store, workspaceID, idempotency, func(txStore *WorkspaceStore) (*WorkspaceMutationResult, *RequestFailure) {
+		patched, err := txStore.PatchDocumentContent(c.Request.Context(), PatchDocumentContentParams{WorkspaceID: workspaceID, DocumentID: documentID, ExpectedContentRev: request.ExpectedContentRev, AutoRebase: request.AutoRebase, Command: request.Command})
+		if err != nil {
+			return nil, MapStoreError(err)
+		}
+		return patched, nil
+	})
+	if failure != nil {
 		LogWorkspaceConflictFailure("patchDocument", c.Request.Method, c.FullPath(), workspaceID, documentID, 0, 0, request.ExpectedContentRev, request.ClientMutationID, failure)
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
-	handler.module.SyncProjectMirrorFromWorkspace(c.Request.Context(), user.ID, workspaceID)
+	if replayed {
+		c.Header(idempotencyReplayedHeader, "true")
+	} else {
+		handler.module.PublishCommitted(workspaceID, result)
+		handler.module.SyncProjectMirrorFromWorkspace(c.Request.Context(), user.ID, workspaceID)
+	}
 	c.JSON(http.StatusOK, BuildMutationSuccessPayload(result, strings.TrimSpace(request.ClientMutationID)))
 }
 
@@ -184,21 +536,71 @@ func (handler *Handler) HandleSaveWorkspaceDocument(c *gin.Context) {
 
 func (handler *Handler) HandleApplyWorkspaceIntent(c *gin.Context) {
 	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
+	user, ok := backendauth.GetAuthUser[backendauth.User](c)
+	if !ok {
+		backendresponse.Error(c, http.StatusUnauthorized, "API-2001", "Authentication required.")
+		return
+	}
 	var request ApplyIntentHTTPrequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		failure := NewRequestFailure(http.StatusBadRequest, ErrorInvalidPayload, "Invalid request payload.", nil)
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
-	result, failure := handler.module.ApplyIntentMutation(c.Request.Context(), workspaceID, ApplyIntentRequest{ExpectedWorkspaceRev: request.ExpectedWorkspaceRev, ExpectedRouteRev: request.ExpectedRouteRev, Intent: toIntent(request.Intent)})
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionWrite) {
+		return
+	}
+	intentRequest := ApplyIntentRequest{ExpectedWorkspaceRev: request.ExpectedWorkspaceRev, ExpectedRouteRev: request.ExpectedRouteRev, ExpectedSettingsRev: request.ExpectedSettingsRev, Intent: toIntent(request.Intent)}
+	idempotencyKey := strings.TrimSpace(request.Intent.IdempotencyKey)
+	if idempotencyKey == "" {
+		idempotencyKey = request.ClientMutationID
+	}
+	fingerprint := map[string]any{"expectedWorkspaceRev": request.ExpectedWorkspaceRev, "expectedRouteRev": request.ExpectedRouteRev, "namespace": request.Intent.Namespace, "type": request.Intent.Type, "version": request.Intent.Version, "payload": request.Intent.Payload}
+	withSettingsRevFingerprint(fingerprint, request.ExpectedSettingsRev)
+	idempotency := idempotentMutationRequest{
+		Key:         idempotencyKey,
+		Scope:       "intent",
+		Fingerprint: fingerprint,
+	}
+	result, replayed, failure := runIdempotentMutation(c.Request.Context(), handler.store, workspaceID, idempotency, func(txStore *WorkspaceStore) (*WorkspaceMutationResult, *RequestFailure) {
+		return handler.module.applyIntentMutation(c.Request.Context(), txStore, workspaceID, intentRequest)
+	})
 	if failure != nil {
 		LogWorkspaceConflictFailure("applyIntent", c.Request.Method, c.FullPath(), workspaceID, "", request.ExpectedWorkspaceRev, request.ExpectedRouteRev, 0, request.ClientMutationID, failure)
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
+	if replayed {
+		c.Header(idempotencyReplayedHeader, "true")
+	} else {
+		handler.module.PublishCommitted(workspaceID, result)
+		if touchesCanonicalDocument(result) {
+			handler.module.SyncProjectMirrorFromWorkspace(c.Request.Context(), user.ID, workspaceID)
+		}
+	}
 	c.JSON(http.StatusOK, BuildMutationSuccessPayload(result, strings.TrimSpace(request.ClientMutationID)))
 }
 
+// touchesCanonicalDocument reports whether an intent changed doc_root, the
+// document the project mirror is saved from. Other intents leave the mirror
+// as it is.
+func touchesCanonicalDocument(result *WorkspaceMutationResult) bool {
+	if result == nil {
+		return false
+	}
+	for _, document := range result.UpdatedDocuments {
+		if document.ID == canonicalWorkspaceDocumentID {
+			return true
+		}
+	}
+	for _, documentID := range result.RemovedDocuments {
+		if documentID == canonicalWorkspaceDocumentID {
+			return true
+		}
+	}
+	return false
+}
+
 func (handler *Handler) HandleApplyWorkspaceBatch(c *gin.Context) {
 	workspaceID := strings.TrimSpace(c.Param("workspaceId"))
 	user, ok := backendauth.GetAuthUser[backendauth.User](c)
@@ -222,72 +624,183 @@ func (handler *Handler) HandleApplyWorkspaceBatch(c *gin.Context) {
 		c.JSON(failure.Status, failure.Payload)
 		return
 	}
-	currentWorkspaceRev := request.ExpectedWorkspaceRev
-	currentRouteRev := request.ExpectedRouteRev
-	var latest *WorkspaceMutationResult
-	for index, operationRaw := range request.Operations {
+	if !handler.authorizeWorkspace(c, user.ID, workspaceID, WorkspaceActionWrite) {
+		return
+	}
+	operations, failure := parseBatchOperations(request.Operations)
+	if failure != nil {
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	// Per-intent idempotency keys inside a batch are not tracked separately;
+	// the batch is replayed as a whole through clientBatchId.
+	fingerprint := map[string]any{"expectedWorkspaceRev": request.ExpectedWorkspaceRev, "expectedRouteRev": request.ExpectedRouteRev, "operations": request.Operations}
+	withSettingsRevFingerprint(fingerprint, request.ExpectedSettingsRev)
+	idempotency := idempotentMutationRequest{
+		Key:         request.ClientBatchID,
+		Scope:       "batch",
+		Fingerprint: fingerprint,
+	}
+	outcome, replayed, failure := runIdempotentMutation(c.Request.Context(), handler.store, workspaceID, idempotency, func(txStore *WorkspaceStore) (*WorkspaceBatchResult, *RequestFailure) {
+		var outcome *WorkspaceBatchResult
+		var failure *RequestFailure
+		err := txStore.RunInTx(c.Request.Context(), func(batchStore *WorkspaceStore) error {
+			outcome, failure = handler.applyBatchOperations(c, batchStore, workspaceID, request, operations)
+			if failure != nil {
+				return errWorkspaceMutationAborted
+			}
+			return nil
+		})
+		if failure != nil {
+			return nil, failure
+		}
+		if err != nil {
+			return nil, MapStoreError(err)
+		}
+		return outcome, nil
+	})
+	if failure != nil {
+		c.JSON(failure.Status, failure.Payload)
+		return
+	}
+	if replayed {
+		c.Header(idempotencyReplayedHeader, "true")
+	} else {
+		handler.module.PublishCommitted(workspaceID, outcome.committedResult())
+		handler.module.SyncProjectMirrorFromWorkspace(c.Request.Context(), user.ID, workspaceID)
+	}
+	c.JSON(http.StatusOK, BuildBatchSuccessPayload(outcome, strings.TrimSpace(request.ClientBatchID)))
+}
+
+type parsedBatchOperation struct {
+	Op            string
+	PatchDocument batchPatchDocumentOperation
+	Intent        IntentEnvelope
+}
+
+// parseBatchOperations validates the shape of every operation up front so a
+// malformed batch is rejected before any transaction is opened.
+func parseBatchOperations(operations []json.RawMessage) ([]parsedBatchOperation, *RequestFailure) {
+	parsed := make([]parsedBatchOperation, 0, len(operations))
+	for index, operationRaw := range operations {
 		var operationKind batchOperationKind
 		if err := json.Unmarshal(operationRaw, &operationKind); err != nil {
-			failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Invalid batch operation payload.", map[string]any{"index": index})
-			c.JSON(failure.Status, failure.Payload)
-			return
+			return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Invalid batch operation payload.", map[string]any{"index": index})
 		}
-		switch strings.TrimSpace(operationKind.Op) {
+		operationName := strings.TrimSpace(operationKind.Op)
+		switch operationName {
 		case "patchDocument":
 			var operation batchPatchDocumentOperation
 			if err := json.Unmarshal(operationRaw, &operation); err != nil {
-				failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Invalid patchDocument operation payload.", map[string]any{"index": index})
-				c.JSON(failure.Status, failure.Payload)
-				return
+				return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Invalid patchDocument operation payload.", map[string]any{"index": index})
 			}
-			documentID := strings.TrimSpace(operation.DocumentID)
-			if documentID == "" {
-				failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "patchDocument operation requires documentId.", map[string]any{"index": index})
-				c.JSON(failure.Status, failure.Payload)
-				return
+			operation.DocumentID = strings.TrimSpace(operation.DocumentID)
+			if operation.DocumentID == "" {
+				return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "patchDocument operation requires documentId.", map[string]any{"index": index})
 			}
 			if operation.ExpectedContentRev <= 0 {
-				failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "patchDocument operation requires expectedContentRev > 0.", map[string]any{"index": index})
-				c.JSON(failure.Status, failure.Payload)
-				return
+				return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "patchDocument operation requires expectedContentRev > 0.", map[string]any{"index": index})
 			}
-			result, err := handler.store.PatchDocumentContent(c.Request.Context(), PatchDocumentContentParams{WorkspaceID: workspaceID, DocumentID: documentID, ExpectedContentRev: operation.ExpectedContentRev, Command: operation.Command})
-			if err != nil {
-				failure := MapStoreError(err)
-				LogWorkspaceConflictFailure("batch.patchDocument", c.Request.Method, c.FullPath(), workspaceID, documentID, currentWorkspaceRev, currentRouteRev, operation.ExpectedContentRev, request.ClientBatchID, failure)
-				c.JSON(failure.Status, failure.Payload)
-				return
+			if failure := rejectHistoryCommand(operation.Command); failure != nil {
+				return nil, withBatchOperationIndex(failure, index)
 			}
-			latest = result
-			currentWorkspaceRev = result.WorkspaceRev
-			currentRouteRev = result.RouteRev
+			parsed = append(parsed, parsedBatchOperation{Op: operationName, PatchDocument: operation})
 		case "intent":
 			var operation batchIntentOperation
 			if err := json.Unmarshal(operationRaw, &operation); err != nil {
-				failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Invalid intent operation payload.", map[string]any{"index": index})
-				c.JSON(failure.Status, failure.Payload)
-				return
+				return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Invalid intent operation payload.", map[string]any{"index": index})
 			}
-			result, failure := handler.module.ApplyIntentMutation(c.Request.Context(), workspaceID, ApplyIntentRequest{ExpectedWorkspaceRev: currentWorkspaceRev, ExpectedRouteRev: currentRouteRev, Intent: toIntent(operation.Intent)})
+			parsed = append(parsed, parsedBatchOperation{Op: operationName, Intent: toIntent(operation.Intent)})
+		default:
+			return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Unsupported batch operation.", map[string]any{"index": index, "op": operationName})
+		}
+	}
+	return parsed, nil
+}
+
+// applyBatchOperations runs every batch operation against txStore. The caller
+// owns the transaction, so returning a failure rolls back operations that
+// already succeeded earlier in the batch.
+func (handler *Handler) applyBatchOperations(c *gin.Context, txStore *WorkspaceStore, workspaceID string, request ApplyBatchRequest, operations []parsedBatchOperation) (*WorkspaceBatchResult, *RequestFailure) {
+	ctx := c.Request.Context()
+	currentWorkspaceRev := request.ExpectedWorkspaceRev
+	currentRouteRev := request.ExpectedRouteRev
+	currentSettingsRev := request.ExpectedSettingsRev
+	outcome := &WorkspaceBatchResult{Operations: make([]WorkspaceBatchOperationResult, 0, len(operations))}
+	for index, operation := range operations {
+		var result *WorkspaceMutationResult
+		switch operation.Op {
+		case "patchDocument":
+			patch := operation.PatchDocument
+			patched, err := txStore.PatchDocumentContent(ctx, PatchDocumentContentParams{WorkspaceID: workspaceID, DocumentID: patch.DocumentID, ExpectedContentRev: patch.ExpectedContentRev, AutoRebase: patch.AutoRebase, Command: patch.Command})
+			if err != nil {
+				failure := withBatchOperationIndex(MapStoreError(err), index)
+				LogWorkspaceConflictFailure("batch.patchDocument", c.Request.Method, c.FullPath(), workspaceID, patch.DocumentID, currentWorkspaceRev, currentRouteRev, patch.ExpectedContentRev, request.ClientBatchID, failure)
+				return nil, failure
+			}
+			result = patched
+		case "intent":
+			applied, failure := handler.module.applyIntentMutation(ctx, txStore, workspaceID, ApplyIntentRequest{ExpectedWorkspaceRev: currentWorkspaceRev, ExpectedRouteRev: currentRouteRev, ExpectedSettingsRev: currentSettingsRev, Intent: operation.Intent})
 			if failure != nil {
+				failure = withBatchOperationIndex(failure, index)
 				LogWorkspaceConflictFailure("batch.intent", c.Request.Method, c.FullPath(), workspaceID, "", currentWorkspaceRev, currentRouteRev, 0, request.ClientBatchID, failure)
-				c.JSON(failure.Status, failure.Payload)
-				return
+				return nil, failure
 			}
-			latest = result
-			currentWorkspaceRev = result.WorkspaceRev
-			currentRouteRev = result.RouteRev
-		default:
-			failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Unsupported batch operation.", map[string]any{"index": index, "op": strings.TrimSpace(operationKind.Op)})
-			c.JSON(failure.Status, failure.Payload)
-			return
+			result = applied
 		}
+		outcome.append(index, operation.Op, result)
+		currentWorkspaceRev = result.WorkspaceRev
+		currentRouteRev = result.RouteRev
+		if result.SettingsRev > 0 {
+			currentSettingsRev = result.SettingsRev
+		}
+	}
+	if outcome.Latest == nil {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Batch did not include executable operations.", nil)
 	}
-	if latest == nil {
-		failure := NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "Batch did not include executable operations.", nil)
+	return outcome, nil
+}
+
+func (handler *Handler) authorizeWorkspace(c *gin.Context, userID string, workspaceID string, action WorkspaceAction) bool {
+	if err := handler.module.AuthorizeWorkspace(c.Request.Context(), userID, workspaceID, action); err != nil {
+		failure := MapStoreError(err)
 		c.JSON(failure.Status, failure.Payload)
-		return
+		return false
+	}
+	return true
+}
+
+// withSettingsRevFingerprint adds expectedSettingsRev to an idempotency
+// fingerprint only when it is set, so keys stored by requests that predate
+// the settings partition still replay.
+func withSettingsRevFingerprint(fingerprint map[string]any, expectedSettingsRev int64) {
+	if expectedSettingsRev > 0 {
+		fingerprint["expectedSettingsRev"] = expectedSettingsRev
+	}
+}
+
+// rejectHistoryCommand keeps clients from forging core.history commands,
+// which the server derives undo/redo stacks from.
+func rejectHistoryCommand(command WorkspaceCommandEnvelope) *RequestFailure {
+	if strings.TrimSpace(command.Namespace) != historyNamespace {
+		return nil
+	}
+	return NewRequestFailure(http.StatusUnprocessableEntity, ErrorReservedDomain, "core.history commands are issued by the server; use the core.history.undo/redo intents.", mapKV("namespace", historyNamespace))
+}
+
+// parseNonNegativeQueryInt treats an empty value as zero so optional query
+// parameters can share one parser.
+func parseNonNegativeQueryInt(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if parsed < 0 {
+		return 0, errors.New("value must not be negative")
 	}
-	handler.module.SyncProjectMirrorFromWorkspace(c.Request.Context(), user.ID, workspaceID)
-	c.JSON(http.StatusOK, BuildMutationSuccessPayload(latest, strings.TrimSpace(request.ClientBatchID)))
+	return parsed, nil
 }
@@ -0,0 +1,153 @@
+package workspace
+
+import (
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+// Store tests run against workspace ws_1; rows were last written at
+// testUpdatedAt.
+var testUpdatedAt = time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC)
+
+const workspaceRowLockQuery = `SELECT id FROM workspaces WHERE id = $1 FOR UPDATE`
+
+const documentLockQuery = `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1 AND id = $2
+FOR UPDATE`
+
+const documentContentLockQuery = `SELECT d.doc_type, d.content_json, d.content_rev, d.meta_rev, w.workspace_rev, w.route_rev, w.op_seq
+FROM workspace_documents d
+JOIN workspaces w ON w.id = d.workspace_id
+WHERE d.workspace_id = $1 AND d.id = $2
+FOR UPDATE OF d, w`
+
+const workspaceStateLockQuery = `SELECT w.workspace_rev, w.route_rev, w.op_seq, w.tree_json, r.manifest_json, s.settings_json
+FROM workspaces w
+LEFT JOIN workspace_routes r ON r.workspace_id = w.id
+LEFT JOIN workspace_settings s ON s.workspace_id = w.id
+WHERE w.id = $1
+FOR UPDATE OF w`
+
+const workspaceStateDocumentsQuery = `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, NULL, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1
+ORDER BY id ASC`
+
+const settingsLockQuery = `SELECT w.workspace_rev, w.route_rev, w.op_seq, s.settings_json, COALESCE(s.settings_rev, 1)
+FROM workspaces w
+LEFT JOIN workspace_settings s ON s.workspace_id = w.id
+WHERE w.id = $1
+FOR UPDATE OF w`
+
+const workspaceSettingsQuery = `SELECT settings_json
+FROM workspace_settings
+WHERE workspace_id = $1`
+
+// testDocument is a workspace_documents row of ws_1.
+type testDocument struct {
+	id         string
+	docType    WorkspaceDocumentType
+	name       string
+	path       string
+	contentRev int64
+	metaRev    int64
+	content    string
+}
+
+// testHead is the workspaces row a lock reads.
+type testHead struct {
+	workspaceRev int64
+	routeRev     int64
+	opSeq        int64
+}
+
+func testDocumentRows(documents ...testDocument) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"workspace_id", "id", "doc_type", "name", "path", "content_rev", "meta_rev", "content_json", "updated_at"})
+	for _, document := range documents {
+		var content any
+		if document.content != "" {
+			content = []byte(document.content)
+		}
+		rows.AddRow("ws_1", document.id, string(document.docType), document.name, document.path, document.contentRev, document.metaRev, content, testUpdatedAt)
+	}
+	return rows
+}
+
+// expectWorkspaceRowLock expects the workspace row lock content writers take
+// before they lock a document.
+func expectWorkspaceRowLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(workspaceRowLockQuery)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("ws_1"))
+}
+
+// expectDocumentLock expects document to be read under FOR UPDATE, as
+// intents do before building their ops.
+func expectDocumentLock(mock sqlmock.Sqlmock, document testDocument) {
+	mock.ExpectQuery(regexp.QuoteMeta(documentLockQuery)).
+		WithArgs("ws_1", document.id).
+		WillReturnRows(testDocumentRows(document))
+}
+
+// expectDocumentContentLock expects PatchDocumentContent to lock document
+// together with the workspace row.
+func expectDocumentContentLock(mock sqlmock.Sqlmock, document testDocument, head testHead) {
+	mock.ExpectQuery(regexp.QuoteMeta(documentContentLockQuery)).
+		WithArgs("ws_1", document.id).
+		WillReturnRows(sqlmock.NewRows([]string{"doc_type", "content_json", "content_rev", "meta_rev", "workspace_rev", "route_rev", "op_seq"}).
+			AddRow(string(document.docType), []byte(document.content), document.contentRev, document.metaRev, head.workspaceRev, head.routeRev, head.opSeq))
+}
+
+// expectWorkspaceStateLock expects lockWorkspaceState to read ws_1 with the
+// given tree, route manifest and document metadata. Content is read later
+// through expectDocumentLock for the documents a command touches.
+func expectWorkspaceStateLock(mock sqlmock.Sqlmock, head testHead, tree string, manifest string, documents ...testDocument) {
+	metadata := make([]testDocument, len(documents))
+	for index, document := range documents {
+		document.content = ""
+		metadata[index] = document
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(workspaceStateLockQuery)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq", "tree_json", "manifest_json", "settings_json"}).
+			AddRow(head.workspaceRev, head.routeRev, head.opSeq, []byte(tree), []byte(manifest), []byte(`{}`)))
+	mock.ExpectQuery(regexp.QuoteMeta(workspaceStateDocumentsQuery)).
+		WithArgs("ws_1").
+		WillReturnRows(testDocumentRows(metadata...))
+}
+
+// expectSettingsLock expects SaveWorkspaceSettings and settings intents to
+// lock ws_1; an empty settings string means no settings row yet.
+func expectSettingsLock(mock sqlmock.Sqlmock, head testHead, settings string, settingsRev int64) {
+	var settingsJSON any
+	if settings != "" {
+		settingsJSON = []byte(settings)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(settingsLockQuery)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq", "settings_json", "settings_rev"}).
+			AddRow(head.workspaceRev, head.routeRev, head.opSeq, settingsJSON, settingsRev))
+}
+
+// expectWorkspaceSettingsRead serves the settings dispatch reads for the
+// workspace capability flags.
+func expectWorkspaceSettingsRead(mock sqlmock.Sqlmock, settings string) {
+	mock.ExpectQuery(regexp.QuoteMeta(workspaceSettingsQuery)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"settings_json"}).AddRow([]byte(settings)))
+}
+
+// testCommand builds a ws_1 command envelope without ops.
+func testCommand(namespace string, commandType string, issuedAt time.Time) WorkspaceCommandEnvelope {
+	return WorkspaceCommandEnvelope{
+		ID:        "intent_" + commandType,
+		Namespace: namespace,
+		Type:      commandType,
+		Version:   "1.0",
+		IssuedAt:  issuedAt,
+		Target:    WorkspaceCommandTarget{WorkspaceID: "ws_1"},
+	}
+}
@@ -0,0 +1,537 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	historyNamespace  = "core.history"
+	historyActionUndo = "undo"
+	historyActionRedo = "redo"
+	historyScanWindow = 1000
+)
+
+var ErrWorkspaceHistoryEmpty = errors.New("nothing to undo or redo in this history scope")
+
+// WorkspaceCommandHistory links a server-issued core.history command to the
+// command it undid or redid. Only commands in the core.history namespace
+// carry it; it is ignored on client-submitted commands.
+type WorkspaceCommandHistory struct {
+	Action    string `json:"action"`
+	Namespace string `json:"namespace"`
+	OpSeq     int64  `json:"opSeq"`
+}
+
+// WorkspaceHistoryConflictError reports operations outside the history scope
+// that touched the same paths after the command being undone or redone.
+type WorkspaceHistoryConflictError struct {
+	WorkspaceID string
+	DocumentID  string
+	Action      string
+	TargetOpSeq int64
+	Conflicts   []WorkspaceHistoryConflict
+}
+
+type WorkspaceHistoryConflict struct {
+	OpSeq  int64    `json:"opSeq"`
+	Domain string   `json:"domain"`
+	Paths  []string `json:"paths"`
+}
+
+func (err *WorkspaceHistoryConflictError) Error() string {
+	return fmt.Sprintf("workspace history conflict: %s of op %d on document %s overlaps later operations", err.Action, err.TargetOpSeq, err.DocumentID)
+}
+
+type historyEntry struct {
+	opSeq   int64
+	domain  string
+	command WorkspaceCommandEnvelope
+}
+
+// historyScope replays the operation log of one document and rebuilds the
+// undo and redo stacks of a single namespace, following the
+// workspace + document + namespace scope of the command-history spec.
+type historyScope struct {
+	namespace string
+	entries   []historyEntry
+	// commands holds every in-scope command by the opSeq it was first applied at.
+	commands  map[int64]WorkspaceCommandEnvelope
+	undoStack []int64
+	redoStack []int64
+	// appliedAt/undoneAt record the latest opSeq that applied or reverted a command.
+	appliedAt map[int64]int64
+	undoneAt  map[int64]int64
+}
+
+func buildHistoryScope(namespace string, entries []historyEntry) *historyScope {
+	scope := &historyScope{
+		namespace: namespace,
+		entries:   entries,
+		commands:  make(map[int64]WorkspaceCommandEnvelope),
+		appliedAt: make(map[int64]int64),
+		undoneAt:  make(map[int64]int64),
+	}
+	for _, entry := range entries {
+		if history := historyRef(entry.command); history != nil {
+			if history.Namespace != namespace {
+				continue
+			}
+			switch history.Action {
+			case historyActionUndo:
+				if top, ok := peekHistoryStack(scope.undoStack); ok && top == history.OpSeq {
+					scope.undoStack = scope.undoStack[:len(scope.undoStack)-1]
+					scope.redoStack = append(scope.redoStack, history.OpSeq)
+					scope.undoneAt[history.OpSeq] = entry.opSeq
+				}
+			case historyActionRedo:
+				if top, ok := peekHistoryStack(scope.redoStack); ok && top == history.OpSeq {
+					scope.redoStack = scope.redoStack[:len(scope.redoStack)-1]
+					scope.undoStack = append(scope.undoStack, history.OpSeq)
+					scope.appliedAt[history.OpSeq] = entry.opSeq
+				}
+			}
+			continue
+		}
+		if entry.command.Namespace != namespace || len(entry.command.ForwardOps) == 0 || len(entry.command.ReverseOps) == 0 {
+			continue
+		}
+		scope.commands[entry.opSeq] = entry.command
+		scope.appliedAt[entry.opSeq] = entry.opSeq
+		scope.undoStack = append(scope.undoStack, entry.opSeq)
+		scope.redoStack = nil
+	}
+	return scope
+}
+
+// next returns the command to revert (undo) or re-apply (redo) and the ops
+// that do so.
+func (scope *historyScope) next(action string) (int64, []WorkspacePatchOp, []WorkspacePatchOp, error) {
+	stack := scope.undoStack
+	if action == historyActionRedo {
+		stack = scope.redoStack
+	}
+	target, ok := peekHistoryStack(stack)
+	if !ok {
+		return 0, nil, nil, ErrWorkspaceHistoryEmpty
+	}
+	command := scope.commands[target]
+	if action == historyActionRedo {
+		return target, command.ForwardOps, command.ReverseOps, nil
+	}
+	return target, command.ReverseOps, command.ForwardOps, nil
+}
+
+// conflicts lists later operations outside the scope whose paths overlap the
+// target command. In-scope operations after the target always net out: the
+// target is on top of its stack, so anything above it was already reverted.
+func (scope *historyScope) conflicts(action string, target int64) []WorkspaceHistoryConflict {
+	since := scope.appliedAt[target]
+	if action == historyActionRedo {
+		since = scope.undoneAt[target]
+	}
+	targetPaths := historyAffectedPaths(scope.commands[target].ForwardOps)
+	conflicts := make([]WorkspaceHistoryConflict, 0)
+	for _, entry := range scope.entries {
+		if entry.opSeq <= since || scope.inScope(entry.command) {
+			continue
+		}
+		overlapping := make([]string, 0)
+		for _, path := range historyAffectedPaths(entry.command.ForwardOps) {
+			for _, targetPath := range targetPaths {
+				if jsonPointersOverlap(path, targetPath) {
+					overlapping = append(overlapping, path)
+					break
+				}
+			}
+		}
+		if len(overlapping) > 0 {
+			conflicts = append(conflicts, WorkspaceHistoryConflict{OpSeq: entry.opSeq, Domain: entry.domain, Paths: overlapping})
+		}
+	}
+	return conflicts
+}
+
+func (scope *historyScope) inScope(command WorkspaceCommandEnvelope) bool {
+	if history := historyRef(command); history != nil {
+		return history.Namespace == scope.namespace
+	}
+	return command.Namespace == scope.namespace
+}
+
+func historyRef(command WorkspaceCommandEnvelope) *WorkspaceCommandHistory {
+	if command.Namespace != historyNamespace {
+		return nil
+	}
+	return command.History
+}
+
+func peekHistoryStack(stack []int64) (int64, bool) {
+	if len(stack) == 0 {
+		return 0, false
+	}
+	return stack[len(stack)-1], true
+}
+
+// historyAffectedPaths returns the pointers an op list writes to. Array
+// inserts and removals shift their siblings, so they count as touching the
+// whole array.
+func historyAffectedPaths(ops []WorkspacePatchOp) []string {
+	seen := make(map[string]bool)
+	paths := make([]string, 0, len(ops))
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, op := range ops {
+		switch op.Op {
+		case "test":
+			continue
+		case "add", "remove", "move", "copy":
+			add(arrayParentPointer(op.Path))
+			if op.Op == "move" {
+				add(arrayParentPointer(op.From))
+			}
+		default:
+			add(op.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func arrayParentPointer(path string) string {
+	index := strings.LastIndex(path, "/")
+	if index < 0 {
+		return path
+	}
+	last := path[index+1:]
+	if last == "-" {
+		return path[:index]
+	}
+	if _, err := strconv.Atoi(last); err == nil {
+		return path[:index]
+	}
+	return path
+}
+
+// jsonPointersOverlap reports whether one pointer addresses the other or
+// one of its ancestors.
+func jsonPointersOverlap(left string, right string) bool {
+	if left == right || left == "" || right == "" {
+		return true
+	}
+	return strings.HasPrefix(left, right+"/") || strings.HasPrefix(right, left+"/")
+}
+
+type ApplyHistoryParams struct {
+	WorkspaceID string
+	// DocumentID is empty for the workspace-level scope, which only exists
+	// for namespace core.workspace.
+	DocumentID           string
+	Namespace            string
+	Action               string
+	ExpectedContentRev   int64
+	ExpectedWorkspaceRev int64
+	Command              WorkspaceCommandEnvelope
+}
+
+// ApplyHistory undoes or redoes the most recent command of one history scope
+// by committing its reverse (or forward) ops as a new core.history command.
+func (store *WorkspaceStore) ApplyHistory(ctx context.Context, params ApplyHistoryParams) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	if params.DocumentID == "" {
+		return store.applyWorkspaceHistory(ctx, params)
+	}
+	if params.ExpectedContentRev <= 0 {
+		return nil, errors.New("expectedContentRev must be positive")
+	}
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		if err := txStore.lockHistoryDocument(ctx, params.WorkspaceID, params.DocumentID); err != nil {
+			return err
+		}
+		entries, err := txStore.listDocumentHistoryEntries(ctx, params.WorkspaceID, params.DocumentID)
+		if err != nil {
+			return err
+		}
+		scope := buildHistoryScope(params.Namespace, entries)
+		target, forwardOps, reverseOps, err := scope.next(params.Action)
+		if err != nil {
+			return err
+		}
+		if conflicts := scope.conflicts(params.Action, target); len(conflicts) > 0 {
+			return &WorkspaceHistoryConflictError{WorkspaceID: params.WorkspaceID, DocumentID: params.DocumentID, Action: params.Action, TargetOpSeq: target, Conflicts: conflicts}
+		}
+		command := params.Command
+		command.ForwardOps = forwardOps
+		command.ReverseOps = reverseOps
+		command.Target.DocumentID = params.DocumentID
+		command.History = &WorkspaceCommandHistory{Action: params.Action, Namespace: params.Namespace, OpSeq: target}
+		result, err = txStore.PatchDocumentContent(ctx, PatchDocumentContentParams{
+			WorkspaceID:        params.WorkspaceID,
+			DocumentID:         params.DocumentID,
+			ExpectedContentRev: params.ExpectedContentRev,
+			Command:            command,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// applyWorkspaceHistory undoes or redoes workspace-level core.workspace
+// commands, whose ops patch the workspace state (see workspaceState).
+func (store *WorkspaceStore) applyWorkspaceHistory(ctx context.Context, params ApplyHistoryParams) (*WorkspaceMutationResult, error) {
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		locked, err := txStore.lockWorkspaceState(ctx, params.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		entries, err := txStore.listWorkspaceHistoryEntries(ctx, params.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		scope := buildHistoryScope(params.Namespace, entries)
+		target, forwardOps, reverseOps, err := scope.next(params.Action)
+		if err != nil {
+			return err
+		}
+		if conflicts := scope.conflicts(params.Action, target); len(conflicts) > 0 {
+			return &WorkspaceHistoryConflictError{WorkspaceID: params.WorkspaceID, Action: params.Action, TargetOpSeq: target, Conflicts: conflicts}
+		}
+		command := params.Command
+		command.ForwardOps = forwardOps
+		command.ReverseOps = reverseOps
+		command.Target.DocumentID = ""
+		command.History = &WorkspaceCommandHistory{Action: params.Action, Namespace: params.Namespace, OpSeq: target}
+		result, err = txStore.applyLockedWorkspaceState(ctx, ApplyWorkspaceStateParams{
+			WorkspaceID:          params.WorkspaceID,
+			ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+			Command:              command,
+		}, locked)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// listWorkspaceHistoryEntries loads the most recent historyScanWindow
+// operations of the whole workspace in ascending order, with every op moved
+// onto workspace state paths: document content ops are prefixed with
+// /documents/<id>/content, route and settings commands count as touching
+// /routeManifest and /settings. core.workspace commands already use state
+// paths.
+func (store *WorkspaceStore) listWorkspaceHistoryEntries(ctx context.Context, workspaceID string) ([]historyEntry, error) {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT op_seq, domain, document_id, payload_json
+FROM workspace_operations
+WHERE workspace_id = $1
+ORDER BY op_seq DESC
+LIMIT $2`
+	rows, err := store.conn().QueryContext(ctx, query, workspaceID, historyScanWindow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]historyEntry, 0)
+	for rows.Next() {
+		var entry historyEntry
+		var documentID sql.NullString
+		var payload []byte
+		if err := rows.Scan(&entry.opSeq, &entry.domain, &documentID, &payload); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &entry.command); err != nil {
+			return nil, err
+		}
+		entry.command.ForwardOps = workspaceStateOps(entry.command, documentID.String)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for left, right := 0, len(entries)-1; left < right; left, right = left+1, right-1 {
+		entries[left], entries[right] = entries[right], entries[left]
+	}
+	return entries, nil
+}
+
+func workspaceStateOps(command WorkspaceCommandEnvelope, documentID string) []WorkspacePatchOp {
+	namespace := command.Namespace
+	if history := historyRef(command); history != nil {
+		namespace = history.Namespace
+	}
+	if namespace == "core.workspace" {
+		return command.ForwardOps
+	}
+	if documentID != "" {
+		return prefixWorkspaceStateOps("/documents/"+escapeJSONPointerSegment(documentID)+"/content", command.ForwardOps)
+	}
+	switch namespace {
+	case "core.route":
+		return []WorkspacePatchOp{{Op: "replace", Path: "/routeManifest"}}
+	case "core.settings":
+		// global.patch logs its ops; global.update replaces the settings.
+		if command.Type != "global.update" && len(command.ForwardOps) > 0 {
+			return prefixWorkspaceStateOps("/settings", command.ForwardOps)
+		}
+		return []WorkspacePatchOp{{Op: "replace", Path: "/settings"}}
+	default:
+		return []WorkspacePatchOp{{Op: "replace", Path: ""}}
+	}
+}
+
+func prefixWorkspaceStateOps(prefix string, forwardOps []WorkspacePatchOp) []WorkspacePatchOp {
+	ops := make([]WorkspacePatchOp, 0, len(forwardOps))
+	for _, op := range forwardOps {
+		op.Path = prefix + op.Path
+		if op.From != "" {
+			op.From = prefix + op.From
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// lockHistoryDocument locks the workspace row and then the document, so
+// the history read below cannot move before the undo or redo commits.
+func (store *WorkspaceStore) lockHistoryDocument(ctx context.Context, workspaceID string, documentID string) error {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	if err := store.lockWorkspaceRow(ctx, workspaceID); err != nil {
+		return err
+	}
+	const query = `SELECT id FROM workspace_documents WHERE workspace_id = $1 AND id = $2 FOR UPDATE`
+	var id string
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID, documentID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWorkspaceDocumentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// listDocumentHistoryEntries loads the most recent historyScanWindow
+// operations of a document in ascending order. Commands older than the
+// window can no longer be undone.
+func (store *WorkspaceStore) listDocumentHistoryEntries(ctx context.Context, workspaceID string, documentID string) ([]historyEntry, error) {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT op_seq, domain, payload_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND document_id = $2
+ORDER BY op_seq DESC
+LIMIT $3`
+	rows, err := store.conn().QueryContext(ctx, query, workspaceID, documentID, historyScanWindow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := scanHistoryEntries(rows)
+	if err != nil {
+		return nil, err
+	}
+	for left, right := 0, len(entries)-1; left < right; left, right = left+1, right-1 {
+		entries[left], entries[right] = entries[right], entries[left]
+	}
+	return entries, nil
+}
+
+func scanHistoryEntries(rows *sql.Rows) ([]historyEntry, error) {
+	entries := make([]historyEntry, 0)
+	for rows.Next() {
+		var entry historyEntry
+		var payload []byte
+		if err := rows.Scan(&entry.opSeq, &entry.domain, &payload); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &entry.command); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+type historyIntentHandler struct{}
+
+func (historyIntentHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{
+		intentV1(historyNamespace, historyActionUndo, RevPartitionWorkspace, RevPartitionContent),
+		intentV1(historyNamespace, historyActionRedo, RevPartitionWorkspace, RevPartitionContent),
+	}
+}
+
+func (historyIntentHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	intent IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID         string `json:"documentId"`
+		Namespace          string `json:"namespace"`
+		ExpectedContentRev int64  `json:"expectedContentRev"`
+	}
+	if len(request.Intent.Payload) == 0 ||
+		json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.Namespace) == "" ||
+		(strings.TrimSpace(payload.DocumentID) == "" && strings.TrimSpace(payload.Namespace) != "core.workspace") {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.namespace is required, and payload.documentId unless namespace is core.workspace.",
+			nil,
+		)
+	}
+	if strings.TrimSpace(payload.DocumentID) != "" && payload.ExpectedContentRev <= 0 {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.expectedContentRev is required for document history.",
+			nil,
+		)
+	}
+	result, err := store.ApplyHistory(ctx, ApplyHistoryParams{
+		WorkspaceID:          workspaceID,
+		DocumentID:           strings.TrimSpace(payload.DocumentID),
+		Namespace:            strings.TrimSpace(payload.Namespace),
+		Action:               intent.Type,
+		ExpectedContentRev:   payload.ExpectedContentRev,
+		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		Command:              command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,165 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func historyTestCommand(namespace string, path string, next string, prev string) WorkspaceCommandEnvelope {
+	return WorkspaceCommandEnvelope{
+		Namespace:  namespace,
+		Type:       "update",
+		Version:    "1.0",
+		ForwardOps: []WorkspacePatchOp{{Op: "replace", Path: path, Value: json.RawMessage(next)}},
+		ReverseOps: []WorkspacePatchOp{{Op: "replace", Path: path, Value: json.RawMessage(prev)}},
+	}
+}
+
+func historyTestRecord(action string, namespace string, target int64, forward []WorkspacePatchOp) WorkspaceCommandEnvelope {
+	return WorkspaceCommandEnvelope{
+		Namespace:  historyNamespace,
+		Type:       action,
+		Version:    "1.0",
+		ForwardOps: forward,
+		History:    &WorkspaceCommandHistory{Action: action, Namespace: namespace, OpSeq: target},
+	}
+}
+
+func TestHistoryScopeTracksUndoAndRedoStacks(t *testing.T) {
+	first := historyTestCommand("core.mir", "/ui/graph/nodesById/a/props/title", `"b"`, `"a"`)
+	second := historyTestCommand("core.mir", "/ui/graph/nodesById/a/props/color", `"red"`, `"blue"`)
+	entries := []historyEntry{
+		{opSeq: 1, command: first},
+		{opSeq: 2, command: second},
+		{opSeq: 3, command: historyTestRecord(historyActionUndo, "core.mir", 2, second.ReverseOps)},
+	}
+
+	scope := buildHistoryScope("core.mir", entries)
+	target, forward, reverse, err := scope.next(historyActionUndo)
+	if err != nil || target != 1 || forward[0].Path != first.ReverseOps[0].Path || string(reverse[0].Value) != `"b"` {
+		t.Fatalf("expected to undo op 1 next, got target=%d forward=%+v err=%v", target, forward, err)
+	}
+	target, forward, _, err = scope.next(historyActionRedo)
+	if err != nil || target != 2 || string(forward[0].Value) != `"red"` {
+		t.Fatalf("expected to redo op 2 next, got target=%d forward=%+v err=%v", target, forward, err)
+	}
+
+	// A new command in the scope clears the redo stack.
+	entries = append(entries, historyEntry{opSeq: 4, command: historyTestCommand("core.mir", "/ui/graph/nodesById/b", `{}`, `null`)})
+	scope = buildHistoryScope("core.mir", entries)
+	if _, _, _, err := scope.next(historyActionRedo); !errors.Is(err, ErrWorkspaceHistoryEmpty) {
+		t.Fatalf("expected empty redo stack, got %v", err)
+	}
+	if _, _, _, err := buildHistoryScope("core.code", entries).next(historyActionUndo); !errors.Is(err, ErrWorkspaceHistoryEmpty) {
+		t.Fatalf("expected other namespace to have no history, got %v", err)
+	}
+}
+
+func TestHistoryScopeReportsOverlappingLaterOperations(t *testing.T) {
+	entries := []historyEntry{
+		{opSeq: 1, command: historyTestCommand("core.mir", "/ui/graph/nodesById/a/props", `{"title":"b"}`, `{}`)},
+		{opSeq: 2, domain: "core.nodegraph.node.move@1.0", command: historyTestCommand("core.nodegraph", "/ui/graph/nodesById/a/props/title", `"c"`, `"b"`)},
+		{opSeq: 3, domain: "core.nodegraph.node.move@1.0", command: historyTestCommand("core.nodegraph", "/ui/graph/nodesById/z", `{}`, `null`)},
+	}
+
+	scope := buildHistoryScope("core.mir", entries)
+	conflicts := scope.conflicts(historyActionUndo, 1)
+	if len(conflicts) != 1 || conflicts[0].OpSeq != 2 || conflicts[0].Paths[0] != "/ui/graph/nodesById/a/props/title" {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+}
+
+func TestHistoryAffectedPathsWidensArrayInserts(t *testing.T) {
+	paths := historyAffectedPaths([]WorkspacePatchOp{
+		{Op: "add", Path: "/ui/graph/childIdsById/root/-"},
+		{Op: "remove", Path: "/ui/graph/childIdsById/root/2"},
+		{Op: "test", Path: "/ui/graph/version"},
+		{Op: "replace", Path: "/ui/graph/nodesById/a"},
+	})
+	if len(paths) != 2 || paths[0] != "/ui/graph/childIdsById/root" || paths[1] != "/ui/graph/nodesById/a" {
+		t.Fatalf("unexpected affected paths: %v", paths)
+	}
+	if !jsonPointersOverlap("/ui/graph/childIdsById/root", "/ui/graph/childIdsById/root/3") || jsonPointersOverlap("/ui/graph/nodesById/a", "/ui/graph/nodesById/ab") {
+		t.Fatalf("unexpected pointer overlap result")
+	}
+}
+
+func TestWorkspaceStoreApplyHistoryUndoesLatestCommand(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 10, 5, 0, 0, time.UTC)
+	original := historyTestCommand("core.code", "/source", `"next"`, `"prev"`)
+	originalJSON, _ := json.Marshal(original)
+
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM workspace_documents WHERE workspace_id = $1 AND id = $2 FOR UPDATE`)).
+		WithArgs("ws_1", "code_open_dialog").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("code_open_dialog"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq, domain, payload_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND document_id = $2
+ORDER BY op_seq DESC
+LIMIT $3`)).
+		WithArgs("ws_1", "code_open_dialog", historyScanWindow).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "payload_json"}).AddRow(34, "core.code.update@1.0", originalJSON))
+	expectDocumentContentLock(mock, testDocument{id: "code_open_dialog", docType: WorkspaceDocumentTypeCode, contentRev: 4, metaRev: 1, content: `{"language":"ts","source":"next"}`},
+		testHead{workspaceRev: 9, routeRev: 4, opSeq: 34})
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2
+RETURNING content_rev, meta_rev`)).
+		WithArgs("ws_1", "code_open_dialog", `{"language":"ts","source":"prev"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(5, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 35))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)).
+		WithArgs("ws_1", int64(35), "core.history.undo@1.0", "code_open_dialog", sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := store.ApplyHistory(context.Background(), ApplyHistoryParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "code_open_dialog",
+		Namespace:          "core.code",
+		Action:             historyActionUndo,
+		ExpectedContentRev: 4,
+		Command:            testCommand(historyNamespace, historyActionUndo, issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("apply history: %v", err)
+	}
+	if result.OpSeq != 35 || result.UpdatedDocuments[0].ContentRev != 5 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHistoryIntentRequiresExpectedContentRevForDocuments(t *testing.T) {
+	request := ApplyIntentRequest{ExpectedWorkspaceRev: 9, Intent: IntentEnvelope{
+		Payload: json.RawMessage(`{"documentId":"code_open_dialog","namespace":"core.code"}`),
+	}}
+	_, failure := historyIntentHandler{}.Handle(context.Background(), nil, "ws_1", request, IntentEnvelope{Type: historyActionUndo}, testCommand(historyNamespace, historyActionUndo, time.Now()))
+	if failure == nil || failure.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without expectedContentRev, got %+v", failure)
+	}
+}
@@ -0,0 +1,202 @@
+package workspace
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrWorkspaceIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// workspaceIdempotencyRetention bounds how long a stored mutation result can be
+// replayed. After the window a reused key is treated as a fresh request.
+var workspaceIdempotencyRetention = 24 * time.Hour
+
+const maxWorkspaceIdempotencyKeyLength = 200
+
+// idempotencyReplayedHeader marks responses served from the ledger.
+const idempotencyReplayedHeader = "Idempotency-Replayed"
+
+// errWorkspaceIdempotencyRace is returned when a concurrent request recorded
+// the same key first; the caller retries so the winner's result is replayed.
+var errWorkspaceIdempotencyRace = errors.New("workspace idempotency key recorded concurrently")
+
+type idempotentMutationRequest struct {
+	Key         string
+	Scope       string
+	Fingerprint any
+}
+
+type workspaceIdempotencyRecord struct {
+	RequestHash string
+	Result      json.RawMessage
+	CreatedAt   time.Time
+}
+
+// runIdempotentMutation executes mutate once per idempotency key. Without a key
+// mutate runs directly against store. With a key the stored result of an
+// earlier identical request is replayed instead, and a new result is recorded
+// in the same transaction as the mutation so a retry can never observe a
+// half-applied request. The key is locked before the lookup, so a retry that
+// arrives while the first request is still in flight waits for it and then
+// replays its result.
+func runIdempotentMutation[T any](
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request idempotentMutationRequest,
+	mutate func(txStore *WorkspaceStore) (*T, *RequestFailure),
+) (*T, bool, *RequestFailure) {
+	key := strings.TrimSpace(request.Key)
+	if len(key) > maxWorkspaceIdempotencyKeyLength {
+		return nil, false, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, fmt.Sprintf("idempotency key must not exceed %d characters.", maxWorkspaceIdempotencyKeyLength), nil)
+	}
+	if key == "" {
+		result, failure := mutate(store)
+		return result, false, failure
+	}
+	requestHash, err := idempotencyRequestHash(request.Scope, request.Fingerprint)
+	if err != nil {
+		return nil, false, MapStoreError(err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		var result *T
+		var replayed bool
+		var failure *RequestFailure
+		err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+			now := time.Now().UTC()
+			if err := txStore.lockIdempotencyKey(ctx, workspaceID, key); err != nil {
+				return err
+			}
+			record, err := txStore.findIdempotencyRecord(ctx, workspaceID, key)
+			if err != nil {
+				return err
+			}
+			if record != nil && record.CreatedAt.After(now.Add(-workspaceIdempotencyRetention)) {
+				if record.RequestHash != requestHash {
+					return ErrWorkspaceIdempotencyKeyReused
+				}
+				var stored T
+				if err := json.Unmarshal(record.Result, &stored); err != nil {
+					return err
+				}
+				result = &stored
+				replayed = true
+				return nil
+			}
+			result, failure = mutate(txStore)
+			if failure != nil {
+				return errWorkspaceMutationAborted
+			}
+			payload, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			if err := txStore.purgeExpiredIdempotencyRecords(ctx, workspaceID, now); err != nil {
+				return err
+			}
+			return txStore.saveIdempotencyRecord(ctx, workspaceID, key, requestHash, payload, now)
+		})
+		if failure != nil {
+			return nil, false, failure
+		}
+		if err != nil {
+			if attempt == 0 && (errors.Is(err, errWorkspaceIdempotencyRace) || isUniqueViolation(err)) {
+				continue
+			}
+			return nil, false, MapStoreError(err)
+		}
+		return result, replayed, nil
+	}
+}
+
+func idempotencyRequestHash(scope string, fingerprint any) (string, error) {
+	payload, err := json.Marshal(struct {
+		Scope       string `json:"scope"`
+		Fingerprint any    `json:"fingerprint"`
+	}{Scope: scope, Fingerprint: fingerprint})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lockIdempotencyKey serializes requests sharing a key for the rest of the
+// transaction. A row lock is not enough: before the first request commits
+// there is no ledger row to lock.
+func (store *WorkspaceStore) lockIdempotencyKey(ctx context.Context, workspaceID string, key string) error {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))`
+	_, err := store.conn().ExecContext(ctx, query, workspaceID, key)
+	return err
+}
+
+func (store *WorkspaceStore) findIdempotencyRecord(ctx context.Context, workspaceID string, key string) (*workspaceIdempotencyRecord, error) {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT request_hash, result_json, created_at
+FROM workspace_idempotency_keys
+WHERE workspace_id = $1 AND idempotency_key = $2
+FOR UPDATE`
+	record := &workspaceIdempotencyRecord{}
+	var resultBytes []byte
+	err := store.conn().QueryRowContext(ctx, query, workspaceID, key).Scan(&record.RequestHash, &resultBytes, &record.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	record.Result = json.RawMessage(resultBytes)
+	return record, nil
+}
+
+// purgeExpiredIdempotencyRecords drops ledger entries of the workspace that
+// can no longer be replayed. It runs whenever a new entry is recorded, so the
+// ledger stays bounded by the keys used within the retention window.
+func (store *WorkspaceStore) purgeExpiredIdempotencyRecords(ctx context.Context, workspaceID string, now time.Time) error {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const query = `DELETE FROM workspace_idempotency_keys
+WHERE workspace_id = $1 AND created_at <= $2`
+	_, err := store.conn().ExecContext(ctx, query, workspaceID, now.Add(-workspaceIdempotencyRetention))
+	return err
+}
+
+func (store *WorkspaceStore) saveIdempotencyRecord(ctx context.Context, workspaceID string, key string, requestHash string, result json.RawMessage, now time.Time) error {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	// Only an expired entry may be overwritten; a live one means another
+	// request with the same key committed first.
+	const query = `INSERT INTO workspace_idempotency_keys (workspace_id, idempotency_key, request_hash, result_json, created_at)
+VALUES ($1, $2, $3, $4::jsonb, $5)
+ON CONFLICT (workspace_id, idempotency_key) DO UPDATE
+SET request_hash = EXCLUDED.request_hash, result_json = EXCLUDED.result_json, created_at = EXCLUDED.created_at
+WHERE workspace_idempotency_keys.created_at <= $6`
+	outcome, err := store.conn().ExecContext(ctx, query, workspaceID, key, requestHash, string(result), now, now.Add(-workspaceIdempotencyRetention))
+	if err != nil {
+		return err
+	}
+	affected, err := outcome.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errWorkspaceIdempotencyRace
+	}
+	return nil
+}
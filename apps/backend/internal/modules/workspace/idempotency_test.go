@@ -0,0 +1,177 @@
+package workspace
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleApplyWorkspaceBatchReplaysStoredResult(t *testing.T) {
+	requestHash, err := idempotencyRequestHash("batch", batchTestFingerprint(t))
+	if err != nil {
+		t.Fatalf("hash request: %v", err)
+	}
+	stored, err := json.Marshal(WorkspaceBatchResult{
+		Latest:     &WorkspaceMutationResult{WorkspaceID: "ws_1", WorkspaceRev: 10, RouteRev: 4, OpSeq: 35},
+		FromOpSeq:  34,
+		ToOpSeq:    35,
+		Operations: []WorkspaceBatchOperationResult{{Index: 0, Op: "patchDocument", OpSeq: 34}, {Index: 1, Op: "intent", OpSeq: 35}},
+	})
+	if err != nil {
+		t.Fatalf("encode stored result: %v", err)
+	}
+
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectBegin()
+	expectIdempotencyRecordLookup(mock, "ws_1", "batch_1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "result_json", "created_at"}).AddRow(requestHash, stored, time.Now().UTC()))
+	mock.ExpectCommit()
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodPost,
+		"/api/workspaces/ws_1/batch",
+		workspaceBatchTestBody,
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleApplyWorkspaceBatch(context)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	if response.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Fatalf("expected replay header, got %v", response.Header())
+	}
+	var payload struct {
+		WorkspaceRev     int64  `json:"workspaceRev"`
+		OpSeq            int64  `json:"opSeq"`
+		AcceptedMutation string `json:"acceptedMutationId"`
+		OpSeqRange       struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		} `json:"opSeqRange"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.WorkspaceRev != 10 || payload.OpSeq != 35 || payload.OpSeqRange.From != 34 || payload.AcceptedMutation != "batch_1" {
+		t.Fatalf("unexpected replayed payload: %s", response.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleApplyWorkspaceBatchRejectsReusedKeyWithDifferentPayload(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectBegin()
+	expectIdempotencyRecordLookup(mock, "ws_1", "batch_1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "result_json", "created_at"}).AddRow("other-hash", []byte(`{}`), time.Now().UTC()))
+	mock.ExpectRollback()
+
+	context, response := newWorkspaceHandlerContext(
+		http.MethodPost,
+		"/api/workspaces/ws_1/batch",
+		workspaceBatchTestBody,
+		gin.Params{{Key: "workspaceId", Value: "ws_1"}},
+	)
+
+	handler.HandleApplyWorkspaceBatch(context)
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", response.Code, response.Body.String())
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(response.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if errorCode(payload) != ErrorIdempotencyKeyReused {
+		t.Fatalf("unexpected error payload: %v", payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRunIdempotentMutationRetriesAfterConcurrentRecord(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	requestHash, err := idempotencyRequestHash("intent", "payload")
+	if err != nil {
+		t.Fatalf("hash request: %v", err)
+	}
+	mock.ExpectBegin()
+	expectIdempotencyRecordLookup(mock, "ws_1", "key_1")
+	expectIdempotencyRecordSave(mock, "ws_1", "key_1", requestHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	expectIdempotencyRecordLookup(mock, "ws_1", "key_1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "result_json", "created_at"}).AddRow(requestHash, []byte(`{"workspaceId":"ws_1","opSeq":7}`), time.Now().UTC()))
+	mock.ExpectCommit()
+
+	calls := 0
+	result, replayed, failure := runIdempotentMutation(t.Context(), handler.store, "ws_1", idempotentMutationRequest{Key: "key_1", Scope: "intent", Fingerprint: "payload"}, func(*WorkspaceStore) (*WorkspaceMutationResult, *RequestFailure) {
+		calls++
+		return &WorkspaceMutationResult{WorkspaceID: "ws_1", OpSeq: 8}, nil
+	})
+	if failure != nil {
+		t.Fatalf("unexpected failure: %+v", failure)
+	}
+	if !replayed || result.OpSeq != 7 || calls != 1 {
+		t.Fatalf("expected winner result to be replayed, got replayed=%v result=%+v calls=%d", replayed, result, calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func batchTestFingerprint(t *testing.T) map[string]any {
+	t.Helper()
+	var request ApplyBatchRequest
+	if err := json.Unmarshal([]byte(workspaceBatchTestBody), &request); err != nil {
+		t.Fatalf("decode batch body: %v", err)
+	}
+	fingerprint := map[string]any{"expectedWorkspaceRev": request.ExpectedWorkspaceRev, "expectedRouteRev": request.ExpectedRouteRev, "operations": request.Operations}
+	withSettingsRevFingerprint(fingerprint, request.ExpectedSettingsRev)
+	return fingerprint
+}
+
+func expectIdempotencyRecordLookup(mock sqlmock.Sqlmock, workspaceID string, key string) *sqlmock.ExpectedQuery {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))`)).
+		WithArgs(workspaceID, key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	return mock.ExpectQuery(regexp.QuoteMeta(`SELECT request_hash, result_json, created_at
+FROM workspace_idempotency_keys
+WHERE workspace_id = $1 AND idempotency_key = $2
+FOR UPDATE`)).
+		WithArgs(workspaceID, key).
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "result_json", "created_at"}))
+}
+
+func expectIdempotencyRecordSave(mock sqlmock.Sqlmock, workspaceID string, key string, requestHash driver.Value, result driver.Value) *sqlmock.ExpectedExec {
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM workspace_idempotency_keys
+WHERE workspace_id = $1 AND created_at <= $2`)).
+		WithArgs(workspaceID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	return mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_idempotency_keys (workspace_id, idempotency_key, request_hash, result_json, created_at)
+VALUES ($1, $2, $3, $4::jsonb, $5)
+ON CONFLICT (workspace_id, idempotency_key) DO UPDATE
+SET request_hash = EXCLUDED.request_hash, result_json = EXCLUDED.result_json, created_at = EXCLUDED.created_at
+WHERE workspace_idempotency_keys.created_at <= $6`)).
+		WithArgs(workspaceID, key, requestHash, result, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
@@ -19,9 +19,17 @@ const (
 	ErrorMIRValidationFailed        = "MIR-4001"
 	ErrorMIRGraphPatchPathForbidden = "WKS-5002"
 	ErrorWorkspaceNotFound          = "WKS-1001"
+	ErrorWorkspaceAccessDenied      = "API-3001"
 	ErrorWorkspaceDocumentNotFound  = "WKS-3001"
 	ErrorWorkspaceOperationFailed   = "API-9001"
 	ErrorWorkspacePatchFailed       = "WKS-5002"
+	ErrorIdempotencyKeyReused       = "WKS-4004"
+	ErrorHistoryConflict            = "WKS-4005"
+	ErrorHistoryEmpty               = "WKS-5003"
+	ErrorCheckpointNotFound         = "WKS-3003"
+	ErrorCheckpointNameTaken        = "WKS-4006"
+	ErrorCanonicalDocument          = "WKS-3004"
+	ErrorDocumentTypeUnsupported    = "WKS-3002"
 )
 
 type IntentActor struct {
@@ -43,6 +51,7 @@ type IntentEnvelope struct {
 type ApplyIntentRequest struct {
 	ExpectedWorkspaceRev int64          `json:"expectedWorkspaceRev"`
 	ExpectedRouteRev     int64          `json:"expectedRouteRev"`
+	ExpectedSettingsRev  int64          `json:"expectedSettingsRev"`
 	Intent               IntentEnvelope `json:"intent"`
 }
 
@@ -88,6 +97,12 @@ func ResolveDocumentCommand(workspaceID, documentID string, provided *WorkspaceC
 }
 
 func (module *Module) ApplyIntentMutation(ctx context.Context, workspaceID string, request ApplyIntentRequest) (*WorkspaceMutationResult, *RequestFailure) {
+	return module.applyIntentMutation(ctx, module.store, workspaceID, request)
+}
+
+// applyIntentMutation dispatches the intent against store, which may be bound
+// to an enclosing transaction (see WorkspaceStore.RunInTx).
+func (module *Module) applyIntentMutation(ctx context.Context, store *WorkspaceStore, workspaceID string, request ApplyIntentRequest) (*WorkspaceMutationResult, *RequestFailure) {
 	if request.ExpectedWorkspaceRev <= 0 {
 		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "expectedWorkspaceRev must be positive.", nil)
 	}
@@ -95,11 +110,31 @@ func (module *Module) ApplyIntentMutation(ctx context.Context, workspaceID strin
 	if intent.ID == "" || intent.Namespace == "" || intent.Type == "" || intent.Version == "" || intent.IssuedAt.IsZero() {
 		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "intent envelope is incomplete.", nil)
 	}
-	if intent.Version != "1.0" {
-		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidVersion, "Unsupported intent version.", mapKV("version", intent.Version))
+	registry := module.intentRegistry()
+	registered, ok := registry.lookup(intent.Namespace, intent.Type)
+	if !ok {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorUnsupportedIntent,
+			"Unsupported intent.",
+			mapKV("namespace", intent.Namespace, "type", intent.Type),
+		)
+	}
+	descriptor := registered.descriptor
+	if !descriptor.supportsVersion(intent.Version) {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidVersion, "Unsupported intent version.", mapKV("version", intent.Version, "supportedVersions", descriptor.Versions))
+	}
+	if descriptor.requiresPartition(RevPartitionRoute) && request.ExpectedRouteRev <= 0 {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "expectedRouteRev must be positive for route intents.", nil)
+	}
+	if descriptor.requiresPartition(RevPartitionSettings) && request.ExpectedSettingsRev <= 0 {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "expectedSettingsRev must be positive for settings intents.", nil)
+	}
+	if descriptor.requiresPartition(RevPartitionContent) && contentRevisionMissing(intent.Payload) {
+		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "payload.expectedContentRev must be positive for content intents.", nil)
 	}
-	if IsReservedIntentDomain(intent.Namespace) {
-		return nil, NewRequestFailure(http.StatusUnprocessableEntity, ErrorReservedDomain, "Reserved domain is disabled.", mapKV("namespace", intent.Namespace))
+	if failure := module.checkIntentEnabled(ctx, store, workspaceID, descriptor, intent.Version); failure != nil {
+		return nil, failure
 	}
 	command := WorkspaceCommandEnvelope{
 		ID:         intent.ID,
@@ -112,26 +147,64 @@ func (module *Module) ApplyIntentMutation(ctx context.Context, workspaceID strin
 		Target:     WorkspaceCommandTarget{WorkspaceID: workspaceID},
 	}
 	request.Intent = intent
-	handlers := module.intentHandlers
-	if handlers == nil {
-		// Allow callers to construct Module via struct literal without going
-		// through NewModule; without this guard every intent would fall
-		// through to "Unsupported intent" silently.
-		handlers = defaultIntentHandlers()
-	}
-	for _, handler := range handlers {
-		if handler.CanHandle(intent) {
-			return handler.Handle(ctx, module.store, workspaceID, request, intent, command)
+	return registered.handler.Handle(ctx, store, workspaceID, request, intent, command)
+}
+
+// contentRevisionMissing reports a content intent that names a document but
+// not the content revision it was built against. Payloads that do not
+// decode, or that name no document (workspace-scope history), are left to the
+// handler.
+func contentRevisionMissing(payload json.RawMessage) bool {
+	var target struct {
+		DocumentID         string `json:"documentId"`
+		ExpectedContentRev int64  `json:"expectedContentRev"`
+	}
+	if len(payload) == 0 || json.Unmarshal(payload, &target) != nil {
+		return false
+	}
+	return strings.TrimSpace(target.DocumentID) != "" && target.ExpectedContentRev <= 0
+}
+
+// intentRegistry allows callers to construct Module via struct literal
+// without going through NewModule; without this guard every intent would
+// fall through to "Unsupported intent" silently.
+func (module *Module) intentRegistry() *IntentRegistry {
+	if module == nil || module.intents == nil {
+		return defaultIntentRegistry()
+	}
+	return module.intents
+}
+
+func (module *Module) checkIntentEnabled(ctx context.Context, store *WorkspaceStore, workspaceID string, descriptor IntentDescriptor, version string) *RequestFailure {
+	var workspaceFlags CapabilityFlags
+	if _, set := module.capabilityFlags.lookup(descriptor, version); !set && workspaceFlagsApply(descriptor) {
+		settings, err := store.readWorkspaceSettings(ctx, workspaceID)
+		if err != nil {
+			return MapStoreError(err)
 		}
+		workspaceFlags = workspaceCapabilityFlags(settings)
+	}
+	if intentEnabled(descriptor, version, module.capabilityFlags, workspaceFlags) {
+		return nil
 	}
-	return nil, NewRequestFailure(
+	return NewRequestFailure(
 		http.StatusUnprocessableEntity,
-		ErrorUnsupportedIntent,
-		"Unsupported intent.",
-		mapKV("namespace", intent.Namespace, "type", intent.Type),
+		ErrorReservedDomain,
+		"Intent is disabled for this workspace.",
+		mapKV("namespace", descriptor.Namespace, "type", descriptor.Type, "version", version),
 	)
 }
 
+// Capabilities reports the intents this module's registry accepts for a
+// workspace with the given settings.
+func (module *Module) Capabilities(settings json.RawMessage) (map[string]bool, []IntentCapability) {
+	var environment CapabilityFlags
+	if module != nil {
+		environment = module.capabilityFlags
+	}
+	return capabilitiesFor(module.intentRegistry(), environment, workspaceCapabilityFlags(settings))
+}
+
 func NormalizeIntent(intent IntentEnvelope) IntentEnvelope {
 	intent.ID = strings.TrimSpace(intent.ID)
 	intent.Namespace = strings.TrimSpace(intent.Namespace)
@@ -144,10 +217,6 @@ func NormalizeIntent(intent IntentEnvelope) IntentEnvelope {
 	return intent
 }
 
-func IsReservedIntentDomain(namespace string) bool {
-	return strings.HasPrefix(namespace, "core.nodegraph") || strings.HasPrefix(namespace, "core.animation")
-}
-
 func mapKV(kv ...any) map[string]any {
 	if len(kv) == 0 {
 		return nil
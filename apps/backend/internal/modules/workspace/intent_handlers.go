@@ -7,11 +7,12 @@ import (
 	"strings"
 )
 
-// IntentHandler dispatches a single (namespace, type) pair. New intent
-// categories should be added by registering a new handler in
-// defaultIntentHandlers rather than extending ApplyIntentMutation switch.
+// IntentHandler owns the intents it declares. New intent categories should
+// be added by registering a new handler in defaultIntentHandlers rather than
+// extending ApplyIntentMutation; the registry checks versions and rev
+// partitions before Handle runs.
 type IntentHandler interface {
-	CanHandle(intent IntentEnvelope) bool
+	Intents() []IntentDescriptor
 	Handle(
 		ctx context.Context,
 		store *WorkspaceStore,
@@ -24,8 +25,8 @@ type IntentHandler interface {
 
 type routeManifestUpdateHandler struct{}
 
-func (routeManifestUpdateHandler) CanHandle(intent IntentEnvelope) bool {
-	return intent.Namespace == "core.route" && intent.Type == "manifest.update"
+func (routeManifestUpdateHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.route", "manifest.update", RevPartitionWorkspace, RevPartitionRoute)}
 }
 
 func (routeManifestUpdateHandler) Handle(
@@ -36,14 +37,6 @@ func (routeManifestUpdateHandler) Handle(
 	_ IntentEnvelope,
 	command WorkspaceCommandEnvelope,
 ) (*WorkspaceMutationResult, *RequestFailure) {
-	if request.ExpectedRouteRev <= 0 {
-		return nil, NewRequestFailure(
-			http.StatusUnprocessableEntity,
-			ErrorInvalidPayload,
-			"expectedRouteRev must be positive for route intents.",
-			nil,
-		)
-	}
 	var payload struct {
 		RouteManifest json.RawMessage `json:"routeManifest"`
 	}
@@ -81,8 +74,8 @@ func (routeManifestUpdateHandler) Handle(
 
 type workspaceSettingsUpdateHandler struct{}
 
-func (workspaceSettingsUpdateHandler) CanHandle(intent IntentEnvelope) bool {
-	return intent.Namespace == "core.settings" && intent.Type == "global.update"
+func (workspaceSettingsUpdateHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.settings", "global.update", RevPartitionWorkspace, RevPartitionSettings)}
 }
 
 func (workspaceSettingsUpdateHandler) Handle(
@@ -109,6 +102,7 @@ func (workspaceSettingsUpdateHandler) Handle(
 	result, err := store.SaveWorkspaceSettings(ctx, SaveWorkspaceSettingsParams{
 		WorkspaceID:          workspaceID,
 		ExpectedWorkspaceRev: request.ExpectedWorkspaceRev,
+		ExpectedSettingsRev:  request.ExpectedSettingsRev,
 		Settings:             payload.Settings,
 		Command:              command,
 	})
@@ -120,8 +114,8 @@ func (workspaceSettingsUpdateHandler) Handle(
 
 type workspaceCodeDocumentCreateHandler struct{}
 
-func (workspaceCodeDocumentCreateHandler) CanHandle(intent IntentEnvelope) bool {
-	return intent.Namespace == "core.workspace" && intent.Type == "code-document.create"
+func (workspaceCodeDocumentCreateHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.workspace", "code-document.create", RevPartitionWorkspace)}
 }
 
 func (workspaceCodeDocumentCreateHandler) Handle(
@@ -168,8 +162,23 @@ func (workspaceCodeDocumentCreateHandler) Handle(
 
 func defaultIntentHandlers() []IntentHandler {
 	return []IntentHandler{
+		mirGraphReplaceHandler{},
+		nodeGraphIntentHandler{},
+		animationIntentHandler{},
 		routeManifestUpdateHandler{},
 		workspaceSettingsUpdateHandler{},
+		workspaceSettingsPatchHandler{},
 		workspaceCodeDocumentCreateHandler{},
+		codeSourceEditHandler{},
+		workspaceMIRDocumentCreateHandler{},
+		workspaceDocumentRenameHandler{},
+		workspaceDocumentMoveHandler{},
+		workspaceDocumentDeleteHandler{},
+		workspaceDirectoryCreateHandler{},
+		workspaceDirectoryRenameHandler{},
+		workspaceDirectoryDeleteHandler{},
+		historyIntentHandler{},
+		documentRestoreIntentHandler{},
+		workspaceCheckpointRestoreHandler{},
 	}
 }
@@ -0,0 +1,273 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RevPartition names a revision counter an intent must be guarded by
+// (decision 11). Dispatch enforces the request side of each partition before
+// the handler runs: expectedWorkspaceRev always, expectedRouteRev and
+// expectedSettingsRev on the request, and payload.expectedContentRev whenever
+// a content intent names a payload.documentId.
+type RevPartition string
+
+const (
+	RevPartitionWorkspace RevPartition = "workspace"
+	RevPartitionRoute     RevPartition = "route"
+	RevPartitionContent   RevPartition = "content"
+	RevPartitionSettings  RevPartition = "settings"
+)
+
+// IntentDescriptor declares one intent a handler accepts.
+type IntentDescriptor struct {
+	Namespace  string         `json:"namespace"`
+	Type       string         `json:"type"`
+	Versions   []string       `json:"versions"`
+	Partitions []RevPartition `json:"partitions"`
+	// Experimental intents are disabled unless a capability flag enables
+	// them for the environment or the workspace.
+	Experimental bool `json:"experimental,omitempty"`
+}
+
+func intentV1(namespace, intentType string, partitions ...RevPartition) IntentDescriptor {
+	return IntentDescriptor{Namespace: namespace, Type: intentType, Versions: []string{"1.0"}, Partitions: partitions}
+}
+
+func (descriptor IntentDescriptor) name() string {
+	return descriptor.Namespace + "." + descriptor.Type
+}
+
+func (descriptor IntentDescriptor) supportsVersion(version string) bool {
+	for _, supported := range descriptor.Versions {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (descriptor IntentDescriptor) requiresPartition(partition RevPartition) bool {
+	for _, required := range descriptor.Partitions {
+		if required == partition {
+			return true
+		}
+	}
+	return false
+}
+
+type registeredIntent struct {
+	descriptor IntentDescriptor
+	handler    IntentHandler
+}
+
+// IntentRegistry maps (namespace, type) to the handler that owns it. Both
+// dispatch and GET /capabilities read from it, so a capability can never
+// claim support that dispatch does not have.
+type IntentRegistry struct {
+	intents map[string]registeredIntent
+	order   []string
+}
+
+func NewIntentRegistry(handlers ...IntentHandler) (*IntentRegistry, error) {
+	registry := &IntentRegistry{intents: make(map[string]registeredIntent)}
+	for _, handler := range handlers {
+		if err := registry.Register(handler); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register adds every intent the handler declares. An intent may be owned by
+// one handler only.
+func (registry *IntentRegistry) Register(handler IntentHandler) error {
+	descriptors := handler.Intents()
+	if len(descriptors) == 0 {
+		return fmt.Errorf("intent handler %T declares no intents", handler)
+	}
+	for _, descriptor := range descriptors {
+		if strings.TrimSpace(descriptor.Namespace) == "" || strings.TrimSpace(descriptor.Type) == "" || len(descriptor.Versions) == 0 {
+			return fmt.Errorf("intent handler %T declares an incomplete intent %q", handler, descriptor.name())
+		}
+		if _, exists := registry.intents[descriptor.name()]; exists {
+			return fmt.Errorf("intent %q is already registered", descriptor.name())
+		}
+		registry.intents[descriptor.name()] = registeredIntent{descriptor: descriptor, handler: handler}
+		registry.order = append(registry.order, descriptor.name())
+	}
+	return nil
+}
+
+func (registry *IntentRegistry) lookup(namespace, intentType string) (registeredIntent, bool) {
+	if registry == nil {
+		return registeredIntent{}, false
+	}
+	registered, ok := registry.intents[namespace+"."+intentType]
+	return registered, ok
+}
+
+// Descriptors lists registered intents in registration order.
+func (registry *IntentRegistry) Descriptors() []IntentDescriptor {
+	if registry == nil {
+		return nil
+	}
+	descriptors := make([]IntentDescriptor, 0, len(registry.order))
+	for _, name := range registry.order {
+		descriptors = append(descriptors, registry.intents[name].descriptor)
+	}
+	return descriptors
+}
+
+func defaultIntentRegistry() *IntentRegistry {
+	registry, err := NewIntentRegistry(defaultIntentHandlers()...)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// CapabilityFlags switch intents on or off. Keys are matched from the most
+// specific to the least: "core.history.restore@1.0", "core.history.restore",
+// "core.history", "core".
+type CapabilityFlags map[string]bool
+
+// ParseCapabilityFlags reads a comma separated list such as
+// "core.nodegraph=on,core.history.restore@1.0=off".
+func ParseCapabilityFlags(raw string) (CapabilityFlags, error) {
+	flags := make(CapabilityFlags)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("capability flag %q must be key=on|off", entry)
+		}
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			flags[key] = true
+		case "off", "false", "0":
+			flags[key] = false
+		default:
+			return nil, fmt.Errorf("capability flag %q must be key=on|off", entry)
+		}
+	}
+	return flags, nil
+}
+
+// workspaceCapabilityFlags reads the "capabilities" object of the workspace
+// settings. Malformed values are ignored so a bad setting cannot lock a
+// workspace out of every intent.
+func workspaceCapabilityFlags(settings json.RawMessage) CapabilityFlags {
+	var document struct {
+		Capabilities CapabilityFlags `json:"capabilities"`
+	}
+	if len(settings) == 0 || json.Unmarshal(settings, &document) != nil {
+		return nil
+	}
+	return document.Capabilities
+}
+
+func (flags CapabilityFlags) lookup(descriptor IntentDescriptor, version string) (enabled bool, set bool) {
+	if len(flags) == 0 {
+		return false, false
+	}
+	if enabled, ok := flags[descriptor.name()+"@"+version]; ok {
+		return enabled, true
+	}
+	for key := descriptor.name(); key != ""; {
+		if enabled, ok := flags[key]; ok {
+			return enabled, true
+		}
+		index := strings.LastIndex(key, ".")
+		if index < 0 {
+			break
+		}
+		key = key[:index]
+	}
+	return false, false
+}
+
+// intentEnabled applies the environment flags first, then the workspace
+// flags; an intent neither sets is enabled unless it is experimental.
+// Settings intents ignore workspace flags, so a workspace that switched
+// something off can always edit its flags back.
+func intentEnabled(descriptor IntentDescriptor, version string, environment, workspace CapabilityFlags) bool {
+	if enabled, set := environment.lookup(descriptor, version); set {
+		return enabled
+	}
+	if workspaceFlagsApply(descriptor) {
+		if enabled, set := workspace.lookup(descriptor, version); set {
+			return enabled
+		}
+	}
+	return !descriptor.Experimental
+}
+
+func workspaceFlagsApply(descriptor IntentDescriptor) bool {
+	return descriptor.Namespace != "core.settings"
+}
+
+// readWorkspaceSettings is only consulted when the environment flags leave an
+// intent's state to the workspace.
+func (store *WorkspaceStore) readWorkspaceSettings(ctx context.Context, workspaceID string) (json.RawMessage, error) {
+	queryCtx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+	var settings []byte
+	const query = `SELECT settings_json
+FROM workspace_settings
+WHERE workspace_id = $1`
+	if err := store.conn().QueryRowContext(queryCtx, query, workspaceID).Scan(&settings); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return defaultWorkspaceSettings, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+type IntentCapability struct {
+	IntentDescriptor
+	Enabled map[string]bool `json:"enabled"`
+}
+
+// documentCommandCapabilities are served by PATCH /documents/:documentId
+// rather than by an intent handler.
+var documentCommandCapabilities = []string{"core.mir.document.update@1.0"}
+
+// capabilitiesFor builds the GET /capabilities view of a registry: the flat
+// "namespace.type@version" map clients already read, plus the descriptors.
+func capabilitiesFor(registry *IntentRegistry, environment, workspace CapabilityFlags) (map[string]bool, []IntentCapability) {
+	capabilities := make(map[string]bool)
+	for _, key := range documentCommandCapabilities {
+		capabilities[key] = true
+	}
+	descriptors := registry.Descriptors()
+	intents := make([]IntentCapability, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		intent := IntentCapability{IntentDescriptor: descriptor, Enabled: make(map[string]bool, len(descriptor.Versions))}
+		for _, version := range descriptor.Versions {
+			enabled := intentEnabled(descriptor, version, environment, workspace)
+			intent.Enabled[version] = enabled
+			capabilities[descriptor.name()+"@"+version] = enabled
+		}
+		intents = append(intents, intent)
+	}
+	sort.SliceStable(intents, func(i, j int) bool {
+		return intents[i].name() < intents[j].name()
+	})
+	return capabilities, intents
+}
+
+func DefaultCapabilities() map[string]bool {
+	capabilities, _ := capabilitiesFor(defaultIntentRegistry(), nil, nil)
+	return capabilities
+}
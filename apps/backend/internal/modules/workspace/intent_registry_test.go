@@ -0,0 +1,159 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	backendresponse "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/http/response"
+)
+
+type experimentalTestHandler struct{}
+
+func (experimentalTestHandler) Intents() []IntentDescriptor {
+	descriptor := intentV1("core.labs", "probe", RevPartitionWorkspace)
+	descriptor.Versions = []string{"1.0", "1.1"}
+	descriptor.Experimental = true
+	return []IntentDescriptor{descriptor}
+}
+
+func (experimentalTestHandler) Handle(
+	context.Context,
+	*WorkspaceStore,
+	string,
+	ApplyIntentRequest,
+	IntentEnvelope,
+	WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	return &WorkspaceMutationResult{WorkspaceRev: 2}, nil
+}
+
+func TestIntentRegistryRejectsDuplicateIntents(t *testing.T) {
+	if _, err := NewIntentRegistry(routeManifestUpdateHandler{}, routeManifestUpdateHandler{}); err == nil {
+		t.Fatalf("expected duplicate registration to fail")
+	}
+	registry := defaultIntentRegistry()
+	if _, ok := registry.lookup("core.mir", "graph.replace"); !ok {
+		t.Fatalf("graph.replace should be registered")
+	}
+	if _, ok := registry.lookup("core.animation", "clip.bind"); !ok {
+		t.Fatalf("clip.bind should be registered")
+	}
+}
+
+func TestCapabilitiesFollowRegistryAndFlags(t *testing.T) {
+	capabilities := DefaultCapabilities()
+	if !capabilities["core.mir.graph.replace@1.0"] || !capabilities["core.mir.document.update@1.0"] {
+		t.Fatalf("stable intents should be enabled: %+v", capabilities)
+	}
+	if !capabilities["core.animation.clip.bind@1.0"] || !capabilities["core.animation.timeline.keyframe.add@1.0"] {
+		t.Fatalf("animation intents should be enabled: %+v", capabilities)
+	}
+
+	registry, err := NewIntentRegistry(routeManifestUpdateHandler{}, experimentalTestHandler{})
+	if err != nil {
+		t.Fatalf("unexpected registry error: %v", err)
+	}
+	environment, err := ParseCapabilityFlags("core.route=off, core.labs.probe@1.1=on")
+	if err != nil {
+		t.Fatalf("unexpected flag error: %v", err)
+	}
+	capabilities, intents := capabilitiesFor(registry, environment, nil)
+	if capabilities["core.mir.graph.replace@1.0"] {
+		t.Fatalf("capabilities must follow the registry: %+v", capabilities)
+	}
+	if capabilities["core.route.manifest.update@1.0"] || capabilities["core.labs.probe@1.0"] || !capabilities["core.labs.probe@1.1"] {
+		t.Fatalf("environment flags not applied: %+v", capabilities)
+	}
+	if len(intents) != 2 || intents[0].Namespace != "core.labs" || intents[1].Partitions[1] != RevPartitionRoute {
+		t.Fatalf("unexpected intent descriptors: %+v", intents)
+	}
+
+	workspace := workspaceCapabilityFlags(json.RawMessage(`{"capabilities":{"core.labs":true,"core.route":false,"core.settings":false}}`))
+	settingsRegistry, err := NewIntentRegistry(routeManifestUpdateHandler{}, experimentalTestHandler{}, workspaceSettingsUpdateHandler{})
+	if err != nil {
+		t.Fatalf("unexpected registry error: %v", err)
+	}
+	capabilities, _ = capabilitiesFor(settingsRegistry, nil, workspace)
+	if !capabilities["core.labs.probe@1.0"] || capabilities["core.route.manifest.update@1.0"] {
+		t.Fatalf("workspace flags not applied: %+v", capabilities)
+	}
+	if !capabilities["core.settings.global.update@1.0"] {
+		t.Fatalf("workspace flags must not disable settings intents: %+v", capabilities)
+	}
+	capabilities, _ = capabilitiesFor(registry, CapabilityFlags{"core.route": true}, workspace)
+	if !capabilities["core.route.manifest.update@1.0"] {
+		t.Fatalf("environment flags must override workspace flags: %+v", capabilities)
+	}
+
+	if _, err := ParseCapabilityFlags("core.labs=maybe"); err == nil {
+		t.Fatalf("expected malformed flag to fail")
+	}
+}
+
+func TestApplyIntentMutationChecksRegistryBeforeDispatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	registry, err := NewIntentRegistry(routeManifestUpdateHandler{}, experimentalTestHandler{})
+	if err != nil {
+		t.Fatalf("unexpected registry error: %v", err)
+	}
+	module := &Module{store: NewWorkspaceStore(db), intents: registry}
+	request := func(namespace, intentType, version string) ApplyIntentRequest {
+		return ApplyIntentRequest{
+			ExpectedWorkspaceRev: 1,
+			Intent: IntentEnvelope{
+				ID:        "intent_1",
+				Namespace: namespace,
+				Type:      intentType,
+				Version:   version,
+				IssuedAt:  time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC),
+			},
+		}
+	}
+	expectFailure := func(name string, got *RequestFailure, code string) {
+		t.Helper()
+		if got == nil || got.Status != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422 failure, got %+v", name, got)
+		}
+		if errorPayload, _ := got.Payload["error"].(backendresponse.ErrorPayload); errorPayload.Code != code {
+			t.Fatalf("%s: expected %s, got %+v", name, code, got.Payload)
+		}
+	}
+
+	_, failure := module.ApplyIntentMutation(context.Background(), "ws_1", request("core.route", "manifest.update", "2.0"))
+	expectFailure("version", failure, ErrorInvalidVersion)
+	_, failure = module.ApplyIntentMutation(context.Background(), "ws_1", request("core.route", "manifest.update", "1.0"))
+	expectFailure("route partition", failure, ErrorInvalidPayload)
+	_, failure = module.ApplyIntentMutation(context.Background(), "ws_1", request("core.unknown", "noop", "1.0"))
+	expectFailure("unknown", failure, ErrorUnsupportedIntent)
+	contentRequest := request("core.mir", "graph.replace", "1.0")
+	contentRequest.Intent.Payload = json.RawMessage(`{"documentId":"page_home","mirDoc":{}}`)
+	_, failure = (&Module{store: NewWorkspaceStore(db)}).ApplyIntentMutation(context.Background(), "ws_1", contentRequest)
+	expectFailure("content partition", failure, ErrorInvalidPayload)
+
+	expectWorkspaceSettingsRead(mock, `{}`)
+	_, failure = module.ApplyIntentMutation(context.Background(), "ws_1", request("core.labs", "probe", "1.0"))
+	expectFailure("experimental", failure, ErrorReservedDomain)
+
+	expectWorkspaceSettingsRead(mock, `{"capabilities":{"core.labs.probe":true}}`)
+	result, failure := module.ApplyIntentMutation(context.Background(), "ws_1", request("core.labs", "probe", "1.0"))
+	if failure != nil || result == nil || result.WorkspaceRev != 2 {
+		t.Fatalf("expected workspace opt-in to enable the intent, got %+v %+v", result, failure)
+	}
+
+	module.SetCapabilityFlags(CapabilityFlags{"core.labs": false})
+	_, failure = module.ApplyIntentMutation(context.Background(), "ws_1", request("core.labs", "probe", "1.0"))
+	expectFailure("environment off", failure, ErrorReservedDomain)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sqlmock expectations: %v", err)
+	}
+}
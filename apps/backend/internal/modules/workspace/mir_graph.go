@@ -0,0 +1,87 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type ReplaceDocumentGraphParams struct {
+	WorkspaceID        string
+	DocumentID         string
+	ExpectedContentRev int64
+	Graph              json.RawMessage
+	Command            WorkspaceCommandEnvelope
+}
+
+// ReplaceDocumentGraph swaps ui.graph of a MIR document. Only the parts of
+// the graph that differ end up in the logged ops, so the command stays small
+// and undoable like any other content patch.
+func (store *WorkspaceStore) ReplaceDocumentGraph(ctx context.Context, params ReplaceDocumentGraphParams) (*WorkspaceMutationResult, error) {
+	var graph map[string]any
+	if err := json.Unmarshal(params.Graph, &graph); err != nil || graph == nil {
+		return nil, documentValidationError(mirDomain, DiagnosticMIRGraphMissing, "/ui/graph", "ui.graph must be an object")
+	}
+	return store.changeDocumentContent(ctx, ChangeDocumentContentParams{
+		WorkspaceID:        params.WorkspaceID,
+		DocumentID:         params.DocumentID,
+		ExpectedContentRev: params.ExpectedContentRev,
+		Command:            params.Command,
+	}, func(documentType WorkspaceDocumentType, document map[string]any) (string, error) {
+		if !isMIRWorkspaceDocumentType(documentType) {
+			return "", ErrInvalidWorkspaceDocumentType
+		}
+		ui, ok := document["ui"].(map[string]any)
+		if !ok {
+			ui = make(map[string]any)
+			document["ui"] = ui
+		}
+		ui["graph"] = graph
+		return "Replace graph", nil
+	})
+}
+
+type mirGraphReplaceHandler struct{}
+
+func (mirGraphReplaceHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.mir", "graph.replace", RevPartitionContent)}
+}
+
+func (mirGraphReplaceHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID         string          `json:"documentId"`
+		ExpectedContentRev int64           `json:"expectedContentRev"`
+		Graph              json.RawMessage `json:"graph"`
+	}
+	if len(request.Intent.Payload) == 0 ||
+		json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.DocumentID) == "" ||
+		payload.ExpectedContentRev <= 0 ||
+		len(payload.Graph) == 0 {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.documentId, payload.expectedContentRev and payload.graph are required.",
+			nil,
+		)
+	}
+	result, err := store.ReplaceDocumentGraph(ctx, ReplaceDocumentGraphParams{
+		WorkspaceID:        workspaceID,
+		DocumentID:         strings.TrimSpace(payload.DocumentID),
+		ExpectedContentRev: payload.ExpectedContentRev,
+		Graph:              payload.Graph,
+		Command:            command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
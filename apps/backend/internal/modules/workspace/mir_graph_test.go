@@ -0,0 +1,93 @@
+package workspace
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type graphReplaceOpsMatcher struct {
+	t     *testing.T
+	paths []string
+}
+
+func (matcher graphReplaceOpsMatcher) Match(value driver.Value) bool {
+	var raw []byte
+	switch typed := value.(type) {
+	case []byte:
+		raw = typed
+	case string:
+		raw = []byte(typed)
+	default:
+		return false
+	}
+	var command WorkspaceCommandEnvelope
+	if err := json.Unmarshal(raw, &command); err != nil {
+		matcher.t.Errorf("decode logged command: %v", err)
+		return false
+	}
+	if len(command.ForwardOps) != len(matcher.paths) || len(command.ReverseOps) != len(matcher.paths) {
+		matcher.t.Errorf("unexpected logged ops: %+v", command)
+		return false
+	}
+	for index, path := range matcher.paths {
+		if command.ForwardOps[index].Path != path {
+			matcher.t.Errorf("unexpected forward op %d: %+v", index, command.ForwardOps[index])
+			return false
+		}
+	}
+	return true
+}
+
+func TestWorkspaceStoreReplaceDocumentGraphLogsMinimalOps(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	content := `{"version":"1.3","ui":{"graph":{"version":1,"rootId":"root","nodesById":{"root":{"id":"root","type":"container"}},"childIdsById":{"root":[]}}}}`
+
+	document := testDocument{id: "doc_root", docType: WorkspaceDocumentTypeMIRPage, name: "Root", path: "/mir.json", contentRev: 3, metaRev: 1, content: content}
+
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	expectDocumentLock(mock, document)
+	expectDocumentContentLock(mock, document, testHead{workspaceRev: 9, routeRev: 4, opSeq: 33})
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", "doc_root", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(4, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(34), "core.mir.graph.replace@1.0", "doc_root",
+			graphReplaceOpsMatcher{t: t, paths: []string{"/ui/graph/childIdsById/root", "/ui/graph/nodesById/btn"}}, issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := NewWorkspaceStore(db).ReplaceDocumentGraph(context.Background(), ReplaceDocumentGraphParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "doc_root",
+		ExpectedContentRev: 3,
+		Graph:              json.RawMessage(`{"version":1,"rootId":"root","nodesById":{"root":{"id":"root","type":"container"},"btn":{"id":"btn","type":"MdrButton"}},"childIdsById":{"root":["btn"]}}`),
+		Command:            testCommand("core.mir", "graph.replace", issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("replace graph: %v", err)
+	}
+	if result.UpdatedDocuments[0].ContentRev != 4 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package workspace
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// mirPatchScope is what a patch can have changed in a MIR document, derived
+// from its op paths alone.
+type mirPatchScope struct {
+	// nodes are nodesById keys the patch wrote into or below.
+	nodes map[string]bool
+	// parents are childIdsById keys the patch wrote into or below.
+	parents map[string]bool
+	// reshaped is set when an op may have created or deleted a node, i.e.
+	// anything but replace/test on a whole nodesById entry.
+	reshaped bool
+}
+
+// mirPatchScopeFor classifies the op paths of a MIR patch. ok is false when
+// an op touches something the incremental check cannot reason about locally
+// (the graph root, whole nodesById/childIdsById maps, or unknown ui keys).
+func mirPatchScopeFor(ops []WorkspacePatchOp) (mirPatchScope, bool) {
+	scope := mirPatchScope{nodes: map[string]bool{}, parents: map[string]bool{}}
+	for _, op := range ops {
+		paths := []string{op.Path}
+		if strings.TrimSpace(op.From) != "" {
+			paths = append(paths, op.From)
+		}
+		opName := strings.ToLower(strings.TrimSpace(op.Op))
+		for _, path := range paths {
+			pointer, err := parseJSONPointer(strings.TrimSpace(path))
+			if err != nil || len(pointer) == 0 {
+				return scope, false
+			}
+			switch {
+			case pointer[0] == "logic" || pointer[0] == "animation" || pointer[0] == "metadata" || strings.HasPrefix(pointer[0], "x-"):
+				// Not part of the graph validation.
+			case len(pointer) >= 3 && pointer[0] == "ui" && pointer[1] == "graph" && pointer[2] == "regionsById":
+				// Region slots are not part of the graph validation.
+			case len(pointer) >= 4 && pointer[0] == "ui" && pointer[1] == "graph" && pointer[2] == "nodesById":
+				scope.nodes[pointer[3]] = true
+				if len(pointer) == 4 && opName != "replace" && opName != "test" {
+					scope.reshaped = true
+				}
+			case len(pointer) >= 4 && pointer[0] == "ui" && pointer[1] == "graph" && pointer[2] == "childIdsById":
+				scope.parents[pointer[3]] = true
+			default:
+				return scope, false
+			}
+		}
+	}
+	return scope, true
+}
+
+// mirGraphIndex is a shallow decode of ui.graph: node bodies and child lists
+// stay raw until a check needs them.
+type mirGraphIndex struct {
+	rootID   string
+	nodes    map[string]json.RawMessage
+	childIDs map[string]json.RawMessage
+}
+
+func decodeMIRGraphIndex(payload json.RawMessage) (*mirGraphIndex, bool) {
+	var document struct {
+		UI struct {
+			Graph *struct {
+				RootID       string                     `json:"rootId"`
+				NodesByID    map[string]json.RawMessage `json:"nodesById"`
+				ChildIDsByID map[string]json.RawMessage `json:"childIdsById"`
+			} `json:"graph"`
+		} `json:"ui"`
+	}
+	if err := json.Unmarshal(payload, &document); err != nil || document.UI.Graph == nil {
+		return nil, false
+	}
+	graph := document.UI.Graph
+	return &mirGraphIndex{rootID: graph.RootID, nodes: graph.NodesByID, childIDs: graph.ChildIDsByID}, true
+}
+
+func (index *mirGraphIndex) children(parentID string) ([]string, bool) {
+	raw, exists := index.childIDs[parentID]
+	if !exists {
+		return nil, true
+	}
+	var children []string
+	if err := json.Unmarshal(raw, &children); err != nil || children == nil {
+		return nil, false
+	}
+	return children, true
+}
+
+// validateMIRV13Patch validates a patched MIR document. When the patch stays
+// within nodes and child lists it only checks what the patch touched,
+// relying on the stored document having passed validation when it was
+// written. Anything else, and any problem the local check finds, goes
+// through validateMIRV13Document so diagnostics are always complete.
+func validateMIRV13Patch(previous json.RawMessage, next json.RawMessage, ops []WorkspacePatchOp) error {
+	scope, ok := mirPatchScopeFor(ops)
+	if !ok || !mirPatchLocallyValid(previous, next, scope) {
+		return validateMIRV13Document(next)
+	}
+	return nil
+}
+
+// mirPatchLocallyValid reports whether next is still a valid tree given that
+// previous was one and only the scoped nodes and child lists changed. Since
+// every node of previous had exactly one parent and was reachable from the
+// root, it is enough to check that:
+//   - touched nodes are well formed;
+//   - touched child lists only name existing nodes, each at most once;
+//   - a node only gains a parent in a touched list if it was created or its
+//     old parent was also touched, and only loses it if it was deleted;
+//   - a node that changed parents does not end up inside its own subtree.
+func mirPatchLocallyValid(previous json.RawMessage, next json.RawMessage, scope mirPatchScope) bool {
+	if len(scope.nodes) == 0 && len(scope.parents) == 0 {
+		return true
+	}
+	nextGraph, ok := decodeMIRGraphIndex(next)
+	if !ok {
+		return false
+	}
+	for nodeID := range scope.nodes {
+		raw, exists := nextGraph.nodes[nodeID]
+		if !exists {
+			continue
+		}
+		var node any
+		if err := json.Unmarshal(raw, &node); err != nil {
+			return false
+		}
+		diagnostics := diagnosticReport{domain: mirDomain}
+		checkMIRNode(&diagnostics, nodeID, node)
+		if len(diagnostics.diagnostics) > 0 {
+			return false
+		}
+	}
+
+	if !scope.reshaped && len(scope.parents) == 0 {
+		return true
+	}
+
+	previousGraph, ok := decodeMIRGraphIndex(previous)
+	if !ok || previousGraph.rootID != nextGraph.rootID {
+		return false
+	}
+	rootID := nextGraph.rootID
+	added := make(map[string]bool)
+	removed := make(map[string]bool)
+	for nodeID := range scope.nodes {
+		_, existed := previousGraph.nodes[nodeID]
+		_, exists := nextGraph.nodes[nodeID]
+		if exists && !existed {
+			added[nodeID] = true
+		}
+		if existed && !exists {
+			removed[nodeID] = true
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 && len(scope.parents) == 0 {
+		return true
+	}
+
+	oldParent := make(map[string]string)
+	newParent := make(map[string]string)
+	for parentID := range scope.parents {
+		previousChildren, ok := previousGraph.children(parentID)
+		if !ok {
+			return false
+		}
+		for _, childID := range previousChildren {
+			oldParent[childID] = parentID
+		}
+		if _, exists := nextGraph.childIDs[parentID]; !exists {
+			continue
+		}
+		if _, exists := nextGraph.nodes[parentID]; !exists {
+			return false
+		}
+		nextChildren, ok := nextGraph.children(parentID)
+		if !ok {
+			return false
+		}
+		for _, childID := range nextChildren {
+			if _, exists := nextGraph.nodes[childID]; !exists || childID == rootID {
+				return false
+			}
+			if _, duplicate := newParent[childID]; duplicate {
+				return false
+			}
+			newParent[childID] = parentID
+		}
+	}
+
+	for childID := range newParent {
+		if _, hadTouchedParent := oldParent[childID]; !hadTouchedParent && !added[childID] {
+			return false
+		}
+	}
+	for childID := range oldParent {
+		if _, hasParent := newParent[childID]; !hasParent && !removed[childID] {
+			return false
+		}
+	}
+	for nodeID := range added {
+		if _, hasParent := newParent[nodeID]; !hasParent && nodeID != rootID {
+			return false
+		}
+	}
+	for nodeID := range removed {
+		if nodeID == rootID {
+			return false
+		}
+		if _, hadTouchedParent := oldParent[nodeID]; !hadTouchedParent {
+			return false
+		}
+		if _, hasChildren := nextGraph.childIDs[nodeID]; hasChildren {
+			return false
+		}
+	}
+	for childID, parentID := range newParent {
+		if oldParent[childID] != parentID && mirSubtreeContains(nextGraph, childID, parentID) {
+			return false
+		}
+	}
+	return true
+}
+
+// mirSubtreeContains walks the subtree under rootID looking for target. A
+// repeated node also counts as found: the walk has hit a cycle.
+func mirSubtreeContains(graph *mirGraphIndex, rootID string, target string) bool {
+	visited := map[string]bool{rootID: true}
+	pending := []string{rootID}
+	for len(pending) > 0 {
+		nodeID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		children, ok := graph.children(nodeID)
+		if !ok {
+			return true
+		}
+		for _, childID := range children {
+			if childID == target || visited[childID] {
+				return true
+			}
+			visited[childID] = true
+			pending = append(pending, childID)
+		}
+	}
+	return false
+}
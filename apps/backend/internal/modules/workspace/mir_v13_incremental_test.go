@@ -0,0 +1,211 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// generateMIRTestGraph builds a valid v1.3 document with nodeCount nodes in a
+// random tree; every node carries a few props so node bodies dominate size.
+func generateMIRTestGraph(nodeCount int, rng *rand.Rand) json.RawMessage {
+	nodesByID := make(map[string]any, nodeCount)
+	childIDsByID := make(map[string]any, nodeCount)
+	for index := 0; index < nodeCount; index++ {
+		nodeID := fmt.Sprintf("n%d", index)
+		nodesByID[nodeID] = map[string]any{
+			"id":    nodeID,
+			"type":  "container",
+			"props": map[string]any{"label": nodeID, "gap": index % 16},
+			"style": map[string]any{"padding": "8px", "display": "flex"},
+		}
+		childIDsByID[nodeID] = []any{}
+		if index > 0 {
+			parentID := fmt.Sprintf("n%d", rng.Intn(index))
+			childIDsByID[parentID] = append(childIDsByID[parentID].([]any), nodeID)
+		}
+	}
+	document, _ := json.Marshal(map[string]any{
+		"version": "1.3",
+		"ui": map[string]any{"graph": map[string]any{
+			"version": 1, "rootId": "n0", "nodesById": nodesByID, "childIdsById": childIDsByID,
+		}},
+	})
+	return document
+}
+
+func mirTestPatchOp(op, path string, value any) WorkspacePatchOp {
+	raw, _ := json.Marshal(value)
+	if value == nil {
+		raw = nil
+	}
+	return WorkspacePatchOp{Op: op, Path: path, Value: raw}
+}
+
+// randomMIRTestPatch picks a random edit; roughly half of them break the
+// graph so both the fast path and the fallback get exercised.
+func randomMIRTestPatch(document json.RawMessage, rng *rand.Rand, nodeCount int) []WorkspacePatchOp {
+	var decoded struct {
+		UI struct {
+			Graph struct {
+				ChildIDsByID map[string][]string `json:"childIdsById"`
+			} `json:"graph"`
+		} `json:"ui"`
+	}
+	_ = json.Unmarshal(document, &decoded)
+	childIDsByID := decoded.UI.Graph.ChildIDsByID
+	parentOf := make(map[string]string)
+	for parentID, children := range childIDsByID {
+		for _, childID := range children {
+			parentOf[childID] = parentID
+		}
+	}
+	node := func() string { return fmt.Sprintf("n%d", rng.Intn(nodeCount)) }
+	childIndex := func(parentID, childID string) int {
+		for index, candidate := range childIDsByID[parentID] {
+			if candidate == childID {
+				return index
+			}
+		}
+		return -1
+	}
+	nodePath := func(nodeID string) string { return jsonPointerPath("ui", "graph", "nodesById", nodeID) }
+	listPath := func(nodeID string) string { return jsonPointerPath("ui", "graph", "childIdsById", nodeID) }
+
+	switch rng.Intn(9) {
+	case 0:
+		return []WorkspacePatchOp{mirTestPatchOp("replace", nodePath(node())+"/props/label", "edited")}
+	case 1:
+		// Move a non-root node under another node; may create a cycle.
+		childID, targetID := fmt.Sprintf("n%d", 1+rng.Intn(nodeCount-1)), node()
+		parentID := parentOf[childID]
+		return []WorkspacePatchOp{
+			mirTestPatchOp("remove", fmt.Sprintf("%s/%d", listPath(parentID), childIndex(parentID, childID)), nil),
+			mirTestPatchOp("add", listPath(targetID)+"/-", childID),
+		}
+	case 2:
+		parentID := node()
+		return []WorkspacePatchOp{
+			mirTestPatchOp("add", nodePath("fresh"), map[string]any{"id": "fresh", "type": "text"}),
+			mirTestPatchOp("add", listPath(parentID)+"/0", "fresh"),
+		}
+	case 3:
+		// New node without a parent: orphan.
+		return []WorkspacePatchOp{mirTestPatchOp("add", nodePath("fresh"), map[string]any{"id": "fresh", "type": "text"})}
+	case 4:
+		childID := fmt.Sprintf("n%d", 1+rng.Intn(nodeCount-1))
+		parentID := parentOf[childID]
+		ops := []WorkspacePatchOp{
+			mirTestPatchOp("remove", fmt.Sprintf("%s/%d", listPath(parentID), childIndex(parentID, childID)), nil),
+			mirTestPatchOp("remove", nodePath(childID), nil),
+		}
+		if rng.Intn(2) == 0 {
+			ops = append(ops, mirTestPatchOp("remove", listPath(childID), nil))
+		}
+		return ops
+	case 5:
+		return []WorkspacePatchOp{mirTestPatchOp("replace", nodePath(node())+"/id", "renamed")}
+	case 6:
+		// Second parent for an existing node.
+		return []WorkspacePatchOp{mirTestPatchOp("add", listPath(node())+"/-", fmt.Sprintf("n%d", 1+rng.Intn(nodeCount-1)))}
+	case 7:
+		childID := fmt.Sprintf("n%d", 1+rng.Intn(nodeCount-1))
+		parentID := parentOf[childID]
+		return []WorkspacePatchOp{mirTestPatchOp("remove", fmt.Sprintf("%s/%d", listPath(parentID), childIndex(parentID, childID)), nil)}
+	default:
+		return []WorkspacePatchOp{mirTestPatchOp("add", listPath(node())+"/-", "n0")}
+	}
+}
+
+func TestValidateMIRV13PatchMatchesFullValidation(t *testing.T) {
+	rng := rand.New(rand.NewSource(19))
+	const nodeCount = 40
+	accepted, rejected := 0, 0
+	for round := 0; round < 400; round++ {
+		previous := generateMIRTestGraph(nodeCount, rng)
+		ops := randomMIRTestPatch(previous, rng, nodeCount)
+		next, err := applyWorkspacePatch(previous, ops)
+		if err != nil {
+			continue
+		}
+		incremental := validateMIRV13Patch(previous, next, ops)
+		full := validateMIRV13Document(next)
+		if !reflect.DeepEqual(incremental, full) {
+			t.Fatalf("round %d: incremental %v, full %v, ops %+v", round, incremental, full, ops)
+		}
+		if full == nil {
+			accepted++
+		} else {
+			rejected++
+		}
+	}
+	if accepted == 0 || rejected == 0 {
+		t.Fatalf("generator should produce both outcomes: %d accepted, %d rejected", accepted, rejected)
+	}
+}
+
+func TestMIRPatchScopeFallsBackOutsideNodesAndChildLists(t *testing.T) {
+	if _, ok := mirPatchScopeFor([]WorkspacePatchOp{{Op: "replace", Path: "/ui/graph/rootId"}}); ok {
+		t.Fatal("rootId edits need full validation")
+	}
+	if _, ok := mirPatchScopeFor([]WorkspacePatchOp{{Op: "move", From: "/ui/graph/nodesById", Path: "/x-backup"}}); ok {
+		t.Fatal("whole nodesById edits need full validation")
+	}
+	scope, ok := mirPatchScopeFor([]WorkspacePatchOp{{Op: "replace", Path: "/logic/state/count"}, {Op: "replace", Path: "/ui/graph/nodesById/a~1b/props/x"}})
+	if !ok || !scope.nodes["a/b"] || scope.reshaped || len(scope.parents) != 0 {
+		t.Fatalf("unexpected scope: %+v %v", scope, ok)
+	}
+}
+
+func benchmarkMIRPatchValidation(b *testing.B, ops func() []WorkspacePatchOp, validate func(previous, next json.RawMessage, ops []WorkspacePatchOp) error) {
+	rng := rand.New(rand.NewSource(5000))
+	previous := generateMIRTestGraph(5000, rng)
+	patch := ops()
+	next, err := applyWorkspacePatch(previous, patch)
+	if err != nil {
+		b.Fatalf("apply patch: %v", err)
+	}
+	if err := validateMIRV13Document(next); err != nil {
+		b.Fatalf("benchmark patch should be valid: %v", err)
+	}
+	b.ResetTimer()
+	for index := 0; index < b.N; index++ {
+		if err := validate(previous, next, patch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func mirBenchmarkPropEdit() []WorkspacePatchOp {
+	return []WorkspacePatchOp{mirTestPatchOp("replace", "/ui/graph/nodesById/n4200/props/label", "edited")}
+}
+
+// mirBenchmarkInsert creates a node and appends it to the root's children.
+func mirBenchmarkInsert() []WorkspacePatchOp {
+	return []WorkspacePatchOp{
+		mirTestPatchOp("add", "/ui/graph/nodesById/fresh", map[string]any{"id": "fresh", "type": "text"}),
+		mirTestPatchOp("add", "/ui/graph/childIdsById/n0/-", "fresh"),
+	}
+}
+
+func validateMIRV13Full(_ json.RawMessage, next json.RawMessage, _ []WorkspacePatchOp) error {
+	return validateMIRV13Document(next)
+}
+
+func BenchmarkMIRPatchValidationPropEditFull(b *testing.B) {
+	benchmarkMIRPatchValidation(b, mirBenchmarkPropEdit, validateMIRV13Full)
+}
+
+func BenchmarkMIRPatchValidationPropEditIncremental(b *testing.B) {
+	benchmarkMIRPatchValidation(b, mirBenchmarkPropEdit, validateMIRV13Patch)
+}
+
+func BenchmarkMIRPatchValidationInsertFull(b *testing.B) {
+	benchmarkMIRPatchValidation(b, mirBenchmarkInsert, validateMIRV13Full)
+}
+
+func BenchmarkMIRPatchValidationInsertIncremental(b *testing.B) {
+	benchmarkMIRPatchValidation(b, mirBenchmarkInsert, validateMIRV13Patch)
+}
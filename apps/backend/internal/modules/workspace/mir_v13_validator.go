@@ -2,112 +2,187 @@ package workspace
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/mircontract"
 )
 
-var ErrMIRV13ValidationFailed = errors.New("MIR " + mircontract.CurrentLabel + " validation failed")
+const mirDomain = "mir"
 
+const (
+	DiagnosticMIRTreeRootForbidden  = "MIR-1001"
+	DiagnosticMIRGraphMissing       = "MIR-1002"
+	DiagnosticMIRNodeInvalid        = "MIR-1003"
+	DiagnosticMIRVersionUnsupported = "MIR-1004"
+	DiagnosticMIRGraphShapeInvalid  = "MIR-1005"
+	DiagnosticMIRRootNotFound       = "MIR-2001"
+	DiagnosticMIRNodeKeyMismatch    = "MIR-2002"
+	DiagnosticMIRChildNotFound      = "MIR-2003"
+	DiagnosticMIRGraphCycle         = "MIR-2004"
+	DiagnosticMIRMultipleParents    = "MIR-2005"
+	DiagnosticMIROrphanNode         = "MIR-2006"
+)
+
+func mirNodeTarget(nodeID string) map[string]any {
+	return map[string]any{"kind": "mir-node", "nodeId": nodeID}
+}
+
+func sortedKeys[V any](object map[string]V) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateMIRV13Document checks the saved MIR shape and the ui.graph
+// structure, reporting every problem it finds. Node-level diagnostics carry a
+// mir-node targetRef so the editor can highlight the node; the document id is
+// filled in by the caller (see withDocumentTarget).
 func validateMIRV13Document(payload json.RawMessage) error {
 	var document map[string]any
 	if err := json.Unmarshal(payload, &document); err != nil {
 		return err
 	}
+	diagnostics := diagnosticReport{domain: mirDomain}
 	if document["version"] != mircontract.CurrentVersion {
-		return fmt.Errorf("%w: version must be %s", ErrMIRV13ValidationFailed, mircontract.CurrentVersion)
+		diagnostics.add(DiagnosticMIRVersionUnsupported, "/version", "version must be "+mircontract.CurrentVersion)
 	}
 	ui, ok := document["ui"].(map[string]any)
 	if !ok {
-		return fmt.Errorf("%w: ui is required", ErrMIRV13ValidationFailed)
+		diagnostics.add(DiagnosticMIRGraphMissing, "/ui", "ui is required")
+		return diagnostics.err()
 	}
 	if _, hasRoot := ui["root"]; hasRoot {
-		return fmt.Errorf("%w: ui.root is forbidden", ErrMIRV13ValidationFailed)
+		diagnostics.add(DiagnosticMIRTreeRootForbidden, "/ui/root", "ui.root is forbidden")
 	}
 	graph, ok := ui["graph"].(map[string]any)
 	if !ok {
-		return fmt.Errorf("%w: ui.graph is required", ErrMIRV13ValidationFailed)
+		diagnostics.add(DiagnosticMIRGraphMissing, "/ui/graph", "ui.graph is required")
+		return diagnostics.err()
 	}
-	rootID, ok := graph["rootId"].(string)
-	if !ok || rootID == "" {
-		return fmt.Errorf("%w: ui.graph.rootId is required", ErrMIRV13ValidationFailed)
+	rootID, _ := graph["rootId"].(string)
+	if rootID == "" {
+		diagnostics.add(DiagnosticMIRGraphShapeInvalid, "/ui/graph/rootId", "ui.graph.rootId is required")
 	}
 	nodesByID, ok := graph["nodesById"].(map[string]any)
 	if !ok || len(nodesByID) == 0 {
-		return fmt.Errorf("%w: ui.graph.nodesById is required", ErrMIRV13ValidationFailed)
+		diagnostics.add(DiagnosticMIRGraphShapeInvalid, "/ui/graph/nodesById", "ui.graph.nodesById is required")
+		return diagnostics.err()
 	}
-	if _, ok := nodesByID[rootID]; !ok {
-		return fmt.Errorf("%w: rootId not found in nodesById", ErrMIRV13ValidationFailed)
+	if _, ok := nodesByID[rootID]; rootID != "" && !ok {
+		diagnostics.add(DiagnosticMIRRootNotFound, "/ui/graph/rootId", fmt.Sprintf("root node %q not found in nodesById", rootID))
 	}
-	for key, rawNode := range nodesByID {
-		node, ok := rawNode.(map[string]any)
-		if !ok {
-			return fmt.Errorf("%w: node %s must be object", ErrMIRV13ValidationFailed, key)
-		}
-		if node["id"] != key {
-			return fmt.Errorf("%w: node key/id mismatch at %s", ErrMIRV13ValidationFailed, key)
-		}
-		if nodeType, ok := node["type"].(string); !ok || nodeType == "" {
-			return fmt.Errorf("%w: node %s type is required", ErrMIRV13ValidationFailed, key)
-		}
-		if _, hasChildren := node["children"]; hasChildren {
-			return fmt.Errorf("%w: node %s must not contain children", ErrMIRV13ValidationFailed, key)
-		}
+
+	for _, key := range sortedKeys(nodesByID) {
+		checkMIRNode(&diagnostics, key, nodesByID[key])
+	}
+
+	// parentByChild keeps the first structural parent of each node; any
+	// further parent is reported and left out of the cycle and reachability
+	// checks below.
+	childIDsByID, ok := graph["childIdsById"].(map[string]any)
+	if _, exists := graph["childIdsById"]; exists && !ok {
+		diagnostics.add(DiagnosticMIRGraphShapeInvalid, "/ui/graph/childIdsById", "ui.graph.childIdsById must be an object")
 	}
-	childIDsByID, _ := graph["childIdsById"].(map[string]any)
-	parentByChild := map[string]string{}
-	for parentID, rawChildren := range childIDsByID {
+	parentByChild := make(map[string]string)
+	parentEdgePath := make(map[string]string)
+	childrenByParent := make(map[string][]string)
+	for _, parentID := range sortedKeys(childIDsByID) {
+		path := jsonPointerPath("ui", "graph", "childIdsById", parentID)
 		if _, ok := nodesByID[parentID]; !ok {
-			return fmt.Errorf("%w: childIdsById owner %s not found", ErrMIRV13ValidationFailed, parentID)
+			diagnostics.addTarget(DiagnosticMIRChildNotFound, path, fmt.Sprintf("childIdsById owner %q not found in nodesById", parentID), mirNodeTarget(parentID))
+			continue
 		}
-		children, ok := rawChildren.([]any)
+		children, ok := childIDsByID[parentID].([]any)
 		if !ok {
-			return fmt.Errorf("%w: childIdsById.%s must be array", ErrMIRV13ValidationFailed, parentID)
+			diagnostics.addTarget(DiagnosticMIRGraphShapeInvalid, path, "childIdsById entries must be arrays", mirNodeTarget(parentID))
+			continue
 		}
-		for _, rawChildID := range children {
+		for index, rawChildID := range children {
+			childPath := path + "/" + indexSegment(index)
 			childID, ok := rawChildID.(string)
 			if !ok || childID == "" {
-				return fmt.Errorf("%w: child id must be string", ErrMIRV13ValidationFailed)
+				diagnostics.addTarget(DiagnosticMIRGraphShapeInvalid, childPath, "child id must be a non-empty string", mirNodeTarget(parentID))
+				continue
 			}
 			if _, ok := nodesByID[childID]; !ok {
-				return fmt.Errorf("%w: child %s not found", ErrMIRV13ValidationFailed, childID)
+				diagnostics.addTarget(DiagnosticMIRChildNotFound, childPath, fmt.Sprintf("child %q not found in nodesById", childID), mirNodeTarget(parentID))
+				continue
 			}
 			if previous, exists := parentByChild[childID]; exists {
-				return fmt.Errorf("%w: child %s has multiple parents %s and %s", ErrMIRV13ValidationFailed, childID, previous, parentID)
+				diagnostics.addTarget(DiagnosticMIRMultipleParents, childPath, fmt.Sprintf("node %q already has parent %q", childID, previous), mirNodeTarget(childID))
+				continue
 			}
 			parentByChild[childID] = parentID
+			parentEdgePath[childID] = childPath
+			childrenByParent[parentID] = append(childrenByParent[parentID], childID)
 		}
 	}
-	visited := map[string]bool{}
-	visiting := map[string]bool{}
-	var visit func(string) error
-	visit = func(nodeID string) error {
-		if visiting[nodeID] {
-			return fmt.Errorf("%w: cycle detected at %s", ErrMIRV13ValidationFailed, nodeID)
-		}
-		if visited[nodeID] {
-			return nil
+
+	// With one parent per node, a cycle is a parent chain that returns to
+	// where it started. Each cycle is reported once, at its smallest node id.
+	inCycle := make(map[string]bool)
+	for _, nodeID := range sortedKeys(parentByChild) {
+		if inCycle[nodeID] {
+			continue
 		}
-		visiting[nodeID] = true
-		if rawChildren, ok := childIDsByID[nodeID]; ok {
-			for _, rawChildID := range rawChildren.([]any) {
-				if err := visit(rawChildID.(string)); err != nil {
-					return err
+		seen := map[string]bool{nodeID: true}
+		cycle := []string{nodeID}
+		for current := parentByChild[nodeID]; current != ""; current = parentByChild[current] {
+			if current == nodeID {
+				for _, member := range cycle {
+					inCycle[member] = true
 				}
+				diagnostics.addTarget(DiagnosticMIRGraphCycle, parentEdgePath[nodeID], fmt.Sprintf("node %q is its own ancestor", nodeID), mirNodeTarget(nodeID))
+				break
+			}
+			if seen[current] {
+				break
 			}
+			seen[current] = true
+			cycle = append(cycle, current)
 		}
-		delete(visiting, nodeID)
-		visited[nodeID] = true
-		return nil
 	}
-	if err := visit(rootID); err != nil {
-		return err
+
+	reachable := make(map[string]bool)
+	pending := []string{rootID}
+	for len(pending) > 0 {
+		nodeID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[nodeID] {
+			continue
+		}
+		reachable[nodeID] = true
+		pending = append(pending, childrenByParent[nodeID]...)
 	}
-	for nodeID := range nodesByID {
-		if !visited[nodeID] {
-			return fmt.Errorf("%w: orphan node %s", ErrMIRV13ValidationFailed, nodeID)
+	for _, nodeID := range sortedKeys(nodesByID) {
+		if !reachable[nodeID] && !inCycle[nodeID] {
+			diagnostics.addTarget(DiagnosticMIROrphanNode, jsonPointerPath("ui", "graph", "nodesById", nodeID), fmt.Sprintf("node %q is not reachable from the root", nodeID), mirNodeTarget(nodeID))
 		}
 	}
-	return nil
+	return diagnostics.err()
+}
+
+// checkMIRNode reports the shape problems of a single nodesById entry.
+func checkMIRNode(diagnostics *diagnosticReport, key string, value any) {
+	path := jsonPointerPath("ui", "graph", "nodesById", key)
+	target := mirNodeTarget(key)
+	node, ok := value.(map[string]any)
+	if !ok {
+		diagnostics.addTarget(DiagnosticMIRNodeInvalid, path, "node must be an object", target)
+		return
+	}
+	if node["id"] != key {
+		diagnostics.addTarget(DiagnosticMIRNodeKeyMismatch, path+"/id", fmt.Sprintf("node id must match its key %q", key), target)
+	}
+	if nodeType, ok := node["type"].(string); !ok || strings.TrimSpace(nodeType) == "" {
+		diagnostics.addTarget(DiagnosticMIRNodeInvalid, path+"/type", "node type is required", target)
+	}
+	if _, hasChildren := node["children"]; hasChildren {
+		diagnostics.addTarget(DiagnosticMIRNodeInvalid, path+"/children", "node must not contain children; use childIdsById", target)
+	}
 }
@@ -0,0 +1,65 @@
+package workspace
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	backendresponse "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/http/response"
+)
+
+func TestValidateMIRV13DocumentReportsEveryProblem(t *testing.T) {
+	err := validateMIRV13Document(json.RawMessage(`{"version":"1.3","ui":{"graph":{"version":1,"rootId":"root",` +
+		`"nodesById":{"root":{"id":"root","type":"container"},"a":{"id":"a","type":"container"},"b":{"id":"bee","type":"text"},` +
+		`"c":{"id":"c","type":"text"},"d":{"id":"d","type":"text"},"e":{"id":"e","type":"text"},"orphan":{"id":"orphan","type":"text"}},` +
+		`"childIdsById":{"root":["a","ghost"],"a":["b","c"],"b":["c"],"d":["e"],"e":["d"]}}}}`))
+	err = withDocumentTarget(err, "page_1")
+
+	failure := MapStoreError(err)
+	if failure == nil || failure.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %v", err)
+	}
+	payload, _ := failure.Payload["error"].(backendresponse.ErrorPayload)
+	if payload.Code != DiagnosticMIRNodeKeyMismatch || payload.Domain != "mir" {
+		t.Fatalf("unexpected failure payload: %+v", payload)
+	}
+	expected := []struct {
+		code   string
+		path   string
+		nodeID string
+	}{
+		{"MIR-2002", "/ui/graph/nodesById/b/id", "b"},
+		{"MIR-2005", "/ui/graph/childIdsById/b/0", "c"},
+		{"MIR-2003", "/ui/graph/childIdsById/root/1", "root"},
+		{"MIR-2004", "/ui/graph/childIdsById/e/0", "d"},
+		{"MIR-2006", "/ui/graph/nodesById/orphan", "orphan"},
+	}
+	if len(payload.Diagnostics) != len(expected) {
+		t.Fatalf("unexpected diagnostics: %+v", payload.Diagnostics)
+	}
+	for index, want := range expected {
+		diagnostic := payload.Diagnostics[index]
+		if diagnostic.Code != want.code || diagnostic.Path != want.path ||
+			diagnostic.TargetRef["nodeId"] != want.nodeID || diagnostic.TargetRef["documentId"] != "page_1" {
+			t.Fatalf("unexpected diagnostic %d: %+v", index, diagnostic)
+		}
+	}
+}
+
+func TestValidateMIRV13DocumentRejectsLegacyShape(t *testing.T) {
+	err := validateMIRV13Document(json.RawMessage(`{"version":"1.2","ui":{"root":{"id":"root","type":"container"}}}`))
+	validationErr, ok := err.(*DocumentValidationError)
+	if !ok {
+		t.Fatalf("expected MIR validation error, got %v", err)
+	}
+	codes := make([]string, 0, len(validationErr.Diagnostics))
+	for _, diagnostic := range validationErr.Diagnostics {
+		codes = append(codes, diagnostic.Code+" "+diagnostic.Path)
+	}
+	if len(codes) != 3 || codes[0] != "MIR-1004 /version" || codes[1] != "MIR-1001 /ui/root" || codes[2] != "MIR-1002 /ui/graph" {
+		t.Fatalf("unexpected diagnostics: %v", codes)
+	}
+	if err := validateMIRV13Document(defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRLayout)); err != nil {
+		t.Fatalf("default layout should be valid: %v", err)
+	}
+}
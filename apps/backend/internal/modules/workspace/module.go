@@ -12,19 +12,42 @@ import (
 )
 
 type Module struct {
-	store          *WorkspaceStore
-	projects       *backendproject.ProjectStore
-	intentHandlers []IntentHandler
+	store           *WorkspaceStore
+	projects        *backendproject.ProjectStore
+	access          WorkspaceAccessPolicy
+	intents         *IntentRegistry
+	capabilityFlags CapabilityFlags
+	events          *WorkspaceEventBroker
 }
 
 func NewModule(store *WorkspaceStore, projects *backendproject.ProjectStore) *Module {
 	return &Module{
-		store:          store,
-		projects:       projects,
-		intentHandlers: defaultIntentHandlers(),
+		store:    store,
+		projects: projects,
+		access:   NewOwnerWorkspaceAccessPolicy(store),
+		intents:  defaultIntentRegistry(),
+		events:   NewWorkspaceEventBroker(),
 	}
 }
 
+// SetAccessPolicy replaces the policy consulted before every workspace read
+// and write. Passing nil restores the owner-only default.
+func (module *Module) SetAccessPolicy(policy WorkspaceAccessPolicy) {
+	if module == nil {
+		return
+	}
+	module.access = policy
+}
+
+// SetCapabilityFlags installs the environment capability flags. They take
+// precedence over workspace settings and can disable any intent.
+func (module *Module) SetCapabilityFlags(flags CapabilityFlags) {
+	if module == nil {
+		return
+	}
+	module.capabilityFlags = flags
+}
+
 func (module *Module) Store() *WorkspaceStore {
 	if module == nil {
 		return nil
@@ -40,6 +63,11 @@ func (module *Module) BootstrapProjectWorkspace(ctx context.Context, project *ba
 	if workspaceID == "" {
 		return errors.New("project id is required to bootstrap workspace")
 	}
+	// Legacy projects are upgraded here so their first workspace document is
+	// already on the current MIR contract.
+	if _, err := backendproject.UpgradeMIR(project); err != nil {
+		return err
+	}
 	if _, err := module.store.CreateWorkspace(ctx, CreateWorkspaceParams{
 		WorkspaceID: workspaceID,
 		ProjectID:   project.ID,
@@ -52,7 +80,7 @@ func (module *Module) BootstrapProjectWorkspace(ctx context.Context, project *ba
 	}
 	if _, err := module.store.CreateDocument(ctx, CreateWorkspaceDocumentParams{
 		WorkspaceID: workspaceID,
-		DocumentID:  "doc_root",
+		DocumentID:  canonicalWorkspaceDocumentID,
 		Type:        WorkspaceDocumentTypeMIRPage,
 		Name:        "Root",
 		Path:        "/mir.json",
@@ -67,10 +95,32 @@ func (module *Module) GetSnapshotForUser(ctx context.Context, userID string, wor
 	if module == nil || module.store == nil {
 		return nil, errors.New("workspace module is not initialized")
 	}
+	return module.getSnapshotForUser(ctx, userID, workspaceID, module.store.GetSnapshot)
+}
+
+func (module *Module) getSnapshotForUser(
+	ctx context.Context,
+	userID string,
+	workspaceID string,
+	load func(ctx context.Context, workspaceID string) (*WorkspaceSnapshot, error),
+) (*WorkspaceSnapshot, error) {
 	normalizedWorkspaceID := strings.TrimSpace(workspaceID)
-	snapshot, err := module.store.GetSnapshot(ctx, normalizedWorkspaceID)
+	err := module.AuthorizeWorkspace(ctx, userID, normalizedWorkspaceID, WorkspaceActionRead)
+	return module.loadSnapshotForUser(ctx, userID, normalizedWorkspaceID, err, load)
+}
+
+// loadSnapshotForUser finishes a snapshot read whose authorization returned
+// authErr, bootstrapping a workspace the user's project does not have yet.
+// Callers that already authorized pass the result instead of checking again.
+func (module *Module) loadSnapshotForUser(
+	ctx context.Context,
+	userID string,
+	normalizedWorkspaceID string,
+	err error,
+	load func(ctx context.Context, workspaceID string) (*WorkspaceSnapshot, error),
+) (*WorkspaceSnapshot, error) {
 	if err == nil {
-		return snapshot, nil
+		return load(ctx, normalizedWorkspaceID)
 	}
 	if !errors.Is(err, ErrWorkspaceNotFound) {
 		return nil, err
@@ -88,7 +138,7 @@ func (module *Module) GetSnapshotForUser(ctx context.Context, userID string, wor
 	if bootstrapErr := module.BootstrapProjectWorkspace(ctx, project); bootstrapErr != nil {
 		return nil, bootstrapErr
 	}
-	return module.store.GetSnapshot(ctx, normalizedWorkspaceID)
+	return load(ctx, normalizedWorkspaceID)
 }
 
 func ResolveCanonicalWorkspaceMIR(snapshot *WorkspaceSnapshot) (json.RawMessage, bool) {
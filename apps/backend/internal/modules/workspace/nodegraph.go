@@ -0,0 +1,552 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	nodeGraphNamespace = "core.nodegraph"
+	nodeGraphDomain    = "nodegraph"
+)
+
+const (
+	DiagnosticNodeGraphShapeInvalid   = "NGR-1002"
+	DiagnosticNodeGraphNodeExists     = "NGR-1003"
+	DiagnosticNodeGraphNodeNotFound   = "NGR-1004"
+	DiagnosticNodeGraphPortMismatch   = "NGR-2002"
+	DiagnosticNodeGraphPortNotFound   = "NGR-2003"
+	DiagnosticNodeGraphPortDirection  = "NGR-2010"
+	DiagnosticNodeGraphEdgeNotFound   = "NGR-3002"
+	DiagnosticNodeGraphEdgeDuplicated = "NGR-3010"
+)
+
+// Port kinds follow decision 20; "condition" is the editor's internal name
+// for the node kind.
+const (
+	nodeGraphPortControl = "control"
+	nodeGraphPortData    = "data"
+	nodeGraphPortNode    = "node"
+)
+
+type nodeGraphPort struct {
+	ID           string   `json:"id"`
+	Role         string   `json:"role"`
+	Kind         string   `json:"kind"`
+	Multiplicity string   `json:"multiplicity,omitempty"`
+	AcceptsKinds []string `json:"acceptsKinds,omitempty"`
+}
+
+func normalizeNodeGraphPortKind(kind string) string {
+	if kind == "condition" {
+		return nodeGraphPortNode
+	}
+	return kind
+}
+
+func isNodeGraphPortKind(kind string) bool {
+	switch normalizeNodeGraphPortKind(kind) {
+	case nodeGraphPortControl, nodeGraphPortData, nodeGraphPortNode:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultNodeGraphMultiplicity: control.out and data.in and node.in take a
+// single edge, the other ends take many.
+func defaultNodeGraphMultiplicity(role, kind string) string {
+	switch {
+	case kind == nodeGraphPortControl && role == "in",
+		kind == nodeGraphPortData && role == "out",
+		kind == nodeGraphPortNode && role == "out":
+		return "multi"
+	default:
+		return "single"
+	}
+}
+
+func (port nodeGraphPort) normalized() nodeGraphPort {
+	port.Kind = normalizeNodeGraphPortKind(port.Kind)
+	if port.Multiplicity == "" {
+		port.Multiplicity = defaultNodeGraphMultiplicity(port.Role, port.Kind)
+	}
+	if len(port.AcceptsKinds) == 0 {
+		port.AcceptsKinds = []string{port.Kind}
+	}
+	for index, kind := range port.AcceptsKinds {
+		port.AcceptsKinds[index] = normalizeNodeGraphPortKind(kind)
+	}
+	return port
+}
+
+func (port nodeGraphPort) accepts(kind string) bool {
+	for _, accepted := range port.AcceptsKinds {
+		if accepted == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveNodeGraphPort finds handle among the node's declared ports. Nodes
+// without a ports list fall back to the editor's handle naming,
+// "<role>.<kind>.<name>".
+func resolveNodeGraphPort(node map[string]any, handle string) (nodeGraphPort, bool) {
+	if raw, declared := node["ports"]; declared {
+		var ports []nodeGraphPort
+		if encoded, err := json.Marshal(raw); err != nil || json.Unmarshal(encoded, &ports) != nil {
+			return nodeGraphPort{}, false
+		}
+		for _, port := range ports {
+			if port.ID == handle {
+				return port.normalized(), true
+			}
+		}
+		return nodeGraphPort{}, false
+	}
+	parts := strings.SplitN(handle, ".", 3)
+	if len(parts) != 3 || (parts[0] != "in" && parts[0] != "out") || !isNodeGraphPortKind(parts[1]) || parts[2] == "" {
+		return nodeGraphPort{}, false
+	}
+	return nodeGraphPort{ID: handle, Role: parts[0], Kind: parts[1]}.normalized(), true
+}
+
+type nodeGraphEdge struct {
+	ID           string `json:"id"`
+	Source       string `json:"source"`
+	SourceHandle string `json:"sourceHandle"`
+	Target       string `json:"target"`
+	TargetHandle string `json:"targetHandle"`
+}
+
+func decodeNodeGraphEdge(raw any) (nodeGraphEdge, bool) {
+	object, ok := raw.(map[string]any)
+	if !ok {
+		return nodeGraphEdge{}, false
+	}
+	var edge nodeGraphEdge
+	edge.ID, _ = object["id"].(string)
+	edge.Source, _ = object["source"].(string)
+	edge.SourceHandle, _ = object["sourceHandle"].(string)
+	edge.Target, _ = object["target"].(string)
+	edge.TargetHandle, _ = object["targetHandle"].(string)
+	return edge, true
+}
+
+// nodeGraphDocument is a view over the nodesById and edgesById objects of a
+// decoded mir-graph document; edits write through to the document.
+type nodeGraphDocument struct {
+	nodes map[string]any
+	edges map[string]any
+}
+
+func openNodeGraphDocument(documentType WorkspaceDocumentType, document map[string]any) (nodeGraphDocument, error) {
+	if documentType != WorkspaceDocumentTypeMIRGraph {
+		return nodeGraphDocument{}, ErrInvalidWorkspaceDocumentType
+	}
+	graph := nodeGraphDocument{}
+	for key, target := range map[string]*map[string]any{"nodesById": &graph.nodes, "edgesById": &graph.edges} {
+		raw, exists := document[key]
+		if !exists {
+			raw = make(map[string]any)
+			document[key] = raw
+		}
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return nodeGraphDocument{}, documentValidationError(nodeGraphDomain, DiagnosticNodeGraphShapeInvalid, jsonPointerPath(key), key+" must be an object")
+		}
+		*target = object
+	}
+	return graph, nil
+}
+
+func (graph nodeGraphDocument) node(nodeID string) (map[string]any, error) {
+	node, ok := graph.nodes[nodeID].(map[string]any)
+	if !ok {
+		return nil, documentValidationError(nodeGraphDomain, DiagnosticNodeGraphNodeNotFound, jsonPointerPath("nodesById", nodeID), fmt.Sprintf("node %q does not exist", nodeID))
+	}
+	return node, nil
+}
+
+// edgeIDs returns the ids of edges matching keep, sorted for stable ops.
+func (graph nodeGraphDocument) edgeIDs(keep func(edge nodeGraphEdge) bool) []string {
+	ids := make([]string, 0)
+	for id, raw := range graph.edges {
+		if edge, ok := decodeNodeGraphEdge(raw); ok && keep(edge) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// validateNodeGraphDocument checks a mir-graph document: node and edge ids
+// match their keys, declared ports are well formed, every edge joins an out
+// port to a compatible in port of existing nodes, and no two edges join the
+// same pair of ports. It runs for intents and generic PATCHes alike.
+func validateNodeGraphDocument(payload json.RawMessage) error {
+	var document map[string]any
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return err
+	}
+	if document == nil {
+		return documentValidationError(nodeGraphDomain, DiagnosticNodeGraphShapeInvalid, "", "graph document must be an object")
+	}
+	diagnostics := diagnosticReport{domain: nodeGraphDomain}
+	report := diagnostics.add
+	if version, ok := document["version"].(float64); !ok || version != 1 {
+		report(DiagnosticNodeGraphShapeInvalid, "/version", "graph document version must be 1")
+	}
+	nodes, nodesOK := document["nodesById"].(map[string]any)
+	if _, exists := document["nodesById"]; exists && !nodesOK {
+		report(DiagnosticNodeGraphShapeInvalid, "/nodesById", "nodesById must be an object")
+	}
+	edges, edgesOK := document["edgesById"].(map[string]any)
+	if _, exists := document["edgesById"]; exists && !edgesOK {
+		report(DiagnosticNodeGraphShapeInvalid, "/edgesById", "edgesById must be an object")
+	}
+
+	nodeIDs := make([]string, 0, len(nodes))
+	for id := range nodes {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	for _, id := range nodeIDs {
+		path := jsonPointerPath("nodesById", id)
+		node, ok := nodes[id].(map[string]any)
+		if !ok {
+			report(DiagnosticNodeGraphShapeInvalid, path, "node must be an object")
+			continue
+		}
+		if nodeID, _ := node["id"].(string); nodeID != id {
+			report(DiagnosticNodeGraphShapeInvalid, path+"/id", "node id must match its key")
+		}
+		if nodeType, _ := node["type"].(string); strings.TrimSpace(nodeType) == "" {
+			report(DiagnosticNodeGraphShapeInvalid, path+"/type", "node type is required")
+		}
+		if position, exists := node["position"]; exists && !isNodeGraphPosition(position) {
+			report(DiagnosticNodeGraphShapeInvalid, path+"/position", "node position must have finite x and y")
+		}
+		rawPorts, declared := node["ports"]
+		if !declared {
+			continue
+		}
+		ports, ok := rawPorts.([]any)
+		if !ok {
+			report(DiagnosticNodeGraphShapeInvalid, path+"/ports", "node ports must be an array")
+			continue
+		}
+		seen := make(map[string]bool, len(ports))
+		for index, rawPort := range ports {
+			portPath := fmt.Sprintf("%s/ports/%d", path, index)
+			port, ok := rawPort.(map[string]any)
+			if !ok {
+				report(DiagnosticNodeGraphShapeInvalid, portPath, "port must be an object")
+				continue
+			}
+			portID, _ := port["id"].(string)
+			if strings.TrimSpace(portID) == "" || seen[portID] {
+				report(DiagnosticNodeGraphShapeInvalid, portPath+"/id", "port id must be unique and non-empty")
+			}
+			seen[portID] = true
+			if role, _ := port["role"].(string); role != "in" && role != "out" {
+				report(DiagnosticNodeGraphPortDirection, portPath+"/role", "port role must be in or out")
+			}
+			if kind, _ := port["kind"].(string); !isNodeGraphPortKind(kind) {
+				report(DiagnosticNodeGraphPortMismatch, portPath+"/kind", "port kind must be control, data or node")
+			}
+			if multiplicity, exists := port["multiplicity"]; exists && multiplicity != "single" && multiplicity != "multi" {
+				report(DiagnosticNodeGraphShapeInvalid, portPath+"/multiplicity", "port multiplicity must be single or multi")
+			}
+			if accepts, exists := port["acceptsKinds"]; exists {
+				kinds, ok := accepts.([]any)
+				valid := ok
+				for _, kind := range kinds {
+					name, _ := kind.(string)
+					valid = valid && isNodeGraphPortKind(name)
+				}
+				if !valid {
+					report(DiagnosticNodeGraphPortMismatch, portPath+"/acceptsKinds", "acceptsKinds must list port kinds")
+				}
+			}
+		}
+	}
+
+	edgeIDs := make([]string, 0, len(edges))
+	for id := range edges {
+		edgeIDs = append(edgeIDs, id)
+	}
+	sort.Strings(edgeIDs)
+	connected := make(map[nodeGraphEdge]string, len(edgeIDs))
+	for _, id := range edgeIDs {
+		path := jsonPointerPath("edgesById", id)
+		edge, ok := decodeNodeGraphEdge(edges[id])
+		if !ok {
+			report(DiagnosticNodeGraphShapeInvalid, path, "edge must be an object")
+			continue
+		}
+		if edge.ID != id {
+			report(DiagnosticNodeGraphShapeInvalid, path+"/id", "edge id must match its key")
+		}
+		checkNodeGraphEdge(&diagnostics, nodes, path, edge)
+		ports := edge
+		ports.ID = ""
+		if first, taken := connected[ports]; taken {
+			report(DiagnosticNodeGraphEdgeDuplicated, path, fmt.Sprintf("edge %q already connects the same ports", first))
+			continue
+		}
+		connected[ports] = id
+	}
+	return diagnostics.err()
+}
+
+// checkNodeGraphEdge reports problems with the edge stored at path: both ends
+// must resolve to ports of existing nodes, run out -> in, and the target must
+// accept the source's kind. The ports are returned when ok.
+func checkNodeGraphEdge(diagnostics *diagnosticReport, nodes map[string]any, path string, edge nodeGraphEdge) (source nodeGraphPort, target nodeGraphPort, ok bool) {
+	resolve := func(nodeID, handle, field, role string) (nodeGraphPort, bool) {
+		node, exists := nodes[nodeID].(map[string]any)
+		if !exists {
+			diagnostics.add(DiagnosticNodeGraphNodeNotFound, path+"/"+field, fmt.Sprintf("node %q does not exist", nodeID))
+			return nodeGraphPort{}, false
+		}
+		if handle == "" {
+			diagnostics.add(DiagnosticNodeGraphPortNotFound, path+"/"+field+"Handle", field+"Handle is required")
+			return nodeGraphPort{}, false
+		}
+		port, found := resolveNodeGraphPort(node, handle)
+		if !found {
+			diagnostics.add(DiagnosticNodeGraphPortNotFound, path+"/"+field+"Handle", fmt.Sprintf("port %q does not exist on node %q", handle, nodeID))
+			return nodeGraphPort{}, false
+		}
+		if port.Role != role {
+			diagnostics.add(DiagnosticNodeGraphPortDirection, path+"/"+field+"Handle", fmt.Sprintf("port %q must be an %s port", handle, role))
+			return nodeGraphPort{}, false
+		}
+		return port, true
+	}
+	source, sourceOK := resolve(edge.Source, edge.SourceHandle, "source", "out")
+	target, targetOK := resolve(edge.Target, edge.TargetHandle, "target", "in")
+	if !sourceOK || !targetOK {
+		return source, target, false
+	}
+	if source.Kind != target.Kind && !target.accepts(source.Kind) {
+		diagnostics.add(DiagnosticNodeGraphPortMismatch, path, fmt.Sprintf("%s port cannot connect to %s port", source.Kind, target.Kind))
+		return source, target, false
+	}
+	return source, target, true
+}
+
+func isNodeGraphPosition(raw any) bool {
+	position, ok := raw.(map[string]any)
+	if !ok {
+		return false
+	}
+	x, xOK := position["x"].(float64)
+	y, yOK := position["y"].(float64)
+	return xOK && yOK && !math.IsInf(x, 0) && !math.IsNaN(x) && !math.IsInf(y, 0) && !math.IsNaN(y)
+}
+
+type NodeGraphPosition struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type NodeGraphIntentParams struct {
+	WorkspaceID        string
+	DocumentID         string
+	ExpectedContentRev int64
+	Command            WorkspaceCommandEnvelope
+}
+
+func (store *WorkspaceStore) changeNodeGraph(ctx context.Context, params NodeGraphIntentParams, change func(graph nodeGraphDocument) (string, error)) (*WorkspaceMutationResult, error) {
+	return store.changeDocumentContent(ctx, ChangeDocumentContentParams(params), func(documentType WorkspaceDocumentType, document map[string]any) (string, error) {
+		graph, err := openNodeGraphDocument(documentType, document)
+		if err != nil {
+			return "", err
+		}
+		return change(graph)
+	})
+}
+
+// AddGraphNode inserts node, which must carry an id not used in the graph.
+func (store *WorkspaceStore) AddGraphNode(ctx context.Context, params NodeGraphIntentParams, node json.RawMessage) (*WorkspaceMutationResult, error) {
+	var object map[string]any
+	if err := json.Unmarshal(node, &object); err != nil || object == nil {
+		return nil, documentValidationError(nodeGraphDomain, DiagnosticNodeGraphShapeInvalid, "", "node must be an object")
+	}
+	nodeID, _ := object["id"].(string)
+	if strings.TrimSpace(nodeID) == "" {
+		return nil, documentValidationError(nodeGraphDomain, DiagnosticNodeGraphShapeInvalid, "", "node id is required")
+	}
+	return store.changeNodeGraph(ctx, params, func(graph nodeGraphDocument) (string, error) {
+		if _, exists := graph.nodes[nodeID]; exists {
+			return "", documentValidationError(nodeGraphDomain, DiagnosticNodeGraphNodeExists, jsonPointerPath("nodesById", nodeID), fmt.Sprintf("node %q already exists", nodeID))
+		}
+		graph.nodes[nodeID] = object
+		return fmt.Sprintf("Add node %q", nodeID), nil
+	})
+}
+
+func (store *WorkspaceStore) MoveGraphNode(ctx context.Context, params NodeGraphIntentParams, nodeID string, position NodeGraphPosition) (*WorkspaceMutationResult, error) {
+	return store.changeNodeGraph(ctx, params, func(graph nodeGraphDocument) (string, error) {
+		node, err := graph.node(nodeID)
+		if err != nil {
+			return "", err
+		}
+		node["position"] = map[string]any{"x": position.X, "y": position.Y}
+		return fmt.Sprintf("Move node %q", nodeID), nil
+	})
+}
+
+// RemoveGraphNode deletes the node together with every edge attached to it,
+// so the reverse ops restore both.
+func (store *WorkspaceStore) RemoveGraphNode(ctx context.Context, params NodeGraphIntentParams, nodeID string) (*WorkspaceMutationResult, error) {
+	return store.changeNodeGraph(ctx, params, func(graph nodeGraphDocument) (string, error) {
+		if _, err := graph.node(nodeID); err != nil {
+			return "", err
+		}
+		delete(graph.nodes, nodeID)
+		for _, edgeID := range graph.edgeIDs(func(edge nodeGraphEdge) bool {
+			return edge.Source == nodeID || edge.Target == nodeID
+		}) {
+			delete(graph.edges, edgeID)
+		}
+		return fmt.Sprintf("Remove node %q", nodeID), nil
+	})
+}
+
+// ConnectGraphEdge adds an out -> in edge between compatible ports. Connecting
+// to a single port replaces the edge already attached to it.
+func (store *WorkspaceStore) ConnectGraphEdge(ctx context.Context, params NodeGraphIntentParams, edge nodeGraphEdge) (*WorkspaceMutationResult, error) {
+	if edge.ID == "" {
+		edge.ID = fmt.Sprintf("edge_%s_%s_%s_%s", edge.Source, edge.SourceHandle, edge.Target, edge.TargetHandle)
+	}
+	return store.changeNodeGraph(ctx, params, func(graph nodeGraphDocument) (string, error) {
+		path := jsonPointerPath("edgesById", edge.ID)
+		diagnostics := diagnosticReport{domain: nodeGraphDomain}
+		// The document validator repeats these checks on commit; running them
+		// first keeps single-port replacement from hiding a duplicate.
+		source, target, _ := checkNodeGraphEdge(&diagnostics, graph.nodes, path, edge)
+		if _, exists := graph.edges[edge.ID]; exists {
+			diagnostics.add(DiagnosticNodeGraphEdgeDuplicated, path, fmt.Sprintf("edge %q already exists", edge.ID))
+		}
+		for _, duplicate := range graph.edgeIDs(func(existing nodeGraphEdge) bool {
+			return existing.Source == edge.Source && existing.SourceHandle == edge.SourceHandle &&
+				existing.Target == edge.Target && existing.TargetHandle == edge.TargetHandle
+		}) {
+			diagnostics.add(DiagnosticNodeGraphEdgeDuplicated, jsonPointerPath("edgesById", duplicate), "the ports are already connected")
+		}
+		if err := diagnostics.err(); err != nil {
+			return "", err
+		}
+		for _, replaced := range graph.edgeIDs(func(existing nodeGraphEdge) bool {
+			return (source.Multiplicity == "single" && existing.Source == edge.Source && existing.SourceHandle == edge.SourceHandle) ||
+				(target.Multiplicity == "single" && existing.Target == edge.Target && existing.TargetHandle == edge.TargetHandle)
+		}) {
+			delete(graph.edges, replaced)
+		}
+		graph.edges[edge.ID] = map[string]any{
+			"id":           edge.ID,
+			"source":       edge.Source,
+			"sourceHandle": edge.SourceHandle,
+			"target":       edge.Target,
+			"targetHandle": edge.TargetHandle,
+		}
+		return fmt.Sprintf("Connect %q to %q", edge.Source, edge.Target), nil
+	})
+}
+
+func (store *WorkspaceStore) DisconnectGraphEdge(ctx context.Context, params NodeGraphIntentParams, edgeID string) (*WorkspaceMutationResult, error) {
+	return store.changeNodeGraph(ctx, params, func(graph nodeGraphDocument) (string, error) {
+		if _, exists := graph.edges[edgeID]; !exists {
+			return "", documentValidationError(nodeGraphDomain, DiagnosticNodeGraphEdgeNotFound, jsonPointerPath("edgesById", edgeID), fmt.Sprintf("edge %q does not exist", edgeID))
+		}
+		delete(graph.edges, edgeID)
+		return fmt.Sprintf("Disconnect edge %q", edgeID), nil
+	})
+}
+
+type nodeGraphIntentHandler struct{}
+
+func (nodeGraphIntentHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{
+		intentV1(nodeGraphNamespace, "node.add", RevPartitionContent),
+		intentV1(nodeGraphNamespace, "node.move", RevPartitionContent),
+		intentV1(nodeGraphNamespace, "node.remove", RevPartitionContent),
+		intentV1(nodeGraphNamespace, "edge.connect", RevPartitionContent),
+		intentV1(nodeGraphNamespace, "edge.disconnect", RevPartitionContent),
+	}
+}
+
+func (nodeGraphIntentHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	intent IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID         string             `json:"documentId"`
+		ExpectedContentRev int64              `json:"expectedContentRev"`
+		NodeID             string             `json:"nodeId"`
+		Node               json.RawMessage    `json:"node"`
+		Position           *NodeGraphPosition `json:"position"`
+		EdgeID             string             `json:"edgeId"`
+		Edge               *nodeGraphEdge     `json:"edge"`
+	}
+	invalid := func(message string) *RequestFailure {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, message, nil)
+	}
+	if len(request.Intent.Payload) == 0 || json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.DocumentID) == "" || payload.ExpectedContentRev <= 0 {
+		return nil, invalid("intent payload.documentId and payload.expectedContentRev are required.")
+	}
+	params := NodeGraphIntentParams{
+		WorkspaceID:        workspaceID,
+		DocumentID:         strings.TrimSpace(payload.DocumentID),
+		ExpectedContentRev: payload.ExpectedContentRev,
+		Command:            command,
+	}
+	var result *WorkspaceMutationResult
+	var err error
+	switch intent.Type {
+	case "node.add":
+		if len(payload.Node) == 0 {
+			return nil, invalid("intent payload.node is required.")
+		}
+		result, err = store.AddGraphNode(ctx, params, payload.Node)
+	case "node.move":
+		if strings.TrimSpace(payload.NodeID) == "" || payload.Position == nil {
+			return nil, invalid("intent payload.nodeId and payload.position are required.")
+		}
+		result, err = store.MoveGraphNode(ctx, params, payload.NodeID, *payload.Position)
+	case "node.remove":
+		if strings.TrimSpace(payload.NodeID) == "" {
+			return nil, invalid("intent payload.nodeId is required.")
+		}
+		result, err = store.RemoveGraphNode(ctx, params, payload.NodeID)
+	case "edge.connect":
+		if payload.Edge == nil || payload.Edge.Source == "" || payload.Edge.SourceHandle == "" ||
+			payload.Edge.Target == "" || payload.Edge.TargetHandle == "" {
+			return nil, invalid("intent payload.edge requires source, sourceHandle, target and targetHandle.")
+		}
+		result, err = store.ConnectGraphEdge(ctx, params, *payload.Edge)
+	default:
+		if strings.TrimSpace(payload.EdgeID) == "" {
+			return nil, invalid("intent payload.edgeId is required.")
+		}
+		result, err = store.DisconnectGraphEdge(ctx, params, payload.EdgeID)
+	}
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
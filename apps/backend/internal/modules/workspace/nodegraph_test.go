@@ -0,0 +1,233 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	backendresponse "github.com/Mdr-Tutorials/mdr-front-engine/apps/backend/internal/platform/http/response"
+)
+
+const nodeGraphTestContent = `{"version":1,"nodesById":{` +
+	`"a":{"id":"a","type":"start"},` +
+	`"b":{"id":"b","type":"log"},` +
+	`"c":{"id":"c","type":"log"},` +
+	`"v":{"id":"v","type":"value","ports":[{"id":"result","role":"out","kind":"data"},{"id":"trigger","role":"in","kind":"data","acceptsKinds":["data","control"]}]}` +
+	`},"edgesById":{"e1":{"id":"e1","source":"a","sourceHandle":"out.control.next","target":"b","targetHandle":"in.control.prev"}}}`
+
+var nodeGraphTestDocument = testDocument{
+	id:         "graph_1",
+	docType:    WorkspaceDocumentTypeMIRGraph,
+	name:       "Flow",
+	path:       "/flow.graph.json",
+	contentRev: 5,
+	metaRev:    1,
+	content:    nodeGraphTestContent,
+}
+
+func TestWorkspaceStoreConnectGraphEdgeReplacesSinglePort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	expectWorkspaceRowLock(mock)
+	expectDocumentLock(mock, nodeGraphTestDocument)
+	expectDocumentContentLock(mock, nodeGraphTestDocument, testHead{workspaceRev: 9, routeRev: 4, opSeq: 33})
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()`)).
+		WithArgs("ws_1", "graph_1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(6, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)`)).
+		WithArgs("ws_1", int64(34), "core.nodegraph.edge.connect@1.0", "graph_1",
+			graphReplaceOpsMatcher{t: t, paths: []string{"/edgesById/e1", "/edgesById/e2"}}, issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	params := NodeGraphIntentParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "graph_1",
+		ExpectedContentRev: 5,
+		Command:            testCommand(nodeGraphNamespace, "edge.connect", issuedAt),
+	}
+	result, err := NewWorkspaceStore(db).ConnectGraphEdge(context.Background(), params, nodeGraphEdge{
+		ID:           "e2",
+		Source:       "a",
+		SourceHandle: "out.control.next",
+		Target:       "c",
+		TargetHandle: "in.control.prev",
+	})
+	if err != nil {
+		t.Fatalf("connect edge: %v", err)
+	}
+	if result.UpdatedDocuments[0].ContentRev != 6 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreConnectGraphEdgeRejectsInvalidPorts(t *testing.T) {
+	cases := []struct {
+		name string
+		edge nodeGraphEdge
+		code string
+		path string
+	}{
+		{
+			name: "direction",
+			edge: nodeGraphEdge{ID: "e2", Source: "b", SourceHandle: "in.control.prev", Target: "c", TargetHandle: "in.control.prev"},
+			code: DiagnosticNodeGraphPortDirection,
+			path: "/edgesById/e2/sourceHandle",
+		},
+		{
+			name: "kind",
+			edge: nodeGraphEdge{ID: "e2", Source: "v", SourceHandle: "result", Target: "c", TargetHandle: "in.control.prev"},
+			code: DiagnosticNodeGraphPortMismatch,
+			path: "/edgesById/e2",
+		},
+		{
+			name: "missing port",
+			edge: nodeGraphEdge{ID: "e2", Source: "v", SourceHandle: "out.data.value", Target: "c", TargetHandle: "in.control.prev"},
+			code: DiagnosticNodeGraphPortNotFound,
+			path: "/edgesById/e2/sourceHandle",
+		},
+		{
+			name: "duplicate",
+			edge: nodeGraphEdge{ID: "e2", Source: "a", SourceHandle: "out.control.next", Target: "b", TargetHandle: "in.control.prev"},
+			code: DiagnosticNodeGraphEdgeDuplicated,
+			path: "/edgesById/e1",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("create sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			expectWorkspaceRowLock(mock)
+			expectDocumentLock(mock, nodeGraphTestDocument)
+			mock.ExpectRollback()
+
+			params := NodeGraphIntentParams{
+				WorkspaceID:        "ws_1",
+				DocumentID:         "graph_1",
+				ExpectedContentRev: 5,
+				Command:            testCommand(nodeGraphNamespace, "edge.connect", time.Now()),
+			}
+			_, err = NewWorkspaceStore(db).ConnectGraphEdge(context.Background(), params, tc.edge)
+			failure := MapStoreError(err)
+			if failure == nil || failure.Status != http.StatusUnprocessableEntity {
+				t.Fatalf("expected 422, got %v", err)
+			}
+			payload, _ := failure.Payload["error"].(backendresponse.ErrorPayload)
+			if payload.Code != tc.code || payload.Domain != "nodegraph" || len(payload.Diagnostics) == 0 || payload.Diagnostics[0].Path != tc.path {
+				t.Fatalf("unexpected failure payload: %+v", payload)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("sql expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestNodeGraphPortsFollowDeclaredAndDefaultSemantics(t *testing.T) {
+	var document map[string]any
+	if err := json.Unmarshal([]byte(nodeGraphTestContent), &document); err != nil {
+		t.Fatalf("decode graph: %v", err)
+	}
+	nodes := document["nodesById"].(map[string]any)
+	control, ok := resolveNodeGraphPort(nodes["a"].(map[string]any), "out.control.next")
+	if !ok || control.Multiplicity != "single" {
+		t.Fatalf("control.out should default to single: %+v", control)
+	}
+	condition, ok := resolveNodeGraphPort(nodes["a"].(map[string]any), "in.condition.case-1")
+	if !ok || condition.Kind != nodeGraphPortNode || condition.Multiplicity != "single" {
+		t.Fatalf("condition should map to node.in single: %+v", condition)
+	}
+	trigger, ok := resolveNodeGraphPort(nodes["v"].(map[string]any), "trigger")
+	if !ok || trigger.Multiplicity != "single" || !trigger.accepts(nodeGraphPortControl) {
+		t.Fatalf("declared acceptsKinds should allow control: %+v", trigger)
+	}
+	if _, ok := resolveNodeGraphPort(nodes["v"].(map[string]any), "out.data.value"); ok {
+		t.Fatalf("declared ports must not fall back to handle naming")
+	}
+}
+
+func TestValidateNodeGraphDocumentReportsEveryProblem(t *testing.T) {
+	if err := validateNodeGraphDocument(defaultMIRGraphDocument); err != nil {
+		t.Fatalf("default graph should be valid: %v", err)
+	}
+	err := validateNodeGraphDocument(json.RawMessage(`{"version":1,"nodesById":{"a":{"id":"b","type":"log","ports":[{"id":"p","role":"up","kind":"data"}]}},"edgesById":{"e1":{"id":"e1","source":"a","sourceHandle":"out.control.next","target":"gone","targetHandle":"in.control.prev"}}}`))
+	validationErr, ok := err.(*DocumentValidationError)
+	if !ok {
+		t.Fatalf("expected node graph validation error, got %v", err)
+	}
+	paths := make([]string, 0, len(validationErr.Diagnostics))
+	for _, diagnostic := range validationErr.Diagnostics {
+		paths = append(paths, diagnostic.Code+" "+diagnostic.Path)
+	}
+	expected := []string{
+		"NGR-1002 /nodesById/a/id",
+		"NGR-2010 /nodesById/a/ports/0/role",
+		"NGR-2003 /edgesById/e1/sourceHandle",
+		"NGR-1004 /edgesById/e1/target",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("unexpected diagnostics: %v", paths)
+	}
+	for index := range expected {
+		if paths[index] != expected[index] {
+			t.Fatalf("unexpected diagnostics: %v", paths)
+		}
+	}
+}
+
+func TestValidateNodeGraphDocumentChecksEdgePorts(t *testing.T) {
+	// A generic PATCH can write edges directly, so the validator checks what
+	// core.nodegraph.edge.connect would have.
+	err := validateNodeGraphDocument(json.RawMessage(`{"version":1,"nodesById":{` +
+		`"a":{"id":"a","type":"start"},` +
+		`"b":{"id":"b","type":"log"},` +
+		`"v":{"id":"v","type":"value","ports":[{"id":"result","role":"out","kind":"data"}]}` +
+		`},"edgesById":{` +
+		`"e1":{"id":"e1","source":"a","sourceHandle":"out.control.next","target":"b","targetHandle":"in.control.prev"},` +
+		`"e2":{"id":"e2","source":"a","sourceHandle":"out.control.next","target":"b","targetHandle":"in.control.prev"},` +
+		`"e3":{"id":"e3","source":"b","sourceHandle":"in.control.prev","target":"a","targetHandle":"in.control.prev"},` +
+		`"e4":{"id":"e4","source":"v","sourceHandle":"result","target":"b","targetHandle":"in.control.prev"}}}`))
+	validationErr, ok := err.(*DocumentValidationError)
+	if !ok {
+		t.Fatalf("expected node graph validation error, got %v", err)
+	}
+	paths := make([]string, 0, len(validationErr.Diagnostics))
+	for _, diagnostic := range validationErr.Diagnostics {
+		paths = append(paths, diagnostic.Code+" "+diagnostic.Path)
+	}
+	expected := []string{
+		"NGR-3010 /edgesById/e2",
+		"NGR-2010 /edgesById/e3/sourceHandle",
+		"NGR-2002 /edgesById/e4",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("unexpected diagnostics: %v", paths)
+	}
+	for index := range expected {
+		if paths[index] != expected[index] {
+			t.Fatalf("unexpected diagnostics: %v", paths)
+		}
+	}
+}
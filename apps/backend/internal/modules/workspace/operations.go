@@ -0,0 +1,145 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWorkspaceOperationPageSize = 100
+	maxWorkspaceOperationPageSize     = 500
+)
+
+// WorkspaceOperation is one committed command read back from the operation log.
+type WorkspaceOperation struct {
+	OpSeq      int64           `json:"opSeq"`
+	Domain     string          `json:"domain"`
+	DocumentID string          `json:"documentId,omitempty"`
+	Command    json.RawMessage `json:"command"`
+	// Result holds the revisions the operation committed. It is absent for
+	// operations logged before results were recorded.
+	Result    json.RawMessage `json:"result,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type ListWorkspaceOperationsParams struct {
+	WorkspaceID string
+	AfterOpSeq  int64
+	DocumentID  string
+	// Domain matches a full `namespace.type@version` domain exactly, or any
+	// domain starting with it when no version is given (e.g. "core.settings").
+	Domain string
+	Limit  int
+}
+
+type WorkspaceOperationPage struct {
+	WorkspaceID    string               `json:"workspaceId"`
+	Operations     []WorkspaceOperation `json:"operations"`
+	HeadOpSeq      int64                `json:"headOpSeq"`
+	NextAfterOpSeq int64                `json:"nextAfterOpSeq"`
+	HasMore        bool                 `json:"hasMore"`
+}
+
+// ListOperations returns committed operations with op_seq greater than
+// params.AfterOpSeq in ascending order. NextAfterOpSeq is the cursor for the
+// following page; it stays at AfterOpSeq when the page is empty.
+func (store *WorkspaceStore) ListOperations(ctx context.Context, params ListWorkspaceOperationsParams) (*WorkspaceOperationPage, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	params.WorkspaceID = strings.TrimSpace(params.WorkspaceID)
+	if params.WorkspaceID == "" {
+		return nil, ErrWorkspaceNotFound
+	}
+	if params.AfterOpSeq < 0 {
+		params.AfterOpSeq = 0
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultWorkspaceOperationPageSize
+	}
+	if params.Limit > maxWorkspaceOperationPageSize {
+		params.Limit = maxWorkspaceOperationPageSize
+	}
+
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	page := &WorkspaceOperationPage{WorkspaceID: params.WorkspaceID, Operations: make([]WorkspaceOperation, 0), NextAfterOpSeq: params.AfterOpSeq}
+	const headQuery = `SELECT op_seq FROM workspaces WHERE id = $1`
+	if err := store.conn().QueryRowContext(ctx, headQuery, params.WorkspaceID).Scan(&page.HeadOpSeq); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+
+	clauses := []string{"workspace_id = $1", "op_seq > $2"}
+	args := []any{params.WorkspaceID, params.AfterOpSeq}
+	argIndex := 3
+	if documentID := strings.TrimSpace(params.DocumentID); documentID != "" {
+		clauses = append(clauses, fmt.Sprintf("document_id = $%d", argIndex))
+		args = append(args, documentID)
+		argIndex++
+	}
+	if domain := strings.TrimSpace(params.Domain); domain != "" {
+		if strings.Contains(domain, "@") {
+			clauses = append(clauses, fmt.Sprintf("domain = $%d", argIndex))
+			args = append(args, domain)
+		} else {
+			clauses = append(clauses, fmt.Sprintf(`domain LIKE $%d ESCAPE '\'`, argIndex))
+			args = append(args, escapeLikePattern(domain)+"%")
+		}
+		argIndex++
+	}
+	// Fetch one extra row to learn whether another page follows.
+	args = append(args, params.Limit+1)
+
+	query := `SELECT op_seq, domain, document_id, payload_json, created_at, result_json
+FROM workspace_operations
+WHERE ` + strings.Join(clauses, " AND ") + `
+ORDER BY op_seq ASC
+LIMIT ` + fmt.Sprintf("$%d", argIndex)
+
+	rows, err := store.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var operation WorkspaceOperation
+		var documentID sql.NullString
+		var payload []byte
+		var result []byte
+		if err := rows.Scan(&operation.OpSeq, &operation.Domain, &documentID, &payload, &operation.CreatedAt, &result); err != nil {
+			return nil, err
+		}
+		operation.DocumentID = documentID.String
+		operation.Command = json.RawMessage(payload)
+		if len(result) > 0 {
+			operation.Result = json.RawMessage(result)
+		}
+		page.Operations = append(page.Operations, operation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Operations) > params.Limit {
+		page.Operations = page.Operations[:params.Limit]
+		page.HasMore = true
+	}
+	if count := len(page.Operations); count > 0 {
+		page.NextAfterOpSeq = page.Operations[count-1].OpSeq
+	}
+	return page, nil
+}
+
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -25,26 +25,27 @@ func applyWorkspacePatch(content json.RawMessage, ops []WorkspacePatchOp) (json.
 }
 
 func applyWorkspaceDocumentPatch(documentType WorkspaceDocumentType, content json.RawMessage, ops []WorkspacePatchOp) (json.RawMessage, error) {
-	if documentType == WorkspaceDocumentTypeCode {
-		return applyWorkspacePatchWithValidator(content, ops, validateWorkspaceCodePatchPath)
-	}
-	return applyWorkspacePatch(content, ops)
+	return applyWorkspacePatchWithValidator(content, ops, workspaceDocumentPatchPathValidator(documentType))
+}
+
+func workspaceDocumentPatchPathValidator(documentType WorkspaceDocumentType) workspacePatchPathValidator {
+	switch documentType {
+	case WorkspaceDocumentTypeCode:
+		return validateWorkspaceCodePatchPath
+	case WorkspaceDocumentTypeMIRGraph:
+		return validateWorkspaceNodeGraphPatchPath
+	case WorkspaceDocumentTypeMIRAnimation:
+		return validateWorkspaceAnimationPatchPath
+	}
+	return validateWorkspacePatchPath
 }
 
 func applyWorkspacePatchWithValidator(content json.RawMessage, ops []WorkspacePatchOp, validatePath workspacePatchPathValidator) (json.RawMessage, error) {
-	var document any
-	if err := decodeJSONValue(content, &document); err != nil {
+	patch, err := patchWorkspaceJSON(content, ops, validatePath)
+	if err != nil {
 		return nil, err
 	}
-	next := deepCloneJSONValue(document)
-	for index, op := range ops {
-		patched, err := applyWorkspacePatchOperation(next, op, validatePath)
-		if err != nil {
-			return nil, fmt.Errorf("patch operation %d: %w", index, err)
-		}
-		next = patched
-	}
-	return json.Marshal(next)
+	return patch.Content, nil
 }
 
 func validateWorkspacePatchPath(path string) error {
@@ -96,76 +97,44 @@ func validateWorkspaceCodePatchPath(path string) error {
 	return ErrWorkspacePatchPathForbidden
 }
 
-func applyWorkspacePatchOperation(document any, op WorkspacePatchOp, validatePath workspacePatchPathValidator) (any, error) {
-	op.Op = strings.TrimSpace(strings.ToLower(op.Op))
-	op.Path = strings.TrimSpace(op.Path)
-	op.From = strings.TrimSpace(op.From)
-	if err := validatePath(op.Path); err != nil {
-		return nil, err
-	}
-	path, err := parseJSONPointer(op.Path)
+// validateWorkspaceNodeGraphPatchPath mirrors the paths the editor may patch
+// on mir-graph documents.
+func validateWorkspaceNodeGraphPatchPath(path string) error {
+	pointer, err := parseJSONPointer(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	value, err := decodePatchValue(op.Value)
-	if err != nil {
-		return nil, err
+	if len(pointer) == 0 {
+		return ErrWorkspacePatchPathForbidden
 	}
-
-	switch op.Op {
-	case "add":
-		return addJSONValue(document, path, value)
-	case "remove":
-		return removeJSONValue(document, path)
-	case "replace":
-		if _, err := getJSONValue(document, path); err != nil {
-			return nil, err
-		}
-		removed, err := removeJSONValue(document, path)
-		if err != nil {
-			return nil, err
-		}
-		return addJSONValue(removed, path, value)
-	case "test":
-		current, err := getJSONValue(document, path)
-		if err != nil {
-			return nil, err
-		}
-		if !jsonDeepEqual(current, value) {
-			return nil, ErrWorkspacePatchTestFailed
-		}
-		return document, nil
-	case "copy":
-		from, err := parseAndValidateFromPointer(op.From, validatePath)
-		if err != nil {
-			return nil, err
-		}
-		value, err := getJSONValue(document, from)
-		if err != nil {
-			return nil, err
-		}
-		return addJSONValue(document, path, deepCloneJSONValue(value))
-	case "move":
-		from, err := parseAndValidateFromPointer(op.From, validatePath)
-		if err != nil {
-			return nil, err
-		}
-		if isPointerPrefix(from, path) {
-			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrWorkspacePatchInvalid)
-		}
-		value, err := getJSONValue(document, from)
-		if err != nil {
-			return nil, err
-		}
-		removed, err := removeJSONValue(document, from)
-		if err != nil {
-			return nil, err
+	switch pointer[0] {
+	case "nodesById", "edgesById", "groupsById", "metadata":
+		return nil
+	default:
+		if strings.HasPrefix(pointer[0], "x-") {
+			return nil
 		}
-		adjustedPath := adjustMoveDestinationAfterRemove(from, path)
-		return addJSONValue(removed, adjustedPath, value)
+	}
+	return ErrWorkspacePatchPathForbidden
+}
+
+func validateWorkspaceAnimationPatchPath(path string) error {
+	pointer, err := parseJSONPointer(path)
+	if err != nil {
+		return err
+	}
+	if len(pointer) == 0 {
+		return ErrWorkspacePatchPathForbidden
+	}
+	switch pointer[0] {
+	case "timelines", "svgFilters", "metadata":
+		return nil
 	default:
-		return nil, fmt.Errorf("%w: unsupported op %q", ErrWorkspacePatchInvalid, op.Op)
+		if strings.HasPrefix(pointer[0], "x-") {
+			return nil
+		}
 	}
+	return ErrWorkspacePatchPathForbidden
 }
 
 func parseAndValidateFromPointer(raw string, validatePath workspacePatchPathValidator) (jsonPointer, error) {
@@ -271,99 +240,6 @@ func getJSONValue(document any, path jsonPointer) (any, error) {
 	return current, nil
 }
 
-func addJSONValue(document any, path jsonPointer, value any) (any, error) {
-	if len(path) == 0 {
-		return deepCloneJSONValue(value), nil
-	}
-	parentPath := path[:len(path)-1]
-	key := path[len(path)-1]
-	parent, err := getJSONValue(document, parentPath)
-	if err != nil {
-		return nil, err
-	}
-	switch typed := parent.(type) {
-	case map[string]any:
-		nextParent := deepCloneJSONValue(typed).(map[string]any)
-		nextParent[key] = deepCloneJSONValue(value)
-		return replaceExistingJSONValue(document, parentPath, nextParent)
-	case []any:
-		index, err := parseArrayIndex(key, len(typed), true)
-		if err != nil {
-			return nil, err
-		}
-		nextParent := make([]any, 0, len(typed)+1)
-		nextParent = append(nextParent, typed[:index]...)
-		nextParent = append(nextParent, deepCloneJSONValue(value))
-		nextParent = append(nextParent, typed[index:]...)
-		return replaceExistingJSONValue(document, parentPath, nextParent)
-	default:
-		return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
-	}
-}
-
-func removeJSONValue(document any, path jsonPointer) (any, error) {
-	if len(path) == 0 {
-		return nil, fmt.Errorf("%w: remove root is forbidden", ErrWorkspacePatchInvalid)
-	}
-	parentPath := path[:len(path)-1]
-	key := path[len(path)-1]
-	parent, err := getJSONValue(document, parentPath)
-	if err != nil {
-		return nil, err
-	}
-	switch typed := parent.(type) {
-	case map[string]any:
-		if _, ok := typed[key]; !ok {
-			return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, key)
-		}
-		nextParent := deepCloneJSONValue(typed).(map[string]any)
-		delete(nextParent, key)
-		return replaceExistingJSONValue(document, parentPath, nextParent)
-	case []any:
-		index, err := parseArrayIndex(key, len(typed), false)
-		if err != nil {
-			return nil, err
-		}
-		nextParent := make([]any, 0, len(typed)-1)
-		nextParent = append(nextParent, typed[:index]...)
-		nextParent = append(nextParent, typed[index+1:]...)
-		return replaceExistingJSONValue(document, parentPath, nextParent)
-	default:
-		return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
-	}
-}
-
-func replaceExistingJSONValue(document any, path jsonPointer, value any) (any, error) {
-	if len(path) == 0 {
-		return deepCloneJSONValue(value), nil
-	}
-	parentPath := path[:len(path)-1]
-	key := path[len(path)-1]
-	parent, err := getJSONValue(document, parentPath)
-	if err != nil {
-		return nil, err
-	}
-	switch typed := parent.(type) {
-	case map[string]any:
-		if _, ok := typed[key]; !ok {
-			return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, key)
-		}
-		nextParent := deepCloneJSONValue(typed).(map[string]any)
-		nextParent[key] = deepCloneJSONValue(value)
-		return replaceExistingJSONValue(document, parentPath, nextParent)
-	case []any:
-		index, err := parseArrayIndex(key, len(typed), false)
-		if err != nil {
-			return nil, err
-		}
-		nextParent := deepCloneJSONValue(typed).([]any)
-		nextParent[index] = deepCloneJSONValue(value)
-		return replaceExistingJSONValue(document, parentPath, nextParent)
-	default:
-		return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
-	}
-}
-
 func parseArrayIndex(segment string, length int, allowAppend bool) (int, error) {
 	if segment == "-" {
 		if allowAppend {
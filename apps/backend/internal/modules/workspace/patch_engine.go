@@ -0,0 +1,368 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// jsonPatchSession applies ops to a decoded document without mutating it.
+// Containers along each modified path are copied once per session and then
+// updated in place; everything off the path stays shared with the input.
+type jsonPatchSession struct {
+	validatePath workspacePatchPathValidator
+	// owned maps a container's identity to the container itself; holding it
+	// keeps the address from being reused by a later allocation.
+	owned map[uintptr]any
+}
+
+func newJSONPatchSession(validatePath workspacePatchPathValidator) *jsonPatchSession {
+	return &jsonPatchSession{validatePath: validatePath, owned: make(map[uintptr]any)}
+}
+
+// workspacePatch is the result of applying forward ops: the original and the
+// patched value trees share every subtree the ops did not touch.
+type workspacePatch struct {
+	original     any
+	patched      any
+	Content      json.RawMessage
+	validatePath workspacePatchPathValidator
+}
+
+func patchWorkspaceJSON(content json.RawMessage, ops []WorkspacePatchOp, validatePath workspacePatchPathValidator) (*workspacePatch, error) {
+	var document any
+	if err := decodeJSONValue(content, &document); err != nil {
+		return nil, err
+	}
+	patched, err := newJSONPatchSession(validatePath).apply(document, ops)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(patched)
+	if err != nil {
+		return nil, err
+	}
+	return &workspacePatch{original: document, patched: patched, Content: encoded, validatePath: validatePath}, nil
+}
+
+func patchWorkspaceDocument(documentType WorkspaceDocumentType, content json.RawMessage, ops []WorkspacePatchOp) (*workspacePatch, error) {
+	if documentType != WorkspaceDocumentTypeCode {
+		for index, op := range ops {
+			if op.Op == "text" {
+				return nil, fmt.Errorf("patch operation %d: %w: text ops only apply to code documents", index, ErrWorkspacePatchInvalid)
+			}
+		}
+	}
+	return patchWorkspaceJSON(content, ops, workspaceDocumentPatchPathValidator(documentType))
+}
+
+// restoredBy applies reverseOps to the patched tree and reports whether they
+// give back the original. Unchanged subtrees are still shared, so the
+// comparison only descends into what either side modified.
+func (patch *workspacePatch) restoredBy(reverseOps []WorkspacePatchOp) (bool, error) {
+	reversed, err := newJSONPatchSession(patch.validatePath).apply(patch.patched, reverseOps)
+	if err != nil {
+		return false, err
+	}
+	return jsonValuesEqual(patch.original, reversed), nil
+}
+
+func (session *jsonPatchSession) apply(document any, ops []WorkspacePatchOp) (any, error) {
+	next := document
+	for index, op := range ops {
+		patched, err := session.applyOperation(next, op)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d: %w", index, err)
+		}
+		next = patched
+	}
+	return next, nil
+}
+
+func (session *jsonPatchSession) applyOperation(document any, op WorkspacePatchOp) (any, error) {
+	op.Op = strings.TrimSpace(strings.ToLower(op.Op))
+	op.Path = strings.TrimSpace(op.Path)
+	op.From = strings.TrimSpace(op.From)
+	if err := session.validatePath(op.Path); err != nil {
+		return nil, err
+	}
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodePatchValue(op.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return session.add(document, path, value)
+	case "remove":
+		return session.remove(document, path)
+	case "replace":
+		return session.replace(document, path, value)
+	case "test":
+		current, err := getJSONValue(document, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonDeepEqual(current, value) {
+			return nil, ErrWorkspacePatchTestFailed
+		}
+		return document, nil
+	case "copy":
+		from, err := parseAndValidateFromPointer(op.From, session.validatePath)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getJSONValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		// The copy gets its own containers so later in-place updates through
+		// one location never show up at the other.
+		return session.add(document, path, deepCloneJSONValue(value))
+	case "text":
+		return session.text(document, path, op.Value)
+	case "move":
+		from, err := parseAndValidateFromPointer(op.From, session.validatePath)
+		if err != nil {
+			return nil, err
+		}
+		if isPointerPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrWorkspacePatchInvalid)
+		}
+		value, err := getJSONValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		removed, err := session.remove(document, from)
+		if err != nil {
+			return nil, err
+		}
+		return session.add(removed, adjustMoveDestinationAfterRemove(from, path), value)
+	default:
+		return nil, fmt.Errorf("%w: unsupported op %q", ErrWorkspacePatchInvalid, op.Op)
+	}
+}
+
+func (session *jsonPatchSession) add(document any, path jsonPointer, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	key := path[len(path)-1]
+	return session.updateContainer(document, path[:len(path)-1], func(parent any) (any, error) {
+		switch typed := parent.(type) {
+		case map[string]any:
+			owned := session.ownMap(typed)
+			owned[key] = value
+			return owned, nil
+		case []any:
+			index, err := parseArrayIndex(key, len(typed), true)
+			if err != nil {
+				return nil, err
+			}
+			owned := append(session.ownSlice(typed), nil)
+			copy(owned[index+1:], owned[index:])
+			owned[index] = value
+			session.markSlice(owned)
+			return owned, nil
+		default:
+			return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
+		}
+	})
+}
+
+func (session *jsonPatchSession) remove(document any, path jsonPointer) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: remove root is forbidden", ErrWorkspacePatchInvalid)
+	}
+	key := path[len(path)-1]
+	return session.updateContainer(document, path[:len(path)-1], func(parent any) (any, error) {
+		switch typed := parent.(type) {
+		case map[string]any:
+			if _, ok := typed[key]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, key)
+			}
+			owned := session.ownMap(typed)
+			delete(owned, key)
+			return owned, nil
+		case []any:
+			index, err := parseArrayIndex(key, len(typed), false)
+			if err != nil {
+				return nil, err
+			}
+			owned := session.ownSlice(typed)
+			copy(owned[index:], owned[index+1:])
+			owned[len(owned)-1] = nil
+			return owned[:len(owned)-1], nil
+		default:
+			return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
+		}
+	})
+}
+
+// replace sets an existing value in place instead of removing and re-adding
+// it; the result is the same as remove+add at the same path.
+func (session *jsonPatchSession) replace(document any, path jsonPointer, value any) (any, error) {
+	if _, err := getJSONValue(document, path); err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: remove root is forbidden", ErrWorkspacePatchInvalid)
+	}
+	key := path[len(path)-1]
+	return session.updateContainer(document, path[:len(path)-1], func(parent any) (any, error) {
+		switch typed := parent.(type) {
+		case map[string]any:
+			owned := session.ownMap(typed)
+			owned[key] = value
+			return owned, nil
+		case []any:
+			index, _ := strconv.Atoi(key)
+			owned := session.ownSlice(typed)
+			owned[index] = value
+			return owned, nil
+		default:
+			return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
+		}
+	})
+}
+
+// text splices the string at path; see workspaceTextEdit.
+func (session *jsonPatchSession) text(document any, path jsonPointer, raw json.RawMessage) (any, error) {
+	edit, err := decodeWorkspaceTextEdit(raw)
+	if err != nil {
+		return nil, err
+	}
+	current, err := getJSONValue(document, path)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := current.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: text target is not a string", ErrWorkspacePatchInvalid)
+	}
+	next, err := spliceWorkspaceText(source, edit)
+	if err != nil {
+		return nil, err
+	}
+	return session.replace(document, path, next)
+}
+
+// updateContainer walks to the container at path, lets update produce its
+// new version and re-links every ancestor, copying the ones this session
+// does not own yet.
+func (session *jsonPatchSession) updateContainer(document any, path jsonPointer, update func(container any) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return update(document)
+	}
+	segment := path[0]
+	switch typed := document.(type) {
+	case map[string]any:
+		child, ok := typed[segment]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, segment)
+		}
+		nextChild, err := session.updateContainer(child, path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		owned := session.ownMap(typed)
+		owned[segment] = nextChild
+		return owned, nil
+	case []any:
+		index, err := parseArrayIndex(segment, len(typed), false)
+		if err != nil {
+			return nil, err
+		}
+		nextChild, err := session.updateContainer(typed[index], path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		owned := session.ownSlice(typed)
+		owned[index] = nextChild
+		return owned, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, segment)
+	}
+}
+
+func (session *jsonPatchSession) ownMap(value map[string]any) map[string]any {
+	if _, ok := session.owned[reflect.ValueOf(value).Pointer()]; ok {
+		return value
+	}
+	owned := maps.Clone(value)
+	session.owned[reflect.ValueOf(owned).Pointer()] = owned
+	return owned
+}
+
+// ownSlice identifies slices by their backing array. Zero-capacity slices
+// may share one, so they are never treated as owned.
+func (session *jsonPatchSession) ownSlice(value []any) []any {
+	if cap(value) > 0 {
+		if _, ok := session.owned[reflect.ValueOf(value).Pointer()]; ok {
+			return value
+		}
+	}
+	owned := make([]any, len(value), len(value)+1)
+	copy(owned, value)
+	session.markSlice(owned)
+	return owned
+}
+
+func (session *jsonPatchSession) markSlice(value []any) {
+	if cap(value) > 0 {
+		session.owned[reflect.ValueOf(value).Pointer()] = value
+	}
+}
+
+// jsonValuesEqual compares decoded JSON the way jsonBytesEqual does (numbers
+// by float64 value) but short-circuits on shared containers.
+func jsonValuesEqual(left any, right any) bool {
+	switch typed := left.(type) {
+	case map[string]any:
+		other, ok := right.(map[string]any)
+		if !ok || len(typed) != len(other) {
+			return false
+		}
+		if reflect.ValueOf(typed).Pointer() == reflect.ValueOf(other).Pointer() {
+			return true
+		}
+		for key, item := range typed {
+			otherItem, ok := other[key]
+			if !ok || !jsonValuesEqual(item, otherItem) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		other, ok := right.([]any)
+		if !ok || len(typed) != len(other) {
+			return false
+		}
+		if len(typed) > 0 && &typed[0] == &other[0] {
+			return true
+		}
+		for index := range typed {
+			if !jsonValuesEqual(typed[index], other[index]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		other, ok := right.(json.Number)
+		if !ok {
+			return false
+		}
+		leftFloat, leftErr := strconv.ParseFloat(typed.String(), 64)
+		rightFloat, rightErr := strconv.ParseFloat(other.String(), 64)
+		return leftErr == nil && rightErr == nil && leftFloat == rightFloat
+	default:
+		return left == right
+	}
+}
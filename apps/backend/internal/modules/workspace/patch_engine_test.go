@@ -0,0 +1,232 @@
+package workspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+const patchFuzzDocument = `{"ui":{"graph":{"version":1,"rootId":"root",` +
+	`"nodesById":{"root":{"id":"root","type":"container","props":{"gap":8,"ratio":1.5}},"a":{"id":"a","type":"text","text":"hi"},"b":{"id":"b","type":"button","props":{}}},` +
+	`"childIdsById":{"root":["a","b"],"a":[],"b":[]}}},` +
+	`"logic":{"state":{"count":{"type":"number","initial":0}},"graphs":[{"id":"g1","nodes":[1,2,3]},{"id":"g2","nodes":[]}]},` +
+	`"metadata":{"tags":["x","y"],"flag":true,"nothing":null}}`
+
+// allowAnyNonRootPath lets the fuzzer reach every container; only the root
+// pointer stays forbidden, as it is for every real validator.
+func allowAnyNonRootPath(path string) error {
+	pointer, err := parseJSONPointer(path)
+	if err != nil {
+		return err
+	}
+	if len(pointer) == 0 {
+		return ErrWorkspacePatchPathForbidden
+	}
+	return nil
+}
+
+// patchFuzzReader turns fuzz input into choices; it yields zeros once the
+// input runs out so every input decodes to some patch.
+type patchFuzzReader struct {
+	data []byte
+}
+
+func (reader *patchFuzzReader) next(limit int) int {
+	if len(reader.data) == 0 || limit <= 0 {
+		return 0
+	}
+	value := int(reader.data[0])
+	reader.data = reader.data[1:]
+	return value % limit
+}
+
+func collectJSONPointers(value any, prefix string, pointers *[]string) {
+	*pointers = append(*pointers, prefix)
+	switch typed := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectJSONPointers(typed[key], prefix+"/"+escapeJSONPointerSegment(key), pointers)
+		}
+	case []any:
+		for index, item := range typed {
+			collectJSONPointers(item, fmt.Sprintf("%s/%d", prefix, index), pointers)
+		}
+	}
+}
+
+var patchFuzzValues = []string{`1`, `1.0`, `"text"`, `null`, `true`, `{}`, `[]`, `{"k":[1,{"z":2}]}`, `[0,"a",{"b":false}]`}
+
+// generatePatchOps builds ops against the evolving reference state so most of
+// them address real paths, with some deliberately broken ones mixed in.
+func generatePatchOps(reader *patchFuzzReader, content json.RawMessage, count int) []WorkspacePatchOp {
+	ops := make([]WorkspacePatchOp, 0, count)
+	current := content
+	for len(ops) < count {
+		var document any
+		_ = decodeJSONValue(current, &document)
+		pointers := []string{}
+		collectJSONPointers(document, "", &pointers)
+		pick := func() string {
+			path := pointers[reader.next(len(pointers))]
+			switch reader.next(8) {
+			case 0:
+				return path + "/-"
+			case 1:
+				return path + "/new"
+			case 2:
+				return path + "/0"
+			case 3:
+				return path + "/9"
+			default:
+				return path
+			}
+		}
+		op := WorkspacePatchOp{
+			Op:    []string{"add", "remove", "replace", "test", "copy", "move", "ADD", "bogus"}[reader.next(8)],
+			Path:  pick(),
+			Value: json.RawMessage(patchFuzzValues[reader.next(len(patchFuzzValues))]),
+		}
+		if op.Op == "copy" || op.Op == "move" {
+			op.From = pick()
+		}
+		if op.Op == "remove" && reader.next(2) == 0 {
+			op.Value = nil
+		}
+		ops = append(ops, op)
+		if next, err := referenceApplyWorkspacePatch(current, []WorkspacePatchOp{op}, allowAnyNonRootPath); err == nil {
+			current = next
+		}
+	}
+	return ops
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func FuzzWorkspacePatchMatchesReference(f *testing.F) {
+	rng := rand.New(rand.NewSource(20))
+	for seed := 0; seed < 300; seed++ {
+		input := make([]byte, 64)
+		rng.Read(input)
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		reader := &patchFuzzReader{data: input}
+		source := json.RawMessage(patchFuzzDocument)
+		forward := generatePatchOps(reader, source, 1+reader.next(6))
+
+		want, wantErr := referenceApplyWorkspacePatch(source, forward, allowAnyNonRootPath)
+		patch, gotErr := patchWorkspaceJSON(source, forward, allowAnyNonRootPath)
+		if errorString(gotErr) != errorString(wantErr) {
+			t.Fatalf("error mismatch for %+v:\nengine:    %v\nreference: %v", forward, gotErr, wantErr)
+		}
+		if wantErr != nil {
+			return
+		}
+		if !bytes.Equal(patch.Content, want) {
+			t.Fatalf("content mismatch for %+v:\nengine:    %s\nreference: %s", forward, patch.Content, want)
+		}
+		original, _ := json.Marshal(patch.original)
+		if !jsonBytesEqual(original, source) {
+			t.Fatalf("engine mutated its input: %s", original)
+		}
+
+		reverse := generatePatchOps(reader, want, 1+reader.next(6))
+		restored, gotErr := patch.restoredBy(reverse)
+		reversed, wantErr := referenceApplyWorkspacePatch(want, reverse, allowAnyNonRootPath)
+		if errorString(gotErr) != errorString(wantErr) {
+			t.Fatalf("reverse error mismatch for %+v:\nengine:    %v\nreference: %v", reverse, gotErr, wantErr)
+		}
+		if wantErr == nil && restored != jsonBytesEqual(source, reversed) {
+			t.Fatalf("reversibility mismatch for %+v: engine %v", reverse, restored)
+		}
+		if after, _ := json.Marshal(patch.patched); !bytes.Equal(after, patch.Content) {
+			t.Fatalf("reverse check mutated the patched tree: %s", after)
+		}
+	})
+}
+
+func TestWorkspacePatchRestoredByDetectsWrongReverseOps(t *testing.T) {
+	source := json.RawMessage(patchFuzzDocument)
+	patch, err := patchWorkspaceJSON(source, []WorkspacePatchOp{
+		{Op: "replace", Path: "/ui/graph/nodesById/a/text", Value: mustRaw(`"bye"`)},
+		{Op: "add", Path: "/ui/graph/childIdsById/root/0", Value: mustRaw(`"b"`)},
+	}, allowAnyNonRootPath)
+	if err != nil {
+		t.Fatalf("apply patch: %v", err)
+	}
+	restored, err := patch.restoredBy([]WorkspacePatchOp{
+		{Op: "remove", Path: "/ui/graph/childIdsById/root/0"},
+		{Op: "replace", Path: "/ui/graph/nodesById/a/text", Value: mustRaw(`"hi"`)},
+	})
+	if err != nil || !restored {
+		t.Fatalf("exact reverse ops should restore: %v %v", restored, err)
+	}
+	restored, err = patch.restoredBy([]WorkspacePatchOp{
+		{Op: "remove", Path: "/ui/graph/childIdsById/root/0"},
+	})
+	if err != nil || restored {
+		t.Fatalf("partial reverse ops must not restore: %v %v", restored, err)
+	}
+}
+
+func BenchmarkWorkspacePatchReference(b *testing.B) {
+	benchmarkWorkspacePatch(b, func(source json.RawMessage, forward, reverse []WorkspacePatchOp) {
+		patched, _ := referenceApplyWorkspacePatch(source, forward, validateWorkspacePatchPath)
+		reversed, _ := referenceApplyWorkspacePatch(patched, reverse, validateWorkspacePatchPath)
+		if !jsonBytesEqual(source, reversed) {
+			b.Fatal("reference did not restore")
+		}
+	})
+}
+
+func BenchmarkWorkspacePatchEngine(b *testing.B) {
+	benchmarkWorkspacePatch(b, func(source json.RawMessage, forward, reverse []WorkspacePatchOp) {
+		patch, _ := patchWorkspaceJSON(source, forward, validateWorkspacePatchPath)
+		if restored, _ := patch.restoredBy(reverse); !restored {
+			b.Fatal("engine did not restore")
+		}
+	})
+}
+
+func benchmarkWorkspacePatch(b *testing.B, run func(source json.RawMessage, forward, reverse []WorkspacePatchOp)) {
+	source := generateMIRTestGraph(5000, rand.New(rand.NewSource(5000)))
+	forward := []WorkspacePatchOp{
+		{Op: "replace", Path: "/ui/graph/nodesById/n4200/props/label", Value: mustRaw(`"edited"`)},
+		{Op: "add", Path: "/ui/graph/nodesById/fresh", Value: mustRaw(`{"id":"fresh","type":"text"}`)},
+		{Op: "add", Path: "/ui/graph/childIdsById/n0/-", Value: mustRaw(`"fresh"`)},
+	}
+	reverse := []WorkspacePatchOp{
+		{Op: "remove", Path: "/ui/graph/childIdsById/n0/" + fmt.Sprint(countRootChildren(source))},
+		{Op: "remove", Path: "/ui/graph/nodesById/fresh"},
+		{Op: "replace", Path: "/ui/graph/nodesById/n4200/props/label", Value: mustRaw(`"n4200"`)},
+	}
+	b.ResetTimer()
+	for index := 0; index < b.N; index++ {
+		run(source, forward, reverse)
+	}
+}
+
+func countRootChildren(source json.RawMessage) int {
+	var document struct {
+		UI struct {
+			Graph struct {
+				ChildIDsByID map[string][]string `json:"childIdsById"`
+			} `json:"graph"`
+		} `json:"ui"`
+	}
+	_ = json.Unmarshal(source, &document)
+	return len(document.UI.Graph.ChildIDsByID["n0"])
+}
@@ -0,0 +1,193 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// This file keeps the original clone-everything patch implementation as the
+// reference the structural-sharing engine is fuzzed against. It deep-clones
+// the document, implements replace as remove+add and copies every container
+// it touches.
+
+func referenceApplyWorkspacePatch(content json.RawMessage, ops []WorkspacePatchOp, validatePath workspacePatchPathValidator) (json.RawMessage, error) {
+	var document any
+	if err := decodeJSONValue(content, &document); err != nil {
+		return nil, err
+	}
+	next := deepCloneJSONValue(document)
+	for index, op := range ops {
+		patched, err := referenceApplyPatchOperation(next, op, validatePath)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d: %w", index, err)
+		}
+		next = patched
+	}
+	return json.Marshal(next)
+}
+
+func referenceApplyPatchOperation(document any, op WorkspacePatchOp, validatePath workspacePatchPathValidator) (any, error) {
+	op.Op = strings.TrimSpace(strings.ToLower(op.Op))
+	op.Path = strings.TrimSpace(op.Path)
+	op.From = strings.TrimSpace(op.From)
+	if err := validatePath(op.Path); err != nil {
+		return nil, err
+	}
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodePatchValue(op.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return referenceAddJSONValue(document, path, value)
+	case "remove":
+		return referenceRemoveJSONValue(document, path)
+	case "replace":
+		if _, err := getJSONValue(document, path); err != nil {
+			return nil, err
+		}
+		removed, err := referenceRemoveJSONValue(document, path)
+		if err != nil {
+			return nil, err
+		}
+		return referenceAddJSONValue(removed, path, value)
+	case "test":
+		current, err := getJSONValue(document, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonDeepEqual(current, value) {
+			return nil, ErrWorkspacePatchTestFailed
+		}
+		return document, nil
+	case "copy":
+		from, err := parseAndValidateFromPointer(op.From, validatePath)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getJSONValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		return referenceAddJSONValue(document, path, deepCloneJSONValue(value))
+	case "move":
+		from, err := parseAndValidateFromPointer(op.From, validatePath)
+		if err != nil {
+			return nil, err
+		}
+		if isPointerPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrWorkspacePatchInvalid)
+		}
+		value, err := getJSONValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		removed, err := referenceRemoveJSONValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		adjustedPath := adjustMoveDestinationAfterRemove(from, path)
+		return referenceAddJSONValue(removed, adjustedPath, value)
+	default:
+		return nil, fmt.Errorf("%w: unsupported op %q", ErrWorkspacePatchInvalid, op.Op)
+	}
+}
+
+func referenceAddJSONValue(document any, path jsonPointer, value any) (any, error) {
+	if len(path) == 0 {
+		return deepCloneJSONValue(value), nil
+	}
+	parentPath := path[:len(path)-1]
+	key := path[len(path)-1]
+	parent, err := getJSONValue(document, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch typed := parent.(type) {
+	case map[string]any:
+		nextParent := deepCloneJSONValue(typed).(map[string]any)
+		nextParent[key] = deepCloneJSONValue(value)
+		return referenceReplaceJSONValue(document, parentPath, nextParent)
+	case []any:
+		index, err := parseArrayIndex(key, len(typed), true)
+		if err != nil {
+			return nil, err
+		}
+		nextParent := make([]any, 0, len(typed)+1)
+		nextParent = append(nextParent, typed[:index]...)
+		nextParent = append(nextParent, deepCloneJSONValue(value))
+		nextParent = append(nextParent, typed[index:]...)
+		return referenceReplaceJSONValue(document, parentPath, nextParent)
+	default:
+		return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
+	}
+}
+
+func referenceRemoveJSONValue(document any, path jsonPointer) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: remove root is forbidden", ErrWorkspacePatchInvalid)
+	}
+	parentPath := path[:len(path)-1]
+	key := path[len(path)-1]
+	parent, err := getJSONValue(document, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch typed := parent.(type) {
+	case map[string]any:
+		if _, ok := typed[key]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, key)
+		}
+		nextParent := deepCloneJSONValue(typed).(map[string]any)
+		delete(nextParent, key)
+		return referenceReplaceJSONValue(document, parentPath, nextParent)
+	case []any:
+		index, err := parseArrayIndex(key, len(typed), false)
+		if err != nil {
+			return nil, err
+		}
+		nextParent := make([]any, 0, len(typed)-1)
+		nextParent = append(nextParent, typed[:index]...)
+		nextParent = append(nextParent, typed[index+1:]...)
+		return referenceReplaceJSONValue(document, parentPath, nextParent)
+	default:
+		return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
+	}
+}
+
+func referenceReplaceJSONValue(document any, path jsonPointer, value any) (any, error) {
+	if len(path) == 0 {
+		return deepCloneJSONValue(value), nil
+	}
+	parentPath := path[:len(path)-1]
+	key := path[len(path)-1]
+	parent, err := getJSONValue(document, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch typed := parent.(type) {
+	case map[string]any:
+		if _, ok := typed[key]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrWorkspacePatchPathMissing, key)
+		}
+		nextParent := deepCloneJSONValue(typed).(map[string]any)
+		nextParent[key] = deepCloneJSONValue(value)
+		return referenceReplaceJSONValue(document, parentPath, nextParent)
+	case []any:
+		index, err := parseArrayIndex(key, len(typed), false)
+		if err != nil {
+			return nil, err
+		}
+		nextParent := deepCloneJSONValue(typed).([]any)
+		nextParent[index] = deepCloneJSONValue(value)
+		return referenceReplaceJSONValue(document, parentPath, nextParent)
+	default:
+		return nil, fmt.Errorf("%w: parent is not container", ErrWorkspacePatchInvalid)
+	}
+}
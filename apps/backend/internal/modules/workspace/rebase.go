@@ -0,0 +1,164 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// maxAutoRebaseDistance caps how many content revisions a stale patch may be
+// rebased across; older bases always get a plain revision conflict.
+const maxAutoRebaseDistance = 100
+
+// findRebaseConflicts compares a stale patch against the content commands
+// committed since its base revision. ok is false when those commands cannot
+// be read back, in which case the caller reports a plain revision conflict.
+// Otherwise conflicts lists every later command whose paths overlap the
+// paths the patch writes or tests; an empty list means the patch can be
+// applied to the current content as is.
+func findRebaseConflicts(
+	ctx context.Context,
+	conn workspaceQuerier,
+	workspaceID string,
+	documentID string,
+	baseContentRev int64,
+	currentContentRev int64,
+	ops []WorkspacePatchOp,
+) (conflicts []WorkspaceHistoryConflict, ok bool, err error) {
+	distance := currentContentRev - baseContentRev
+	if distance <= 0 || distance > maxAutoRebaseDistance {
+		return nil, false, nil
+	}
+
+	// Workspace-level operations are logged without a document id but can
+	// still change this document's content (checkpoint restores, workspace
+	// undo, directory deletes), so they are read alongside its own commands.
+	// The logged results carry the content revision each one left behind,
+	// which is what places an operation after the base revision.
+	const query = `SELECT op_seq, domain, document_id, payload_json, result_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND (document_id = $2 OR document_id IS NULL)
+ORDER BY op_seq DESC
+LIMIT $3`
+	rows, err := conn.QueryContext(ctx, query, workspaceID, documentID, historyScanWindow)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	type loggedRevision struct {
+		entry          historyEntry
+		workspaceLevel bool
+		contentRev     int64
+	}
+	newerFirst := make([]loggedRevision, 0, distance)
+	reachedBase := false
+	for rows.Next() {
+		var logged loggedRevision
+		var loggedDocumentID sql.NullString
+		var payload []byte
+		var resultJSON []byte
+		if err := rows.Scan(&logged.entry.opSeq, &logged.entry.domain, &loggedDocumentID, &payload, &resultJSON); err != nil {
+			return nil, false, err
+		}
+		var result WorkspaceMutationResult
+		if len(resultJSON) == 0 || json.Unmarshal(resultJSON, &result) != nil {
+			return nil, false, nil
+		}
+		contentRev, mentioned, removed := loggedDocumentRevision(result, documentID)
+		if removed {
+			return nil, false, nil
+		}
+		if !mentioned {
+			continue
+		}
+		if contentRev <= baseContentRev {
+			reachedBase = true
+			break
+		}
+		logged.workspaceLevel = !loggedDocumentID.Valid
+		logged.contentRev = contentRev
+		if !logged.workspaceLevel {
+			if err := json.Unmarshal(payload, &logged.entry.command); err != nil {
+				return nil, false, err
+			}
+		}
+		newerFirst = append(newerFirst, logged)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if !reachedBase {
+		return nil, false, nil
+	}
+
+	later := make([]historyEntry, 0, distance)
+	lastRev := baseContentRev
+	for index := len(newerFirst) - 1; index >= 0; index-- {
+		logged := newerFirst[index]
+		if logged.contentRev == lastRev {
+			// Metadata-only writes leave the content revision alone.
+			continue
+		}
+		// A workspace-level write has no ops under this document to rebase
+		// across.
+		if logged.workspaceLevel || !isDocumentContentCommand(logged.entry.command) {
+			return nil, false, nil
+		}
+		later = append(later, logged.entry)
+		lastRev = logged.contentRev
+	}
+	if lastRev != currentContentRev {
+		return nil, false, nil
+	}
+
+	patchPaths := rebasePatchPaths(ops)
+	conflicts = make([]WorkspaceHistoryConflict, 0)
+	for _, entry := range later {
+		// Commands logged without ops replaced the whole document.
+		laterPaths := []string{""}
+		if len(entry.command.ForwardOps) > 0 {
+			laterPaths = historyAffectedPaths(entry.command.ForwardOps)
+		}
+		overlapping := make([]string, 0)
+		for _, path := range laterPaths {
+			for _, patchPath := range patchPaths {
+				if jsonPointersOverlap(path, patchPath) {
+					overlapping = append(overlapping, path)
+					break
+				}
+			}
+		}
+		if len(overlapping) > 0 {
+			conflicts = append(conflicts, WorkspaceHistoryConflict{OpSeq: entry.opSeq, Domain: entry.domain, Paths: overlapping})
+		}
+	}
+	return conflicts, true, nil
+}
+
+// rebasePatchPaths returns the pointers a patch depends on: the ones it
+// writes plus the ones its test ops read.
+func rebasePatchPaths(ops []WorkspacePatchOp) []string {
+	paths := historyAffectedPaths(ops)
+	for _, op := range ops {
+		if op.Op == "test" {
+			paths = append(paths, op.Path)
+		}
+	}
+	return paths
+}
+
+// loggedDocumentRevision finds documentID in a logged mutation result.
+func loggedDocumentRevision(result WorkspaceMutationResult, documentID string) (contentRev int64, mentioned bool, removed bool) {
+	for _, removedID := range result.RemovedDocuments {
+		if removedID == documentID {
+			return 0, true, true
+		}
+	}
+	for _, updated := range result.UpdatedDocuments {
+		if updated.ID == documentID {
+			return updated.ContentRev, true, false
+		}
+	}
+	return 0, false, false
+}
@@ -0,0 +1,211 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func rebaseOperationJSON(t *testing.T, id string, path string) []byte {
+	t.Helper()
+	command := WorkspaceCommandEnvelope{
+		ID:         id,
+		Namespace:  "core.code",
+		Type:       "source.update",
+		Version:    "1.0",
+		IssuedAt:   time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC),
+		ForwardOps: []WorkspacePatchOp{{Op: "replace", Path: path, Value: json.RawMessage(`"later"`)}},
+		ReverseOps: []WorkspacePatchOp{{Op: "replace", Path: path, Value: json.RawMessage(`"earlier"`)}},
+		Target:     WorkspaceCommandTarget{WorkspaceID: "ws_1", DocumentID: "code_1"},
+	}
+	payload, err := json.Marshal(command)
+	if err != nil {
+		t.Fatalf("marshal operation: %v", err)
+	}
+	return payload
+}
+
+var rebaseTestDocument = testDocument{
+	id:         "code_1",
+	docType:    WorkspaceDocumentTypeCode,
+	contentRev: 5,
+	metaRev:    1,
+	content:    `{"language":"ts","source":"a"}`,
+}
+
+func rebaseResultJSON(t *testing.T, opSeq int64, contentRev int64) []byte {
+	t.Helper()
+	result, err := json.Marshal(WorkspaceMutationResult{
+		WorkspaceID:      "ws_1",
+		OpSeq:            opSeq,
+		UpdatedDocuments: []WorkspaceDocumentRevision{{ID: "code_1", ContentRev: contentRev, MetaRev: 1}},
+	})
+	if err != nil {
+		t.Fatalf("marshal result: %v", err)
+	}
+	return result
+}
+
+// rebaseOperationRows logs one command per path, newest first, down from the
+// document's current revision 5 to the op that produced base revision 3.
+func rebaseOperationRows(t *testing.T, paths ...string) *sqlmock.Rows {
+	t.Helper()
+	rows := sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json", "result_json"})
+	for index, path := range paths {
+		opSeq := int64(40 - index)
+		rows.AddRow(opSeq, "core.code.source.update@1.0", "code_1", rebaseOperationJSON(t, "cmd_later", path), rebaseResultJSON(t, opSeq, int64(5-index)))
+	}
+	opSeq := int64(40 - len(paths))
+	rows.AddRow(opSeq, "core.code.source.update@1.0", "code_1", rebaseOperationJSON(t, "cmd_base", "/source"), rebaseResultJSON(t, opSeq, 3))
+	return rows
+}
+
+const rebaseOperationsQuery = `SELECT op_seq, domain, document_id, payload_json, result_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND (document_id = $2 OR document_id IS NULL)
+ORDER BY op_seq DESC
+LIMIT $3`
+
+func rebaseCommand(issuedAt time.Time) WorkspaceCommandEnvelope {
+	command := testCommand("core.code", "source.update", issuedAt)
+	command.ID = "cmd_stale"
+	command.ForwardOps = []WorkspacePatchOp{{Op: "replace", Path: "/source", Value: json.RawMessage(`"b"`)}}
+	command.ReverseOps = []WorkspacePatchOp{{Op: "replace", Path: "/source", Value: json.RawMessage(`"a"`)}}
+	command.Target.DocumentID = "code_1"
+	return command
+}
+
+func TestWorkspaceStorePatchDocumentContentRebasesDisjointPaths(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 10, 1, 30, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectDocumentContentLock(mock, rebaseTestDocument, testHead{workspaceRev: 9, routeRev: 4, opSeq: 40})
+	mock.ExpectQuery(regexp.QuoteMeta(rebaseOperationsQuery)).
+		WithArgs("ws_1", "code_1", historyScanWindow).
+		WillReturnRows(rebaseOperationRows(t, "/language", "/metadata/owner"))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspace_documents
+SET content_json = $3::jsonb, content_rev = content_rev + 1, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2
+RETURNING content_rev, meta_rev`)).
+		WithArgs("ws_1", "code_1", `{"language":"ts","source":"b"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"content_rev", "meta_rev"}).AddRow(6, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 41))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)).
+		WithArgs("ws_1", int64(41), "core.code.source.update@1.0", "code_1", sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := store.PatchDocumentContent(context.Background(), PatchDocumentContentParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "code_1",
+		ExpectedContentRev: 3,
+		AutoRebase:         true,
+		Command:            rebaseCommand(issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("rebase patch: %v", err)
+	}
+	if result.RebasedFromContentRev != 3 || result.UpdatedDocuments[0].ContentRev != 6 {
+		t.Fatalf("unexpected rebase result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStorePatchDocumentContentReportsRebaseConflicts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	mock.ExpectBegin()
+	expectDocumentContentLock(mock, rebaseTestDocument, testHead{workspaceRev: 9, routeRev: 4, opSeq: 40})
+	mock.ExpectQuery(regexp.QuoteMeta(rebaseOperationsQuery)).
+		WithArgs("ws_1", "code_1", historyScanWindow).
+		WillReturnRows(rebaseOperationRows(t, "/source", "/language"))
+	mock.ExpectRollback()
+
+	_, err = store.PatchDocumentContent(context.Background(), PatchDocumentContentParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "code_1",
+		ExpectedContentRev: 3,
+		AutoRebase:         true,
+		Command:            rebaseCommand(time.Date(2026, time.February, 8, 10, 1, 30, 0, time.UTC)),
+	})
+	var conflictErr *WorkspaceRevisionConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected revision conflict, got %v", err)
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].OpSeq != 40 || conflictErr.Conflicts[0].Paths[0] != "/source" {
+		t.Fatalf("unexpected conflicts: %+v", conflictErr.Conflicts)
+	}
+	details := ExtractErrorDetails(BuildConflictPayload(conflictErr))
+	if _, ok := details["conflicts"]; !ok {
+		t.Fatalf("expected conflicts in payload details: %+v", details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStorePatchDocumentContentDoesNotRebaseAcrossWorkspaceRestore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// A checkpoint restore at opSeq 39 moved the document from revision 3 to
+	// 4 without logging ops under it; the later edit at 40 is disjoint.
+	rows := sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json", "result_json"}).
+		AddRow(int64(40), "core.code.source.update@1.0", "code_1", rebaseOperationJSON(t, "cmd_later", "/language"), rebaseResultJSON(t, 40, 5)).
+		AddRow(int64(39), "core.workspace.checkpoint.restore@1.0", nil, []byte(`{"id":"cmd_restore"}`), rebaseResultJSON(t, 39, 4)).
+		AddRow(int64(38), "core.code.source.update@1.0", "code_1", rebaseOperationJSON(t, "cmd_base", "/source"), rebaseResultJSON(t, 38, 3))
+
+	store := NewWorkspaceStore(db)
+	mock.ExpectBegin()
+	expectDocumentContentLock(mock, rebaseTestDocument, testHead{workspaceRev: 9, routeRev: 4, opSeq: 40})
+	mock.ExpectQuery(regexp.QuoteMeta(rebaseOperationsQuery)).
+		WithArgs("ws_1", "code_1", historyScanWindow).
+		WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	_, err = store.PatchDocumentContent(context.Background(), PatchDocumentContentParams{
+		WorkspaceID:        "ws_1",
+		DocumentID:         "code_1",
+		ExpectedContentRev: 3,
+		AutoRebase:         true,
+		Command:            rebaseCommand(time.Date(2026, time.February, 8, 10, 1, 30, 0, time.UTC)),
+	})
+	var conflictErr *WorkspaceRevisionConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected revision conflict, got %v", err)
+	}
+	if len(conflictErr.Conflicts) != 0 || conflictErr.ServerContentRev != 5 {
+		t.Fatalf("expected a plain conflict at revision 5, got %+v", conflictErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
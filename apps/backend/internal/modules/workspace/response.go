@@ -29,9 +29,67 @@ func MapStoreError(err error) *RequestFailure {
 		)
 		return &RequestFailure{Status: http.StatusConflict, Payload: BuildConflictPayload(conflictErr)}
 	}
+	var historyConflictErr *WorkspaceHistoryConflictError
+	if errors.As(err, &historyConflictErr) {
+		return &RequestFailure{Status: http.StatusConflict, Payload: BuildErrorEnvelopePayload(
+			ErrorHistoryConflict,
+			"Later operations touched the same paths.",
+			map[string]any{
+				"action":      historyConflictErr.Action,
+				"documentId":  historyConflictErr.DocumentID,
+				"targetOpSeq": historyConflictErr.TargetOpSeq,
+				"conflicts":   historyConflictErr.Conflicts,
+			},
+			backendresponse.WithDomain("workspace"),
+			backendresponse.WithSeverity("warning"),
+		)}
+	}
+	var validationErr *DocumentValidationError
+	if errors.As(err, &validationErr) && len(validationErr.Diagnostics) > 0 {
+		return &RequestFailure{Status: http.StatusUnprocessableEntity, Payload: BuildErrorEnvelopePayload(
+			validationErr.Diagnostics[0].Code,
+			"Document validation failed.",
+			nil,
+			backendresponse.WithDomain(validationErr.Domain),
+			backendresponse.WithDiagnostics(validationErr.Diagnostics),
+		)}
+	}
+	if errors.Is(err, ErrWorkspaceHistoryEmpty) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorHistoryEmpty, "Nothing to undo or redo.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceRestoreUnchanged) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorHistoryEmpty, "Content already matches the restore target.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceStateUnchanged) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "The intent does not change the workspace.", nil)
+	}
+	if errors.Is(err, ErrInvalidWorkspaceDocumentType) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorDocumentTypeUnsupported, "Document type is not supported for this operation.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceCanonicalDocument) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorCanonicalDocument, "The canonical workspace document cannot be deleted.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceCheckpointNotFound) {
+		return NewRequestFailure(http.StatusNotFound, ErrorCheckpointNotFound, "Workspace checkpoint not found.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceCheckpointNameTaken) {
+		return NewRequestFailure(http.StatusConflict, ErrorCheckpointNameTaken, "A checkpoint with this name already exists.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceOpSeqOutOfRange) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, "atOpSeq is beyond the workspace head.", nil)
+	}
+	if errors.Is(err, ErrRoutePathInvalid) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, err.Error(), nil)
+	}
 	if errors.Is(err, ErrWorkspaceNotFound) {
 		return NewRequestFailure(http.StatusNotFound, ErrorWorkspaceNotFound, "Workspace not found.", nil)
 	}
+	if errors.Is(err, ErrWorkspaceAccessDenied) {
+		return NewRequestFailure(http.StatusForbidden, ErrorWorkspaceAccessDenied, "You do not have permission to access this workspace.", nil)
+	}
+	if errors.Is(err, ErrWorkspaceIdempotencyKeyReused) {
+		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorIdempotencyKeyReused, "Idempotency key was already used with a different request payload.", nil)
+	}
 	if errors.Is(err, ErrWorkspaceDocumentNotFound) {
 		return NewRequestFailure(http.StatusNotFound, ErrorWorkspaceDocumentNotFound, "Workspace document not found.", nil)
 	}
@@ -48,9 +106,6 @@ func MapStoreError(err error) *RequestFailure {
 	if errors.Is(err, ErrWorkspaceVFSInvalid) {
 		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, err.Error(), nil)
 	}
-	if errors.Is(err, ErrMIRV13ValidationFailed) {
-		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorMIRValidationFailed, err.Error(), nil)
-	}
 	if IsWorkspaceEnvelopeError(err) {
 		return NewRequestFailure(http.StatusUnprocessableEntity, ErrorInvalidPayload, err.Error(), nil)
 	}
@@ -73,6 +128,12 @@ func BuildConflictPayload(conflictErr *WorkspaceRevisionConflictError) map[strin
 			"metaRev":    conflictErr.ServerMetaRev,
 		}
 	}
+	if conflictErr.ServerSettingsRev > 0 {
+		details["serverSettingsRev"] = conflictErr.ServerSettingsRev
+	}
+	if len(conflictErr.Conflicts) > 0 {
+		details["conflicts"] = conflictErr.Conflicts
+	}
 	return BuildErrorEnvelopePayload(
 		code,
 		"Revision conflict.",
@@ -89,6 +150,8 @@ func ErrorWorkspaceConflictCode(conflictType WorkspaceConflictType) string {
 		return "WKS-4002"
 	case WorkspaceConflictDocument:
 		return "WKS-4003"
+	case WorkspaceConflictSettings:
+		return "WKS-4007"
 	default:
 		return "WKS-4001"
 	}
@@ -104,12 +167,31 @@ func BuildMutationSuccessPayload(result *WorkspaceMutationResult, acceptedMutati
 	if len(result.UpdatedDocuments) > 0 {
 		response["updatedDocuments"] = result.UpdatedDocuments
 	}
+	if len(result.RemovedDocuments) > 0 {
+		response["removedDocuments"] = result.RemovedDocuments
+	}
+	if result.RebasedFromContentRev > 0 {
+		response["rebasedFromContentRev"] = result.RebasedFromContentRev
+	}
+	if result.SettingsRev > 0 {
+		response["settingsRev"] = result.SettingsRev
+	}
+	if result.RebasedFromSettingsRev > 0 {
+		response["rebasedFromSettingsRev"] = result.RebasedFromSettingsRev
+	}
 	if acceptedMutationID != "" {
 		response["acceptedMutationId"] = acceptedMutationID
 	}
 	return response
 }
 
+func BuildBatchSuccessPayload(batch *WorkspaceBatchResult, acceptedMutationID string) map[string]any {
+	response := BuildMutationSuccessPayload(batch.Latest, acceptedMutationID)
+	response["opSeqRange"] = map[string]any{"from": batch.FromOpSeq, "to": batch.ToOpSeq}
+	response["operations"] = batch.Operations
+	return response
+}
+
 func LogWorkspaceConflictFailure(
 	action string,
 	method string,
@@ -173,19 +255,6 @@ func IsWorkspaceEnvelopeError(err error) bool {
 		strings.Contains(message, "target.workspaceId") ||
 		strings.Contains(message, "expectedContentRev") ||
 		strings.Contains(message, "expectedWorkspaceRev") ||
-		strings.Contains(message, "expectedRouteRev")
-}
-
-func DefaultCapabilities() map[string]bool {
-	return map[string]bool{
-		"core.mir.document.update@1.0":             true,
-		"core.mir.graph.replace@1.0":               true,
-		"core.route.manifest.update@1.0":           true,
-		"core.settings.global.update@1.0":          true,
-		"core.workspace.code-document.create@1.0":  true,
-		"core.nodegraph.node.move@1.0":             false,
-		"core.nodegraph.edge.connect@1.0":          false,
-		"core.animation.timeline.keyframe.add@1.0": false,
-		"core.animation.clip.bind@1.0":             false,
-	}
+		strings.Contains(message, "expectedRouteRev") ||
+		strings.Contains(message, "expectedSettingsRev")
 }
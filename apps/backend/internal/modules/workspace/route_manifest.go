@@ -0,0 +1,492 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const routeDomain = "route"
+
+const (
+	DiagnosticRoutePathDuplicated       = "RTE-1001"
+	DiagnosticRoutePathInvalid          = "RTE-1002"
+	DiagnosticRouteIDDuplicated         = "RTE-1003"
+	DiagnosticRouteManifestInvalid      = "RTE-1004"
+	DiagnosticRouteParamInvalid         = "RTE-1010"
+	DiagnosticRouteDocumentNotFound     = "RTE-2001"
+	DiagnosticRouteDocumentTypeMismatch = "RTE-2002"
+	DiagnosticRouteOutletMissing        = "RTE-3001"
+	DiagnosticRouteOutletNodeNotFound   = "RTE-3003"
+)
+
+const (
+	routeManifestVersion = "1"
+	routeOutletNodeType  = "MdrOutlet"
+)
+
+var (
+	routeStaticSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+	routeParamNamePattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+type routePatternPartKind int
+
+const (
+	routePatternStatic routePatternPartKind = iota
+	routePatternParam
+	routePatternSplat
+)
+
+// routePatternPart is one "/"-separated piece of a route segment: a literal,
+// a ":name" param or the trailing "*" splat.
+type routePatternPart struct {
+	kind  routePatternPartKind
+	value string
+}
+
+// parseRouteSegment splits a RouteNode segment into pattern parts. A leading
+// "/" marks the segment as absolute; the message is empty when the segment
+// is valid and names the first problem otherwise.
+func parseRouteSegment(segment string) (parts []routePatternPart, absolute bool, code string, message string) {
+	trimmed := strings.TrimSpace(segment)
+	if trimmed != segment {
+		return nil, false, DiagnosticRoutePathInvalid, "segment must not have surrounding whitespace"
+	}
+	absolute = strings.HasPrefix(segment, "/")
+	body := strings.TrimPrefix(segment, "/")
+	if body == "" {
+		return nil, absolute, "", ""
+	}
+	pieces := strings.Split(body, "/")
+	for index, piece := range pieces {
+		switch {
+		case piece == "":
+			return nil, absolute, DiagnosticRoutePathInvalid, fmt.Sprintf("segment %q contains an empty path part", segment)
+		case piece == "*":
+			if index != len(pieces)-1 {
+				return nil, absolute, DiagnosticRoutePathInvalid, fmt.Sprintf("segment %q may only use * as its last part", segment)
+			}
+			parts = append(parts, routePatternPart{kind: routePatternSplat, value: "*"})
+		case strings.HasPrefix(piece, ":"):
+			name := piece[1:]
+			if !routeParamNamePattern.MatchString(name) {
+				return nil, absolute, DiagnosticRouteParamInvalid, fmt.Sprintf("param %q must be a letter or _ followed by letters, digits or _", piece)
+			}
+			parts = append(parts, routePatternPart{kind: routePatternParam, value: name})
+		default:
+			if !routeStaticSegmentPattern.MatchString(piece) {
+				return nil, absolute, DiagnosticRoutePathInvalid, fmt.Sprintf("path part %q may only contain letters, digits and -._~", piece)
+			}
+			parts = append(parts, routePatternPart{kind: routePatternStatic, value: piece})
+		}
+	}
+	return parts, absolute, "", ""
+}
+
+// routeSiblingKey is what two sibling routes must not share: params compare
+// equal regardless of their names.
+func routeSiblingKey(parts []routePatternPart, absolute bool) string {
+	var builder strings.Builder
+	if absolute {
+		builder.WriteString("/")
+	}
+	for index, part := range parts {
+		if index > 0 {
+			builder.WriteString("/")
+		}
+		switch part.kind {
+		case routePatternParam:
+			builder.WriteString(":")
+		default:
+			builder.WriteString(part.value)
+		}
+	}
+	return builder.String()
+}
+
+func routeNodeTarget(routeID string) map[string]any {
+	if routeID == "" {
+		return nil
+	}
+	return map[string]any{"kind": "route-node", "routeId": routeID}
+}
+
+// routeManifestValidator walks a manifest once. documents are the workspace
+// documents it may reference; only mir-layout entries need their content.
+type routeManifestValidator struct {
+	diagnostics diagnosticReport
+	documents   map[string]workspaceStateDocument
+	routePaths  map[string]string
+	outlets     map[string]map[string]bool
+}
+
+// validateRouteManifest checks a route manifest against decisions 08 and 13:
+// unique route ids, valid segments and params, distinct sibling paths,
+// page/layout references to documents of the right type, and an outlet in
+// every layout that has child routes.
+func validateRouteManifest(manifest json.RawMessage, documents map[string]workspaceStateDocument) error {
+	var document map[string]any
+	if err := json.Unmarshal(manifest, &document); err != nil {
+		return err
+	}
+	validator := &routeManifestValidator{
+		diagnostics: diagnosticReport{domain: routeDomain},
+		documents:   documents,
+		routePaths:  make(map[string]string),
+		outlets:     make(map[string]map[string]bool),
+	}
+	if document["version"] != routeManifestVersion {
+		validator.diagnostics.add(DiagnosticRouteManifestInvalid, "/version", `version must be "`+routeManifestVersion+`"`)
+	}
+	if _, ok := document["root"].(map[string]any); !ok {
+		validator.diagnostics.add(DiagnosticRouteManifestInvalid, "/root", "root route is required")
+		return validator.diagnostics.err()
+	}
+	validator.checkNode(document["root"], "/root", nil)
+	return validator.diagnostics.err()
+}
+
+func (validator *routeManifestValidator) checkNode(value any, path string, params map[string]bool) {
+	diagnostics := &validator.diagnostics
+	node, ok := value.(map[string]any)
+	if !ok {
+		diagnostics.add(DiagnosticRouteManifestInvalid, path, "route must be an object")
+		return
+	}
+	routeID, _ := node["id"].(string)
+	target := routeNodeTarget(routeID)
+	if strings.TrimSpace(routeID) == "" {
+		diagnostics.add(DiagnosticRouteManifestInvalid, path+"/id", "route id is required")
+	} else if previous, taken := validator.routePaths[routeID]; taken {
+		diagnostics.addTarget(DiagnosticRouteIDDuplicated, path+"/id", fmt.Sprintf("route id %q is already used at %s", routeID, previous), target)
+	} else {
+		validator.routePaths[routeID] = path
+	}
+
+	fields := make(map[string]string)
+	for _, key := range []string{"segment", "layoutDocId", "pageDocId", "outletNodeId"} {
+		raw, present := node[key]
+		if !present {
+			continue
+		}
+		text, ok := raw.(string)
+		if !ok {
+			diagnostics.addTarget(DiagnosticRouteManifestInvalid, path+"/"+key, key+" must be a string", target)
+			continue
+		}
+		fields[key] = text
+	}
+	isIndex := false
+	if raw, present := node["index"]; present {
+		flag, ok := raw.(bool)
+		if !ok {
+			diagnostics.addTarget(DiagnosticRouteManifestInvalid, path+"/index", "index must be a boolean", target)
+		}
+		isIndex = flag
+	}
+	var children []any
+	if raw, present := node["children"]; present {
+		list, ok := raw.([]any)
+		if !ok {
+			diagnostics.addTarget(DiagnosticRouteManifestInvalid, path+"/children", "children must be an array", target)
+		}
+		children = list
+	}
+
+	if isIndex && fields["segment"] != "" {
+		diagnostics.addTarget(DiagnosticRoutePathInvalid, path+"/segment", "index routes must not declare a segment", target)
+	}
+	if isIndex && len(children) > 0 {
+		diagnostics.addTarget(DiagnosticRoutePathInvalid, path+"/children", "index routes must not have children", target)
+	}
+	childParams := params
+	if segment := fields["segment"]; segment != "" {
+		parts, _, code, message := parseRouteSegment(segment)
+		if code != "" {
+			diagnostics.addTarget(code, path+"/segment", message, target)
+		}
+		for _, part := range parts {
+			switch part.kind {
+			case routePatternParam:
+				if childParams[part.value] {
+					diagnostics.addTarget(DiagnosticRouteParamInvalid, path+"/segment", fmt.Sprintf("param %q is already declared by a parent route", part.value), target)
+					continue
+				}
+				next := make(map[string]bool, len(childParams)+1)
+				for name := range childParams {
+					next[name] = true
+				}
+				next[part.value] = true
+				childParams = next
+			case routePatternSplat:
+				if len(children) > 0 {
+					diagnostics.addTarget(DiagnosticRoutePathInvalid, path+"/segment", "splat routes must not have children", target)
+				}
+			}
+		}
+	}
+
+	if documentID := fields["layoutDocId"]; documentID != "" {
+		if validator.checkDocumentRef(path+"/layoutDocId", documentID, WorkspaceDocumentTypeMIRLayout, target) && len(children) > 0 {
+			validator.checkOutlet(path, documentID, fields["outletNodeId"], target)
+		}
+	}
+	if documentID := fields["pageDocId"]; documentID != "" {
+		validator.checkDocumentRef(path+"/pageDocId", documentID, WorkspaceDocumentTypeMIRPage, target)
+	}
+	validator.checkRuntime(node["runtime"], path+"/runtime", target)
+
+	siblings := make(map[string]string)
+	for index, child := range children {
+		childPath := fmt.Sprintf("%s/children/%d", path, index)
+		validator.checkNode(child, childPath, childParams)
+		childNode, _ := child.(map[string]any)
+		key, keyPath := "", ""
+		if childIndex, _ := childNode["index"].(bool); childIndex {
+			key, keyPath = "(index)", childPath+"/index"
+		} else if segment, _ := childNode["segment"].(string); segment != "" {
+			parts, absolute, code, _ := parseRouteSegment(segment)
+			if code != "" {
+				continue
+			}
+			key, keyPath = routeSiblingKey(parts, absolute), childPath+"/segment"
+		}
+		if key == "" {
+			continue
+		}
+		if previous, taken := siblings[key]; taken {
+			childID, _ := childNode["id"].(string)
+			diagnostics.addTarget(DiagnosticRoutePathDuplicated, keyPath, fmt.Sprintf("route path %q duplicates sibling %s", key, previous), routeNodeTarget(childID))
+			continue
+		}
+		siblings[key] = childPath
+	}
+}
+
+// checkRuntime covers the document references of the route runtime
+// contract; loader/guard/action refs are resolved by the runtime itself.
+func (validator *routeManifestValidator) checkRuntime(value any, path string, target map[string]any) {
+	if value == nil {
+		return
+	}
+	runtime, ok := value.(map[string]any)
+	if !ok {
+		validator.diagnostics.addTarget(DiagnosticRouteManifestInvalid, path, "runtime must be an object", target)
+		return
+	}
+	for _, key := range []string{"errorBoundaryDocId", "suspenseDocId"} {
+		if documentID, ok := runtime[key].(string); ok && documentID != "" {
+			validator.checkDocumentRef(path+"/"+key, documentID, WorkspaceDocumentTypeMIRPage, target)
+		}
+	}
+	experiment, _ := runtime["experiment"].(map[string]any)
+	variantMap, _ := experiment["variantMap"].(map[string]any)
+	for _, variant := range sortedKeys(variantMap) {
+		if documentID, ok := variantMap[variant].(string); ok && documentID != "" {
+			validator.checkDocumentRef(path+"/experiment/variantMap/"+escapeJSONPointerSegment(variant), documentID, WorkspaceDocumentTypeMIRPage, target)
+		}
+	}
+}
+
+// checkDocumentRef reports whether documentID names a document of the
+// expected type.
+func (validator *routeManifestValidator) checkDocumentRef(path, documentID string, expected WorkspaceDocumentType, target map[string]any) bool {
+	document, exists := validator.documents[documentID]
+	if !exists {
+		validator.diagnostics.addTarget(DiagnosticRouteDocumentNotFound, path, fmt.Sprintf("document %q not found in workspace", documentID), target)
+		return false
+	}
+	if document.Type != expected {
+		validator.diagnostics.addTarget(DiagnosticRouteDocumentTypeMismatch, path, fmt.Sprintf("document %q is %s, expected %s", documentID, document.Type, expected), target)
+		return false
+	}
+	return true
+}
+
+func (validator *routeManifestValidator) checkOutlet(path, layoutDocID, outletNodeID string, target map[string]any) {
+	outlets, cached := validator.outlets[layoutDocID]
+	if !cached {
+		outlets = mirOutletNodeIDs(validator.documents[layoutDocID].Content)
+		validator.outlets[layoutDocID] = outlets
+	}
+	if outletNodeID != "" {
+		if !outlets[outletNodeID] {
+			validator.diagnostics.addTarget(DiagnosticRouteOutletNodeNotFound, path+"/outletNodeId", fmt.Sprintf("layout %q has no %s node %q", layoutDocID, routeOutletNodeType, outletNodeID), target)
+		}
+		return
+	}
+	if len(outlets) == 0 {
+		validator.diagnostics.addTarget(DiagnosticRouteOutletMissing, path+"/layoutDocId", fmt.Sprintf("layout %q has child routes but no %s node", layoutDocID, routeOutletNodeType), target)
+	}
+}
+
+// mirOutletNodeIDs lists the outlet nodes of a MIR v1.3 document.
+func mirOutletNodeIDs(content json.RawMessage) map[string]bool {
+	var document struct {
+		UI struct {
+			Graph struct {
+				NodesByID map[string]struct {
+					Type string `json:"type"`
+				} `json:"nodesById"`
+			} `json:"graph"`
+		} `json:"ui"`
+	}
+	outlets := make(map[string]bool)
+	if err := json.Unmarshal(content, &document); err != nil {
+		return outlets
+	}
+	for nodeID, node := range document.UI.Graph.NodesByID {
+		if node.Type == routeOutletNodeType {
+			outlets[nodeID] = true
+		}
+	}
+	return outlets
+}
+
+// loadRouteDocuments reads what validateRouteManifest needs: every
+// document's type, and content for layouts only.
+func loadRouteDocuments(ctx context.Context, tx workspaceQuerier, workspaceID string) (map[string]workspaceStateDocument, error) {
+	const routeDocumentQuery = `SELECT id, doc_type, CASE WHEN doc_type = 'mir-layout' THEN content_json END
+FROM workspace_documents
+WHERE workspace_id = $1`
+
+	rows, err := tx.QueryContext(ctx, routeDocumentQuery, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := make(map[string]workspaceStateDocument)
+	for rows.Next() {
+		var documentID string
+		var documentType string
+		var content []byte
+		if err := rows.Scan(&documentID, &documentType, &content); err != nil {
+			return nil, err
+		}
+		documents[documentID] = workspaceStateDocument{Type: WorkspaceDocumentType(documentType), Content: json.RawMessage(content)}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// checkWorkspaceRouteManifest validates the route manifest of a workspace
+// state change when the change can break it: the manifest itself changed, or
+// a document it may reference was removed or changed type. In the latter
+// case a manifest that was already invalid is left to its next edit, so
+// unrelated document deletions are not blocked by older problems.
+func checkWorkspaceRouteManifest(previous workspaceState, next workspaceState) error {
+	if !jsonBytesEqual(previous.RouteManifest, next.RouteManifest) {
+		return validateRouteManifest(next.RouteManifest, next.Documents)
+	}
+	if !workspaceRoutesAffected(previous, next) || validateRouteManifest(previous.RouteManifest, previous.Documents) != nil {
+		return nil
+	}
+	return validateRouteManifest(next.RouteManifest, next.Documents)
+}
+
+// workspaceRoutesAffected reports whether next changes the route manifest,
+// removes or retypes a document it could reference, or changes the outlets
+// of a loaded layout.
+func workspaceRoutesAffected(previous workspaceState, next workspaceState) bool {
+	if !jsonBytesEqual(previous.RouteManifest, next.RouteManifest) {
+		return true
+	}
+	for documentID, before := range previous.Documents {
+		after, exists := next.Documents[documentID]
+		if !exists || after.Type != before.Type {
+			return true
+		}
+		if before.Type == WorkspaceDocumentTypeMIRLayout && contentLoaded(before.Content) && contentLoaded(after.Content) &&
+			layoutOutletsChanged(before.Content, after.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutOutletsChanged reports whether a layout edit adds or removes outlet
+// nodes, the only part of a layout route validation reads.
+func layoutOutletsChanged(previous json.RawMessage, next json.RawMessage) bool {
+	before := mirOutletNodeIDs(previous)
+	after := mirOutletNodeIDs(next)
+	if len(before) != len(after) {
+		return true
+	}
+	for nodeID := range before {
+		if !after[nodeID] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLayoutRoutes re-checks the route manifest when a content patch
+// changes the outlets of a layout it references. As in
+// checkWorkspaceRouteManifest, a manifest that was already invalid is left
+// to its next edit.
+func validateLayoutRoutes(ctx context.Context, tx workspaceQuerier, workspaceID, layoutID string, previous, next json.RawMessage) error {
+	if !layoutOutletsChanged(previous, next) {
+		return nil
+	}
+	const manifestQuery = `SELECT manifest_json FROM workspace_routes WHERE workspace_id = $1`
+	var manifest json.RawMessage
+	if err := tx.QueryRowContext(ctx, manifestQuery, workspaceID).Scan(&manifest); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !routeManifestLayoutIDs(manifest)[layoutID] {
+		return nil
+	}
+	documents, err := loadRouteDocuments(ctx, tx, workspaceID)
+	if err != nil {
+		return err
+	}
+	layout := documents[layoutID]
+	layout.Content = previous
+	documents[layoutID] = layout
+	if validateRouteManifest(manifest, documents) != nil {
+		return nil
+	}
+	layout.Content = next
+	documents[layoutID] = layout
+	return validateRouteManifest(manifest, documents)
+}
+
+// routeManifestLayoutIDs lists the layoutDocId of every route in manifest.
+func routeManifestLayoutIDs(manifest json.RawMessage) map[string]bool {
+	type routeNode struct {
+		LayoutDocID string            `json:"layoutDocId"`
+		Children    []json.RawMessage `json:"children"`
+	}
+	var document struct {
+		Root json.RawMessage `json:"root"`
+	}
+	layoutIDs := make(map[string]bool)
+	if json.Unmarshal(manifest, &document) != nil {
+		return layoutIDs
+	}
+	pending := []json.RawMessage{document.Root}
+	for len(pending) > 0 {
+		var node routeNode
+		raw := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if json.Unmarshal(raw, &node) != nil {
+			continue
+		}
+		if node.LayoutDocID != "" {
+			layoutIDs[node.LayoutDocID] = true
+		}
+		pending = append(pending, node.Children...)
+	}
+	return layoutIDs
+}
@@ -0,0 +1,253 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+var routeDocumentQuery = regexp.QuoteMeta(`SELECT id, doc_type, CASE WHEN doc_type = 'mir-layout' THEN content_json END
+FROM workspace_documents
+WHERE workspace_id = $1`)
+
+func routeTestDocuments() map[string]workspaceStateDocument {
+	return map[string]workspaceStateDocument{
+		"layout_root":  {Type: WorkspaceDocumentTypeMIRLayout, Content: defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRLayout)},
+		"layout_plain": {Type: WorkspaceDocumentTypeMIRLayout, Content: defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRPage)},
+		"page_home":    {Type: WorkspaceDocumentTypeMIRPage},
+		"page_user":    {Type: WorkspaceDocumentTypeMIRPage},
+		"code_a":       {Type: WorkspaceDocumentTypeCode},
+	}
+}
+
+func routeDiagnostics(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *DocumentValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	got := make([]string, 0, len(validationErr.Diagnostics))
+	for _, diagnostic := range validationErr.Diagnostics {
+		got = append(got, diagnostic.Code+" "+diagnostic.Path)
+	}
+	return got
+}
+
+func TestValidateRouteManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "default manifest",
+			manifest: string(defaultWorkspaceRouteManifest),
+		},
+		{
+			name: "nested layouts, params and splat",
+			manifest: `{"version":"1","root":{"id":"root","layoutDocId":"layout_root","outletNodeId":"outlet","children":[
+				{"id":"home","index":true,"pageDocId":"page_home"},
+				{"id":"users","segment":"users","layoutDocId":"layout_root","children":[
+					{"id":"user","segment":":userId","pageDocId":"page_user"},
+					{"id":"user-files","segment":":userId/files/*","pageDocId":"page_user"}
+				]},
+				{"id":"docs","segment":"/docs/v1.2","runtime":{"errorBoundaryDocId":"page_home","experiment":{"key":"k","variantMap":{"a":"page_user"}}}}
+			]}}`,
+		},
+		{
+			name:     "shape problems",
+			manifest: `{"version":2,"root":{"id":"","segment":3,"index":"yes","children":{}}}`,
+			want: []string{
+				"RTE-1004 /version",
+				"RTE-1004 /root/id",
+				"RTE-1004 /root/segment",
+				"RTE-1004 /root/index",
+				"RTE-1004 /root/children",
+			},
+		},
+		{
+			name:     "missing root",
+			manifest: `{"version":"1"}`,
+			want:     []string{"RTE-1004 /root"},
+		},
+		{
+			name: "duplicate ids and sibling paths",
+			manifest: `{"version":"1","root":{"id":"root","children":[
+				{"id":"a","segment":"users/:id"},
+				{"id":"a","segment":"users/:slug"},
+				{"id":"home","index":true},
+				{"id":"home2","index":true},
+				{"id":"group","children":[{"id":"nested","segment":"users/:id"}]}
+			]}}`,
+			want: []string{
+				"RTE-1003 /root/children/1/id",
+				"RTE-1001 /root/children/1/segment",
+				"RTE-1001 /root/children/3/index",
+			},
+		},
+		{
+			name: "invalid segments and params",
+			manifest: `{"version":"1","root":{"id":"root","children":[
+				{"id":"a","segment":"a//b"},
+				{"id":"b","segment":"*/x"},
+				{"id":"c","segment":"sp ace"},
+				{"id":"d","segment":":1st"},
+				{"id":"e","segment":":id","children":[{"id":"f","segment":"x/:id"}]},
+				{"id":"g","index":true,"segment":"g","children":[{"id":"h"}]},
+				{"id":"i","segment":"*","children":[{"id":"j"}]},
+				{"id":"k","segment":" k"}
+			]}}`,
+			want: []string{
+				"RTE-1002 /root/children/0/segment",
+				"RTE-1002 /root/children/1/segment",
+				"RTE-1002 /root/children/2/segment",
+				"RTE-1010 /root/children/3/segment",
+				"RTE-1010 /root/children/4/children/0/segment",
+				"RTE-1002 /root/children/5/segment",
+				"RTE-1002 /root/children/5/children",
+				"RTE-1002 /root/children/6/segment",
+				"RTE-1002 /root/children/7/segment",
+			},
+		},
+		{
+			name: "document references",
+			manifest: `{"version":"1","root":{"id":"root","children":[
+				{"id":"a","segment":"a","pageDocId":"page_missing"},
+				{"id":"b","segment":"b","pageDocId":"layout_root","layoutDocId":"code_a"},
+				{"id":"c","segment":"c","runtime":{"suspenseDocId":"layout_root","experiment":{"variantMap":{"x/y":"nope"}}}}
+			]}}`,
+			want: []string{
+				"RTE-2001 /root/children/0/pageDocId",
+				"RTE-2002 /root/children/1/layoutDocId",
+				"RTE-2002 /root/children/1/pageDocId",
+				"RTE-2002 /root/children/2/runtime/suspenseDocId",
+				"RTE-2001 /root/children/2/runtime/experiment/variantMap/x~1y",
+			},
+		},
+		{
+			name: "layouts with child routes need an outlet",
+			manifest: `{"version":"1","root":{"id":"root","children":[
+				{"id":"a","segment":"a","layoutDocId":"layout_plain","children":[{"id":"a1","index":true}]},
+				{"id":"b","segment":"b","layoutDocId":"layout_root","outletNodeId":"root","children":[{"id":"b1","index":true}]},
+				{"id":"c","segment":"c","layoutDocId":"layout_plain"}
+			]}}`,
+			want: []string{
+				"RTE-3001 /root/children/0/layoutDocId",
+				"RTE-3003 /root/children/1/outletNodeId",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := routeDiagnostics(t, validateRouteManifest(json.RawMessage(test.manifest), routeTestDocuments()))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("diagnostics = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateRouteManifestTargetsRouteNodes(t *testing.T) {
+	err := validateRouteManifest(json.RawMessage(`{"version":"1","root":{"id":"root","children":[{"id":"a","pageDocId":"gone"}]}}`), nil)
+	var validationErr *DocumentValidationError
+	if !errors.As(err, &validationErr) || validationErr.Domain != routeDomain {
+		t.Fatalf("expected route validation error, got %v", err)
+	}
+	target := validationErr.Diagnostics[0].TargetRef
+	if target["kind"] != "route-node" || target["routeId"] != "a" {
+		t.Fatalf("unexpected targetRef: %v", target)
+	}
+}
+
+func TestCheckWorkspaceRouteManifestOnlyBlocksNewBreakage(t *testing.T) {
+	manifest := json.RawMessage(`{"version":"1","root":{"id":"root","children":[{"id":"home","index":true,"pageDocId":"page_home"}]}}`)
+	previous := workspaceState{RouteManifest: manifest, Documents: routeTestDocuments()}
+	next := workspaceState{RouteManifest: manifest, Documents: routeTestDocuments()}
+	delete(next.Documents, "code_a")
+	if err := checkWorkspaceRouteManifest(previous, next); err != nil {
+		t.Fatalf("deleting an unreferenced document: %v", err)
+	}
+	delete(next.Documents, "page_home")
+	if got := routeDiagnostics(t, checkWorkspaceRouteManifest(previous, next)); !reflect.DeepEqual(got, []string{"RTE-2001 /root/children/0/pageDocId"}) {
+		t.Fatalf("deleting a routed page: %v", got)
+	}
+
+	broken := json.RawMessage(`{"root":{"id":"root"}}`)
+	previous.RouteManifest, next.RouteManifest = broken, broken
+	if err := checkWorkspaceRouteManifest(previous, next); err != nil {
+		t.Fatalf("an already broken manifest must not block deletions: %v", err)
+	}
+	next.RouteManifest = json.RawMessage(`{"root":{"id":"root","segment":""}}`)
+	if got := routeDiagnostics(t, checkWorkspaceRouteManifest(previous, next)); !reflect.DeepEqual(got, []string{"RTE-1004 /version"}) {
+		t.Fatalf("edited manifests are always validated: %v", got)
+	}
+}
+
+func TestCheckWorkspaceRouteManifestRevalidatesLayoutOutlets(t *testing.T) {
+	manifest := json.RawMessage(`{"version":"1","root":{"id":"root","layoutDocId":"layout_root","children":[{"id":"home","index":true,"pageDocId":"page_home"}]}}`)
+	previous := workspaceState{RouteManifest: manifest, Documents: routeTestDocuments()}
+	next := workspaceState{RouteManifest: manifest, Documents: routeTestDocuments()}
+	layout := next.Documents["layout_root"]
+	layout.Content = defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRPage)
+	next.Documents["layout_root"] = layout
+	if !workspaceRoutesAffected(previous, next) {
+		t.Fatalf("removing a layout outlet must affect routes")
+	}
+	if got := routeDiagnostics(t, checkWorkspaceRouteManifest(previous, next)); !reflect.DeepEqual(got, []string{"RTE-3001 /root/layoutDocId"}) {
+		t.Fatalf("removing a routed outlet: %v", got)
+	}
+
+	next.Documents["layout_root"] = previous.Documents["layout_root"]
+	layout = next.Documents["layout_plain"]
+	layout.Content = json.RawMessage(`{"version":"1.3","ui":{"graph":{"version":1,"rootId":"root","nodesById":{"root":{"id":"root","type":"container"}},"childIdsById":{"root":[]}}}}`)
+	next.Documents["layout_plain"] = layout
+	if workspaceRoutesAffected(previous, next) {
+		t.Fatalf("editing a layout without touching outlets must not affect routes")
+	}
+}
+
+func TestValidateLayoutRoutesRejectsRemovedOutlet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	withOutlet := defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRLayout)
+	withoutOutlet := defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRPage)
+	manifestQuery := regexp.QuoteMeta(`SELECT manifest_json FROM workspace_routes WHERE workspace_id = $1`)
+	mock.ExpectQuery(manifestQuery).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"manifest_json"}).AddRow([]byte(`{"version":"1","root":{"id":"root","layoutDocId":"layout_root","children":[{"id":"home","index":true,"pageDocId":"page_home"}]}}`)))
+	mock.ExpectQuery(routeDocumentQuery).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doc_type", "content_json"}).
+			AddRow("layout_root", "mir-layout", []byte(withOutlet)).
+			AddRow("page_home", "mir-page", nil))
+	mock.ExpectQuery(manifestQuery).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"manifest_json"}).AddRow([]byte(`{"version":"1","root":{"id":"root","children":[{"id":"home","index":true,"pageDocId":"page_home"}]}}`)))
+
+	err = validateLayoutRoutes(context.Background(), db, "ws_1", "layout_root", withOutlet, withoutOutlet)
+	if got := routeDiagnostics(t, err); !reflect.DeepEqual(got, []string{"RTE-3001 /root/layoutDocId"}) {
+		t.Fatalf("removing a routed outlet: %v", got)
+	}
+	// Layouts the manifest does not reference are not re-validated.
+	if err := validateLayoutRoutes(context.Background(), db, "ws_1", "layout_root", withOutlet, withoutOutlet); err != nil {
+		t.Fatalf("unreferenced layout: %v", err)
+	}
+	if err := validateLayoutRoutes(context.Background(), db, "ws_1", "layout_root", withOutlet, withOutlet); err != nil {
+		t.Fatalf("unchanged outlets: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
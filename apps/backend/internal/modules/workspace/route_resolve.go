@@ -0,0 +1,412 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var ErrRoutePathInvalid = errors.New("route path must be an absolute URL path")
+
+const maxRouteCandidates = 3
+
+// RouteResolution is the outcome of matching a URL path against the route
+// manifest. Chain runs from the root to the matched route; when nothing
+// matches it is empty and Candidates lists the closest routes instead.
+type RouteResolution struct {
+	WorkspaceID string            `json:"workspaceId"`
+	RouteRev    int64             `json:"routeRev"`
+	Path        string            `json:"path"`
+	Matched     bool              `json:"matched"`
+	Chain       []ResolvedRoute   `json:"chain"`
+	Params      map[string]string `json:"params"`
+	Candidates  []RouteCandidate  `json:"candidates,omitempty"`
+}
+
+type ResolvedRoute struct {
+	ID           string                 `json:"id"`
+	Pattern      string                 `json:"pattern"`
+	Index        bool                   `json:"index,omitempty"`
+	OutletNodeID string                 `json:"outletNodeId,omitempty"`
+	Layout       *ResolvedRouteDocument `json:"layout,omitempty"`
+	Page         *ResolvedRouteDocument `json:"page,omitempty"`
+}
+
+// ResolvedRouteDocument pins a layout or page to the revisions the preview
+// should render; Missing marks references to documents that no longer exist.
+type ResolvedRouteDocument struct {
+	ID         string `json:"id"`
+	ContentRev int64  `json:"contentRev"`
+	MetaRev    int64  `json:"metaRev"`
+	Missing    bool   `json:"missing,omitempty"`
+}
+
+type RouteCandidate struct {
+	ID       string  `json:"id"`
+	Pattern  string  `json:"pattern"`
+	Distance float64 `json:"distance"`
+}
+
+type routeManifestNode struct {
+	ID           string              `json:"id"`
+	Segment      string              `json:"segment"`
+	Index        bool                `json:"index"`
+	LayoutDocID  string              `json:"layoutDocId"`
+	PageDocID    string              `json:"pageDocId"`
+	OutletNodeID string              `json:"outletNodeId"`
+	Children     []routeManifestNode `json:"children"`
+}
+
+// routeBranch is one route a URL can resolve to, with the routes above it.
+// patterns[i] is the full pattern of chain[i].
+type routeBranch struct {
+	chain    []routeManifestNode
+	patterns [][]routePatternPart
+}
+
+func (branch routeBranch) parts() []routePatternPart {
+	return branch.patterns[len(branch.patterns)-1]
+}
+
+func (branch routeBranch) leaf() routeManifestNode {
+	return branch.chain[len(branch.chain)-1]
+}
+
+// score ranks branches the way the route runtime does (decision 13 follows
+// React Router): literals beat params, a splat is penalised and index routes
+// win over their parent.
+func (branch routeBranch) score() int {
+	parts := branch.parts()
+	score := len(parts)
+	for _, part := range parts {
+		switch part.kind {
+		case routePatternStatic:
+			score += 10
+		case routePatternParam:
+			score += 3
+		case routePatternSplat:
+			score -= 2
+		}
+	}
+	if branch.leaf().Index {
+		score += 2
+	}
+	return score
+}
+
+func formatRoutePattern(parts []routePatternPart) string {
+	pieces := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch part.kind {
+		case routePatternParam:
+			pieces = append(pieces, ":"+part.value)
+		default:
+			pieces = append(pieces, part.value)
+		}
+	}
+	return "/" + strings.Join(pieces, "/")
+}
+
+// flattenRouteBranches lists every route that can end a match: the root,
+// index routes and routes with a segment. Pathless routes only group their
+// children, and routes with a segment the validator would reject are skipped
+// together with their subtree.
+func flattenRouteBranches(node routeManifestNode, chain []routeManifestNode, patterns [][]routePatternPart, branches *[]routeBranch) {
+	var parts []routePatternPart
+	if len(patterns) > 0 {
+		parts = patterns[len(patterns)-1]
+	}
+	if !node.Index && node.Segment != "" {
+		own, absolute, code, _ := parseRouteSegment(node.Segment)
+		if code != "" {
+			return
+		}
+		if absolute {
+			parts = own
+		} else {
+			parts = append(append([]routePatternPart{}, parts...), own...)
+		}
+	}
+	chain = append(chain[:len(chain):len(chain)], node)
+	patterns = append(patterns[:len(patterns):len(patterns)], parts)
+	if len(chain) == 1 || node.Index || node.Segment != "" {
+		*branches = append(*branches, routeBranch{chain: chain, patterns: patterns})
+	}
+	for _, child := range node.Children {
+		flattenRouteBranches(child, chain, patterns, branches)
+	}
+}
+
+// splitRoutePath drops the query and fragment, ignores empty and trailing
+// segments, and percent-decodes what is left.
+func splitRoutePath(path string) ([]string, error) {
+	if cut := strings.IndexAny(path, "?#"); cut >= 0 {
+		path = path[:cut]
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, ErrRoutePathInvalid
+	}
+	segments := make([]string, 0)
+	for _, raw := range strings.Split(path, "/") {
+		if raw == "" {
+			continue
+		}
+		segment, err := url.PathUnescape(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRoutePathInvalid, err)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// matchRouteParts matches literals case-insensitively, a param against one
+// segment and a splat against the rest of the path (stored as "*").
+func matchRouteParts(parts []routePatternPart, segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for index, part := range parts {
+		if part.kind == routePatternSplat {
+			params["*"] = strings.Join(segments[index:], "/")
+			return params, true
+		}
+		if index >= len(segments) {
+			return nil, false
+		}
+		switch part.kind {
+		case routePatternStatic:
+			if !strings.EqualFold(part.value, segments[index]) {
+				return nil, false
+			}
+		case routePatternParam:
+			params[part.value] = segments[index]
+		}
+	}
+	if len(segments) != len(parts) {
+		return nil, false
+	}
+	return params, true
+}
+
+// routeDistance is the segment edit distance between a pattern and a path.
+// Inserting or dropping a segment costs 1; a literal that differs from its
+// segment costs the share of characters that would have to change, so a
+// typo ranks closer than a missing segment. A segment the part accepts costs
+// nothing and a trailing splat absorbs any suffix.
+func routeDistance(parts []routePatternPart, segments []string) float64 {
+	splat := len(parts) > 0 && parts[len(parts)-1].kind == routePatternSplat
+	if splat {
+		parts = parts[:len(parts)-1]
+	}
+	previous := make([]float64, len(segments)+1)
+	current := make([]float64, len(segments)+1)
+	for column := range previous {
+		previous[column] = float64(column)
+	}
+	for row, part := range parts {
+		current[0] = float64(row + 1)
+		for column, segment := range segments {
+			substitution := 0.0
+			if part.kind == routePatternStatic {
+				substitution = routeLiteralDistance(part.value, segment)
+			}
+			current[column+1] = min(previous[column]+substitution, previous[column+1]+1, current[column]+1)
+		}
+		previous, current = current, previous
+	}
+	if !splat {
+		return previous[len(segments)]
+	}
+	return slices.Min(previous)
+}
+
+// routeLiteralDistance is the case-insensitive character edit distance of
+// two literals relative to the longer one.
+func routeLiteralDistance(literal string, segment string) float64 {
+	left, right := []rune(strings.ToLower(literal)), []rune(strings.ToLower(segment))
+	previous := make([]int, len(right)+1)
+	current := make([]int, len(right)+1)
+	for column := range previous {
+		previous[column] = column
+	}
+	for row, leftRune := range left {
+		current[0] = row + 1
+		for column, rightRune := range right {
+			substitution := 1
+			if leftRune == rightRune {
+				substitution = 0
+			}
+			current[column+1] = min(previous[column]+substitution, previous[column+1]+1, current[column]+1)
+		}
+		previous, current = current, previous
+	}
+	return float64(previous[len(right)]) / float64(max(len(left), len(right), 1))
+}
+
+// resolveRouteManifest matches path against a manifest. Document revisions
+// are left for the store to fill in.
+func resolveRouteManifest(manifest json.RawMessage, path string) (*RouteResolution, error) {
+	segments, err := splitRoutePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var document struct {
+		Root *routeManifestNode `json:"root"`
+	}
+	if err := json.Unmarshal(manifest, &document); err != nil || document.Root == nil {
+		return nil, documentValidationError(routeDomain, DiagnosticRouteManifestInvalid, "/root", "stored route manifest cannot be resolved")
+	}
+	var branches []routeBranch
+	flattenRouteBranches(*document.Root, nil, nil, &branches)
+
+	resolution := &RouteResolution{Path: path, Chain: []ResolvedRoute{}, Params: map[string]string{}}
+	var best *routeBranch
+	for index := range branches {
+		branch := &branches[index]
+		params, ok := matchRouteParts(branch.parts(), segments)
+		if !ok || (best != nil && branch.score() <= best.score()) {
+			continue
+		}
+		best = branch
+		resolution.Params = params
+	}
+	if best == nil {
+		resolution.Candidates = closestRouteCandidates(branches, segments)
+		return resolution, nil
+	}
+	resolution.Matched = true
+	for index, node := range best.chain {
+		route := ResolvedRoute{ID: node.ID, Pattern: formatRoutePattern(best.patterns[index]), Index: node.Index, OutletNodeID: node.OutletNodeID}
+		if node.LayoutDocID != "" {
+			route.Layout = &ResolvedRouteDocument{ID: node.LayoutDocID}
+		}
+		if node.PageDocID != "" {
+			route.Page = &ResolvedRouteDocument{ID: node.PageDocID}
+		}
+		resolution.Chain = append(resolution.Chain, route)
+	}
+	return resolution, nil
+}
+
+// closestRouteCandidates ranks every route by routeDistance, breaking ties
+// by match score. Routes sharing a pattern (a parent and its index route)
+// are listed once.
+func closestRouteCandidates(branches []routeBranch, segments []string) []RouteCandidate {
+	type ranked struct {
+		branch   routeBranch
+		distance float64
+		score    int
+	}
+	rankedBranches := make([]ranked, 0, len(branches))
+	for _, branch := range branches {
+		rankedBranches = append(rankedBranches, ranked{branch: branch, distance: routeDistance(branch.parts(), segments), score: branch.score()})
+	}
+	sort.SliceStable(rankedBranches, func(left, right int) bool {
+		if rankedBranches[left].distance != rankedBranches[right].distance {
+			return rankedBranches[left].distance < rankedBranches[right].distance
+		}
+		return rankedBranches[left].score > rankedBranches[right].score
+	})
+	candidates := make([]RouteCandidate, 0, maxRouteCandidates)
+	seen := make(map[string]bool)
+	for _, entry := range rankedBranches {
+		if len(candidates) == maxRouteCandidates {
+			break
+		}
+		pattern := formatRoutePattern(entry.branch.parts())
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		candidates = append(candidates, RouteCandidate{ID: entry.branch.leaf().ID, Pattern: pattern, Distance: math.Round(entry.distance*100) / 100})
+	}
+	return candidates
+}
+
+// ResolveRoute matches path against the stored route manifest and pins the
+// layouts and pages of the matched chain to their current revisions.
+func (store *WorkspaceStore) ResolveRoute(ctx context.Context, workspaceID string, path string) (*RouteResolution, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const routeQuery = `SELECT w.route_rev, r.manifest_json
+FROM workspaces w
+LEFT JOIN workspace_routes r ON r.workspace_id = w.id
+WHERE w.id = $1`
+
+	var routeRev int64
+	var manifestBytes []byte
+	if err := store.conn().QueryRowContext(ctx, routeQuery, workspaceID).Scan(&routeRev, &manifestBytes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	manifest := json.RawMessage(manifestBytes)
+	if len(manifest) == 0 {
+		manifest = defaultWorkspaceRouteManifest
+	}
+	resolution, err := resolveRouteManifest(manifest, path)
+	if err != nil {
+		return nil, err
+	}
+	resolution.WorkspaceID = workspaceID
+	resolution.RouteRev = routeRev
+
+	var references []*ResolvedRouteDocument
+	for index := range resolution.Chain {
+		for _, reference := range []*ResolvedRouteDocument{resolution.Chain[index].Layout, resolution.Chain[index].Page} {
+			if reference != nil {
+				references = append(references, reference)
+			}
+		}
+	}
+	if len(references) == 0 {
+		return resolution, nil
+	}
+	documentIDs := make([]string, 0, len(references))
+	for _, reference := range references {
+		documentIDs = append(documentIDs, reference.ID)
+	}
+	documentIDsJSON, err := json.Marshal(documentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	const revisionQuery = `SELECT id, content_rev, meta_rev
+FROM workspace_documents
+WHERE workspace_id = $1 AND id IN (SELECT jsonb_array_elements_text($2::jsonb))`
+
+	rows, err := store.conn().QueryContext(ctx, revisionQuery, workspaceID, string(documentIDsJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := make(map[string]WorkspaceDocumentRevision)
+	for rows.Next() {
+		var revision WorkspaceDocumentRevision
+		if err := rows.Scan(&revision.ID, &revision.ContentRev, &revision.MetaRev); err != nil {
+			return nil, err
+		}
+		revisions[revision.ID] = revision
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, reference := range references {
+		revision, exists := revisions[reference.ID]
+		reference.ContentRev = revision.ContentRev
+		reference.MetaRev = revision.MetaRev
+		reference.Missing = !exists
+	}
+	return resolution, nil
+}
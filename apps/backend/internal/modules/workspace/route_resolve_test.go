@@ -0,0 +1,178 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+const routeResolveTestManifest = `{"version":"1","root":{"id":"root","layoutDocId":"layout_root","children":[
+	{"id":"home","index":true,"pageDocId":"page_home"},
+	{"id":"users","segment":"users","layoutDocId":"layout_users","outletNodeId":"outlet","children":[
+		{"id":"users-index","index":true,"pageDocId":"page_users"},
+		{"id":"user-new","segment":"new","pageDocId":"page_user_new"},
+		{"id":"user","segment":":userId","pageDocId":"page_user"},
+		{"id":"user-files","segment":":userId/files/*","pageDocId":"page_files"}
+	]},
+	{"id":"group","children":[{"id":"about","segment":"about","pageDocId":"page_about"}]},
+	{"id":"docs","segment":"/docs/*","pageDocId":"page_docs"},
+	{"id":"broken","segment":"a//b","children":[{"id":"hidden","segment":"x"}]}
+]}}`
+
+func resolvedChainIDs(resolution *RouteResolution) []string {
+	ids := make([]string, 0, len(resolution.Chain))
+	for _, route := range resolution.Chain {
+		ids = append(ids, route.ID)
+	}
+	return ids
+}
+
+func TestResolveRouteManifest(t *testing.T) {
+	tests := []struct {
+		path       string
+		chain      []string
+		params     map[string]string
+		candidates []string
+	}{
+		{path: "/", chain: []string{"root", "home"}, params: map[string]string{}},
+		{path: "/users", chain: []string{"root", "users", "users-index"}, params: map[string]string{}},
+		{path: "/users/", chain: []string{"root", "users", "users-index"}, params: map[string]string{}},
+		{path: "/Users/42?tab=posts#top", chain: []string{"root", "users", "user"}, params: map[string]string{"userId": "42"}},
+		{path: "/users/new", chain: []string{"root", "users", "user-new"}, params: map[string]string{}},
+		{path: "/users/a%20b", chain: []string{"root", "users", "user"}, params: map[string]string{"userId": "a b"}},
+		{path: "/users/42/files", chain: []string{"root", "users", "user-files"}, params: map[string]string{"userId": "42", "*": ""}},
+		{path: "/users/42/files/a/b.png", chain: []string{"root", "users", "user-files"}, params: map[string]string{"userId": "42", "*": "a/b.png"}},
+		{path: "//about", chain: []string{"root", "group", "about"}, params: map[string]string{}},
+		{path: "/docs/guide/intro", chain: []string{"root", "docs"}, params: map[string]string{"*": "guide/intro"}},
+		{path: "/users/42/settings", params: map[string]string{}, candidates: []string{"/users/:userId/files/*", "/docs/*", "/users/:userId"}},
+		{path: "/abuot", params: map[string]string{}, candidates: []string{"/about", "/users/:userId", "/users"}},
+		{path: "/a/b/x", params: map[string]string{}, candidates: []string{"/docs/*", "/users/:userId/files/*", "/users/:userId"}},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			resolution, err := resolveRouteManifest(json.RawMessage(routeResolveTestManifest), test.path)
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if resolution.Matched != (test.chain != nil) {
+				t.Fatalf("matched = %v, want chain %v", resolution.Matched, test.chain)
+			}
+			if got := resolvedChainIDs(resolution); test.chain != nil && !reflect.DeepEqual(got, test.chain) {
+				t.Fatalf("chain = %v, want %v", got, test.chain)
+			}
+			if !reflect.DeepEqual(resolution.Params, test.params) {
+				t.Fatalf("params = %v, want %v", resolution.Params, test.params)
+			}
+			patterns := make([]string, 0, len(resolution.Candidates))
+			for _, candidate := range resolution.Candidates {
+				patterns = append(patterns, candidate.Pattern)
+			}
+			if test.candidates != nil && !reflect.DeepEqual(patterns, test.candidates) {
+				t.Fatalf("candidates = %v, want %v", patterns, test.candidates)
+			}
+		})
+	}
+}
+
+func TestRouteDistanceRanksTyposBelowMissingSegments(t *testing.T) {
+	about, _, _, _ := parseRouteSegment("/about")
+	user, _, _, _ := parseRouteSegment("/users/:userId")
+	segments := []string{"abuot"}
+	if got := routeDistance(about, segments); got != 0.4 {
+		t.Fatalf("typo distance = %v, want 0.4", got)
+	}
+	if got := routeDistance(user, segments); got != 1 {
+		t.Fatalf("missing segment distance = %v, want 1", got)
+	}
+}
+
+func TestResolveRouteManifestChainCarriesDocumentsAndPatterns(t *testing.T) {
+	resolution, err := resolveRouteManifest(json.RawMessage(routeResolveTestManifest), "/users/7")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	want := []ResolvedRoute{
+		{ID: "root", Pattern: "/", Layout: &ResolvedRouteDocument{ID: "layout_root"}},
+		{ID: "users", Pattern: "/users", OutletNodeID: "outlet", Layout: &ResolvedRouteDocument{ID: "layout_users"}},
+		{ID: "user", Pattern: "/users/:userId", Page: &ResolvedRouteDocument{ID: "page_user"}},
+	}
+	if !reflect.DeepEqual(resolution.Chain, want) {
+		encoded, _ := json.Marshal(resolution.Chain)
+		t.Fatalf("unexpected chain: %s", encoded)
+	}
+}
+
+func TestResolveRouteManifestRejectsRelativePaths(t *testing.T) {
+	for _, path := range []string{"", "users/42", "/users/%zz"} {
+		if _, err := resolveRouteManifest(defaultWorkspaceRouteManifest, path); !errors.Is(err, ErrRoutePathInvalid) {
+			t.Fatalf("path %q: expected ErrRoutePathInvalid, got %v", path, err)
+		}
+	}
+}
+
+func TestHandleResolveRouteReturnsDocumentRevisions(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	expectWorkspaceOwnerQuery(mock, "ws_1", "user_1")
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT w.route_rev, r.manifest_json
+FROM workspaces w
+LEFT JOIN workspace_routes r ON r.workspace_id = w.id
+WHERE w.id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"route_rev", "manifest_json"}).AddRow(6, []byte(routeResolveTestManifest)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, content_rev, meta_rev
+FROM workspace_documents
+WHERE workspace_id = $1 AND id IN (SELECT jsonb_array_elements_text($2::jsonb))`)).
+		WithArgs("ws_1", `["layout_root","layout_users","page_user"]`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "content_rev", "meta_rev"}).
+			AddRow("layout_root", 3, 1).
+			AddRow("page_user", 9, 2))
+
+	context, response := newWorkspaceHandlerContext(http.MethodGet, "/api/workspaces/ws_1/routes/resolve?path=/users/42", "", gin.Params{{Key: "workspaceId", Value: "ws_1"}})
+	handler.HandleResolveRoute(context)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", response.Code, response.Body.String())
+	}
+	var resolution RouteResolution
+	if err := json.Unmarshal(response.Body.Bytes(), &resolution); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resolution.Matched || resolution.RouteRev != 6 || resolution.Params["userId"] != "42" {
+		t.Fatalf("unexpected resolution: %+v", resolution)
+	}
+	if layout := resolution.Chain[0].Layout; layout.ContentRev != 3 || layout.MetaRev != 1 || layout.Missing {
+		t.Fatalf("unexpected root layout: %+v", layout)
+	}
+	if layout := resolution.Chain[1].Layout; !layout.Missing {
+		t.Fatalf("deleted layout should be reported missing: %+v", layout)
+	}
+	if page := resolution.Chain[2].Page; page.ContentRev != 9 || page.MetaRev != 2 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHandleResolveRouteRequiresAbsolutePath(t *testing.T) {
+	handler, mock, cleanup := newWorkspaceHandlerTestHandler(t)
+	defer cleanup()
+
+	context, response := newWorkspaceHandlerContext(http.MethodGet, "/api/workspaces/ws_1/routes/resolve?path=users", "", gin.Params{{Key: "workspaceId", Value: "ws_1"}})
+	handler.HandleResolveRoute(context)
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", response.Code, response.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -9,11 +9,25 @@ type RouteHandlers struct {
 	PatchWorkspaceDocument   gin.HandlerFunc
 	ApplyWorkspaceIntent     gin.HandlerFunc
 	ApplyWorkspaceBatch      gin.HandlerFunc
+	ListWorkspaceOperations  gin.HandlerFunc
+	StreamWorkspaceEvents    gin.HandlerFunc
+	GetWorkspaceDocument     gin.HandlerFunc
+	ListCheckpoints          gin.HandlerFunc
+	CreateCheckpoint         gin.HandlerFunc
+	DiffCheckpoint           gin.HandlerFunc
+	ResolveRoute             gin.HandlerFunc
 }
 
 func RegisterRoutes(api *gin.RouterGroup, handlers RouteHandlers) {
 	api.GET("/workspaces/:workspaceId", handlers.RequireAuth, handlers.GetWorkspace)
 	api.GET("/workspaces/:workspaceId/capabilities", handlers.RequireAuth, handlers.GetWorkspaceCapabilities)
+	api.GET("/workspaces/:workspaceId/operations", handlers.RequireAuth, handlers.ListWorkspaceOperations)
+	api.GET("/workspaces/:workspaceId/events", handlers.RequireAuth, handlers.StreamWorkspaceEvents)
+	api.GET("/workspaces/:workspaceId/checkpoints", handlers.RequireAuth, handlers.ListCheckpoints)
+	api.POST("/workspaces/:workspaceId/checkpoints", handlers.RequireAuth, handlers.CreateCheckpoint)
+	api.GET("/workspaces/:workspaceId/checkpoints/:checkpointId/diff", handlers.RequireAuth, handlers.DiffCheckpoint)
+	api.GET("/workspaces/:workspaceId/routes/resolve", handlers.RequireAuth, handlers.ResolveRoute)
+	api.GET("/workspaces/:workspaceId/documents/:documentId", handlers.RequireAuth, handlers.GetWorkspaceDocument)
 	api.PATCH("/workspaces/:workspaceId/documents/:documentId", handlers.RequireAuth, handlers.PatchWorkspaceDocument)
 	api.POST("/workspaces/:workspaceId/intents", handlers.RequireAuth, handlers.ApplyWorkspaceIntent)
 	api.POST("/workspaces/:workspaceId/batch", handlers.RequireAuth, handlers.ApplyWorkspaceBatch)
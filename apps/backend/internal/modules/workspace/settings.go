@@ -0,0 +1,537 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const settingsDomain = "workspace"
+
+const DiagnosticSettingsInvalid = "WKS-3005"
+
+const settingsSchemaVersion = "1"
+
+var (
+	settingsLocalePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	settingsThemeModes    = map[string]bool{"light": true, "dark": true, "system": true}
+	settingsFrameworks    = map[string]bool{"react": true, "vue": true, "html": true}
+)
+
+// validateWorkspaceSettings checks settings against the settings schema. A
+// document without "version" predates the schema and is read as version 1.
+// global and projectGlobalById hold the editor preferences synced by the
+// settings page and are only required to be objects.
+func validateWorkspaceSettings(settings json.RawMessage) error {
+	var document any
+	if err := decodeJSONValue(settings, &document); err != nil {
+		return err
+	}
+	validator := &settingsValidator{diagnostics: diagnosticReport{domain: settingsDomain}}
+	object, ok := document.(map[string]any)
+	if !ok {
+		validator.fail("", "settings must be an object")
+		return validator.diagnostics.err()
+	}
+	for _, key := range sortedKeys(object) {
+		value := object[key]
+		path := jsonPointerPath(key)
+		switch key {
+		case "version":
+			if value != settingsSchemaVersion {
+				validator.fail(path, `version must be "`+settingsSchemaVersion+`"`)
+			}
+		case "theme":
+			validator.checkTheme(value, path)
+		case "i18n":
+			validator.checkI18n(value, path)
+		case "externalLibraries":
+			validator.checkExternalLibraries(value, path)
+		case "codegen":
+			validator.checkCodegen(value, path)
+		case "capabilities":
+			if flags, ok := validator.object(value, path); ok {
+				for _, name := range sortedKeys(flags) {
+					if _, ok := flags[name].(bool); !ok {
+						validator.fail(jsonPointerPath(key, name), "capability flags must be booleans")
+					}
+				}
+			}
+		case "global":
+			validator.object(value, path)
+		case "projectGlobalById":
+			if projects, ok := validator.object(value, path); ok {
+				for _, projectID := range sortedKeys(projects) {
+					validator.object(projects[projectID], jsonPointerPath(key, projectID))
+				}
+			}
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				validator.fail(path, fmt.Sprintf("unknown settings key %q", key))
+			}
+		}
+	}
+	return validator.diagnostics.err()
+}
+
+type settingsValidator struct {
+	diagnostics diagnosticReport
+}
+
+func (validator *settingsValidator) fail(path, message string) {
+	validator.diagnostics.add(DiagnosticSettingsInvalid, path, message)
+}
+
+func (validator *settingsValidator) object(value any, path string) (map[string]any, bool) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		validator.fail(path, "must be an object")
+	}
+	return object, ok
+}
+
+// text reads an optional string field; ok is false when the field is absent
+// or has been reported.
+func (validator *settingsValidator) text(object map[string]any, key, path string) (string, bool) {
+	value, present := object[key]
+	if !present {
+		return "", false
+	}
+	text, ok := value.(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		validator.fail(path+"/"+escapeJSONPointerSegment(key), key+" must be a non-empty string")
+		return "", false
+	}
+	return text, true
+}
+
+func (validator *settingsValidator) requiredText(object map[string]any, key, path string) (string, bool) {
+	if _, present := object[key]; !present {
+		validator.fail(path+"/"+escapeJSONPointerSegment(key), key+" is required")
+		return "", false
+	}
+	return validator.text(object, key, path)
+}
+
+func (validator *settingsValidator) checkTheme(value any, path string) {
+	theme, ok := validator.object(value, path)
+	if !ok {
+		return
+	}
+	validator.text(theme, "id", path)
+	if mode, ok := validator.text(theme, "mode", path); ok && !settingsThemeModes[mode] {
+		validator.fail(path+"/mode", `mode must be "light", "dark" or "system"`)
+	}
+	if raw, present := theme["tokens"]; present {
+		if tokens, ok := validator.object(raw, path+"/tokens"); ok {
+			for _, name := range sortedKeys(tokens) {
+				if _, ok := tokens[name].(string); !ok {
+					validator.fail(path+"/tokens/"+escapeJSONPointerSegment(name), "theme tokens must be strings")
+				}
+			}
+		}
+	}
+}
+
+func (validator *settingsValidator) checkI18n(value any, path string) {
+	i18n, ok := validator.object(value, path)
+	if !ok {
+		return
+	}
+	defaultLocale, hasDefault := validator.requiredText(i18n, "defaultLocale", path)
+	if hasDefault && !settingsLocalePattern.MatchString(defaultLocale) {
+		validator.fail(path+"/defaultLocale", fmt.Sprintf("%q is not a locale tag", defaultLocale))
+		hasDefault = false
+	}
+	var locales map[string]bool
+	if raw, present := i18n["locales"]; present {
+		items, ok := raw.([]any)
+		if !ok {
+			validator.fail(path+"/locales", "locales must be an array")
+		} else {
+			locales = make(map[string]bool, len(items))
+			for index, item := range items {
+				itemPath := fmt.Sprintf("%s/locales/%d", path, index)
+				locale, ok := item.(string)
+				switch {
+				case !ok || !settingsLocalePattern.MatchString(locale):
+					validator.fail(itemPath, "locales must be locale tags")
+				case locales[locale]:
+					validator.fail(itemPath, fmt.Sprintf("locale %q is listed twice", locale))
+				default:
+					locales[locale] = true
+				}
+			}
+			if hasDefault && !locales[defaultLocale] {
+				validator.fail(path+"/defaultLocale", fmt.Sprintf("defaultLocale %q is not in locales", defaultLocale))
+			}
+		}
+	}
+	if fallback, ok := validator.text(i18n, "fallbackLocale", path); ok {
+		if locales != nil && !locales[fallback] {
+			validator.fail(path+"/fallbackLocale", fmt.Sprintf("fallbackLocale %q is not in locales", fallback))
+		} else if locales == nil && !settingsLocalePattern.MatchString(fallback) {
+			validator.fail(path+"/fallbackLocale", fmt.Sprintf("%q is not a locale tag", fallback))
+		}
+	}
+}
+
+func (validator *settingsValidator) checkExternalLibraries(value any, path string) {
+	libraries, ok := value.([]any)
+	if !ok {
+		validator.fail(path, "externalLibraries must be an array")
+		return
+	}
+	seen := make(map[string]bool, len(libraries))
+	for index, item := range libraries {
+		itemPath := fmt.Sprintf("%s/%d", path, index)
+		library, ok := validator.object(item, itemPath)
+		if !ok {
+			continue
+		}
+		if id, ok := validator.requiredText(library, "id", itemPath); ok {
+			if seen[id] {
+				validator.fail(itemPath+"/id", fmt.Sprintf("library id %q is used twice", id))
+			}
+			seen[id] = true
+		}
+		validator.requiredText(library, "package", itemPath)
+		validator.requiredText(library, "version", itemPath)
+		if raw, ok := validator.text(library, "url", itemPath); ok {
+			parsed, err := url.Parse(raw)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				validator.fail(itemPath+"/url", "url must be an absolute http(s) URL")
+			}
+		}
+	}
+}
+
+func (validator *settingsValidator) checkCodegen(value any, path string) {
+	codegen, ok := validator.object(value, path)
+	if !ok {
+		return
+	}
+	targets := make(map[string]bool)
+	if raw, present := codegen["targets"]; present {
+		items, ok := raw.([]any)
+		if !ok {
+			validator.fail(path+"/targets", "targets must be an array")
+		}
+		for index, item := range items {
+			itemPath := fmt.Sprintf("%s/targets/%d", path, index)
+			target, ok := validator.object(item, itemPath)
+			if !ok {
+				continue
+			}
+			if id, ok := validator.requiredText(target, "id", itemPath); ok {
+				if targets[id] {
+					validator.fail(itemPath+"/id", fmt.Sprintf("target id %q is used twice", id))
+				}
+				targets[id] = true
+			}
+			if framework, ok := validator.requiredText(target, "framework", itemPath); ok && !settingsFrameworks[framework] {
+				validator.fail(itemPath+"/framework", `framework must be "react", "vue" or "html"`)
+			}
+			if outDir, ok := validator.text(target, "outDir", itemPath); ok && !isRelativeSettingsPath(outDir) {
+				validator.fail(itemPath+"/outDir", "outDir must be a relative path inside the project")
+			}
+		}
+	}
+	if defaultTarget, ok := validator.text(codegen, "defaultTarget", path); ok && !targets[defaultTarget] {
+		validator.fail(path+"/defaultTarget", fmt.Sprintf("defaultTarget %q is not a codegen target", defaultTarget))
+	}
+}
+
+func isRelativeSettingsPath(path string) bool {
+	if strings.HasPrefix(path, "/") || strings.Contains(path, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWorkspaceSettingsPatchPath lets settings patches reach any key;
+// the patched document is checked against the schema as a whole.
+func validateWorkspaceSettingsPatchPath(path string) error {
+	pointer, err := parseJSONPointer(path)
+	if err != nil {
+		return err
+	}
+	if len(pointer) == 0 {
+		return ErrWorkspacePatchPathForbidden
+	}
+	return nil
+}
+
+// upsertWorkspaceSettingsQuery stores settings and bumps the settings
+// partition revision. A workspace without a settings row is at revision 1.
+const upsertWorkspaceSettingsQuery = `INSERT INTO workspace_settings (workspace_id, settings_json, settings_rev, updated_at)
+VALUES ($1, $2::jsonb, 2, NOW())
+ON CONFLICT (workspace_id) DO UPDATE
+SET settings_json = EXCLUDED.settings_json, settings_rev = workspace_settings.settings_rev + 1, updated_at = EXCLUDED.updated_at
+RETURNING settings_rev`
+
+type workspaceSettingsLock struct {
+	workspaceRev int64
+	routeRev     int64
+	opSeq        int64
+	settingsRev  int64
+	settings     json.RawMessage
+}
+
+func lockWorkspaceSettings(ctx context.Context, conn workspaceQuerier, workspaceID string) (*workspaceSettingsLock, error) {
+	const query = `SELECT w.workspace_rev, w.route_rev, w.op_seq, s.settings_json, COALESCE(s.settings_rev, 1)
+FROM workspaces w
+LEFT JOIN workspace_settings s ON s.workspace_id = w.id
+WHERE w.id = $1
+FOR UPDATE OF w`
+
+	locked := &workspaceSettingsLock{}
+	var settingsBytes []byte
+	if err := conn.QueryRowContext(ctx, query, workspaceID).Scan(
+		&locked.workspaceRev,
+		&locked.routeRev,
+		&locked.opSeq,
+		&settingsBytes,
+		&locked.settingsRev,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	locked.settings = json.RawMessage(settingsBytes)
+	if len(settingsBytes) == 0 {
+		locked.settings = defaultWorkspaceSettings
+	}
+	return locked, nil
+}
+
+func (locked *workspaceSettingsLock) conflict(workspaceID string, conflicts []WorkspaceHistoryConflict) *WorkspaceRevisionConflictError {
+	return &WorkspaceRevisionConflictError{
+		ConflictType:       WorkspaceConflictSettings,
+		WorkspaceID:        workspaceID,
+		ServerWorkspaceRev: locked.workspaceRev,
+		ServerRouteRev:     locked.routeRev,
+		ServerSettingsRev:  locked.settingsRev,
+		ServerOpSeq:        locked.opSeq,
+		Conflicts:          conflicts,
+	}
+}
+
+type PatchWorkspaceSettingsParams struct {
+	WorkspaceID         string
+	ExpectedSettingsRev int64
+	Command             WorkspaceCommandEnvelope
+}
+
+// PatchWorkspaceSettings applies command.ForwardOps to the workspace
+// settings. Only the settings partition is guarded: a stale patch is rebased
+// onto the current settings when the settings changes committed since
+// ExpectedSettingsRev touched other keys.
+func (store *WorkspaceStore) PatchWorkspaceSettings(ctx context.Context, params PatchWorkspaceSettingsParams) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	if strings.TrimSpace(params.WorkspaceID) == "" {
+		return nil, errors.New("workspaceID is required")
+	}
+	if params.ExpectedSettingsRev <= 0 {
+		return nil, errors.New("expectedSettingsRev must be positive")
+	}
+	command, err := normalizeWorkspaceCommand(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWorkspaceCommand(command, params.WorkspaceID, nil); err != nil {
+		return nil, err
+	}
+	if command.Target.DocumentID != "" {
+		return nil, errors.New("settings command must not set target.documentId")
+	}
+	if len(command.ForwardOps) == 0 || len(command.ReverseOps) == 0 {
+		return nil, errors.New("command.forwardOps and command.reverseOps are required")
+	}
+	payloadJSON, err := json.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	var result *WorkspaceMutationResult
+	err = store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		conn := txStore.conn()
+		locked, err := lockWorkspaceSettings(ctx, conn, params.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		var rebasedFromSettingsRev int64
+		if locked.settingsRev != params.ExpectedSettingsRev {
+			conflicts, rebased, err := txStore.findSettingsRebaseConflicts(ctx, params.WorkspaceID, locked.settingsRev-params.ExpectedSettingsRev, command.ForwardOps)
+			if err != nil {
+				return err
+			}
+			if !rebased || len(conflicts) > 0 {
+				log.Printf(
+					"[workspace] conflict patch_workspace_settings workspace=%s expectedSettingsRev=%d serverSettingsRev=%d serverOpSeq=%d conflicts=%d",
+					params.WorkspaceID,
+					params.ExpectedSettingsRev,
+					locked.settingsRev,
+					locked.opSeq,
+					len(conflicts),
+				)
+				return locked.conflict(params.WorkspaceID, conflicts)
+			}
+			rebasedFromSettingsRev = params.ExpectedSettingsRev
+		}
+
+		patch, err := patchWorkspaceJSON(locked.settings, command.ForwardOps, validateWorkspaceSettingsPatchPath)
+		if err != nil {
+			return err
+		}
+		if err := validateWorkspaceSettings(patch.Content); err != nil {
+			return err
+		}
+		restored, err := patch.restoredBy(command.ReverseOps)
+		if err != nil {
+			return err
+		}
+		if !restored {
+			return errors.New("command.reverseOps do not restore original settings")
+		}
+
+		result = &WorkspaceMutationResult{WorkspaceID: params.WorkspaceID, RebasedFromSettingsRev: rebasedFromSettingsRev}
+		if err := conn.QueryRowContext(ctx, upsertWorkspaceSettingsQuery, params.WorkspaceID, string(patch.Content)).Scan(&result.SettingsRev); err != nil {
+			return err
+		}
+		const bumpSequenceOnly = `UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`
+		if err := conn.QueryRowContext(ctx, bumpSequenceOnly, params.WorkspaceID).Scan(&result.WorkspaceRev, &result.RouteRev, &result.OpSeq); err != nil {
+			return err
+		}
+		return insertWorkspaceOperation(ctx, conn, params.WorkspaceID, result.OpSeq, commandDomain(command), nil, payloadJSON, command.IssuedAt, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findSettingsRebaseConflicts is findRebaseConflicts for the settings
+// partition. Settings change through core.settings commands and through
+// workspace-level commands whose state ops reach /settings, so it walks the
+// workspace log on state paths and stops at the first command it cannot
+// place. Conflict paths are relative to the settings document.
+func (store *WorkspaceStore) findSettingsRebaseConflicts(
+	ctx context.Context,
+	workspaceID string,
+	distance int64,
+	ops []WorkspacePatchOp,
+) (conflicts []WorkspaceHistoryConflict, ok bool, err error) {
+	if distance <= 0 || distance > maxAutoRebaseDistance {
+		return nil, false, nil
+	}
+	entries, err := store.listWorkspaceHistoryEntries(ctx, workspaceID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	later := make([]WorkspaceHistoryConflict, 0, distance)
+	for index := len(entries) - 1; index >= 0 && int64(len(later)) < distance; index-- {
+		entry := entries[index]
+		paths := make([]string, 0)
+		for _, path := range historyAffectedPaths(entry.command.ForwardOps) {
+			switch {
+			case path == "":
+				return nil, false, nil
+			case path == "/settings":
+				paths = append(paths, "")
+			case strings.HasPrefix(path, "/settings/"):
+				paths = append(paths, strings.TrimPrefix(path, "/settings"))
+			}
+		}
+		if len(paths) > 0 {
+			later = append(later, WorkspaceHistoryConflict{OpSeq: entry.opSeq, Domain: entry.domain, Paths: paths})
+		}
+	}
+	if int64(len(later)) < distance {
+		return nil, false, nil
+	}
+
+	patchPaths := rebasePatchPaths(ops)
+	conflicts = make([]WorkspaceHistoryConflict, 0)
+	for index := len(later) - 1; index >= 0; index-- {
+		entry := later[index]
+		overlapping := make([]string, 0)
+		for _, path := range entry.Paths {
+			for _, patchPath := range patchPaths {
+				if jsonPointersOverlap(path, patchPath) {
+					overlapping = append(overlapping, path)
+					break
+				}
+			}
+		}
+		if len(overlapping) > 0 {
+			entry.Paths = overlapping
+			conflicts = append(conflicts, entry)
+		}
+	}
+	return conflicts, true, nil
+}
+
+type workspaceSettingsPatchHandler struct{}
+
+func (workspaceSettingsPatchHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1("core.settings", "global.patch", RevPartitionSettings)}
+}
+
+func (workspaceSettingsPatchHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		ForwardOps []WorkspacePatchOp `json:"forwardOps"`
+		ReverseOps []WorkspacePatchOp `json:"reverseOps"`
+	}
+	if len(request.Intent.Payload) == 0 ||
+		json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		len(payload.ForwardOps) == 0 ||
+		len(payload.ReverseOps) == 0 {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.forwardOps and payload.reverseOps are required.",
+			nil,
+		)
+	}
+	command.ForwardOps = payload.ForwardOps
+	command.ReverseOps = payload.ReverseOps
+	result, err := store.PatchWorkspaceSettings(ctx, PatchWorkspaceSettingsParams{
+		WorkspaceID:         workspaceID,
+		ExpectedSettingsRev: request.ExpectedSettingsRev,
+		Command:             command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,261 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+const settingsHistoryQuery = `SELECT op_seq, domain, document_id, payload_json
+FROM workspace_operations
+WHERE workspace_id = $1
+ORDER BY op_seq DESC
+LIMIT $2`
+
+func expectSettingsUpsert(mock sqlmock.Sqlmock, workspaceID string, settings any, settingsRev int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(upsertWorkspaceSettingsQuery)).
+		WithArgs(workspaceID, settings).
+		WillReturnRows(sqlmock.NewRows([]string{"settings_rev"}).AddRow(settingsRev))
+}
+
+func settingsPatchOperationJSON(t *testing.T, path string) []byte {
+	t.Helper()
+	command := WorkspaceCommandEnvelope{
+		ID:         "cmd_settings_later",
+		Namespace:  "core.settings",
+		Type:       "global.patch",
+		Version:    "1.0",
+		IssuedAt:   time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC),
+		ForwardOps: []WorkspacePatchOp{{Op: "replace", Path: path, Value: json.RawMessage(`"later"`)}},
+		ReverseOps: []WorkspacePatchOp{{Op: "replace", Path: path, Value: json.RawMessage(`"earlier"`)}},
+		Target:     WorkspaceCommandTarget{WorkspaceID: "ws_1"},
+	}
+	payload, err := json.Marshal(command)
+	if err != nil {
+		t.Fatalf("marshal operation: %v", err)
+	}
+	return payload
+}
+
+func settingsPatchCommand(issuedAt time.Time) WorkspaceCommandEnvelope {
+	return WorkspaceCommandEnvelope{
+		ID:         "cmd_settings_patch",
+		Namespace:  "core.settings",
+		Type:       "global.patch",
+		Version:    "1.0",
+		IssuedAt:   issuedAt,
+		ForwardOps: []WorkspacePatchOp{{Op: "replace", Path: "/theme/mode", Value: json.RawMessage(`"dark"`)}},
+		ReverseOps: []WorkspacePatchOp{{Op: "replace", Path: "/theme/mode", Value: json.RawMessage(`"light"`)}},
+		Target:     WorkspaceCommandTarget{WorkspaceID: "ws_1"},
+	}
+}
+
+const settingsPatchBase = `{"version":"1","theme":{"mode":"light"},"i18n":{"defaultLocale":"en","locales":["en","zh-CN"]}}`
+
+func TestValidateWorkspaceSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings string
+		invalid  bool
+		path     string
+	}{
+		{name: "legacy", settings: `{"global":{"theme":"dark"},"projectGlobalById":{}}`},
+		{name: "empty", settings: `{}`},
+		{name: "full", settings: `{
+			"version":"1",
+			"theme":{"id":"brand","mode":"system","tokens":{"primary":"#336699"}},
+			"i18n":{"defaultLocale":"en","locales":["en","zh-CN"],"fallbackLocale":"en"},
+			"externalLibraries":[{"id":"antd","package":"antd","version":"^5.0.0","url":"https://cdn.example.com/antd.js"}],
+			"codegen":{"targets":[{"id":"web","framework":"react","outDir":"src/generated"}],"defaultTarget":"web"},
+			"capabilities":{"animation":true},
+			"x-editor":{"grid":8}
+		}`},
+		{name: "not an object", settings: `[]`, invalid: true, path: ""},
+		{name: "unknown version", settings: `{"version":"2"}`, invalid: true, path: "/version"},
+		{name: "unknown key", settings: `{"colors":{}}`, invalid: true, path: "/colors"},
+		{name: "bad theme mode", settings: `{"theme":{"mode":"sepia"}}`, invalid: true, path: "/theme/mode"},
+		{name: "missing default locale", settings: `{"i18n":{"locales":["en"]}}`, invalid: true, path: "/i18n/defaultLocale"},
+		{name: "default locale not listed", settings: `{"i18n":{"defaultLocale":"fr","locales":["en"]}}`, invalid: true, path: "/i18n/defaultLocale"},
+		{name: "duplicate library", settings: `{"externalLibraries":[{"id":"a","package":"a","version":"1"},{"id":"a","package":"b","version":"1"}]}`, invalid: true, path: "/externalLibraries/1/id"},
+		{name: "library url scheme", settings: `{"externalLibraries":[{"id":"a","package":"a","version":"1","url":"ftp://example.com/a.js"}]}`, invalid: true, path: "/externalLibraries/0/url"},
+		{name: "codegen escapes outDir", settings: `{"codegen":{"targets":[{"id":"web","framework":"react","outDir":"../out"}]}}`, invalid: true, path: "/codegen/targets/0/outDir"},
+		{name: "unknown default target", settings: `{"codegen":{"targets":[{"id":"web","framework":"vue","outDir":"out"}],"defaultTarget":"app"}}`, invalid: true, path: "/codegen/defaultTarget"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateWorkspaceSettings(json.RawMessage(tc.settings))
+			if !tc.invalid {
+				if err != nil {
+					t.Fatalf("expected valid settings, got %v", err)
+				}
+				return
+			}
+			var validationErr *DocumentValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected DocumentValidationError, got %v", err)
+			}
+			diagnostic := validationErr.Diagnostics[0]
+			if diagnostic.Code != DiagnosticSettingsInvalid || diagnostic.Path != tc.path {
+				t.Fatalf("unexpected diagnostic: %+v", diagnostic)
+			}
+		})
+	}
+}
+
+func TestWorkspaceStorePatchWorkspaceSettingsBumpsSettingsRevOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectSettingsLock(mock, testHead{workspaceRev: 9, routeRev: 4, opSeq: 34}, settingsPatchBase, 3)
+	expectSettingsUpsert(mock, "ws_1", `{"i18n":{"defaultLocale":"en","locales":["en","zh-CN"]},"theme":{"mode":"dark"},"version":"1"}`, 4)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 35))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)).
+		WithArgs("ws_1", int64(35), "core.settings.global.patch@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := store.PatchWorkspaceSettings(context.Background(), PatchWorkspaceSettingsParams{
+		WorkspaceID:         "ws_1",
+		ExpectedSettingsRev: 3,
+		Command:             settingsPatchCommand(issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("patch workspace settings: %v", err)
+	}
+	if result.WorkspaceRev != 9 || result.OpSeq != 35 || result.SettingsRev != 4 || result.RebasedFromSettingsRev != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStorePatchWorkspaceSettingsRebasesDisjointKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectSettingsLock(mock, testHead{workspaceRev: 10, routeRev: 4, opSeq: 35}, settingsPatchBase, 4)
+	mock.ExpectQuery(regexp.QuoteMeta(settingsHistoryQuery)).
+		WithArgs("ws_1", historyScanWindow).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json"}).
+			AddRow(int64(35), "core.settings.global.patch@1.0", nil, settingsPatchOperationJSON(t, "/i18n/defaultLocale")).
+			AddRow(int64(34), "core.code.source.update@1.0", "code_1", rebaseOperationJSON(t, "cmd_code", "/source")))
+	expectSettingsUpsert(mock, "ws_1", sqlmock.AnyArg(), 5)
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE workspaces
+SET op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(10, 4, 36))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)).
+		WithArgs("ws_1", int64(36), "core.settings.global.patch@1.0", nil, sqlmock.AnyArg(), issuedAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := store.PatchWorkspaceSettings(context.Background(), PatchWorkspaceSettingsParams{
+		WorkspaceID:         "ws_1",
+		ExpectedSettingsRev: 3,
+		Command:             settingsPatchCommand(issuedAt),
+	})
+	if err != nil {
+		t.Fatalf("patch workspace settings: %v", err)
+	}
+	if result.SettingsRev != 5 || result.RebasedFromSettingsRev != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStorePatchWorkspaceSettingsReturnsSettingsConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+
+	mock.ExpectBegin()
+	expectSettingsLock(mock, testHead{workspaceRev: 10, routeRev: 4, opSeq: 35}, settingsPatchBase, 4)
+	mock.ExpectQuery(regexp.QuoteMeta(settingsHistoryQuery)).
+		WithArgs("ws_1", historyScanWindow).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json"}).
+			AddRow(int64(35), "core.settings.global.patch@1.0", nil, settingsPatchOperationJSON(t, "/theme")))
+	mock.ExpectRollback()
+
+	_, err = store.PatchWorkspaceSettings(context.Background(), PatchWorkspaceSettingsParams{
+		WorkspaceID:         "ws_1",
+		ExpectedSettingsRev: 3,
+		Command:             settingsPatchCommand(time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC)),
+	})
+	var conflictErr *WorkspaceRevisionConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected WorkspaceRevisionConflictError, got %v", err)
+	}
+	if conflictErr.ConflictType != WorkspaceConflictSettings || conflictErr.ServerSettingsRev != 4 {
+		t.Fatalf("unexpected conflict: %+v", conflictErr)
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].OpSeq != 35 || conflictErr.Conflicts[0].Paths[0] != "/theme" {
+		t.Fatalf("unexpected conflicts: %+v", conflictErr.Conflicts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStorePatchWorkspaceSettingsRejectsInvalidSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	command := settingsPatchCommand(time.Date(2026, time.February, 8, 11, 0, 0, 0, time.UTC))
+	command.ForwardOps = []WorkspacePatchOp{{Op: "replace", Path: "/theme/mode", Value: json.RawMessage(`"sepia"`)}}
+
+	mock.ExpectBegin()
+	expectSettingsLock(mock, testHead{workspaceRev: 9, routeRev: 4, opSeq: 34}, settingsPatchBase, 3)
+	mock.ExpectRollback()
+
+	_, err = store.PatchWorkspaceSettings(context.Background(), PatchWorkspaceSettingsParams{
+		WorkspaceID:         "ws_1",
+		ExpectedSettingsRev: 3,
+		Command:             command,
+	})
+	failure := MapStoreError(err)
+	if failure == nil || failure.Status != 422 {
+		t.Fatalf("expected 422 failure, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
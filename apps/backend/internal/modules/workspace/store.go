@@ -25,6 +25,7 @@ const (
 	WorkspaceConflictDocument  WorkspaceConflictType = "DOCUMENT_CONFLICT"
 	WorkspaceConflictWorkspace WorkspaceConflictType = "WORKSPACE_CONFLICT"
 	WorkspaceConflictRoute     WorkspaceConflictType = "ROUTE_CONFLICT"
+	WorkspaceConflictSettings  WorkspaceConflictType = "SETTINGS_CONFLICT"
 )
 
 type WorkspaceRevisionConflictError struct {
@@ -35,7 +36,11 @@ type WorkspaceRevisionConflictError struct {
 	ServerRouteRev     int64
 	ServerContentRev   int64
 	ServerMetaRev      int64
+	ServerSettingsRev  int64
 	ServerOpSeq        int64
+	// Conflicts lists the later operations that overlap a rejected
+	// auto-rebase patch.
+	Conflicts []WorkspaceHistoryConflict
 }
 
 func (err *WorkspaceRevisionConflictError) Error() string {
@@ -58,6 +63,7 @@ const (
 
 type WorkspaceStore struct {
 	db *sql.DB
+	tx *sql.Tx
 }
 
 func NewWorkspaceStore(db *sql.DB) *WorkspaceStore {
@@ -69,6 +75,31 @@ var defaultWorkspaceRouteManifest = json.RawMessage(`{"version":"1","root":{"id"
 var defaultWorkspaceSettings = json.RawMessage(`{}`)
 var defaultMIRDocument = mircontract.DefaultDocument()
 var defaultCodeDocument = json.RawMessage(`{"language":"ts","source":""}`)
+var defaultMIRLayoutDocument = json.RawMessage(fmt.Sprintf(
+	`{"version":"%s","ui":{"graph":{"version":%d,"rootId":"root","nodesById":{"root":{"id":"root","type":"container"},"outlet":{"id":"outlet","type":"MdrOutlet"}},"childIdsById":{"root":["outlet"],"outlet":[]}}}}`,
+	mircontract.CurrentVersion,
+	mircontract.UIGraphVersion,
+))
+var defaultMIRGraphDocument = json.RawMessage(`{"version":1,"nodesById":{},"edgesById":{}}`)
+var defaultMIRAnimationDocument = json.RawMessage(`{"version":1,"timelines":[]}`)
+
+// defaultWorkspaceDocumentContent is the content a new document of
+// documentType starts with. Layouts carry an outlet so nested routes can
+// render into them.
+func defaultWorkspaceDocumentContent(documentType WorkspaceDocumentType) json.RawMessage {
+	switch documentType {
+	case WorkspaceDocumentTypeCode:
+		return defaultCodeDocument
+	case WorkspaceDocumentTypeMIRLayout:
+		return defaultMIRLayoutDocument
+	case WorkspaceDocumentTypeMIRGraph:
+		return defaultMIRGraphDocument
+	case WorkspaceDocumentTypeMIRAnimation:
+		return defaultMIRAnimationDocument
+	default:
+		return defaultMIRDocument
+	}
+}
 
 type WorkspaceRecord struct {
 	ID           string          `json:"id"`
@@ -100,6 +131,7 @@ type WorkspaceSnapshot struct {
 	Workspace     WorkspaceRecord           `json:"workspace"`
 	RouteManifest json.RawMessage           `json:"routeManifest"`
 	Settings      json.RawMessage           `json:"settings"`
+	SettingsRev   int64                     `json:"settingsRev"`
 	Documents     []WorkspaceDocumentRecord `json:"documents"`
 }
 
@@ -115,6 +147,13 @@ type WorkspaceMutationResult struct {
 	RouteRev         int64                       `json:"routeRev"`
 	OpSeq            int64                       `json:"opSeq"`
 	UpdatedDocuments []WorkspaceDocumentRevision `json:"updatedDocuments,omitempty"`
+	RemovedDocuments []string                    `json:"removedDocuments,omitempty"`
+	// RebasedFromContentRev is the stale base revision an auto-rebased patch
+	// was submitted against.
+	RebasedFromContentRev int64 `json:"rebasedFromContentRev,omitempty"`
+	// SettingsRev is set by mutations that changed the workspace settings.
+	SettingsRev            int64 `json:"settingsRev,omitempty"`
+	RebasedFromSettingsRev int64 `json:"rebasedFromSettingsRev,omitempty"`
 }
 
 type CreateWorkspaceParams struct {
@@ -170,6 +209,8 @@ type WorkspaceCommandEnvelope struct {
 	MergeKey   string                 `json:"mergeKey,omitempty"`
 	Label      string                 `json:"label,omitempty"`
 	DomainHint string                 `json:"domainHint,omitempty"`
+	// History is set by the server on core.history undo/redo commands.
+	History *WorkspaceCommandHistory `json:"history,omitempty"`
 }
 
 type SaveDocumentContentParams struct {
@@ -184,7 +225,10 @@ type PatchDocumentContentParams struct {
 	WorkspaceID        string
 	DocumentID         string
 	ExpectedContentRev int64
-	Command            WorkspaceCommandEnvelope
+	// AutoRebase applies a stale patch on top of the current content when no
+	// command committed since ExpectedContentRev touched the same paths.
+	AutoRebase bool
+	Command    WorkspaceCommandEnvelope
 }
 
 type SaveRouteManifestParams struct {
@@ -198,8 +242,11 @@ type SaveRouteManifestParams struct {
 type SaveWorkspaceSettingsParams struct {
 	WorkspaceID          string
 	ExpectedWorkspaceRev int64
-	Settings             json.RawMessage
-	Command              WorkspaceCommandEnvelope
+	// ExpectedSettingsRev must match the settings partition too, so a full
+	// update never overwrites a settings patch it has not seen.
+	ExpectedSettingsRev int64
+	Settings            json.RawMessage
+	Command             WorkspaceCommandEnvelope
 }
 
 func (store *WorkspaceStore) CreateWorkspace(ctx context.Context, params CreateWorkspaceParams) (*WorkspaceRecord, error) {
@@ -228,7 +275,7 @@ func (store *WorkspaceStore) CreateWorkspace(ctx context.Context, params CreateW
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	tx, err := store.db.BeginTx(ctx, nil)
+	tx, err := store.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -291,7 +338,7 @@ func (store *WorkspaceStore) CreateDocument(ctx context.Context, params CreateWo
 
 	contentJSON, err := normalizeWorkspaceDocumentContent(params.Type, params.Content)
 	if err != nil {
-		return nil, err
+		return nil, withDocumentTarget(err, params.DocumentID)
 	}
 
 	ctx, cancel := withStoreTimeout(ctx)
@@ -302,7 +349,7 @@ func (store *WorkspaceStore) CreateDocument(ctx context.Context, params CreateWo
 ) VALUES ($1, $2, $3, $4, $5, 1, 1, $6::jsonb, NOW())
 RETURNING workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at`
 
-	row := store.db.QueryRowContext(
+	row := store.conn().QueryRowContext(
 		ctx,
 		query,
 		params.WorkspaceID,
@@ -321,6 +368,16 @@ RETURNING workspace_id, id, doc_type, name, path, content_rev, meta_rev, content
 }
 
 func (store *WorkspaceStore) GetSnapshot(ctx context.Context, workspaceID string) (*WorkspaceSnapshot, error) {
+	return store.loadSnapshot(ctx, workspaceID, true)
+}
+
+// GetSnapshotMeta is GetSnapshot without document content: tree, revisions
+// and paths only, for clients that load documents lazily.
+func (store *WorkspaceStore) GetSnapshotMeta(ctx context.Context, workspaceID string) (*WorkspaceSnapshot, error) {
+	return store.loadSnapshot(ctx, workspaceID, false)
+}
+
+func (store *WorkspaceStore) loadSnapshot(ctx context.Context, workspaceID string, withContent bool) (*WorkspaceSnapshot, error) {
 	if store == nil || store.db == nil {
 		return nil, errors.New("workspace store is not initialized")
 	}
@@ -332,7 +389,7 @@ func (store *WorkspaceStore) GetSnapshot(ctx context.Context, workspaceID string
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	const workspaceQuery = `SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json
+	const workspaceQuery = `SELECT w.id, w.project_id, w.owner_id, w.name, w.workspace_rev, w.route_rev, w.op_seq, w.tree_root_id, w.tree_json, w.created_at, w.updated_at, r.manifest_json, s.settings_json, COALESCE(s.settings_rev, 1)
 FROM workspaces w
 LEFT JOIN workspace_routes r ON r.workspace_id = w.id
 LEFT JOIN workspace_settings s ON s.workspace_id = w.id
@@ -342,7 +399,8 @@ WHERE w.id = $1`
 	var treeBytes []byte
 	var routeBytes []byte
 	var settingsBytes []byte
-	err := store.db.QueryRowContext(ctx, workspaceQuery, workspaceID).Scan(
+	var settingsRev int64
+	err := store.conn().QueryRowContext(ctx, workspaceQuery, workspaceID).Scan(
 		&workspace.ID,
 		&workspace.ProjectID,
 		&workspace.OwnerID,
@@ -356,6 +414,7 @@ WHERE w.id = $1`
 		&workspace.UpdatedAt,
 		&routeBytes,
 		&settingsBytes,
+		&settingsRev,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -379,12 +438,16 @@ WHERE w.id = $1`
 		settingsBytes = workspaceSettings
 	}
 
-	const documentQuery = `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
+	contentColumn := "content_json"
+	if !withContent {
+		contentColumn = "NULL"
+	}
+	documentQuery := `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, ` + contentColumn + `, updated_at
 FROM workspace_documents
 WHERE workspace_id = $1
 ORDER BY path ASC`
 
-	rows, err := store.db.QueryContext(ctx, documentQuery, workspaceID)
+	rows, err := store.conn().QueryContext(ctx, documentQuery, workspaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -406,6 +469,7 @@ ORDER BY path ASC`
 		Workspace:     workspace,
 		RouteManifest: routeBytes,
 		Settings:      settingsBytes,
+		SettingsRev:   settingsRev,
 		Documents:     documents,
 	}, nil
 }
@@ -451,7 +515,7 @@ func (store *WorkspaceStore) CreateCodeDocument(ctx context.Context, params Crea
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	tx, err := store.db.BeginTx(ctx, nil)
+	tx, err := store.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -553,9 +617,10 @@ ORDER BY path ASC`
 		return nil, err
 	}
 
+	// The workspace row is locked, so the operation below gets currentOpSeq+1.
 	const insertDocument = `INSERT INTO workspace_documents (
-	workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
-) VALUES ($1, $2, $3, $4, $5, 1, 1, $6::jsonb, NOW())`
+	workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, created_op_seq, updated_at
+) VALUES ($1, $2, $3, $4, $5, 1, 1, $6::jsonb, $7, NOW())`
 	if _, err := tx.ExecContext(
 		ctx,
 		insertDocument,
@@ -565,6 +630,7 @@ ORDER BY path ASC`
 		documentName,
 		documentPath,
 		string(contentJSON),
+		currentOpSeq+1,
 	); err != nil {
 		_ = tx.Rollback()
 		return nil, err
@@ -582,7 +648,16 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, nextOpSeq, commandDomain(command), &params.DocumentID, payloadJSON, command.IssuedAt); err != nil {
+	result := &WorkspaceMutationResult{
+		WorkspaceID:  params.WorkspaceID,
+		WorkspaceRev: nextWorkspaceRev,
+		RouteRev:     nextRouteRev,
+		OpSeq:        nextOpSeq,
+		UpdatedDocuments: []WorkspaceDocumentRevision{
+			{ID: params.DocumentID, ContentRev: 1, MetaRev: 1},
+		},
+	}
+	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, nextOpSeq, commandDomain(command), &params.DocumentID, payloadJSON, command.IssuedAt, result); err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
@@ -591,15 +666,7 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	return &WorkspaceMutationResult{
-		WorkspaceID:  params.WorkspaceID,
-		WorkspaceRev: nextWorkspaceRev,
-		RouteRev:     nextRouteRev,
-		OpSeq:        nextOpSeq,
-		UpdatedDocuments: []WorkspaceDocumentRevision{
-			{ID: params.DocumentID, ContentRev: 1, MetaRev: 1},
-		},
-	}, nil
+	return result, nil
 }
 
 func (store *WorkspaceStore) SaveDocumentContent(ctx context.Context, params SaveDocumentContentParams) (*WorkspaceMutationResult, error) {
@@ -634,7 +701,7 @@ func (store *WorkspaceStore) SaveDocumentContent(ctx context.Context, params Sav
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	tx, err := store.db.BeginTx(ctx, nil)
+	tx, err := store.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -722,16 +789,7 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, opSeq, commandDomain(command), &params.DocumentID, payloadJSON, command.IssuedAt); err != nil {
-		_ = tx.Rollback()
-		return nil, err
-	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, err
-	}
-
-	return &WorkspaceMutationResult{
+	result := &WorkspaceMutationResult{
 		WorkspaceID:  params.WorkspaceID,
 		WorkspaceRev: workspaceRev,
 		RouteRev:     routeRev,
@@ -743,7 +801,24 @@ RETURNING workspace_rev, route_rev, op_seq`
 				MetaRev:    nextMetaRev,
 			},
 		},
-	}, nil
+	}
+	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, opSeq, commandDomain(command), &params.DocumentID, payloadJSON, command.IssuedAt, result); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if shouldCheckpointDocument(nextContentRev) {
+		if err := insertDocumentCheckpoint(ctx, tx, params.WorkspaceID, params.DocumentID, opSeq, nextContentRev, contentJSON); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 func (store *WorkspaceStore) PatchDocumentContent(ctx context.Context, params PatchDocumentContentParams) (*WorkspaceMutationResult, error) {
@@ -777,7 +852,7 @@ func (store *WorkspaceStore) PatchDocumentContent(ctx context.Context, params Pa
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	tx, err := store.db.BeginTx(ctx, nil)
+	tx, err := store.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -813,18 +888,34 @@ FOR UPDATE OF d, w`
 		return nil, err
 	}
 
+	var rebasedFromContentRev int64
 	if currentContentRev != params.ExpectedContentRev {
-		_ = tx.Rollback()
-		return nil, &WorkspaceRevisionConflictError{
-			ConflictType:       WorkspaceConflictDocument,
-			WorkspaceID:        params.WorkspaceID,
-			DocumentID:         params.DocumentID,
-			ServerWorkspaceRev: currentWorkspaceRev,
-			ServerRouteRev:     currentRouteRev,
-			ServerContentRev:   currentContentRev,
-			ServerMetaRev:      currentMetaRev,
-			ServerOpSeq:        currentOpSeq,
+		var conflicts []WorkspaceHistoryConflict
+		rebased := false
+		if params.AutoRebase {
+			var rebaseErr error
+			conflicts, rebased, rebaseErr = findRebaseConflicts(ctx, tx, params.WorkspaceID, params.DocumentID, params.ExpectedContentRev, currentContentRev, command.ForwardOps)
+			if rebaseErr != nil {
+				_ = tx.Rollback()
+				return nil, rebaseErr
+			}
+			rebased = rebased && len(conflicts) == 0
+		}
+		if !rebased {
+			_ = tx.Rollback()
+			return nil, &WorkspaceRevisionConflictError{
+				ConflictType:       WorkspaceConflictDocument,
+				WorkspaceID:        params.WorkspaceID,
+				DocumentID:         params.DocumentID,
+				ServerWorkspaceRev: currentWorkspaceRev,
+				ServerRouteRev:     currentRouteRev,
+				ServerContentRev:   currentContentRev,
+				ServerMetaRev:      currentMetaRev,
+				ServerOpSeq:        currentOpSeq,
+				Conflicts:          conflicts,
+			}
 		}
+		rebasedFromContentRev = params.ExpectedContentRev
 	}
 
 	documentType := WorkspaceDocumentType(rawDocumentType)
@@ -833,21 +924,34 @@ FOR UPDATE OF d, w`
 		return nil, ErrInvalidWorkspaceDocumentType
 	}
 
-	patchedContent, err := applyWorkspaceDocumentPatch(documentType, currentContent, command.ForwardOps)
+	patch, err := patchWorkspaceDocument(documentType, currentContent, command.ForwardOps)
 	if err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
-	if err := validateWorkspaceDocumentContent(documentType, patchedContent); err != nil {
+	patchedContent := patch.Content
+	if err := validateWorkspaceDocumentPatch(documentType, currentContent, patchedContent, command.ForwardOps); err != nil {
 		_ = tx.Rollback()
-		return nil, err
+		return nil, withDocumentTarget(err, params.DocumentID)
 	}
-	reversedContent, err := applyWorkspaceDocumentPatch(documentType, patchedContent, command.ReverseOps)
+	if documentType == WorkspaceDocumentTypeMIRAnimation {
+		if err := validateAnimationTargets(ctx, tx, params.WorkspaceID, currentContent, patchedContent); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	if documentType == WorkspaceDocumentTypeMIRLayout {
+		if err := validateLayoutRoutes(ctx, tx, params.WorkspaceID, params.DocumentID, currentContent, patchedContent); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	restored, err := patch.restoredBy(command.ReverseOps)
 	if err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
-	if !jsonBytesEqual(currentContent, reversedContent) {
+	if !restored {
 		_ = tx.Rollback()
 		return nil, errors.New("command.reverseOps do not restore original document")
 	}
@@ -883,24 +987,33 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, opSeq, commandDomain(command), &params.DocumentID, payloadJSON, command.IssuedAt); err != nil {
+	result := &WorkspaceMutationResult{
+		WorkspaceID:  params.WorkspaceID,
+		WorkspaceRev: workspaceRev,
+		RouteRev:     routeRev,
+		OpSeq:        opSeq,
+		UpdatedDocuments: []WorkspaceDocumentRevision{
+			{ID: params.DocumentID, ContentRev: nextContentRev, MetaRev: nextMetaRev},
+		},
+		RebasedFromContentRev: rebasedFromContentRev,
+	}
+	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, opSeq, commandDomain(command), &params.DocumentID, payloadJSON, command.IssuedAt, result); err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
 
+	if shouldCheckpointDocument(nextContentRev) {
+		if err := insertDocumentCheckpoint(ctx, tx, params.WorkspaceID, params.DocumentID, opSeq, nextContentRev, patchedContent); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return &WorkspaceMutationResult{
-		WorkspaceID:  params.WorkspaceID,
-		WorkspaceRev: workspaceRev,
-		RouteRev:     routeRev,
-		OpSeq:        opSeq,
-		UpdatedDocuments: []WorkspaceDocumentRevision{
-			{ID: params.DocumentID, ContentRev: nextContentRev, MetaRev: nextMetaRev},
-		},
-	}, nil
+	return result, nil
 }
 
 func (store *WorkspaceStore) SaveRouteManifest(ctx context.Context, params SaveRouteManifestParams) (*WorkspaceMutationResult, error) {
@@ -938,7 +1051,7 @@ func (store *WorkspaceStore) SaveRouteManifest(ctx context.Context, params SaveR
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	tx, err := store.db.BeginTx(ctx, nil)
+	tx, err := store.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -1001,6 +1114,16 @@ FOR UPDATE`
 		}
 	}
 
+	documents, err := loadRouteDocuments(ctx, tx, params.WorkspaceID)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err := validateRouteManifest(manifestJSON, documents); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
 	const upsertRoute = `INSERT INTO workspace_routes (workspace_id, manifest_json, updated_at)
 VALUES ($1, $2::jsonb, NOW())
 ON CONFLICT (workspace_id) DO UPDATE
@@ -1024,7 +1147,13 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, nextOpSeq, commandDomain(command), nil, payloadJSON, command.IssuedAt); err != nil {
+	result := &WorkspaceMutationResult{
+		WorkspaceID:  params.WorkspaceID,
+		WorkspaceRev: nextWorkspaceRev,
+		RouteRev:     nextRouteRev,
+		OpSeq:        nextOpSeq,
+	}
+	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, nextOpSeq, commandDomain(command), nil, payloadJSON, command.IssuedAt, result); err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
@@ -1033,12 +1162,7 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	return &WorkspaceMutationResult{
-		WorkspaceID:  params.WorkspaceID,
-		WorkspaceRev: nextWorkspaceRev,
-		RouteRev:     nextRouteRev,
-		OpSeq:        nextOpSeq,
-	}, nil
+	return result, nil
 }
 
 func (store *WorkspaceStore) SaveWorkspaceSettings(ctx context.Context, params SaveWorkspaceSettingsParams) (*WorkspaceMutationResult, error) {
@@ -1051,11 +1175,17 @@ func (store *WorkspaceStore) SaveWorkspaceSettings(ctx context.Context, params S
 	if params.ExpectedWorkspaceRev <= 0 {
 		return nil, errors.New("expectedWorkspaceRev must be positive")
 	}
+	if params.ExpectedSettingsRev <= 0 {
+		return nil, errors.New("expectedSettingsRev must be positive")
+	}
 
 	settingsJSON, err := normalizeJSONDocument(params.Settings, defaultWorkspaceSettings)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateWorkspaceSettings(settingsJSON); err != nil {
+		return nil, err
+	}
 	command, err := normalizeWorkspaceCommand(params.Command)
 	if err != nil {
 		return nil, err
@@ -1076,52 +1206,43 @@ func (store *WorkspaceStore) SaveWorkspaceSettings(ctx context.Context, params S
 	ctx, cancel := withStoreTimeout(ctx)
 	defer cancel()
 
-	tx, err := store.db.BeginTx(ctx, nil)
+	tx, err := store.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	const lockWorkspace = `SELECT workspace_rev, route_rev, op_seq
-FROM workspaces
-WHERE id = $1
-FOR UPDATE`
-
-	var currentWorkspaceRev int64
-	var currentRouteRev int64
-	var currentOpSeq int64
-	err = tx.QueryRowContext(ctx, lockWorkspace, params.WorkspaceID).Scan(&currentWorkspaceRev, &currentRouteRev, &currentOpSeq)
+	locked, err := lockWorkspaceSettings(ctx, tx, params.WorkspaceID)
 	if err != nil {
 		_ = tx.Rollback()
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrWorkspaceNotFound
-		}
 		return nil, err
 	}
 
-	if currentWorkspaceRev != params.ExpectedWorkspaceRev {
+	if locked.workspaceRev != params.ExpectedWorkspaceRev {
 		_ = tx.Rollback()
 		log.Printf(
 			"[workspace] conflict save_workspace_settings workspace=%s expectedWorkspaceRev=%d serverWorkspaceRev=%d serverRouteRev=%d serverOpSeq=%d",
 			params.WorkspaceID,
 			params.ExpectedWorkspaceRev,
-			currentWorkspaceRev,
-			currentRouteRev,
-			currentOpSeq,
+			locked.workspaceRev,
+			locked.routeRev,
+			locked.opSeq,
 		)
 		return nil, &WorkspaceRevisionConflictError{
 			ConflictType:       WorkspaceConflictWorkspace,
 			WorkspaceID:        params.WorkspaceID,
-			ServerWorkspaceRev: currentWorkspaceRev,
-			ServerRouteRev:     currentRouteRev,
-			ServerOpSeq:        currentOpSeq,
+			ServerWorkspaceRev: locked.workspaceRev,
+			ServerRouteRev:     locked.routeRev,
+			ServerSettingsRev:  locked.settingsRev,
+			ServerOpSeq:        locked.opSeq,
 		}
 	}
+	if locked.settingsRev != params.ExpectedSettingsRev {
+		_ = tx.Rollback()
+		return nil, locked.conflict(params.WorkspaceID, nil)
+	}
 
-	const upsertSettings = `INSERT INTO workspace_settings (workspace_id, settings_json, updated_at)
-VALUES ($1, $2::jsonb, NOW())
-ON CONFLICT (workspace_id) DO UPDATE
-SET settings_json = EXCLUDED.settings_json, updated_at = EXCLUDED.updated_at`
-	if _, err := tx.ExecContext(ctx, upsertSettings, params.WorkspaceID, string(settingsJSON)); err != nil {
+	var nextSettingsRev int64
+	if err := tx.QueryRowContext(ctx, upsertWorkspaceSettingsQuery, params.WorkspaceID, string(settingsJSON)).Scan(&nextSettingsRev); err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
@@ -1139,7 +1260,14 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, nextOpSeq, commandDomain(command), nil, payloadJSON, command.IssuedAt); err != nil {
+	result := &WorkspaceMutationResult{
+		WorkspaceID:  params.WorkspaceID,
+		WorkspaceRev: nextWorkspaceRev,
+		RouteRev:     nextRouteRev,
+		OpSeq:        nextOpSeq,
+		SettingsRev:  nextSettingsRev,
+	}
+	if err := insertWorkspaceOperation(ctx, tx, params.WorkspaceID, nextOpSeq, commandDomain(command), nil, payloadJSON, command.IssuedAt, result); err != nil {
 		_ = tx.Rollback()
 		return nil, err
 	}
@@ -1148,33 +1276,36 @@ RETURNING workspace_rev, route_rev, op_seq`
 		return nil, err
 	}
 
-	return &WorkspaceMutationResult{
-		WorkspaceID:  params.WorkspaceID,
-		WorkspaceRev: nextWorkspaceRev,
-		RouteRev:     nextRouteRev,
-		OpSeq:        nextOpSeq,
-	}, nil
+	return result, nil
 }
 
 func insertWorkspaceOperation(
 	ctx context.Context,
-	tx *sql.Tx,
+	tx workspaceQuerier,
 	workspaceID string,
 	opSeq int64,
 	domain string,
 	documentID *string,
 	payload json.RawMessage,
 	issuedAt time.Time,
+	result *WorkspaceMutationResult,
 ) error {
-	const query = `INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`
+	const query = `INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`
 
 	var docID any
 	if documentID != nil {
 		docID = *documentID
 	}
+	// The result is logged with the command so a client resuming from the log
+	// can advance its revisions past operations that are not tied to a
+	// single document.
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
 
-	_, err := tx.ExecContext(ctx, query, workspaceID, opSeq, domain, docID, string(payload), issuedAt)
+	_, err = tx.ExecContext(ctx, query, workspaceID, opSeq, domain, docID, string(payload), issuedAt, string(resultJSON))
 	return err
 }
 
@@ -1278,7 +1409,7 @@ func validateWorkspaceCommand(command WorkspaceCommandEnvelope, workspaceID stri
 
 func isSupportedPatchOperation(operation string) bool {
 	switch operation {
-	case "add", "remove", "replace", "move", "copy", "test":
+	case "add", "remove", "replace", "move", "copy", "test", "text":
 		return true
 	default:
 		return false
@@ -1292,7 +1423,7 @@ func commandDomain(command WorkspaceCommandEnvelope) string {
 func (store *WorkspaceStore) resolveDocumentLookupError(ctx context.Context, workspaceID string) error {
 	const query = `SELECT 1 FROM workspaces WHERE id = $1`
 	var marker int
-	err := store.db.QueryRowContext(ctx, query, workspaceID).Scan(&marker)
+	err := store.conn().QueryRowContext(ctx, query, workspaceID).Scan(&marker)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrWorkspaceNotFound
@@ -1340,11 +1471,7 @@ func normalizeJSONDocument(payload json.RawMessage, fallback json.RawMessage) (j
 }
 
 func normalizeWorkspaceDocumentContent(documentType WorkspaceDocumentType, payload json.RawMessage) (json.RawMessage, error) {
-	fallback := defaultMIRDocument
-	if documentType == WorkspaceDocumentTypeCode {
-		fallback = defaultCodeDocument
-	}
-	normalized, err := normalizeJSONDocument(payload, fallback)
+	normalized, err := normalizeJSONDocument(payload, defaultWorkspaceDocumentContent(documentType))
 	if err != nil {
 		return nil, err
 	}
@@ -1358,12 +1485,28 @@ func validateWorkspaceDocumentContent(documentType WorkspaceDocumentType, payloa
 	if documentType == WorkspaceDocumentTypeCode {
 		return validateWorkspaceCodeDocument(payload)
 	}
+	if documentType == WorkspaceDocumentTypeMIRGraph {
+		return validateNodeGraphDocument(payload)
+	}
+	if documentType == WorkspaceDocumentTypeMIRAnimation {
+		return validateAnimationDocument(payload)
+	}
 	if isMIRWorkspaceDocumentType(documentType) {
 		return validateMIRV13Document(payload)
 	}
 	return nil
 }
 
+// validateWorkspaceDocumentPatch validates content produced by a patch. MIR
+// documents are checked incrementally against the stored content; other
+// types are small enough to revalidate whole.
+func validateWorkspaceDocumentPatch(documentType WorkspaceDocumentType, previous json.RawMessage, next json.RawMessage, ops []WorkspacePatchOp) error {
+	if isMIRWorkspaceDocumentType(documentType) {
+		return validateMIRV13Patch(previous, next, ops)
+	}
+	return validateWorkspaceDocumentContent(documentType, next)
+}
+
 func validateWorkspaceCodeDocument(payload json.RawMessage) error {
 	var document map[string]any
 	if err := json.Unmarshal(payload, &document); err != nil {
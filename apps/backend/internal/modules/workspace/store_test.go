@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -63,8 +64,8 @@ RETURNING content_rev, meta_rev`)
 SET op_seq = op_seq + 1, updated_at = NOW()
 WHERE id = $1
 RETURNING workspace_rev, route_rev, op_seq`)
-	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
+	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(lockQuery).
@@ -77,7 +78,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 		WithArgs("ws_1").
 		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
 	mock.ExpectExec(insertOperation).
-		WithArgs("ws_1", int64(34), "core.mir.document.update@1.0", "doc_home", sqlmock.AnyArg(), issuedAt.UTC()).
+		WithArgs("ws_1", int64(34), "core.mir.document.update@1.0", "doc_home", sqlmock.AnyArg(), issuedAt.UTC(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -194,8 +195,8 @@ RETURNING content_rev, meta_rev`)
 SET op_seq = op_seq + 1, updated_at = NOW()
 WHERE id = $1
 RETURNING workspace_rev, route_rev, op_seq`)
-	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
+	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(lockQuery).
@@ -209,7 +210,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 		WithArgs("ws_1").
 		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
 	mock.ExpectExec(insertOperation).
-		WithArgs("ws_1", int64(34), "core.code.source.update@1.0", "code_open_dialog", sqlmock.AnyArg(), issuedAt.UTC()).
+		WithArgs("ws_1", int64(34), "core.code.source.update@1.0", "code_open_dialog", sqlmock.AnyArg(), issuedAt.UTC(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -253,13 +254,18 @@ SET manifest_json = EXCLUDED.manifest_json, updated_at = EXCLUDED.updated_at`)
 SET workspace_rev = workspace_rev + 1, route_rev = route_rev + 1, op_seq = op_seq + 1, updated_at = NOW()
 WHERE id = $1
 RETURNING workspace_rev, route_rev, op_seq`)
-	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
+	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(lockWorkspace).
 		WithArgs("ws_1").
 		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectQuery(routeDocumentQuery).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doc_type", "content_json"}).
+			AddRow("layout_root", "mir-layout", []byte(defaultWorkspaceDocumentContent(WorkspaceDocumentTypeMIRLayout))).
+			AddRow("page_home", "mir-page", nil))
 	mock.ExpectExec(upsertRoute).
 		WithArgs("ws_1", sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
@@ -267,7 +273,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 		WithArgs("ws_1").
 		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(10, 5, 35))
 	mock.ExpectExec(insertOperation).
-		WithArgs("ws_1", int64(35), "core.route.manifest.update@1.0", nil, sqlmock.AnyArg(), issuedAt.UTC()).
+		WithArgs("ws_1", int64(35), "core.route.manifest.update@1.0", nil, sqlmock.AnyArg(), issuedAt.UTC(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -275,7 +281,7 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 		WorkspaceID:          "ws_1",
 		ExpectedWorkspaceRev: 9,
 		ExpectedRouteRev:     4,
-		RouteManifest:        json.RawMessage(`{"version":"1","root":{"id":"root"}}`),
+		RouteManifest:        json.RawMessage(`{"version":"1","root":{"id":"root","layoutDocId":"layout_root","children":[{"id":"home","index":true,"pageDocId":"page_home"}]}}`),
 		Command:              command,
 	})
 	if err != nil {
@@ -289,6 +295,54 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 	}
 }
 
+func TestWorkspaceStoreSaveRouteManifestRejectsBrokenDocumentReferences(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	issuedAt := time.Date(2026, time.February, 8, 10, 2, 0, 0, time.UTC)
+	command := buildTestCommand("cmd_route_update_2", issuedAt, "ws_1", "", "core.route", "manifest.update")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq
+FROM workspaces
+WHERE id = $1
+FOR UPDATE`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
+	mock.ExpectQuery(routeDocumentQuery).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "doc_type", "content_json"}).
+			AddRow("page_home", "mir-page", nil))
+	mock.ExpectRollback()
+
+	_, err = store.SaveRouteManifest(context.Background(), SaveRouteManifestParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 9,
+		ExpectedRouteRev:     4,
+		RouteManifest:        json.RawMessage(`{"version":"1","root":{"id":"root","layoutDocId":"page_home","children":[{"id":"about","segment":"about","pageDocId":"page_about"}]}}`),
+		Command:              command,
+	})
+	var validationErr *DocumentValidationError
+	if !errors.As(err, &validationErr) || validationErr.Domain != routeDomain {
+		t.Fatalf("expected route validation error, got %v", err)
+	}
+	got := make([]string, 0, len(validationErr.Diagnostics))
+	for _, diagnostic := range validationErr.Diagnostics {
+		got = append(got, diagnostic.Code+" "+diagnostic.Path)
+	}
+	want := []string{"RTE-2002 /root/layoutDocId", "RTE-2001 /root/children/0/pageDocId"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected diagnostics: %v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestWorkspaceStoreSaveWorkspaceSettingsIncrementsWorkspaceRevOnly(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -300,46 +354,35 @@ func TestWorkspaceStoreSaveWorkspaceSettingsIncrementsWorkspaceRevOnly(t *testin
 	issuedAt := time.Date(2026, time.February, 8, 10, 3, 0, 0, time.UTC)
 	command := buildTestCommand("cmd_settings_update_1", issuedAt, "ws_1", "", "core.settings", "global.update")
 
-	lockWorkspace := regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq
-FROM workspaces
-WHERE id = $1
-FOR UPDATE`)
-	upsertSettings := regexp.QuoteMeta(`INSERT INTO workspace_settings (workspace_id, settings_json, updated_at)
-VALUES ($1, $2::jsonb, NOW())
-ON CONFLICT (workspace_id) DO UPDATE
-SET settings_json = EXCLUDED.settings_json, updated_at = EXCLUDED.updated_at`)
 	bumpWorkspaceOnly := regexp.QuoteMeta(`UPDATE workspaces
 SET workspace_rev = workspace_rev + 1, op_seq = op_seq + 1, updated_at = NOW()
 WHERE id = $1
 RETURNING workspace_rev, route_rev, op_seq`)
-	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at)
-VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
+	insertOperation := regexp.QuoteMeta(`INSERT INTO workspace_operations (workspace_id, op_seq, domain, document_id, payload_json, created_at, result_json)
+VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7::jsonb)`)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(lockWorkspace).
-		WithArgs("ws_1").
-		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, 34))
-	mock.ExpectExec(upsertSettings).
-		WithArgs("ws_1", `{"global":{"eventTriggerMode":"selected-only"},"projectGlobalById":{}}`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSettingsLock(mock, testHead{workspaceRev: 9, routeRev: 4, opSeq: 34}, "", 1)
+	expectSettingsUpsert(mock, "ws_1", `{"global":{"eventTriggerMode":"selected-only"},"projectGlobalById":{}}`, 2)
 	mock.ExpectQuery(bumpWorkspaceOnly).
 		WithArgs("ws_1").
 		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(10, 4, 35))
 	mock.ExpectExec(insertOperation).
-		WithArgs("ws_1", int64(35), "core.settings.global.update@1.0", nil, sqlmock.AnyArg(), issuedAt.UTC()).
+		WithArgs("ws_1", int64(35), "core.settings.global.update@1.0", nil, sqlmock.AnyArg(), issuedAt.UTC(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	result, err := store.SaveWorkspaceSettings(context.Background(), SaveWorkspaceSettingsParams{
 		WorkspaceID:          "ws_1",
 		ExpectedWorkspaceRev: 9,
+		ExpectedSettingsRev:  1,
 		Settings:             json.RawMessage(`{"global":{"eventTriggerMode":"selected-only"},"projectGlobalById":{}}`),
 		Command:              command,
 	})
 	if err != nil {
 		t.Fatalf("save workspace settings: %v", err)
 	}
-	if result.WorkspaceRev != 10 || result.RouteRev != 4 || result.OpSeq != 35 {
+	if result.WorkspaceRev != 10 || result.RouteRev != 4 || result.OpSeq != 35 || result.SettingsRev != 2 {
 		t.Fatalf("unexpected result: %+v", result)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -347,6 +390,37 @@ VALUES ($1, $2, $3, $4, $5::jsonb, $6)`)
 	}
 }
 
+func TestWorkspaceStoreSaveWorkspaceSettingsReturnsSettingsConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	issuedAt := time.Date(2026, time.February, 8, 10, 4, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectSettingsLock(mock, testHead{workspaceRev: 9, routeRev: 4, opSeq: 35}, `{"global":{"eventTriggerMode":"selected-only"},"projectGlobalById":{}}`, 2)
+	mock.ExpectRollback()
+
+	// A global.patch moved settingsRev without bumping workspaceRev; the
+	// full update must not overwrite it.
+	_, err = NewWorkspaceStore(db).SaveWorkspaceSettings(context.Background(), SaveWorkspaceSettingsParams{
+		WorkspaceID:          "ws_1",
+		ExpectedWorkspaceRev: 9,
+		ExpectedSettingsRev:  1,
+		Settings:             json.RawMessage(`{"global":{"eventTriggerMode":"always"},"projectGlobalById":{}}`),
+		Command:              testCommand("core.settings", "global.update", issuedAt),
+	})
+	var conflictErr *WorkspaceRevisionConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.ConflictType != WorkspaceConflictSettings || conflictErr.ServerSettingsRev != 2 {
+		t.Fatalf("expected settings conflict, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestWorkspaceStoreSaveWorkspaceSettingsReturnsWorkspaceConflict(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -358,20 +432,14 @@ func TestWorkspaceStoreSaveWorkspaceSettingsReturnsWorkspaceConflict(t *testing.
 	issuedAt := time.Date(2026, time.February, 8, 10, 4, 0, 0, time.UTC)
 	command := buildTestCommand("cmd_settings_update_2", issuedAt, "ws_1", "", "core.settings", "global.update")
 
-	lockWorkspace := regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq
-FROM workspaces
-WHERE id = $1
-FOR UPDATE`)
-
 	mock.ExpectBegin()
-	mock.ExpectQuery(lockWorkspace).
-		WithArgs("ws_1").
-		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(10, 4, 35))
+	expectSettingsLock(mock, testHead{workspaceRev: 10, routeRev: 4, opSeq: 35}, "", 1)
 	mock.ExpectRollback()
 
 	_, err = store.SaveWorkspaceSettings(context.Background(), SaveWorkspaceSettingsParams{
 		WorkspaceID:          "ws_1",
 		ExpectedWorkspaceRev: 9,
+		ExpectedSettingsRev:  1,
 		Settings:             json.RawMessage(`{"global":{"eventTriggerMode":"always"},"projectGlobalById":{}}`),
 		Command:              command,
 	})
@@ -470,3 +538,84 @@ func TestWorkspaceStoreSaveDocumentContentValidatesCommandEnvelope(t *testing.T)
 		})
 	}
 }
+
+func TestWorkspaceStoreListOperationsFiltersAndPaginates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	createdAt := time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq"}).AddRow(12))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq, domain, document_id, payload_json, created_at, result_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND op_seq > $2 AND document_id = $3 AND domain LIKE $4 ESCAPE '\'
+ORDER BY op_seq ASC
+LIMIT $5`)).
+		WithArgs("ws_1", int64(4), "doc_home", `core.mir.document\_%`, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json", "created_at", "result_json"}).
+			AddRow(5, "core.mir.document_update@1.0", "doc_home", []byte(`{"id":"cmd_5"}`), createdAt, nil).
+			AddRow(7, "core.mir.document_update@1.0", "doc_home", []byte(`{"id":"cmd_7"}`), createdAt, nil).
+			AddRow(9, "core.mir.document_update@1.0", "doc_home", []byte(`{"id":"cmd_9"}`), createdAt, nil))
+
+	page, err := store.ListOperations(context.Background(), ListWorkspaceOperationsParams{
+		WorkspaceID: "ws_1",
+		AfterOpSeq:  4,
+		DocumentID:  "doc_home",
+		Domain:      "core.mir.document_",
+		Limit:       2,
+	})
+	if err != nil {
+		t.Fatalf("list operations: %v", err)
+	}
+	if len(page.Operations) != 2 || !page.HasMore || page.NextAfterOpSeq != 7 || page.HeadOpSeq != 12 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if page.Operations[0].DocumentID != "doc_home" || string(page.Operations[0].Command) != `{"id":"cmd_5"}` {
+		t.Fatalf("unexpected operation: %+v", page.Operations[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreListOperationsKeepsCursorWhenEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq"}).AddRow(12))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT op_seq, domain, document_id, payload_json, created_at, result_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND op_seq > $2 AND domain = $3
+ORDER BY op_seq ASC
+LIMIT $4`)).
+		WithArgs("ws_1", int64(12), "core.settings.global.update@1.0", defaultWorkspaceOperationPageSize+1).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "document_id", "payload_json", "created_at", "result_json"}))
+
+	page, err := store.ListOperations(context.Background(), ListWorkspaceOperationsParams{
+		WorkspaceID: "ws_1",
+		AfterOpSeq:  12,
+		Domain:      "core.settings.global.update@1.0",
+	})
+	if err != nil {
+		t.Fatalf("list operations: %v", err)
+	}
+	if len(page.Operations) != 0 || page.HasMore || page.NextAfterOpSeq != 12 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// errWorkspaceMutationAborted signals RunInTx to roll back after a mutation
+// produced a RequestFailure; the failure itself is reported to the client
+// instead of this error.
+var errWorkspaceMutationAborted = errors.New("workspace mutation aborted")
+
+type workspaceQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type workspaceTx interface {
+	workspaceQuerier
+	Commit() error
+	Rollback() error
+}
+
+// joinedWorkspaceTx lets a mutation method run inside a transaction owned by
+// RunInTx. Commit and rollback are left to the owner so the whole unit of work
+// succeeds or fails together.
+type joinedWorkspaceTx struct {
+	*sql.Tx
+}
+
+func (joinedWorkspaceTx) Commit() error {
+	return nil
+}
+
+func (joinedWorkspaceTx) Rollback() error {
+	return nil
+}
+
+// RunInTx executes fn with a store bound to a single database transaction.
+// Every mutation issued through the bound store joins that transaction, and
+// the transaction commits only when fn returns nil.
+func (store *WorkspaceStore) RunInTx(ctx context.Context, fn func(txStore *WorkspaceStore) error) error {
+	if store == nil || store.db == nil {
+		return errors.New("workspace store is not initialized")
+	}
+	if store.tx != nil {
+		return fn(store)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&WorkspaceStore{db: store.db, tx: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *WorkspaceStore) beginTx(ctx context.Context) (workspaceTx, error) {
+	if store.tx != nil {
+		return joinedWorkspaceTx{Tx: store.tx}, nil
+	}
+	return store.db.BeginTx(ctx, nil)
+}
+
+// lockWorkspaceRow takes the workspace row lock. Writers that also lock
+// document rows take this one first, the order lockWorkspaceState uses, so
+// two writers never wait on each other's rows.
+func (store *WorkspaceStore) lockWorkspaceRow(ctx context.Context, workspaceID string) error {
+	const query = `SELECT id FROM workspaces WHERE id = $1 FOR UPDATE`
+	var id string
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWorkspaceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (store *WorkspaceStore) conn() workspaceQuerier {
+	if store.tx != nil {
+		return store.tx
+	}
+	return store.db
+}
@@ -0,0 +1,425 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// documentCheckpointInterval bounds time-travel replay: every Nth content
+// revision stores a full copy of the document next to the operation log.
+const documentCheckpointInterval = 50
+
+var ErrWorkspaceOpSeqOutOfRange = errors.New("opSeq is beyond the workspace head")
+var ErrWorkspaceRestoreUnchanged = errors.New("content already matches the restore target")
+
+type workspaceDocumentCheckpoint struct {
+	OpSeq      int64
+	ContentRev int64
+	Content    json.RawMessage
+}
+
+func shouldCheckpointDocument(contentRev int64) bool {
+	return contentRev > 0 && contentRev%documentCheckpointInterval == 0
+}
+
+func insertDocumentCheckpoint(ctx context.Context, tx workspaceQuerier, workspaceID string, documentID string, opSeq int64, contentRev int64, content json.RawMessage) error {
+	const query = `INSERT INTO workspace_document_checkpoints (workspace_id, document_id, op_seq, content_rev, content_json, created_at)
+VALUES ($1, $2, $3, $4, $5::jsonb, NOW())
+ON CONFLICT (workspace_id, document_id, op_seq) DO NOTHING`
+	_, err := tx.ExecContext(ctx, query, workspaceID, documentID, opSeq, contentRev, string(content))
+	return err
+}
+
+// isDocumentContentCommand reports whether a logged document operation
+// patched content. core.workspace.* commands change the tree or document
+// metadata and carry no content ops.
+func isDocumentContentCommand(command WorkspaceCommandEnvelope) bool {
+	return command.Namespace != "core.workspace" && !strings.HasPrefix(command.Namespace, "core.workspace.")
+}
+
+func isDocumentCreateCommand(command WorkspaceCommandEnvelope) bool {
+	return command.Namespace == "core.workspace" && strings.HasSuffix(command.Type, ".create")
+}
+
+func (store *WorkspaceStore) GetDocument(ctx context.Context, workspaceID string, documentID string) (*WorkspaceDocumentRecord, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+	return store.getDocument(ctx, workspaceID, documentID, "")
+}
+
+func (store *WorkspaceStore) getDocument(ctx context.Context, workspaceID string, documentID string, lock string) (*WorkspaceDocumentRecord, error) {
+	query := `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1 AND id = $2` + lock
+	document, err := scanWorkspaceDocument(store.conn().QueryRowContext(ctx, query, workspaceID, documentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.resolveDocumentLookupError(ctx, workspaceID)
+		}
+		return nil, err
+	}
+	return document, nil
+}
+
+// GetDocumentAtOpSeq rebuilds a document as it was right after atOpSeq was
+// committed. It starts from whichever is closer, the nearest checkpoint at or
+// before atOpSeq (replaying forward ops) or the nearest later checkpoint or
+// current content (replaying reverse ops), so the cost stays bounded by the
+// checkpoint interval. Only content is historical; name, path and metaRev are
+// current.
+func (store *WorkspaceStore) GetDocumentAtOpSeq(ctx context.Context, workspaceID string, documentID string, atOpSeq int64) (*WorkspaceDocumentRecord, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	var document *WorkspaceDocumentRecord
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		ctx, cancel := withStoreTimeout(ctx)
+		defer cancel()
+
+		// FOR SHARE blocks content writers so the log cannot move past the
+		// content read here.
+		current, err := txStore.getDocument(ctx, workspaceID, documentID, "\nFOR SHARE")
+		if err != nil {
+			return err
+		}
+		head, err := txStore.GetWorkspaceHead(ctx, workspaceID)
+		if err != nil {
+			return err
+		}
+		if atOpSeq > head.OpSeq {
+			return ErrWorkspaceOpSeqOutOfRange
+		}
+		document = current
+		if atOpSeq == head.OpSeq {
+			return nil
+		}
+		createdOpSeq, err := txStore.getDocumentCreatedOpSeq(ctx, workspaceID, documentID)
+		if err != nil {
+			return err
+		}
+		if atOpSeq < createdOpSeq {
+			// The document did not exist yet at atOpSeq.
+			return ErrWorkspaceDocumentNotFound
+		}
+
+		before, err := txStore.findDocumentCheckpoint(ctx, workspaceID, documentID, atOpSeq, false)
+		if err != nil {
+			return err
+		}
+		after, err := txStore.findDocumentCheckpoint(ctx, workspaceID, documentID, atOpSeq, true)
+		if err != nil {
+			return err
+		}
+		backwardFrom := workspaceDocumentCheckpoint{OpSeq: head.OpSeq, ContentRev: current.ContentRev, Content: current.Content}
+		if after != nil {
+			backwardFrom = *after
+		}
+
+		if before != nil && atOpSeq-before.OpSeq < backwardFrom.OpSeq-atOpSeq {
+			content, contentRev, err := txStore.replayDocumentForward(ctx, current.Type, workspaceID, documentID, *before, atOpSeq)
+			if err != nil {
+				return err
+			}
+			document.Content, document.ContentRev = content, contentRev
+			return nil
+		}
+		content, contentRev, err := txStore.replayDocumentBackward(ctx, current.Type, workspaceID, documentID, backwardFrom, atOpSeq)
+		if err != nil {
+			return err
+		}
+		document.Content, document.ContentRev = content, contentRev
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// getDocumentCreatedOpSeq returns the operation that inserted the current
+// document row. Workspace-level commands such as mir-document.create, restore
+// and undo insert documents without logging under their id, so the log alone
+// cannot tell when they appeared. Rows seeded with the workspace, or created
+// before the column existed, report 0.
+func (store *WorkspaceStore) getDocumentCreatedOpSeq(ctx context.Context, workspaceID string, documentID string) (int64, error) {
+	const query = `SELECT created_op_seq FROM workspace_documents WHERE workspace_id = $1 AND id = $2`
+	var createdOpSeq int64
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID, documentID).Scan(&createdOpSeq); err != nil {
+		return 0, err
+	}
+	return createdOpSeq, nil
+}
+
+func (store *WorkspaceStore) replayDocumentForward(ctx context.Context, documentType WorkspaceDocumentType, workspaceID string, documentID string, from workspaceDocumentCheckpoint, atOpSeq int64) (json.RawMessage, int64, error) {
+	entries, err := store.listDocumentOperationRange(ctx, workspaceID, documentID, from.OpSeq, atOpSeq)
+	if err != nil {
+		return nil, 0, err
+	}
+	content, contentRev := from.Content, from.ContentRev
+	for _, entry := range entries {
+		if !isDocumentContentCommand(entry.command) {
+			continue
+		}
+		content, err = applyWorkspaceDocumentPatch(documentType, content, entry.command.ForwardOps)
+		if err != nil {
+			return nil, 0, fmt.Errorf("replay op %d: %w", entry.opSeq, err)
+		}
+		contentRev++
+	}
+	return content, contentRev, nil
+}
+
+func (store *WorkspaceStore) replayDocumentBackward(ctx context.Context, documentType WorkspaceDocumentType, workspaceID string, documentID string, from workspaceDocumentCheckpoint, atOpSeq int64) (json.RawMessage, int64, error) {
+	entries, err := store.listDocumentOperationRange(ctx, workspaceID, documentID, atOpSeq, from.OpSeq)
+	if err != nil {
+		return nil, 0, err
+	}
+	content, contentRev := from.Content, from.ContentRev
+	for index := len(entries) - 1; index >= 0; index-- {
+		entry := entries[index]
+		if isDocumentCreateCommand(entry.command) {
+			// The document did not exist yet at atOpSeq.
+			return nil, 0, ErrWorkspaceDocumentNotFound
+		}
+		if !isDocumentContentCommand(entry.command) {
+			continue
+		}
+		content, err = applyWorkspaceDocumentPatch(documentType, content, entry.command.ReverseOps)
+		if err != nil {
+			return nil, 0, fmt.Errorf("revert op %d: %w", entry.opSeq, err)
+		}
+		contentRev--
+	}
+	return content, contentRev, nil
+}
+
+func (store *WorkspaceStore) findDocumentCheckpoint(ctx context.Context, workspaceID string, documentID string, opSeq int64, after bool) (*workspaceDocumentCheckpoint, error) {
+	query := `SELECT op_seq, content_rev, content_json
+FROM workspace_document_checkpoints
+WHERE workspace_id = $1 AND document_id = $2 AND op_seq <= $3
+ORDER BY op_seq DESC
+LIMIT 1`
+	if after {
+		query = `SELECT op_seq, content_rev, content_json
+FROM workspace_document_checkpoints
+WHERE workspace_id = $1 AND document_id = $2 AND op_seq > $3
+ORDER BY op_seq ASC
+LIMIT 1`
+	}
+	checkpoint := &workspaceDocumentCheckpoint{}
+	var content []byte
+	if err := store.conn().QueryRowContext(ctx, query, workspaceID, documentID, opSeq).Scan(&checkpoint.OpSeq, &checkpoint.ContentRev, &content); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	checkpoint.Content = json.RawMessage(content)
+	return checkpoint, nil
+}
+
+// listDocumentOperationRange returns the document's operations with
+// afterOpSeq < op_seq <= upToOpSeq in ascending order.
+func (store *WorkspaceStore) listDocumentOperationRange(ctx context.Context, workspaceID string, documentID string, afterOpSeq int64, upToOpSeq int64) ([]historyEntry, error) {
+	const query = `SELECT op_seq, domain, payload_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND document_id = $2 AND op_seq > $3 AND op_seq <= $4
+ORDER BY op_seq ASC`
+	rows, err := store.conn().QueryContext(ctx, query, workspaceID, documentID, afterOpSeq, upToOpSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanHistoryEntries(rows)
+}
+
+type RestoreDocumentParams struct {
+	WorkspaceID        string
+	DocumentID         string
+	AtOpSeq            int64
+	ExpectedContentRev int64
+	Command            WorkspaceCommandEnvelope
+}
+
+// RestoreDocument commits the content a document had at AtOpSeq as a new
+// reversible core.history.restore command. Restores form their own history
+// scope (namespace core.history), so they can be undone like any edit.
+func (store *WorkspaceStore) RestoreDocument(ctx context.Context, params RestoreDocumentParams) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	if params.ExpectedContentRev <= 0 {
+		return nil, errors.New("expectedContentRev must be positive")
+	}
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		lockCtx, cancel := withStoreTimeout(ctx)
+		err := txStore.lockWorkspaceRow(lockCtx, params.WorkspaceID)
+		cancel()
+		if err != nil {
+			return err
+		}
+		past, err := txStore.GetDocumentAtOpSeq(ctx, params.WorkspaceID, params.DocumentID, params.AtOpSeq)
+		if err != nil {
+			return err
+		}
+		current, err := txStore.GetDocument(ctx, params.WorkspaceID, params.DocumentID)
+		if err != nil {
+			return err
+		}
+		forwardOps, err := diffJSONDocuments(current.Content, past.Content)
+		if err != nil {
+			return err
+		}
+		if len(forwardOps) == 0 {
+			return ErrWorkspaceRestoreUnchanged
+		}
+		reverseOps, err := diffJSONDocuments(past.Content, current.Content)
+		if err != nil {
+			return err
+		}
+		command := params.Command
+		command.ForwardOps = forwardOps
+		command.ReverseOps = reverseOps
+		command.Target.DocumentID = params.DocumentID
+		if command.Label == "" {
+			command.Label = fmt.Sprintf("Restore to opSeq %d", params.AtOpSeq)
+		}
+		result, err = txStore.PatchDocumentContent(ctx, PatchDocumentContentParams{
+			WorkspaceID:        params.WorkspaceID,
+			DocumentID:         params.DocumentID,
+			ExpectedContentRev: params.ExpectedContentRev,
+			Command:            command,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// diffJSONDocuments returns patch ops that turn from into to. Objects are
+// compared key by key so the ops stay under the paths that actually changed;
+// arrays and scalars are replaced whole.
+func diffJSONDocuments(from json.RawMessage, to json.RawMessage) ([]WorkspacePatchOp, error) {
+	var fromValue any
+	var toValue any
+	if err := decodeJSONValue(from, &fromValue); err != nil {
+		return nil, err
+	}
+	if err := decodeJSONValue(to, &toValue); err != nil {
+		return nil, err
+	}
+	ops := make([]WorkspacePatchOp, 0)
+	if err := appendJSONDiff(&ops, "", fromValue, toValue); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func appendJSONDiff(ops *[]WorkspacePatchOp, path string, from any, to any) error {
+	fromObject, fromIsObject := from.(map[string]any)
+	toObject, toIsObject := to.(map[string]any)
+	if !fromIsObject || !toIsObject {
+		if jsonDeepEqual(from, to) {
+			return nil
+		}
+		value, err := json.Marshal(to)
+		if err != nil {
+			return err
+		}
+		*ops = append(*ops, WorkspacePatchOp{Op: "replace", Path: path, Value: value})
+		return nil
+	}
+
+	keys := make([]string, 0, len(fromObject)+len(toObject))
+	for key := range fromObject {
+		keys = append(keys, key)
+	}
+	for key := range toObject {
+		if _, ok := fromObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "/" + escapeJSONPointerSegment(key)
+		fromChild, inFrom := fromObject[key]
+		toChild, inTo := toObject[key]
+		switch {
+		case inFrom && !inTo:
+			*ops = append(*ops, WorkspacePatchOp{Op: "remove", Path: childPath})
+		case !inFrom && inTo:
+			value, err := json.Marshal(toChild)
+			if err != nil {
+				return err
+			}
+			*ops = append(*ops, WorkspacePatchOp{Op: "add", Path: childPath, Value: value})
+		default:
+			if err := appendJSONDiff(ops, childPath, fromChild, toChild); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var jsonPointerSegmentEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func escapeJSONPointerSegment(segment string) string {
+	return jsonPointerSegmentEscaper.Replace(segment)
+}
+
+type documentRestoreIntentHandler struct{}
+
+func (documentRestoreIntentHandler) Intents() []IntentDescriptor {
+	return []IntentDescriptor{intentV1(historyNamespace, "restore", RevPartitionContent)}
+}
+
+func (documentRestoreIntentHandler) Handle(
+	ctx context.Context,
+	store *WorkspaceStore,
+	workspaceID string,
+	request ApplyIntentRequest,
+	_ IntentEnvelope,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, *RequestFailure) {
+	var payload struct {
+		DocumentID         string `json:"documentId"`
+		AtOpSeq            *int64 `json:"atOpSeq"`
+		ExpectedContentRev int64  `json:"expectedContentRev"`
+	}
+	if len(request.Intent.Payload) == 0 ||
+		json.Unmarshal(request.Intent.Payload, &payload) != nil ||
+		strings.TrimSpace(payload.DocumentID) == "" ||
+		payload.AtOpSeq == nil || *payload.AtOpSeq < 0 ||
+		payload.ExpectedContentRev <= 0 {
+		return nil, NewRequestFailure(
+			http.StatusUnprocessableEntity,
+			ErrorInvalidPayload,
+			"intent payload.documentId, payload.atOpSeq and payload.expectedContentRev are required.",
+			nil,
+		)
+	}
+	result, err := store.RestoreDocument(ctx, RestoreDocumentParams{
+		WorkspaceID:        workspaceID,
+		DocumentID:         strings.TrimSpace(payload.DocumentID),
+		AtOpSeq:            *payload.AtOpSeq,
+		ExpectedContentRev: payload.ExpectedContentRev,
+		Command:            command,
+	})
+	if err != nil {
+		return nil, MapStoreError(err)
+	}
+	return result, nil
+}
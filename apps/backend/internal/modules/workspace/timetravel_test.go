@@ -0,0 +1,213 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const timeTravelDocumentQuery = `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1 AND id = $2
+FOR SHARE`
+
+const timeTravelRangeQuery = `SELECT op_seq, domain, payload_json
+FROM workspace_operations
+WHERE workspace_id = $1 AND document_id = $2 AND op_seq > $3 AND op_seq <= $4
+ORDER BY op_seq ASC`
+
+func expectTimeTravelBase(mock sqlmock.Sqlmock, source string, contentRev int64, headOpSeq int64, atOpSeq int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(timeTravelDocumentQuery)).
+		WithArgs("ws_1", "code_open_dialog").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_id", "id", "doc_type", "name", "path", "content_rev", "meta_rev", "content_json", "updated_at"}).
+			AddRow("ws_1", "code_open_dialog", "code", "openDialog.ts", "/src/openDialog.ts", contentRev, 2, []byte(`{"language":"ts","source":"`+source+`"}`), time.Date(2026, time.February, 8, 10, 0, 0, 0, time.UTC)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT workspace_rev, route_rev, op_seq FROM workspaces WHERE id = $1`)).
+		WithArgs("ws_1").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_rev", "route_rev", "op_seq"}).AddRow(9, 4, headOpSeq))
+}
+
+// expectTimeTravelCreatedOpSeq expects the lookup of the operation that
+// inserted the document; 0 marks a seeded or legacy row.
+func expectTimeTravelCreatedOpSeq(mock sqlmock.Sqlmock, createdOpSeq int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT created_op_seq FROM workspace_documents WHERE workspace_id = $1 AND id = $2`)).
+		WithArgs("ws_1", "code_open_dialog").
+		WillReturnRows(sqlmock.NewRows([]string{"created_op_seq"}).AddRow(createdOpSeq))
+}
+
+func expectTimeTravelCheckpoints(mock sqlmock.Sqlmock, atOpSeq int64, before *sqlmock.Rows, after *sqlmock.Rows) {
+	columns := []string{"op_seq", "content_rev", "content_json"}
+	if before == nil {
+		before = sqlmock.NewRows(columns)
+	}
+	if after == nil {
+		after = sqlmock.NewRows(columns)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM workspace_document_checkpoints
+WHERE workspace_id = $1 AND document_id = $2 AND op_seq <= $3`)).
+		WithArgs("ws_1", "code_open_dialog", atOpSeq).
+		WillReturnRows(before)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM workspace_document_checkpoints
+WHERE workspace_id = $1 AND document_id = $2 AND op_seq > $3`)).
+		WithArgs("ws_1", "code_open_dialog", atOpSeq).
+		WillReturnRows(after)
+}
+
+func timeTravelOperationJSON(t *testing.T, command WorkspaceCommandEnvelope) []byte {
+	t.Helper()
+	payload, err := json.Marshal(command)
+	if err != nil {
+		t.Fatalf("marshal command: %v", err)
+	}
+	return payload
+}
+
+func TestDiffJSONDocumentsTouchesOnlyChangedPaths(t *testing.T) {
+	ops, err := diffJSONDocuments(
+		json.RawMessage(`{"ui":{"graph":{"a":1,"b":[1,2]}},"x-old":true,"a/b":1}`),
+		json.RawMessage(`{"ui":{"graph":{"a":1,"b":[1,3],"c":"new"}},"a/b":2}`),
+	)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	got := make([]string, 0, len(ops))
+	for _, op := range ops {
+		got = append(got, op.Op+" "+op.Path+" "+string(op.Value))
+	}
+	want := []string{"replace /a~1b 2", "replace /ui/graph/b [1,3]", `add /ui/graph/c "new"`, "remove /x-old "}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected ops: %q", got)
+	}
+	for index := range want {
+		if got[index] != want[index] {
+			t.Fatalf("op %d = %q, want %q", index, got[index], want[index])
+		}
+	}
+}
+
+func TestWorkspaceStoreGetDocumentAtOpSeqRevertsFromCurrentContent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	expectTimeTravelBase(mock, "c", 6, 40, 30)
+	expectTimeTravelCreatedOpSeq(mock, 0)
+	expectTimeTravelCheckpoints(mock, 30, nil, nil)
+	rename := WorkspaceCommandEnvelope{Namespace: "core.workspace", Type: "document.rename", Version: "1.0"}
+	mock.ExpectQuery(regexp.QuoteMeta(timeTravelRangeQuery)).
+		WithArgs("ws_1", "code_open_dialog", int64(30), int64(40)).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "payload_json"}).
+			AddRow(35, "core.code.update@1.0", timeTravelOperationJSON(t, historyTestCommand("core.code", "/source", `"b"`, `"a"`))).
+			AddRow(37, "core.workspace.document.rename@1.0", timeTravelOperationJSON(t, rename)).
+			AddRow(38, "core.code.update@1.0", timeTravelOperationJSON(t, historyTestCommand("core.code", "/source", `"c"`, `"b"`))))
+	mock.ExpectCommit()
+
+	document, err := store.GetDocumentAtOpSeq(context.Background(), "ws_1", "code_open_dialog", 30)
+	if err != nil {
+		t.Fatalf("get document at opSeq: %v", err)
+	}
+	if document.ContentRev != 4 || string(document.Content) != `{"language":"ts","source":"a"}` {
+		t.Fatalf("unexpected document: rev=%d content=%s", document.ContentRev, document.Content)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreGetDocumentAtOpSeqReplaysFromNearestCheckpoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	expectTimeTravelBase(mock, "z", 80, 200, 101)
+	expectTimeTravelCreatedOpSeq(mock, 0)
+	expectTimeTravelCheckpoints(mock, 101,
+		sqlmock.NewRows([]string{"op_seq", "content_rev", "content_json"}).AddRow(100, 50, []byte(`{"language":"ts","source":"x"}`)),
+		nil,
+	)
+	mock.ExpectQuery(regexp.QuoteMeta(timeTravelRangeQuery)).
+		WithArgs("ws_1", "code_open_dialog", int64(100), int64(101)).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "payload_json"}).
+			AddRow(101, "core.code.update@1.0", timeTravelOperationJSON(t, historyTestCommand("core.code", "/source", `"y"`, `"x"`))))
+	mock.ExpectCommit()
+
+	document, err := store.GetDocumentAtOpSeq(context.Background(), "ws_1", "code_open_dialog", 101)
+	if err != nil {
+		t.Fatalf("get document at opSeq: %v", err)
+	}
+	if document.ContentRev != 51 || string(document.Content) != `{"language":"ts","source":"y"}` {
+		t.Fatalf("unexpected document: rev=%d content=%s", document.ContentRev, document.Content)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreGetDocumentAtOpSeqBeforeCreation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	expectTimeTravelBase(mock, "a", 1, 12, 5)
+	expectTimeTravelCreatedOpSeq(mock, 0)
+	expectTimeTravelCheckpoints(mock, 5, nil, nil)
+	create := WorkspaceCommandEnvelope{Namespace: "core.workspace", Type: "code-document.create", Version: "1.0"}
+	mock.ExpectQuery(regexp.QuoteMeta(timeTravelRangeQuery)).
+		WithArgs("ws_1", "code_open_dialog", int64(5), int64(12)).
+		WillReturnRows(sqlmock.NewRows([]string{"op_seq", "domain", "payload_json"}).
+			AddRow(10, "core.workspace.code-document.create@1.0", timeTravelOperationJSON(t, create)))
+	mock.ExpectRollback()
+
+	_, err = store.GetDocumentAtOpSeq(context.Background(), "ws_1", "code_open_dialog", 5)
+	if !errors.Is(err, ErrWorkspaceDocumentNotFound) {
+		t.Fatalf("expected document not found, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceStoreGetDocumentAtOpSeqRejectsFutureOpSeq(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewWorkspaceStore(db)
+	expectTimeTravelBase(mock, "a", 1, 12, 13)
+	mock.ExpectRollback()
+
+	_, err = store.GetDocumentAtOpSeq(context.Background(), "ws_1", "code_open_dialog", 13)
+	if !errors.Is(err, ErrWorkspaceOpSeqOutOfRange) {
+		t.Fatalf("expected out of range, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRestoreIntentRequiresExpectedContentRev(t *testing.T) {
+	request := ApplyIntentRequest{ExpectedWorkspaceRev: 9, Intent: IntentEnvelope{
+		Payload: json.RawMessage(`{"documentId":"code_open_dialog","atOpSeq":3}`),
+	}}
+	_, failure := documentRestoreIntentHandler{}.Handle(context.Background(), nil, "ws_1", request, IntentEnvelope{}, testCommand(historyNamespace, "restore", time.Now()))
+	if failure == nil || failure.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without expectedContentRev, got %+v", failure)
+	}
+}
@@ -52,6 +52,15 @@ func normalizeComparablePath(value string) string {
 	return normalized
 }
 
+// directoryPathSegments splits a directory path; the root yields none.
+func directoryPathSegments(value string) []string {
+	cleaned := path.Clean("/" + strings.TrimLeft(strings.ReplaceAll(strings.TrimSpace(value), "\\", "/"), "/"))
+	if cleaned == "/" {
+		return nil
+	}
+	return strings.Split(strings.Trim(cleaned, "/"), "/")
+}
+
 func workspacePathName(value string) string {
 	name := path.Base(value)
 	if name == "." || name == "/" {
@@ -244,3 +253,142 @@ func (tree workspaceVFSTree) ensureDirectories(segments []string) (string, error
 	}
 	return currentID, nil
 }
+
+func (tree workspaceVFSTree) documentNodeID(documentID string) (string, bool) {
+	for nodeID, node := range tree.TreeByID {
+		if node.Kind == "doc" && node.DocID == documentID {
+			return nodeID, true
+		}
+	}
+	return "", false
+}
+
+// nodePath returns the absolute workspace path of a node, "/" for the root.
+func (tree workspaceVFSTree) nodePath(nodeID string) string {
+	segments := make([]string, 0)
+	for current := nodeID; current != tree.TreeRootID; {
+		node, ok := tree.TreeByID[current]
+		if !ok || node.ParentID == nil || len(segments) > len(tree.TreeByID) {
+			break
+		}
+		segments = append([]string{node.Name}, segments...)
+		current = *node.ParentID
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// placeNode renames nodeID to name under parentID, detaching it from its
+// previous parent. The name must be free among parentID's other children.
+func (tree workspaceVFSTree) placeNode(nodeID string, parentID string, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+		return fmt.Errorf("%w: node name is invalid", ErrWorkspaceVFSInvalid)
+	}
+	node, ok := tree.TreeByID[nodeID]
+	if !ok || nodeID == tree.TreeRootID {
+		return fmt.Errorf("%w: node does not exist", ErrWorkspaceVFSInvalid)
+	}
+	parent, ok := tree.TreeByID[parentID]
+	if !ok || parent.Kind != "dir" {
+		return fmt.Errorf("%w: parent node must be a directory", ErrWorkspaceVFSInvalid)
+	}
+	for ancestor := parentID; ; {
+		if ancestor == nodeID {
+			return fmt.Errorf("%w: a directory cannot be moved into itself", ErrWorkspaceVFSInvalid)
+		}
+		current := tree.TreeByID[ancestor]
+		if current.ParentID == nil {
+			break
+		}
+		ancestor = *current.ParentID
+	}
+	for _, childID := range parent.Children {
+		if childID != nodeID && tree.TreeByID[childID].Name == name {
+			return fmt.Errorf("%w: workspace path already exists", ErrWorkspaceVFSInvalid)
+		}
+	}
+	if node.ParentID != nil && *node.ParentID != parentID {
+		tree.detachNode(nodeID)
+		parent = tree.TreeByID[parentID]
+		parent.Children = append(parent.Children, nodeID)
+		tree.TreeByID[parentID] = parent
+		node.ParentID = makeTreeString(parentID)
+	}
+	node.Name = name
+	tree.TreeByID[nodeID] = node
+	return nil
+}
+
+func (tree workspaceVFSTree) detachNode(nodeID string) {
+	node := tree.TreeByID[nodeID]
+	if node.ParentID == nil {
+		return
+	}
+	parent := tree.TreeByID[*node.ParentID]
+	children := make([]string, 0, len(parent.Children))
+	for _, childID := range parent.Children {
+		if childID != nodeID {
+			children = append(children, childID)
+		}
+	}
+	parent.Children = children
+	tree.TreeByID[*node.ParentID] = parent
+}
+
+// removeNode deletes nodeID and everything below it, returning the ids of
+// the documents that were mounted there.
+func (tree workspaceVFSTree) removeNode(nodeID string) []string {
+	tree.detachNode(nodeID)
+	documentIDs := make([]string, 0)
+	pending := []string{nodeID}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		node, ok := tree.TreeByID[current]
+		if !ok {
+			continue
+		}
+		if node.Kind == "doc" && node.DocID != "" {
+			documentIDs = append(documentIDs, node.DocID)
+		}
+		pending = append(pending, node.Children...)
+		delete(tree.TreeByID, current)
+	}
+	sort.Strings(documentIDs)
+	return documentIDs
+}
+
+// syncDocumentPaths rewrites the path of every mounted document in state
+// to match its position in the tree.
+func (tree workspaceVFSTree) syncDocumentPaths(documents map[string]workspaceStateDocument) {
+	for nodeID, node := range tree.TreeByID {
+		if node.Kind != "doc" {
+			continue
+		}
+		document, ok := documents[node.DocID]
+		if !ok {
+			continue
+		}
+		document.Path = tree.nodePath(nodeID)
+		documents[node.DocID] = document
+	}
+}
+
+// lookupPath returns the node mounted at an absolute workspace path.
+func (tree workspaceVFSTree) lookupPath(value string) (string, bool) {
+	currentID := tree.TreeRootID
+	for _, segment := range directoryPathSegments(value) {
+		nextID := ""
+		for _, childID := range tree.TreeByID[currentID].Children {
+			if tree.TreeByID[childID].Name == segment {
+				nextID = childID
+				break
+			}
+		}
+		if nextID == "" {
+			return "", false
+		}
+		currentID = nextID
+	}
+	return currentID, true
+}
@@ -45,3 +45,48 @@ func TestWorkspaceVFSTreeAddsCodeDocumentWithDirectories(t *testing.T) {
 		t.Fatalf("unexpected document parent: %+v", document)
 	}
 }
+
+func TestWorkspaceVFSTreePlacesAndRemovesNodes(t *testing.T) {
+	tree := defaultWorkspaceVFSTree("root")
+	for _, mount := range []codeDocumentMount{
+		{DocumentID: "code_a", NodeID: "node_a", Path: "/src/a.ts"},
+		{DocumentID: "code_b", NodeID: "node_b", Path: "/src/b.ts"},
+	} {
+		if err := tree.addDocument(mount); err != nil {
+			t.Fatalf("add document: %v", err)
+		}
+	}
+
+	if err := tree.placeNode("node_a", "dir_src", "b.ts"); err == nil {
+		t.Fatalf("expected sibling name collision")
+	}
+	libID, err := tree.ensureDirectories([]string{"lib"})
+	if err != nil {
+		t.Fatalf("ensure directories: %v", err)
+	}
+	if err := tree.placeNode("node_a", libID, "main.ts"); err != nil {
+		t.Fatalf("place node: %v", err)
+	}
+	if got := tree.nodePath("node_a"); got != "/lib/main.ts" {
+		t.Fatalf("unexpected moved path %q", got)
+	}
+	if children := tree.TreeByID["dir_src"].Children; len(children) != 1 || children[0] != "node_b" {
+		t.Fatalf("node was not detached from its old parent: %+v", children)
+	}
+	if err := tree.placeNode("dir_src", "dir_src", "src"); err == nil {
+		t.Fatalf("expected moving a directory into itself to fail")
+	}
+
+	removed := tree.removeNode("dir_src")
+	if len(removed) != 1 || removed[0] != "code_b" {
+		t.Fatalf("unexpected removed documents: %+v", removed)
+	}
+	if _, ok := tree.TreeByID["node_b"]; ok {
+		t.Fatalf("descendant node was not removed")
+	}
+	documents := map[string]workspaceStateDocument{"code_a": {Path: "/src/a.ts"}}
+	tree.syncDocumentPaths(documents)
+	if documents["code_a"].Path != "/lib/main.ts" {
+		t.Fatalf("document path was not synced: %+v", documents["code_a"])
+	}
+}
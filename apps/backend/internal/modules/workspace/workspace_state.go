@@ -0,0 +1,581 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrWorkspaceStateUnchanged = errors.New("workspace command does not change the workspace")
+
+// workspaceState is the JSON view that workspace-level commands patch.
+// Their ops address /tree, /routeManifest, /settings and
+// /documents/<id>/{type,name,path,content}; revisions are derived by the
+// store and never part of the state.
+type workspaceState struct {
+	Tree          json.RawMessage                   `json:"tree"`
+	RouteManifest json.RawMessage                   `json:"routeManifest"`
+	Settings      json.RawMessage                   `json:"settings"`
+	Documents     map[string]workspaceStateDocument `json:"documents"`
+}
+
+type workspaceStateDocument struct {
+	Type    WorkspaceDocumentType `json:"type"`
+	Name    string                `json:"name"`
+	Path    string                `json:"path"`
+	Content json.RawMessage       `json:"content"`
+}
+
+type lockedWorkspaceState struct {
+	workspaceID  string
+	state        workspaceState
+	workspaceRev int64
+	routeRev     int64
+	opSeq        int64
+	documents    map[string]WorkspaceDocumentRecord
+}
+
+type ApplyWorkspaceStateParams struct {
+	WorkspaceID          string
+	ExpectedWorkspaceRev int64
+	Command              WorkspaceCommandEnvelope
+}
+
+func validateWorkspaceStatePatchPath(path string) error {
+	pointer, err := parseJSONPointer(path)
+	if err != nil {
+		return err
+	}
+	if len(pointer) == 0 {
+		return ErrWorkspacePatchPathForbidden
+	}
+	switch pointer[0] {
+	case "tree", "routeManifest", "settings":
+		return nil
+	case "documents":
+		if len(pointer) >= 2 {
+			return nil
+		}
+	}
+	return ErrWorkspacePatchPathForbidden
+}
+
+// lockWorkspaceState locks the workspace row and loads the workspace state
+// without document content. Every writer takes the workspace row lock before,
+// or in the same statement as, any document row lock, so the document
+// metadata read here cannot move and content writers cannot deadlock with
+// it; content is locked and loaded per document by loadWorkspaceStateContent
+// once a command is known to touch it.
+func (store *WorkspaceStore) lockWorkspaceState(ctx context.Context, workspaceID string) (*lockedWorkspaceState, error) {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	const workspaceQuery = `SELECT w.workspace_rev, w.route_rev, w.op_seq, w.tree_json, r.manifest_json, s.settings_json
+FROM workspaces w
+LEFT JOIN workspace_routes r ON r.workspace_id = w.id
+LEFT JOIN workspace_settings s ON s.workspace_id = w.id
+WHERE w.id = $1
+FOR UPDATE OF w`
+
+	locked := &lockedWorkspaceState{workspaceID: workspaceID, documents: make(map[string]WorkspaceDocumentRecord)}
+	var treeBytes []byte
+	var routeBytes []byte
+	var settingsBytes []byte
+	if err := store.conn().QueryRowContext(ctx, workspaceQuery, workspaceID).Scan(
+		&locked.workspaceRev,
+		&locked.routeRev,
+		&locked.opSeq,
+		&treeBytes,
+		&routeBytes,
+		&settingsBytes,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	locked.state.Tree = json.RawMessage(treeBytes)
+	locked.state.RouteManifest = json.RawMessage(routeBytes)
+	if len(routeBytes) == 0 {
+		locked.state.RouteManifest = defaultWorkspaceRouteManifest
+	}
+	locked.state.Settings = json.RawMessage(settingsBytes)
+	if len(settingsBytes) == 0 {
+		locked.state.Settings = defaultWorkspaceSettings
+	}
+
+	const documentQuery = `SELECT workspace_id, id, doc_type, name, path, content_rev, meta_rev, NULL, updated_at
+FROM workspace_documents
+WHERE workspace_id = $1
+ORDER BY id ASC`
+	rows, err := store.conn().QueryContext(ctx, documentQuery, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locked.state.Documents = make(map[string]workspaceStateDocument)
+	for rows.Next() {
+		document, err := scanWorkspaceDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		locked.documents[document.ID] = *document
+		locked.state.Documents[document.ID] = workspaceStateDocument{
+			Type: document.Type,
+			Name: document.Name,
+			Path: document.Path,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return locked, nil
+}
+
+// loadWorkspaceStateContent locks the given documents and loads their
+// content into locked. Documents that are already loaded or do not exist
+// are skipped; rows are locked in id order.
+func (store *WorkspaceStore) loadWorkspaceStateContent(ctx context.Context, locked *lockedWorkspaceState, documentIDs []string) error {
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+
+	sort.Strings(documentIDs)
+	for _, documentID := range documentIDs {
+		current, ok := locked.state.Documents[documentID]
+		if !ok || contentLoaded(current.Content) {
+			continue
+		}
+		document, err := store.getDocument(ctx, locked.workspaceID, documentID, "\nFOR UPDATE")
+		if err != nil {
+			return err
+		}
+		locked.documents[documentID] = *document
+		current.Content = document.Content
+		locked.state.Documents[documentID] = current
+	}
+	return nil
+}
+
+// loadLayoutContent loads every mir-layout document, which route manifest
+// validation reads for outlets.
+func (store *WorkspaceStore) loadLayoutContent(ctx context.Context, locked *lockedWorkspaceState) error {
+	layoutIDs := make([]string, 0)
+	for documentID, document := range locked.state.Documents {
+		if document.Type == WorkspaceDocumentTypeMIRLayout {
+			layoutIDs = append(layoutIDs, documentID)
+		}
+	}
+	return store.loadWorkspaceStateContent(ctx, locked, layoutIDs)
+}
+
+// contentLoaded reports whether a state document carries its content. The
+// locked state leaves content out until it is loaded, and patching such a
+// document round-trips the missing content as JSON null.
+func contentLoaded(content json.RawMessage) bool {
+	return len(content) > 0 && string(content) != "null"
+}
+
+// sameStateContent compares document contents, treating two unloaded
+// contents as equal since neither side was touched.
+func sameStateContent(left json.RawMessage, right json.RawMessage) bool {
+	if !contentLoaded(left) || !contentLoaded(right) {
+		return !contentLoaded(left) && !contentLoaded(right)
+	}
+	return jsonBytesEqual(left, right)
+}
+
+// clone copies the state so documents can be edited without touching the
+// original map.
+func (state workspaceState) clone() workspaceState {
+	next := state
+	next.Documents = make(map[string]workspaceStateDocument, len(state.Documents))
+	for documentID, document := range state.Documents {
+		next.Documents[documentID] = document
+	}
+	return next
+}
+
+// workspaceStateOpTargets returns the documents whose content ops read or
+// write, and whether the ops can change what the route manifest resolves.
+func workspaceStateOpTargets(ops []WorkspacePatchOp) ([]string, bool) {
+	documentIDs := make([]string, 0)
+	routes := false
+	for _, op := range ops {
+		for _, path := range []string{op.Path, op.From} {
+			pointer, err := parseJSONPointer(path)
+			if err != nil || len(pointer) == 0 {
+				continue
+			}
+			switch {
+			case pointer[0] == "routeManifest":
+				routes = true
+			case pointer[0] == "documents" && len(pointer) >= 2:
+				if len(pointer) == 2 || pointer[2] == "type" {
+					routes = true
+					documentIDs = append(documentIDs, pointer[1])
+				} else if pointer[2] == "content" {
+					documentIDs = append(documentIDs, pointer[1])
+				}
+			}
+		}
+	}
+	return documentIDs, routes
+}
+
+// ApplyWorkspaceStateCommand applies a workspace-level command whose ops
+// patch the workspace state. The whole change is one operation log entry
+// that bumps workspaceRev, plus routeRev when the route manifest changes.
+func (store *WorkspaceStore) ApplyWorkspaceStateCommand(ctx context.Context, params ApplyWorkspaceStateParams) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		locked, err := txStore.lockWorkspaceState(ctx, params.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		result, err = txStore.applyLockedWorkspaceState(ctx, params, locked)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// changeWorkspaceState locks the workspace and lets change edit a copy of
+// its state. The difference is committed as the command's forward and
+// reverse ops, so structural intents never have to build ops by hand. The
+// label change returns is used when the command has none.
+func (store *WorkspaceStore) changeWorkspaceState(
+	ctx context.Context,
+	params ApplyWorkspaceStateParams,
+	change func(txStore *WorkspaceStore, locked *lockedWorkspaceState, next *workspaceState) (string, error),
+) (*WorkspaceMutationResult, error) {
+	if store == nil || store.db == nil {
+		return nil, errors.New("workspace store is not initialized")
+	}
+	var result *WorkspaceMutationResult
+	err := store.RunInTx(ctx, func(txStore *WorkspaceStore) error {
+		locked, err := txStore.lockWorkspaceState(ctx, params.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		next := locked.state.clone()
+		label, err := change(txStore, locked, &next)
+		if err != nil {
+			return err
+		}
+		var touched []string
+		for documentID, before := range locked.state.Documents {
+			after, kept := next.Documents[documentID]
+			if !kept || after.Type != before.Type || contentLoaded(after.Content) {
+				touched = append(touched, documentID)
+			}
+		}
+		if err := txStore.loadWorkspaceStateContent(ctx, locked, touched); err != nil {
+			return err
+		}
+		if workspaceRoutesAffected(locked.state, next) {
+			if err := txStore.loadLayoutContent(ctx, locked); err != nil {
+				return err
+			}
+		}
+		for documentID, after := range next.Documents {
+			if !contentLoaded(after.Content) {
+				after.Content = locked.state.Documents[documentID].Content
+				next.Documents[documentID] = after
+			}
+		}
+		currentJSON, err := json.Marshal(locked.state)
+		if err != nil {
+			return err
+		}
+		targetJSON, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+		forwardOps, err := diffJSONDocuments(currentJSON, targetJSON)
+		if err != nil {
+			return err
+		}
+		if len(forwardOps) == 0 {
+			return ErrWorkspaceStateUnchanged
+		}
+		reverseOps, err := diffJSONDocuments(targetJSON, currentJSON)
+		if err != nil {
+			return err
+		}
+		command := params.Command
+		command.ForwardOps = forwardOps
+		command.ReverseOps = reverseOps
+		if command.Label == "" {
+			command.Label = label
+		}
+		result, err = txStore.applyLockedWorkspaceState(ctx, ApplyWorkspaceStateParams{
+			WorkspaceID:          params.WorkspaceID,
+			ExpectedWorkspaceRev: params.ExpectedWorkspaceRev,
+			Command:              command,
+		}, locked)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (store *WorkspaceStore) applyLockedWorkspaceState(ctx context.Context, params ApplyWorkspaceStateParams, locked *lockedWorkspaceState) (*WorkspaceMutationResult, error) {
+	command, err := normalizeWorkspaceCommand(params.Command)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWorkspaceCommand(command, params.WorkspaceID, nil); err != nil {
+		return nil, err
+	}
+	if command.Target.DocumentID != "" {
+		return nil, errors.New("workspace command must not set target.documentId")
+	}
+	if len(command.ForwardOps) == 0 || len(command.ReverseOps) == 0 {
+		return nil, errors.New("command.forwardOps and command.reverseOps are required")
+	}
+	payloadJSON, err := json.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+	if params.ExpectedWorkspaceRev > 0 && locked.workspaceRev != params.ExpectedWorkspaceRev {
+		return nil, &WorkspaceRevisionConflictError{
+			ConflictType:       WorkspaceConflictWorkspace,
+			WorkspaceID:        params.WorkspaceID,
+			ServerWorkspaceRev: locked.workspaceRev,
+			ServerRouteRev:     locked.routeRev,
+			ServerOpSeq:        locked.opSeq,
+		}
+	}
+
+	documentIDs, routes := workspaceStateOpTargets(command.ForwardOps)
+	if err := store.loadWorkspaceStateContent(ctx, locked, documentIDs); err != nil {
+		return nil, err
+	}
+	if routes {
+		if err := store.loadLayoutContent(ctx, locked); err != nil {
+			return nil, err
+		}
+	}
+	currentJSON, err := json.Marshal(locked.state)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := patchWorkspaceJSON(currentJSON, command.ForwardOps, validateWorkspaceStatePatchPath)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := patch.restoredBy(command.ReverseOps)
+	if err != nil {
+		return nil, err
+	}
+	if !restored {
+		return nil, errors.New("command.reverseOps do not restore original workspace")
+	}
+	var next workspaceState
+	if err := json.Unmarshal(patch.Content, &next); err != nil {
+		return nil, err
+	}
+	for documentID, document := range next.Documents {
+		if !contentLoaded(document.Content) {
+			document.Content = nil
+			next.Documents[documentID] = document
+		}
+	}
+	if !routes && workspaceRoutesAffected(locked.state, next) {
+		// A layout edit moved its outlets; route validation needs the
+		// other layouts too.
+		if err := store.loadLayoutContent(ctx, locked); err != nil {
+			return nil, err
+		}
+		for documentID, document := range next.Documents {
+			if !contentLoaded(document.Content) {
+				document.Content = locked.state.Documents[documentID].Content
+				next.Documents[documentID] = document
+			}
+		}
+	}
+	if err := validateWorkspaceState(locked.state, next); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withStoreTimeout(ctx)
+	defer cancel()
+	return store.writeWorkspaceState(ctx, params.WorkspaceID, locked, next, payloadJSON, command)
+}
+
+// validateWorkspaceState checks the parts of next that differ from previous,
+// so documents a command does not touch are never re-validated.
+func validateWorkspaceState(previous workspaceState, state workspaceState) error {
+	for _, raw := range []json.RawMessage{state.Tree, state.RouteManifest, state.Settings} {
+		var value map[string]any
+		if err := json.Unmarshal(raw, &value); err != nil || value == nil {
+			return errors.New("workspace tree, routeManifest and settings must be JSON objects")
+		}
+	}
+	documentPaths := make(map[string]string, len(state.Documents))
+	for documentID, document := range state.Documents {
+		if documentID == "" {
+			return errors.New("document id is required")
+		}
+		comparablePath := normalizeComparablePath(document.Path)
+		if other, taken := documentPaths[comparablePath]; taken {
+			return fmt.Errorf("%w: documents %s and %s share path %s", ErrWorkspaceVFSInvalid, other, documentID, comparablePath)
+		}
+		documentPaths[comparablePath] = documentID
+		before, existed := previous.Documents[documentID]
+		sameContent := existed && before.Type == document.Type && sameStateContent(before.Content, document.Content)
+		if sameContent && before.Name == document.Name && before.Path == document.Path {
+			continue
+		}
+		if !isValidWorkspaceDocumentType(document.Type) {
+			return errors.New("document type is invalid")
+		}
+		if _, err := normalizeWorkspacePath(document.Path); err != nil {
+			return err
+		}
+		if sameContent {
+			continue
+		}
+		if err := validateWorkspaceDocumentContent(document.Type, document.Content); err != nil {
+			return withDocumentTarget(err, documentID)
+		}
+	}
+	if !jsonBytesEqual(previous.Settings, state.Settings) {
+		if err := validateWorkspaceSettings(state.Settings); err != nil {
+			return err
+		}
+	}
+	return checkWorkspaceRouteManifest(previous, state)
+}
+
+// writeWorkspaceState persists the difference between locked and next and
+// logs the command. Documents whose content changes get time-travel
+// checkpoints on both sides of the operation, since it is not logged under
+// their document id.
+func (store *WorkspaceStore) writeWorkspaceState(
+	ctx context.Context,
+	workspaceID string,
+	locked *lockedWorkspaceState,
+	next workspaceState,
+	payloadJSON json.RawMessage,
+	command WorkspaceCommandEnvelope,
+) (*WorkspaceMutationResult, error) {
+	conn := store.conn()
+	routeChanged := !jsonBytesEqual(locked.state.RouteManifest, next.RouteManifest)
+	if routeChanged {
+		const upsertRoute = `INSERT INTO workspace_routes (workspace_id, manifest_json, updated_at)
+VALUES ($1, $2::jsonb, NOW())
+ON CONFLICT (workspace_id) DO UPDATE
+SET manifest_json = EXCLUDED.manifest_json, updated_at = EXCLUDED.updated_at`
+		if _, err := conn.ExecContext(ctx, upsertRoute, workspaceID, string(next.RouteManifest)); err != nil {
+			return nil, err
+		}
+	}
+	var settingsRev int64
+	if !jsonBytesEqual(locked.state.Settings, next.Settings) {
+		if err := conn.QueryRowContext(ctx, upsertWorkspaceSettingsQuery, workspaceID, string(next.Settings)).Scan(&settingsRev); err != nil {
+			return nil, err
+		}
+	}
+
+	routeBump := 0
+	if routeChanged {
+		routeBump = 1
+	}
+	const bumpWorkspace = `UPDATE workspaces
+SET tree_json = $2::jsonb, workspace_rev = workspace_rev + 1, route_rev = route_rev + $3, op_seq = op_seq + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING workspace_rev, route_rev, op_seq`
+	result := &WorkspaceMutationResult{WorkspaceID: workspaceID, SettingsRev: settingsRev}
+	if err := conn.QueryRowContext(ctx, bumpWorkspace, workspaceID, string(next.Tree), routeBump).Scan(&result.WorkspaceRev, &result.RouteRev, &result.OpSeq); err != nil {
+		return nil, err
+	}
+
+	documentIDs := make([]string, 0, len(locked.documents)+len(next.Documents))
+	for documentID := range locked.documents {
+		documentIDs = append(documentIDs, documentID)
+	}
+	for documentID := range next.Documents {
+		if _, ok := locked.documents[documentID]; !ok {
+			documentIDs = append(documentIDs, documentID)
+		}
+	}
+	sort.Strings(documentIDs)
+
+	for _, documentID := range documentIDs {
+		current, existed := locked.documents[documentID]
+		target, kept := next.Documents[documentID]
+		switch {
+		case existed && !kept:
+			const deleteDocument = `DELETE FROM workspace_documents WHERE workspace_id = $1 AND id = $2`
+			if _, err := conn.ExecContext(ctx, deleteDocument, workspaceID, documentID); err != nil {
+				return nil, err
+			}
+			result.RemovedDocuments = append(result.RemovedDocuments, documentID)
+		case !existed && kept:
+			const insertDocument = `INSERT INTO workspace_documents (
+	workspace_id, id, doc_type, name, path, content_rev, meta_rev, content_json, created_op_seq, updated_at
+) VALUES ($1, $2, $3, $4, $5, 1, 1, $6::jsonb, $7, NOW())`
+			if _, err := conn.ExecContext(ctx, insertDocument, workspaceID, documentID, string(target.Type), target.Name, target.Path, string(target.Content), result.OpSeq); err != nil {
+				return nil, err
+			}
+			if err := insertDocumentCheckpoint(ctx, conn, workspaceID, documentID, result.OpSeq, 1, target.Content); err != nil {
+				return nil, err
+			}
+			result.UpdatedDocuments = append(result.UpdatedDocuments, WorkspaceDocumentRevision{ID: documentID, ContentRev: 1, MetaRev: 1})
+		default:
+			contentChanged := !sameStateContent(current.Content, target.Content)
+			metaChanged := current.Type != target.Type || current.Name != target.Name || current.Path != target.Path
+			if !contentChanged && !metaChanged {
+				continue
+			}
+			revision := WorkspaceDocumentRevision{ID: documentID, ContentRev: current.ContentRev, MetaRev: current.MetaRev}
+			if contentChanged {
+				revision.ContentRev++
+			}
+			if metaChanged {
+				revision.MetaRev++
+			}
+			if contentChanged {
+				const updateDocument = `UPDATE workspace_documents
+SET doc_type = $3, name = $4, path = $5, content_json = $6::jsonb, content_rev = $7, meta_rev = $8, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2`
+				if _, err := conn.ExecContext(ctx, updateDocument, workspaceID, documentID, string(target.Type), target.Name, target.Path, string(target.Content), revision.ContentRev, revision.MetaRev); err != nil {
+					return nil, err
+				}
+			} else {
+				const updateDocumentMeta = `UPDATE workspace_documents
+SET doc_type = $3, name = $4, path = $5, meta_rev = $6, updated_at = NOW()
+WHERE workspace_id = $1 AND id = $2`
+				if _, err := conn.ExecContext(ctx, updateDocumentMeta, workspaceID, documentID, string(target.Type), target.Name, target.Path, revision.MetaRev); err != nil {
+					return nil, err
+				}
+			}
+			if contentChanged {
+				if err := insertDocumentCheckpoint(ctx, conn, workspaceID, documentID, result.OpSeq-1, current.ContentRev, current.Content); err != nil {
+					return nil, err
+				}
+				if err := insertDocumentCheckpoint(ctx, conn, workspaceID, documentID, result.OpSeq, revision.ContentRev, target.Content); err != nil {
+					return nil, err
+				}
+			}
+			result.UpdatedDocuments = append(result.UpdatedDocuments, revision)
+		}
+	}
+
+	if err := insertWorkspaceOperation(ctx, conn, workspaceID, result.OpSeq, commandDomain(command), nil, payloadJSON, command.IssuedAt, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
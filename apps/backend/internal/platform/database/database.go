@@ -91,8 +91,11 @@ func RunMigrations(ctx context.Context, db *sql.DB) error {
 		`CREATE TABLE IF NOT EXISTS workspace_settings (
 			workspace_id TEXT PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
 			settings_json JSONB NOT NULL,
-			updated_at TIMESTAMPTZ NOT NULL
+			settings_rev BIGINT NOT NULL DEFAULT 1,
+			updated_at TIMESTAMPTZ NOT NULL,
+			CONSTRAINT workspace_settings_settings_rev_check CHECK (settings_rev >= 1)
 		)`,
+		`ALTER TABLE workspace_settings ADD COLUMN IF NOT EXISTS settings_rev BIGINT NOT NULL DEFAULT 1`,
 		`CREATE TABLE IF NOT EXISTS workspace_documents (
 			workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
 			id TEXT NOT NULL,
@@ -110,6 +113,7 @@ func RunMigrations(ctx context.Context, db *sql.DB) error {
 		)`,
 		`ALTER TABLE workspace_documents DROP CONSTRAINT IF EXISTS workspace_documents_type_check`,
 		`ALTER TABLE workspace_documents ADD CONSTRAINT workspace_documents_type_check CHECK (doc_type IN ('mir-page', 'mir-layout', 'mir-component', 'mir-graph', 'mir-animation', 'code'))`,
+		`ALTER TABLE workspace_documents ADD COLUMN IF NOT EXISTS created_op_seq BIGINT NOT NULL DEFAULT 0`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_workspace_documents_workspace_path ON workspace_documents(workspace_id, path)`,
 		`CREATE INDEX IF NOT EXISTS idx_workspace_documents_workspace_updated_at ON workspace_documents(workspace_id, updated_at DESC)`,
 		`CREATE TABLE IF NOT EXISTS workspace_operations (
@@ -121,7 +125,42 @@ func RunMigrations(ctx context.Context, db *sql.DB) error {
 			created_at TIMESTAMPTZ NOT NULL,
 			PRIMARY KEY (workspace_id, op_seq)
 		)`,
+		`ALTER TABLE workspace_operations ADD COLUMN IF NOT EXISTS result_json JSONB`,
 		`CREATE INDEX IF NOT EXISTS idx_workspace_operations_workspace_created_at ON workspace_operations(workspace_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_workspace_operations_workspace_document_op_seq ON workspace_operations(workspace_id, document_id, op_seq)`,
+		`CREATE TABLE IF NOT EXISTS workspace_idempotency_keys (
+			workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			idempotency_key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			result_json JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (workspace_id, idempotency_key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_workspace_idempotency_keys_created_at ON workspace_idempotency_keys(created_at)`,
+		`CREATE TABLE IF NOT EXISTS workspace_document_checkpoints (
+			workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			document_id TEXT NOT NULL,
+			op_seq BIGINT NOT NULL,
+			content_rev BIGINT NOT NULL,
+			content_json JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (workspace_id, document_id, op_seq)
+		)`,
+		`CREATE TABLE IF NOT EXISTS workspace_checkpoints (
+			id TEXT PRIMARY KEY,
+			workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			op_seq BIGINT NOT NULL,
+			workspace_rev BIGINT NOT NULL,
+			route_rev BIGINT NOT NULL,
+			tree_json JSONB NOT NULL,
+			route_manifest_json JSONB NOT NULL,
+			settings_json JSONB NOT NULL,
+			documents_json JSONB NOT NULL,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (workspace_id, name)
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_projects_owner_updated_at ON projects(owner_id, updated_at DESC)`,
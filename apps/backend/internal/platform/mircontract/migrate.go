@@ -0,0 +1,243 @@
+package mircontract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Migration upgrades a decoded MIR document from one contract version to the
+// next. Apply mutates the document in place; Migrate bumps the version field
+// once Apply succeeds.
+type Migration struct {
+	From        string
+	To          string
+	Name        string
+	Description string
+	Apply       func(document map[string]any) error
+}
+
+// MigrationResult reports which steps ran for a single document. Steps is
+// empty when the document was already on CurrentVersion.
+type MigrationResult struct {
+	FromVersion string   `json:"fromVersion"`
+	ToVersion   string   `json:"toVersion"`
+	Steps       []string `json:"steps"`
+}
+
+func (result MigrationResult) Migrated() bool {
+	return len(result.Steps) > 0
+}
+
+var (
+	ErrVersionMissing     = errors.New("MIR document version is required")
+	ErrVersionUnsupported = errors.New("MIR document version has no migration path to " + CurrentVersion)
+)
+
+// migrations is ordered by From and forms a single chain ending at
+// CurrentVersion.
+var migrations = []Migration{
+	{From: "1.0", To: "1.1", Name: "v1.0-to-v1.1", Description: "rename legacy metadata, debug and event fields", Apply: migrateV10ToV11},
+	{From: "1.1", To: "1.2", Name: "v1.1-to-v1.2", Description: "version bump; v1.2 only adds optional fields", Apply: func(map[string]any) error { return nil }},
+	{From: "1.2", To: "1.3", Name: "v1.2-to-v1.3", Description: "convert the ui.root tree into ui.graph", Apply: migrateV12ToV13},
+}
+
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+func findMigration(from string) (Migration, bool) {
+	for _, migration := range migrations {
+		if migration.From == from {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Migrate upgrades a MIR document to CurrentVersion, running every registered
+// step between its version and the current one. Documents already on
+// CurrentVersion are returned unchanged.
+func Migrate(raw json.RawMessage) (json.RawMessage, MigrationResult, error) {
+	var document map[string]any
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, MigrationResult{}, err
+	}
+	version, ok := document["version"].(string)
+	if !ok || strings.TrimSpace(version) == "" {
+		return nil, MigrationResult{}, ErrVersionMissing
+	}
+	result := MigrationResult{FromVersion: version, ToVersion: version, Steps: []string{}}
+	if version == CurrentVersion {
+		return raw, result, nil
+	}
+	for result.ToVersion != CurrentVersion {
+		migration, ok := findMigration(result.ToVersion)
+		if !ok {
+			return nil, result, fmt.Errorf("%w: %s", ErrVersionUnsupported, result.ToVersion)
+		}
+		if err := migration.Apply(document); err != nil {
+			return nil, result, fmt.Errorf("migrate MIR %s -> %s: %w", migration.From, migration.To, err)
+		}
+		document["version"] = migration.To
+		result.ToVersion = migration.To
+		result.Steps = append(result.Steps, migration.Name)
+	}
+	migrated, err := json.Marshal(document)
+	if err != nil {
+		return nil, result, err
+	}
+	return migrated, result, nil
+}
+
+// legacyEventFields were flattened into v1.0 event bindings; v1.1 only
+// allows trigger, action, params and x-* extensions.
+var legacyEventFields = []string{"debounce", "throttle", "preventDefault", "stopPropagation"}
+
+func migrateV10ToV11(document map[string]any) error {
+	metadata, _ := document["metadata"].(map[string]any)
+	if metadata != nil {
+		if lastModified, ok := metadata["lastModified"]; ok {
+			if _, exists := metadata["updatedAt"]; !exists {
+				metadata["updatedAt"] = lastModified
+			}
+			delete(metadata, "lastModified")
+		}
+	}
+	// v1.1 closes the top level, so debug settings move under metadata.
+	if debug, ok := document["debug"]; ok {
+		if metadata == nil {
+			metadata = map[string]any{}
+			document["metadata"] = metadata
+		}
+		metadata["x-debug"] = debug
+		delete(document, "debug")
+	}
+	ui, _ := document["ui"].(map[string]any)
+	if ui == nil {
+		return nil
+	}
+	if root, ok := ui["root"].(map[string]any); ok {
+		migrateV10Node(root)
+	}
+	return nil
+}
+
+func migrateV10Node(node map[string]any) {
+	if text, ok := node["text"].(map[string]any); ok {
+		if textMap, isMap := text["$map"]; isMap {
+			node["x-textMap"] = textMap
+			delete(node, "text")
+		}
+	}
+	if events, ok := node["events"].(map[string]any); ok {
+		for _, rawEvent := range events {
+			event, ok := rawEvent.(map[string]any)
+			if !ok {
+				continue
+			}
+			if payload, ok := event["payload"]; ok {
+				if _, exists := event["params"]; !exists {
+					event["params"] = payload
+				}
+				delete(event, "payload")
+			}
+			for _, field := range legacyEventFields {
+				if value, ok := event[field]; ok {
+					event["x-"+field] = value
+					delete(event, field)
+				}
+			}
+		}
+	}
+	children, _ := node["children"].([]any)
+	for _, rawChild := range children {
+		if child, ok := rawChild.(map[string]any); ok {
+			migrateV10Node(child)
+		}
+	}
+}
+
+func migrateV12ToV13(document map[string]any) error {
+	ui, ok := document["ui"].(map[string]any)
+	if !ok {
+		return errors.New("ui is required")
+	}
+	if _, hasGraph := ui["graph"]; hasGraph {
+		if _, hasRoot := ui["root"]; hasRoot {
+			return errors.New("ui.root and ui.graph are both present")
+		}
+		return nil
+	}
+	root, ok := ui["root"].(map[string]any)
+	if !ok {
+		return errors.New("ui.root is required")
+	}
+	graph := uiGraphBuilder{
+		nodesByID:    map[string]any{},
+		childIDsByID: map[string]any{},
+	}
+	rootID, err := graph.visit(root, "/ui/root")
+	if err != nil {
+		return err
+	}
+	delete(ui, "root")
+	ui["graph"] = map[string]any{
+		"version":      UIGraphVersion,
+		"rootId":       rootID,
+		"nodesById":    graph.nodesByID,
+		"childIdsById": graph.childIDsByID,
+	}
+	return nil
+}
+
+type uiGraphBuilder struct {
+	nodesByID    map[string]any
+	childIDsByID map[string]any
+}
+
+// visit flattens a node and its subtree. Ids are kept as-is: a tree with a
+// missing or repeated id cannot be converted without rewriting references
+// elsewhere in the document, so it fails the migration instead.
+func (builder uiGraphBuilder) visit(node map[string]any, path string) (string, error) {
+	nodeID, _ := node["id"].(string)
+	nodeID = strings.TrimSpace(nodeID)
+	if nodeID == "" {
+		return "", fmt.Errorf("%s: node id is required", path)
+	}
+	if _, exists := builder.nodesByID[nodeID]; exists {
+		return "", fmt.Errorf("%s: duplicate node id %q", path, nodeID)
+	}
+	data := make(map[string]any, len(node))
+	for key, value := range node {
+		if key != "children" {
+			data[key] = value
+		}
+	}
+	data["id"] = nodeID
+	builder.nodesByID[nodeID] = data
+
+	childIDs := []any{}
+	if rawChildren, exists := node["children"]; exists {
+		children, ok := rawChildren.([]any)
+		if !ok {
+			return "", fmt.Errorf("%s/children: children must be an array", path)
+		}
+		for index, rawChild := range children {
+			childPath := path + "/children/" + strconv.Itoa(index)
+			child, ok := rawChild.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("%s: node must be an object", childPath)
+			}
+			childID, err := builder.visit(child, childPath)
+			if err != nil {
+				return "", err
+			}
+			childIDs = append(childIDs, childID)
+		}
+	}
+	builder.childIDsByID[nodeID] = childIDs
+	return nodeID, nil
+}
@@ -0,0 +1,100 @@
+package mircontract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata/migrate/*.golden.json")
+
+// migrationGolden is the shape of a golden file: the migrated document plus
+// the steps reported for it.
+type migrationGolden struct {
+	Result   MigrationResult `json:"result"`
+	Document json.RawMessage `json:"document"`
+}
+
+func TestMigrateGoldenFiles(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "migrate", "*.input.json"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no migration fixtures found: %v", err)
+	}
+	for _, inputPath := range inputs {
+		name := strings.TrimSuffix(filepath.Base(inputPath), ".input.json")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(inputPath)
+			if err != nil {
+				t.Fatalf("read input: %v", err)
+			}
+			migrated, result, err := Migrate(input)
+			if err != nil {
+				t.Fatalf("migrate: %v", err)
+			}
+			var document bytes.Buffer
+			if err := json.Indent(&document, migrated, "  ", "  "); err != nil {
+				t.Fatalf("indent migrated document: %v", err)
+			}
+			actual, err := json.MarshalIndent(migrationGolden{Result: result, Document: document.Bytes()}, "", "  ")
+			if err != nil {
+				t.Fatalf("encode golden: %v", err)
+			}
+			actual = append(actual, '\n')
+
+			goldenPath := filepath.Join("testdata", "migrate", name+".golden.json")
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, actual, 0o644); err != nil {
+					t.Fatalf("write golden: %v", err)
+				}
+			}
+			expected, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("migration output differs from %s:\n%s", goldenPath, actual)
+			}
+
+			// Migrating the output again must be a no-op.
+			again, rerun, err := Migrate(migrated)
+			if err != nil || rerun.Migrated() || !bytes.Equal(again, migrated) {
+				t.Fatalf("migration is not idempotent: %+v %v", rerun, err)
+			}
+		})
+	}
+}
+
+func TestMigrateRejectsUnconvertibleDocuments(t *testing.T) {
+	cases := map[string]string{
+		"missing version":   `{"ui":{"root":{"id":"root","type":"container"}}}`,
+		"unknown version":   `{"version":"0.9","ui":{"root":{"id":"root","type":"container"}}}`,
+		"duplicate node id": `{"version":"1.2","ui":{"root":{"id":"root","type":"container","children":[{"id":"a","type":"text"},{"id":"a","type":"text"}]}}}`,
+		"missing node id":   `{"version":"1.1","ui":{"root":{"id":"root","type":"container","children":[{"type":"text"}]}}}`,
+	}
+	for name, input := range cases {
+		if _, _, err := Migrate(json.RawMessage(input)); err == nil {
+			t.Fatalf("%s: expected migration error", name)
+		}
+	}
+	_, result, err := Migrate(json.RawMessage(`{"version":"0.9","ui":{}}`))
+	if !errors.Is(err, ErrVersionUnsupported) || result.FromVersion != "0.9" || result.Migrated() {
+		t.Fatalf("unexpected result for unknown version: %+v %v", result, err)
+	}
+}
+
+func TestMigrationsFormChainToCurrentVersion(t *testing.T) {
+	chain := Migrations()
+	if chain[len(chain)-1].To != CurrentVersion {
+		t.Fatalf("last migration must end at %s", CurrentVersion)
+	}
+	for index := 1; index < len(chain); index++ {
+		if chain[index].From != chain[index-1].To {
+			t.Fatalf("migration %s does not follow %s", chain[index].Name, chain[index-1].Name)
+		}
+	}
+}
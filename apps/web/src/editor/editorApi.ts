@@ -85,6 +85,7 @@ export type WorkspaceSnapshot = {
   id: string;
   workspaceRev: number;
   routeRev: number;
+  settingsRev?: number;
   opSeq: number;
   tree: Record<string, unknown>;
   documents: WorkspaceDocumentRecord[];
@@ -103,6 +104,7 @@ export type WorkspaceMutationResponse = {
   workspaceId: string;
   workspaceRev: number;
   routeRev: number;
+  settingsRev?: number;
   opSeq: number;
   updatedDocuments?: WorkspaceMutationDocumentRevision[];
   acceptedMutationId?: string;
@@ -294,6 +296,7 @@ export const editorApi = {
     data: {
       expectedWorkspaceRev: number;
       expectedRouteRev?: number;
+      expectedSettingsRev?: number;
       intent: WorkspaceIntentEnvelope;
       clientMutationId?: string;
     }
@@ -313,6 +316,7 @@ export const editorApi = {
     data: {
       expectedWorkspaceRev: number;
       expectedRouteRev?: number;
+      expectedSettingsRev?: number;
       operations: Array<
         | {
             op: 'patchDocument';
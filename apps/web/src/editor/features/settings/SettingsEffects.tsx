@@ -31,6 +31,7 @@ export const SettingsEffects = () => {
   const workspaceId = useEditorStore((state) => state.workspaceId);
   const workspaceRev = useEditorStore((state) => state.workspaceRev);
   const routeRev = useEditorStore((state) => state.routeRev);
+  const settingsRev = useEditorStore((state) => state.settingsRev);
   const workspaceCapabilitiesLoaded = useEditorStore(
     (state) => state.workspaceCapabilitiesLoaded
   );
@@ -94,6 +95,7 @@ export const SettingsEffects = () => {
     if (!workspaceId) return;
     if (!workspaceCapabilitiesLoaded || !canUpdateWorkspaceSettings) return;
     if (typeof workspaceRev !== 'number' || workspaceRev <= 0) return;
+    if (typeof settingsRev !== 'number' || settingsRev <= 0) return;
     if (serializedSettingsPayload === syncedSettingsPayloadRef.current) return;
 
     let disposed = false;
@@ -103,6 +105,7 @@ export const SettingsEffects = () => {
       void editorApi
         .applyWorkspaceIntent(token, workspaceId, {
           expectedWorkspaceRev: workspaceRev,
+          expectedSettingsRev: settingsRev,
           ...(typeof routeRev === 'number' && routeRev > 0
             ? { expectedRouteRev: routeRev }
             : {}),
@@ -140,6 +143,7 @@ export const SettingsEffects = () => {
     routeRev,
     serializedSettingsPayload,
     settingsPayload,
+    settingsRev,
     isAuthenticated,
     token,
     workspaceCapabilitiesLoaded,
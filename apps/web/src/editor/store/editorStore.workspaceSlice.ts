@@ -22,6 +22,7 @@ export interface WorkspaceSlice {
   workspaceId?: string;
   workspaceRev?: number;
   routeRev?: number;
+  settingsRev?: number;
   opSeq?: number;
   activeDocumentId?: string;
   workspaceDocumentsById: Record<string, WorkspaceDocumentRecord>;
@@ -48,6 +49,7 @@ export const createWorkspaceSlice: StateCreator<
   workspaceId: undefined,
   workspaceRev: undefined,
   routeRev: undefined,
+  settingsRev: undefined,
   opSeq: undefined,
   activeDocumentId: undefined,
   workspaceDocumentsById: {},
@@ -87,6 +89,7 @@ export const createWorkspaceSlice: StateCreator<
         workspaceId: workspace.id,
         workspaceRev: workspace.workspaceRev,
         routeRev: workspace.routeRev,
+        settingsRev: workspace.settingsRev,
         opSeq: workspace.opSeq,
         workspaceDocumentsById: nextDocumentsById,
         treeRootId,
@@ -126,6 +129,7 @@ export const createWorkspaceSlice: StateCreator<
       workspaceId: undefined,
       workspaceRev: undefined,
       routeRev: undefined,
+      settingsRev: undefined,
       opSeq: undefined,
       activeDocumentId: undefined,
       workspaceDocumentsById: {},
@@ -188,6 +192,8 @@ export const createWorkspaceSlice: StateCreator<
       return {
         workspaceRev: mutation.workspaceRev,
         routeRev: mutation.routeRev,
+        // settingsRev is only reported by mutations that change settings.
+        settingsRev: mutation.settingsRev ?? state.settingsRev,
         opSeq: mutation.opSeq,
         workspaceDocumentsById: nextDocumentsById,
       };
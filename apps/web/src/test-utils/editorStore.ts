@@ -32,6 +32,7 @@ export const resetEditorStore = (overrides: Partial<EditorState> = {}) => {
       workspaceId: undefined,
       workspaceRev: undefined,
       routeRev: undefined,
+      settingsRev: undefined,
       opSeq: undefined,
       activeDocumentId: undefined,
       workspaceDocumentsById: {},